@@ -1,7 +1,10 @@
 package main
 
 import (
+	"OpenLinkHub/src/cli"
+	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/controller"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -20,8 +23,17 @@ func waitForExit() {
 	}
 }
 
-// main entry point
+// main entry point. With a command, runs as command line client of a running daemon
 func main() {
+	if cli.IsCommand(os.Args[1:]) {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
+	if err := config.ParseFlags(os.Args[1:]); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+		_, _ = fmt.Fprintln(os.Stderr, "See OpenLinkHub help")
+		os.Exit(2)
+	}
 	go waitForExit()
 	controller.Start()
 }
@@ -0,0 +1,603 @@
+package alerts
+
+// Package: alerts
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/history"
+	"OpenLinkHub/src/logger"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+type Condition struct {
+	DeviceId  string  `json:"deviceId"`  // Empty matches all devices
+	ChannelId string  `json:"channelId"` // Empty matches all channels
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Value     float32 `json:"value"`
+	Duration  int     `json:"duration"` // Seconds condition has to hold before rule fires
+}
+
+type Action struct {
+	Type      string   `json:"type"`
+	DeviceIds []string `json:"deviceIds"` // flash
+	Profile   string   `json:"profile"`   // flash
+	Duration  int      `json:"duration"`  // flash
+	Url       string   `json:"url"`       // webhook
+	Command   string   `json:"command"`   // command
+	Arguments []string `json:"arguments"` // command
+}
+
+type Rule struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Enabled   bool      `json:"enabled"`
+	Condition Condition `json:"condition"`
+	Actions   []Action  `json:"actions"`
+	Cooldown  int       `json:"cooldown"` // Seconds before rule can fire again for the same channel
+	Created   time.Time `json:"created"`
+}
+
+type Event struct {
+	RuleId    string    `json:"ruleId"`
+	Name      string    `json:"name"`
+	DeviceId  string    `json:"deviceId"`
+	ChannelId string    `json:"channelId"`
+	Device    string    `json:"device"`
+	Metric    string    `json:"metric"`
+	Value     float32   `json:"value"`
+	Threshold float32   `json:"threshold"`
+	State     string    `json:"state"`
+	Time      time.Time `json:"time"`
+}
+
+type Alerts struct {
+	Rules  map[string]*Rule `json:"rules"`
+	Events []Event          `json:"events"`
+}
+
+// track is evaluation state of a rule for one device channel
+type track struct {
+	since    time.Time
+	active   bool
+	notified bool
+	fired    time.Time
+}
+
+const (
+	ActionNotification = "notification"
+	ActionFlash        = "flash"
+	ActionWebhook      = "webhook"
+	ActionCommand      = "command"
+
+	StateTriggered = "triggered"
+	StateResolved  = "resolved"
+)
+
+var (
+	location         = ""
+	mutex            sync.Mutex
+	alerts           = Alerts{Rules: map[string]*Rule{}, Events: make([]Event, 0)}
+	tracks           = map[string]map[string]*track{}
+	flashing         = map[string]bool{}
+	maxEvents        = 500
+	evaluateInterval = time.Second
+	actionTimeout    = 10 * time.Second
+	defaultFlash     = 10
+	defaultProfile   = "colorpulse"
+	operators        = map[string]func(a, b float32) bool{
+		">":  func(a, b float32) bool { return a > b },
+		">=": func(a, b float32) bool { return a >= b },
+		"<":  func(a, b float32) bool { return a < b },
+		"<=": func(a, b float32) bool { return a <= b },
+	}
+	metricNames = map[string]bool{
+		history.MetricTemperature: true,
+		history.MetricRpm:         true,
+		history.MetricBattery:     true,
+		history.MetricPower:       true,
+		history.MetricLoad:        true,
+		history.MetricDuty:        true,
+	}
+)
+
+// Init will load alert rules and start evaluation loop
+func Init() {
+	location = config.GetDatabasePath() + "/alerts.json"
+	if common.FileExists(location) {
+		file, err := os.Open(location)
+		if err != nil {
+			logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to load alerts")
+		} else {
+			if err = json.NewDecoder(file).Decode(&alerts); err != nil {
+				logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to decode alerts")
+				alerts = Alerts{}
+			}
+			_ = file.Close()
+		}
+	}
+
+	if alerts.Rules == nil {
+		alerts.Rules = map[string]*Rule{}
+	}
+	if alerts.Events == nil {
+		alerts.Events = make([]Event, 0)
+	}
+
+	go func() {
+		ticker := time.NewTicker(evaluateInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if hasEnabledRules() {
+				evaluate(history.Collect())
+			}
+		}
+	}()
+}
+
+// GetRules will return all alert rules
+func GetRules() []Rule {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make([]Rule, 0, len(alerts.Rules))
+	for _, rule := range alerts.Rules {
+		result = append(result, *rule)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+	return result
+}
+
+// GetEvents will return alert history
+func GetEvents() []Event {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make([]Event, len(alerts.Events))
+	copy(result, alerts.Events)
+	return result
+}
+
+// ClearEvents will remove alert history
+func ClearEvents() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	alerts.Events = make([]Event, 0)
+	save()
+}
+
+// SaveRule will create a new rule when id is empty, or update an existing one.
+// Returns 1 on success, 0 on unknown rule, 2 on invalid condition, 3 on invalid action and 4 when commands are disabled
+func SaveRule(rule Rule) (*Rule, uint8) {
+	if status := validate(&rule); status != 1 {
+		return nil, status
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(rule.Id) == 0 {
+		rule.Id = newRuleId()
+		rule.Created = time.Now()
+	} else {
+		existing, ok := alerts.Rules[rule.Id]
+		if !ok {
+			return nil, 0
+		}
+		rule.Created = existing.Created
+	}
+
+	alerts.Rules[rule.Id] = &rule
+	delete(tracks, rule.Id)
+	save()
+
+	result := rule
+	return &result, 1
+}
+
+// DeleteRule will remove alert rule
+func DeleteRule(ruleId string) uint8 {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, ok := alerts.Rules[ruleId]; !ok {
+		return 0
+	}
+	delete(alerts.Rules, ruleId)
+	delete(tracks, ruleId)
+	save()
+	return 1
+}
+
+// validate will check rule condition and actions
+func validate(rule *Rule) uint8 {
+	if !metricNames[rule.Condition.Metric] {
+		return 2
+	}
+	if _, ok := operators[rule.Condition.Operator]; !ok {
+		return 2
+	}
+	if rule.Condition.Duration < 0 || rule.Cooldown < 0 {
+		return 2
+	}
+
+	if len(rule.Actions) == 0 {
+		return 3
+	}
+
+	for i := range rule.Actions {
+		action := &rule.Actions[i]
+		switch action.Type {
+		case ActionNotification:
+		case ActionFlash:
+			if len(action.DeviceIds) == 0 {
+				return 3
+			}
+			if len(action.Profile) == 0 {
+				action.Profile = defaultProfile
+			}
+			if action.Duration <= 0 {
+				action.Duration = defaultFlash
+			}
+		case ActionWebhook:
+			if !isLocalUrl(action.Url) {
+				return 3
+			}
+		case ActionCommand:
+			if !config.GetConfig().AlertCommands {
+				return 4
+			}
+			if len(action.Command) == 0 {
+				return 3
+			}
+		default:
+			return 3
+		}
+	}
+	return 1
+}
+
+// evaluate will check all enabled rules against current samples and run actions of fired rules
+func evaluate(samples []history.Sample) {
+	mutex.Lock()
+	now := time.Now()
+	fired := make([]Event, 0)
+	actions := make(map[string][]Action)
+	changed := false
+
+	for _, rule := range alerts.Rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		if _, ok := tracks[rule.Id]; !ok {
+			tracks[rule.Id] = make(map[string]*track)
+		}
+
+		condition := rule.Condition
+		compare := operators[condition.Operator]
+		for _, sample := range samples {
+			if sample.Metric != condition.Metric {
+				continue
+			}
+			if len(condition.DeviceId) > 0 && sample.DeviceId != condition.DeviceId {
+				continue
+			}
+			if len(condition.ChannelId) > 0 && sample.ChannelId != condition.ChannelId {
+				continue
+			}
+
+			k := sample.DeviceId + ":" + sample.ChannelId
+			t, ok := tracks[rule.Id][k]
+			if !ok {
+				t = &track{}
+				tracks[rule.Id][k] = t
+			}
+
+			if !compare(sample.Value, condition.Value) {
+				if t.notified {
+					addEvent(newEvent(rule, sample, StateResolved, now))
+					changed = true
+					logger.Log(logger.Fields{"rule": rule.Name, "device": sample.DeviceId, "channelId": sample.ChannelId, "value": sample.Value}).Info("Alert resolved")
+				}
+				t.since = time.Time{}
+				t.active = false
+				t.notified = false
+				continue
+			}
+
+			if t.since.IsZero() {
+				t.since = now
+			}
+
+			if t.active || now.Sub(t.since) < time.Duration(condition.Duration)*time.Second {
+				continue
+			}
+
+			t.active = true
+			if !t.fired.IsZero() && now.Sub(t.fired) < time.Duration(rule.Cooldown)*time.Second {
+				continue
+			}
+			t.fired = now
+			t.notified = true
+			changed = true
+
+			event := newEvent(rule, sample, StateTriggered, now)
+			addEvent(event)
+			fired = append(fired, event)
+			actions[rule.Id] = rule.Actions
+			logger.Log(logger.Fields{"rule": rule.Name, "device": sample.DeviceId, "channelId": sample.ChannelId, "value": sample.Value, "threshold": condition.Value}).Warn("Alert triggered")
+		}
+	}
+
+	if changed {
+		save()
+	}
+	mutex.Unlock()
+
+	for _, event := range fired {
+		for _, action := range actions[event.RuleId] {
+			go run(action, event)
+		}
+	}
+}
+
+// run will execute alert action
+func run(action Action, event Event) {
+	var err error
+	switch action.Type {
+	case ActionNotification:
+		err = notify(event)
+	case ActionFlash:
+		flash(action)
+	case ActionWebhook:
+		err = webhook(action, event)
+	case ActionCommand:
+		err = command(action, event)
+	}
+
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "rule": event.Name, "action": action.Type}).Error("Unable to run alert action")
+	}
+}
+
+// notify will send desktop notification over session D-Bus
+func notify(event Event) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	defer func(conn *dbus.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	obj := conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	call := obj.Call(
+		"org.freedesktop.Notifications.Notify", 0,
+		"OpenLinkHub",
+		uint32(0),
+		"dialog-warning",
+		event.Name,
+		message(event),
+		[]string{},
+		map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(2))},
+		int32(-1),
+	)
+	return call.Err
+}
+
+// flash will temporarily switch LED channels of given devices to alert RGB profile and restore them after
+func flash(action Action) {
+	for _, deviceId := range action.DeviceIds {
+		mutex.Lock()
+		if flashing[deviceId] {
+			mutex.Unlock()
+			continue
+		}
+		flashing[deviceId] = true
+		mutex.Unlock()
+
+		go func(deviceId string) {
+			defer func() {
+				mutex.Lock()
+				delete(flashing, deviceId)
+				mutex.Unlock()
+			}()
+
+			previous := getRgbProfiles(deviceId)
+			if len(previous) == 0 {
+				logger.Log(logger.Fields{"serial": deviceId}).Warn("Device has no LED channels to flash")
+				return
+			}
+
+			for channelId := range previous {
+				devices.CallDeviceMethod(deviceId, "UpdateRgbProfile", channelId, action.Profile)
+			}
+			time.Sleep(time.Duration(action.Duration) * time.Second)
+			for channelId, profile := range previous {
+				devices.CallDeviceMethod(deviceId, "UpdateRgbProfile", channelId, profile)
+			}
+		}(deviceId)
+	}
+}
+
+// webhook will POST alert event as JSON to a local URL
+func webhook(action Action, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, action.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// command will run a command with alert details in environment variables
+func command(action Action, event Event) error {
+	if !config.GetConfig().AlertCommands {
+		return fmt.Errorf("alert commands are disabled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, action.Command, action.Arguments...)
+	cmd.Env = append(os.Environ(),
+		"OLH_ALERT_RULE="+event.Name,
+		"OLH_ALERT_DEVICE="+event.DeviceId,
+		"OLH_ALERT_CHANNEL="+event.ChannelId,
+		"OLH_ALERT_METRIC="+event.Metric,
+		fmt.Sprintf("OLH_ALERT_VALUE=%.2f", event.Value),
+		fmt.Sprintf("OLH_ALERT_THRESHOLD=%.2f", event.Threshold),
+	)
+	return cmd.Run()
+}
+
+// getRgbProfiles will return current RGB profile of all LED channels of a device
+func getRgbProfiles(deviceId string) map[int]string {
+	profiles := make(map[int]string)
+	instance := reflect.Indirect(reflect.ValueOf(devices.GetDevice(deviceId)))
+	if instance.Kind() != reflect.Struct {
+		return profiles
+	}
+
+	channels := instance.FieldByName("Devices")
+	if !channels.IsValid() || channels.Kind() != reflect.Map || channels.Type().Key().Kind() != reflect.Int {
+		return profiles
+	}
+
+	for _, channelKey := range channels.MapKeys() {
+		channel := reflect.Indirect(channels.MapIndex(channelKey))
+		if channel.Kind() != reflect.Struct {
+			continue
+		}
+
+		value := channel.FieldByName("RGB")
+		leds := channel.FieldByName("LedChannels")
+		if !value.IsValid() || value.Kind() != reflect.String || len(value.String()) == 0 {
+			continue
+		}
+		if leds.IsValid() && leds.CanUint() && leds.Uint() == 0 {
+			continue
+		}
+		profiles[int(channelKey.Int())] = value.String()
+	}
+	return profiles
+}
+
+// isLocalUrl will return true if URL is http(s) on a loopback address
+func isLocalUrl(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// message will return human-readable alert message
+func message(event Event) string {
+	device := event.Device
+	if len(device) == 0 {
+		device = event.DeviceId
+	}
+	return fmt.Sprintf("%s %s is %.1f (threshold %.1f)", device, event.Metric, event.Value, event.Threshold)
+}
+
+// newEvent will create alert event for a rule and sample
+func newEvent(rule *Rule, sample history.Sample, state string, now time.Time) Event {
+	return Event{
+		RuleId:    rule.Id,
+		Name:      rule.Name,
+		DeviceId:  sample.DeviceId,
+		ChannelId: sample.ChannelId,
+		Device:    sample.Name,
+		Metric:    sample.Metric,
+		Value:     sample.Value,
+		Threshold: rule.Condition.Value,
+		State:     state,
+		Time:      now,
+	}
+}
+
+// addEvent will append alert event and keep only last maxEvents
+func addEvent(event Event) {
+	alerts.Events = append(alerts.Events, event)
+	if len(alerts.Events) > maxEvents {
+		alerts.Events = alerts.Events[len(alerts.Events)-maxEvents:]
+	}
+}
+
+// hasEnabledRules will return true if at least one rule is enabled
+func hasEnabledRules() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, rule := range alerts.Rules {
+		if rule.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// newRuleId will generate random rule id
+func newRuleId() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// save will save alert rules and history
+func save() {
+	if err := common.SaveJsonData(location, alerts); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to save alerts")
+	}
+}
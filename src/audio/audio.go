@@ -58,7 +58,7 @@ var (
 )
 
 func Init() {
-	location = config.GetConfig().ConfigPath + "/database/audio.json"
+	location = config.GetDatabasePath() + "/audio.json"
 	if !common.FileExists(location) {
 		logger.Log(logger.Fields{"file": location}).Info("Audio file is missing, creating initial one.")
 		data := &Audio{
@@ -16,6 +16,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
@@ -23,13 +24,15 @@ import (
 const (
 	maxUploadSize = 5 * 1024 * 1024 // 5 MB
 	hashFileName  = "_hash.txt"
+	databaseName  = "database" // Database folder in archive, restored to database location wherever it is
 )
 
+// backupExclude are database folders left out of downloadable backup. Telemetry history can grow past maxUploadSize,
+// which would make the backup impossible to restore. Restore does not remove it, so existing history is kept
+var backupExclude = []string{"history"}
+
 // PerformBackup creates a ZIP with SHA-256 integrity hash
 func PerformBackup(w http.ResponseWriter, _ *http.Request) {
-	cfg := config.GetConfig()
-	srcFolder := filepath.Join(cfg.ConfigPath, "database")
-	extraFile := filepath.Join(cfg.ConfigPath, "config.json")
 	backupName := "backup_" + time.Now().Format("2006-01-02-15-04-05") + ".zip"
 
 	tmpFile, err := os.CreateTemp("", backupName)
@@ -51,34 +54,7 @@ func PerformBackup(w http.ResponseWriter, _ *http.Request) {
 		}
 	}(tmpFile)
 
-	archive := zip.NewWriter(tmpFile)
-	hasher := sha256.New()
-
-	// Add database folder
-	if err := hashAndZipFolder(srcFolder, archive, hasher); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Add config.json
-	if err := hashAndZipFile(extraFile, archive, hasher); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Write hash file
-	sum := hex.EncodeToString(hasher.Sum(nil))
-	hf, err := archive.Create(hashFileName)
-	if err != nil {
-		http.Error(w, "Unable to create hash file in archive", http.StatusInternalServerError)
-		return
-	}
-	if _, err := hf.Write([]byte(sum)); err != nil {
-		http.Error(w, "Unable to write hash file", http.StatusInternalServerError)
-		return
-	}
-
-	if err := archive.Close(); err != nil {
+	if err := WriteArchive(tmpFile, backupExclude); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -98,6 +74,39 @@ func PerformBackup(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// WriteArchive writes ZIP of database folder, config.json and given extra files with SHA-256 integrity hash.
+// Excluded folders are relative to database folder. Archive can be restored via PerformRestore
+func WriteArchive(out io.Writer, exclude []string, extraFiles ...string) error {
+	srcFolder := config.GetDatabasePath()
+	configFile, _ := config.GetLocation()
+
+	archive := zip.NewWriter(out)
+	hasher := sha256.New()
+
+	// Add database folder
+	if err := hashAndZipFolder(srcFolder, exclude, archive, hasher); err != nil {
+		return err
+	}
+
+	// Add config.json and extra files
+	for _, file := range append([]string{configFile}, extraFiles...) {
+		if err := hashAndZipFile(file, archive, hasher); err != nil {
+			return err
+		}
+	}
+
+	// Write hash file
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	hf, err := archive.Create(hashFileName)
+	if err != nil {
+		return fmt.Errorf("unable to create hash file in archive: %w", err)
+	}
+	if _, err = hf.Write([]byte(sum)); err != nil {
+		return fmt.Errorf("unable to write hash file: %w", err)
+	}
+	return archive.Close()
+}
+
 // PerformRestore validates and restores a ZIP backup
 func PerformRestore(w http.ResponseWriter, r *http.Request) {
 	path := config.GetConfig().ConfigPath
@@ -159,7 +168,7 @@ func PerformRestore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := unzipFile(tmpZip, path); err != nil {
+	if err := unzipFile(tmpZip, path, config.GetDatabasePath()); err != nil {
 		http.Error(w,
 			fmt.Sprintf("%s - %s", "Restore failed", err.Error()),
 			http.StatusBadRequest,
@@ -174,21 +183,28 @@ func PerformRestore(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// hashAndZipFolder zips folder and feeds data to hash
-func hashAndZipFolder(src string, archive *zip.Writer, hasher io.Writer) error {
+// hashAndZipFolder zips folder, except excluded sub-folders, and feeds data to hash
+func hashAndZipFolder(src string, exclude []string, archive *zip.Writer, hasher io.Writer) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		if info.IsDir() {
+			if rel, e := filepath.Rel(src, path); e == nil && slices.Contains(exclude, rel) {
+				return filepath.SkipDir
+			}
+		}
+
 		header, err := zip.FileInfoHeader(info)
 		if err != nil {
 			return err
 		}
-		header.Name, err = filepath.Rel(filepath.Dir(src), path)
+		rel, err := filepath.Rel(src, path)
 		if err != nil {
 			return err
 		}
+		header.Name = filepath.ToSlash(filepath.Join(databaseName, rel))
 
 		if info.IsDir() {
 			header.Name += "/"
@@ -320,8 +336,8 @@ func verifyZipIntegrity(zipPath string) error {
 	return nil
 }
 
-// unzipFile extracts all files (skipping _hash.txt)
-func unzipFile(src, dest string) error {
+// unzipFile extracts all files (skipping _hash.txt). Database folder is extracted to database location, everything else to home
+func unzipFile(src, home, database string) error {
 	r, err := zip.OpenReader(src)
 	if err != nil {
 		return err
@@ -337,8 +353,12 @@ func unzipFile(src, dest string) error {
 		if f.Name == hashFileName {
 			continue
 		}
-		path := filepath.Join(dest, f.Name)
-		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
+		dest, name := home, f.Name
+		if name == databaseName+"/" || strings.HasPrefix(name, databaseName+"/") {
+			dest, name = database, strings.TrimPrefix(name, databaseName+"/")
+		}
+		path := filepath.Join(dest, name)
+		if path != filepath.Clean(dest) && !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
 			return fmt.Errorf("illegal path: %s", f.Name)
 		}
 
@@ -0,0 +1,376 @@
+package backup
+
+// Package: backup
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/health"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/version"
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type bundleDevice struct {
+	Serial      string `json:"serial"`
+	Product     string `json:"product"`
+	Firmware    string `json:"firmware"`
+	ProductId   string `json:"productId"`
+	ProductType uint16 `json:"productType"`
+	DeviceType  uint32 `json:"deviceType"`
+	Hidden      bool   `json:"hidden"`
+}
+
+type bundleVersion struct {
+	Build     *version.BuildInfo `json:"build"`
+	Version   string             `json:"version"`
+	GoVersion string             `json:"goVersion"`
+	Os        string             `json:"os"`
+	Arch      string             `json:"arch"`
+	Kernel    string             `json:"kernel"`
+	Uid       int                `json:"uid"`
+	Gid       int                `json:"gid"`
+	Created   time.Time          `json:"created"`
+}
+
+const (
+	maxLogSize      = 2 * 1024 * 1024 // Last 2 MB of log
+	redacted        = "[redacted]"
+	udevRulesSystem = "/etc/udev/rules.d/99-openlinkhub.rules"
+	udevRulesFile   = "99-openlinkhub.rules"
+)
+
+// PerformDiagnostics creates a ZIP with configuration, logs, devices and system information for issue reports
+func PerformDiagnostics(w http.ResponseWriter, _ *http.Request) {
+	bundleName := "diagnostics_" + time.Now().Format("2006-01-02-15-04-05") + ".zip"
+
+	tmpFile, err := os.CreateTemp("", bundleName)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err}).Warn("Unable to create diagnostics bundle")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer func(name string) {
+		err := os.Remove(name)
+		if err != nil {
+			logger.Log(logger.Fields{"error": err}).Warn("Unable to remove temp diagnostics bundle")
+		}
+	}(tmpFile.Name())
+	defer func(tmpFile *os.File) {
+		err := tmpFile.Close()
+		if err != nil {
+			logger.Log(logger.Fields{"error": err}).Warn("Unable to close temp diagnostics bundle")
+		}
+	}(tmpFile)
+
+	archive := zip.NewWriter(tmpFile)
+	hasher := sha256.New()
+
+	configData, secrets := getRedactedConfig()
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"config.json", configData},
+		{"stdout.log", getLog(secrets)},
+		{"devices.json", getDevices()},
+		{"health.json", marshal(health.GetHealth())},
+		{"version.json", getVersion()},
+		{"udev.txt", getUdevStatus()},
+		{"hidraw.txt", getHidraw()},
+		{"i2c.txt", getI2c()},
+		{"hwmon.txt", getHwmon()},
+	}
+
+	for _, file := range files {
+		if err = hashAndZipBytes(file.name, file.data, archive, hasher); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Write hash file
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	hf, err := archive.Create(hashFileName)
+	if err != nil {
+		http.Error(w, "Unable to create hash file in archive", http.StatusInternalServerError)
+		return
+	}
+	if _, err := hf.Write([]byte(sum)); err != nil {
+		http.Error(w, "Unable to write hash file", http.StatusInternalServerError)
+		return
+	}
+
+	if err := archive.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename="+bundleName)
+	w.Header().Set("Content-Type", "application/zip")
+
+	_, err = tmpFile.Seek(0, 0)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err}).Error("Unable to send diagnostics bundle")
+		return
+	}
+	_, err = io.Copy(w, tmpFile)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err}).Error("Unable to send diagnostics bundle")
+		return
+	}
+}
+
+// hashAndZipBytes adds in-memory file to ZIP and hash
+func hashAndZipBytes(name string, data []byte, archive *zip.Writer, hasher io.Writer) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	mw := io.MultiWriter(writer, hasher)
+	_, err = mw.Write(data)
+	return err
+}
+
+// getRedactedConfig returns config.json with secret values replaced and list of replaced values
+func getRedactedConfig() ([]byte, []string) {
+	secrets := make([]string, 0)
+	location, _ := config.GetLocation()
+	data, err := os.ReadFile(location)
+	if err != nil {
+		return []byte(err.Error()), secrets
+	}
+
+	var values map[string]interface{}
+	if err = json.Unmarshal(data, &values); err != nil {
+		return []byte("Unable to parse config.json: " + err.Error()), secrets
+	}
+
+	for key, value := range values {
+		if !config.IsSecret(key) {
+			continue
+		}
+		if text, ok := value.(string); ok && len(text) > 0 {
+			secrets = append(secrets, text)
+			values[key] = redacted
+		}
+	}
+	return marshal(values), secrets
+}
+
+// getLog returns the end of log file with secret values replaced
+func getLog(secrets []string) []byte {
+	logFilename := config.GetConfig().LogFile
+	if logFilename == "-" {
+		return []byte("Logging to console, log file is not available")
+	}
+	if logFilename == "" {
+		logFilename = config.GetConfig().ConfigPath + "/stdout.log"
+	}
+
+	f, err := os.Open(logFilename)
+	if err != nil {
+		return []byte(err.Error())
+	}
+	defer func(f *os.File) {
+		err := f.Close()
+		if err != nil {
+			logger.Log(logger.Fields{"error": err}).Warn("Unable to close log file")
+		}
+	}(f)
+
+	if info, err := f.Stat(); err == nil && info.Size() > maxLogSize {
+		if _, err = f.Seek(-maxLogSize, io.SeekEnd); err != nil {
+			return []byte(err.Error())
+		}
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return []byte(err.Error())
+	}
+	for _, secret := range secrets {
+		data = bytes.ReplaceAll(data, []byte(secret), []byte(redacted))
+	}
+	return data
+}
+
+// getDevices returns list of connected devices with firmware
+func getDevices() []byte {
+	list := make([]bundleDevice, 0)
+	for _, device := range devices.GetDevicesEx() {
+		list = append(list, bundleDevice{
+			Serial:      device.Serial,
+			Product:     device.Product,
+			Firmware:    device.Firmware,
+			ProductId:   fmt.Sprintf("%04x", device.ProductId),
+			ProductType: device.ProductType,
+			DeviceType:  device.DeviceType,
+			Hidden:      device.Hidden,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Serial < list[j].Serial
+	})
+	return marshal(list)
+}
+
+// getVersion returns build and runtime information
+func getVersion() []byte {
+	kernel, err := os.ReadFile("/proc/version")
+	if err != nil {
+		kernel = []byte(err.Error())
+	}
+	return marshal(bundleVersion{
+		Build:     version.GetBuildInfo(),
+		Version:   version.Version,
+		GoVersion: runtime.Version(),
+		Os:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Kernel:    strings.TrimSpace(string(kernel)),
+		Uid:       os.Getuid(),
+		Gid:       os.Getgid(),
+		Created:   time.Now(),
+	})
+}
+
+// getUdevStatus returns whether udev rules are installed and match shipped rules
+func getUdevStatus() []byte {
+	var b strings.Builder
+	shipped, shippedErr := os.ReadFile(filepath.Join(config.GetConfig().ConfigPath, udevRulesFile))
+	installed, installedErr := os.ReadFile(udevRulesSystem)
+
+	switch {
+	case installedErr != nil:
+		b.WriteString(fmt.Sprintf("%s: not installed (%v)\n", udevRulesSystem, installedErr))
+	case shippedErr != nil:
+		b.WriteString(fmt.Sprintf("%s: installed, unable to compare with shipped rules (%v)\n", udevRulesSystem, shippedErr))
+	case bytes.Equal(shipped, installed):
+		b.WriteString(fmt.Sprintf("%s: installed, up to date\n", udevRulesSystem))
+	default:
+		b.WriteString(fmt.Sprintf("%s: installed, differs from shipped %s\n", udevRulesSystem, udevRulesFile))
+	}
+
+	matches, _ := filepath.Glob("/etc/udev/rules.d/*corsair*")
+	for _, match := range matches {
+		b.WriteString(fmt.Sprintf("%s: present\n", match))
+	}
+	return []byte(b.String())
+}
+
+// getHidraw returns owner, permissions and access of hidraw devices
+func getHidraw() []byte {
+	var b strings.Builder
+	matches, _ := filepath.Glob("/dev/hidraw*")
+	sort.Strings(matches)
+	for _, match := range matches {
+		b.WriteString(describeNode(match))
+
+		uevent, err := os.ReadFile(filepath.Join("/sys/class/hidraw", filepath.Base(match), "device/uevent"))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(uevent), "\n") {
+			if strings.HasPrefix(line, "HID_ID=") || strings.HasPrefix(line, "HID_NAME=") {
+				b.WriteString("    " + line + "\n")
+			}
+		}
+	}
+	if len(matches) == 0 {
+		b.WriteString("No hidraw devices found\n")
+	}
+	return []byte(b.String())
+}
+
+// getI2c returns i2c adapters and permissions of their device nodes
+func getI2c() []byte {
+	var b strings.Builder
+	adapters, _ := filepath.Glob("/sys/class/i2c-adapter/i2c-*")
+	sort.Strings(adapters)
+	for _, adapter := range adapters {
+		name, _ := os.ReadFile(filepath.Join(adapter, "name"))
+		b.WriteString(fmt.Sprintf("%s: %s\n", filepath.Base(adapter), strings.TrimSpace(string(name))))
+		node := "/dev/" + filepath.Base(adapter)
+		if _, err := os.Stat(node); err == nil {
+			b.WriteString("    " + describeNode(node))
+		}
+	}
+	if len(adapters) == 0 {
+		b.WriteString("No i2c adapters found, i2c-dev module may not be loaded\n")
+	}
+	return []byte(b.String())
+}
+
+// getHwmon returns hwmon devices with their temperature inputs and labels
+func getHwmon() []byte {
+	var b strings.Builder
+	monitors, _ := filepath.Glob("/sys/class/hwmon/hwmon*")
+	sort.Strings(monitors)
+	for _, monitor := range monitors {
+		name, _ := os.ReadFile(filepath.Join(monitor, "name"))
+		b.WriteString(fmt.Sprintf("%s: %s\n", filepath.Base(monitor), strings.TrimSpace(string(name))))
+
+		inputs, _ := filepath.Glob(filepath.Join(monitor, "temp*_input"))
+		sort.Strings(inputs)
+		for _, input := range inputs {
+			value, _ := os.ReadFile(input)
+			label, _ := os.ReadFile(strings.TrimSuffix(input, "_input") + "_label")
+			b.WriteString(fmt.Sprintf("    %s: %s %s\n", filepath.Base(input), strings.TrimSpace(string(value)), strings.TrimSpace(string(label))))
+		}
+	}
+	if len(monitors) == 0 {
+		b.WriteString("No hwmon devices found\n")
+	}
+	return []byte(b.String())
+}
+
+// describeNode returns mode, owner and read / write access of a device node
+func describeNode(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Sprintf("%s: %v\n", path, err)
+	}
+
+	uid, gid := -1, -1
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		uid, gid = int(stat.Uid), int(stat.Gid)
+	}
+
+	access := "rw"
+	if syscall.Access(path, 0x06) != nil { // R_OK | W_OK
+		access = "no access"
+	}
+	return fmt.Sprintf("%s: %s uid=%d gid=%d %s\n", path, info.Mode().String(), uid, gid, access)
+}
+
+// marshal returns indented JSON or error message
+func marshal(value interface{}) []byte {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return []byte(err.Error())
+	}
+	return data
+}
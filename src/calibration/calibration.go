@@ -0,0 +1,379 @@
+package calibration
+
+// Package: calibration
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/temperatures"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Run struct {
+	DeviceId  string                       `json:"deviceId"`
+	ChannelId int                          `json:"channelId"`
+	Status    string                       `json:"status"`
+	Progress  int                          `json:"progress"`
+	Duty      uint8                        `json:"duty"`
+	Started   time.Time                    `json:"started"`
+	Finished  time.Time                    `json:"finished"`
+	Result    *temperatures.FanCalibration `json:"result"`
+	Error     string                       `json:"error"`
+	previous  string
+	cancel    chan struct{}
+}
+
+// interrupted is a running calibration saved to disk, so previous speed profile can be restored after a crash
+type interrupted struct {
+	DeviceId  string `json:"deviceId"`
+	ChannelId int    `json:"channelId"`
+	Previous  string `json:"previous"`
+}
+
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+	StatusFailed    = "failed"
+)
+
+var (
+	location       = ""
+	mutex          sync.Mutex
+	runs           = map[string]*Run{}
+	stopDuties     = []uint8{100, 90, 80, 70, 60, 50, 40, 35, 30, 25, 20, 15, 10, 5, 0}
+	startDuties    = []uint8{5, 10, 15, 20, 25, 30, 35, 40, 50}
+	settleTime     = 10 * time.Second // speed loop interval plus fan spin up / spin down
+	sampleCount    = 3
+	sampleInterval = time.Second
+)
+
+// Init will restore speed profiles of calibrations interrupted in previous run. It runs before devices are loaded,
+// so channels start on their saved speed profiles
+func Init() {
+	location = config.GetDatabasePath() + "/calibration.json"
+	if !common.FileExists(location) {
+		return
+	}
+
+	pending := map[string]interrupted{}
+	file, err := os.Open(location)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to load calibration state")
+		return
+	}
+	if err = json.NewDecoder(file).Decode(&pending); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to decode calibration state")
+	}
+	_ = file.Close()
+
+	for _, run := range pending {
+		restored := 0
+		for _, duty := range append(append([]uint8{}, stopDuties...), startDuties...) {
+			restored += devices.RestoreSpeedProfile(run.DeviceId, run.ChannelId, temperatures.CalibrationProfileName(duty), run.Previous)
+		}
+		if restored > 0 {
+			logger.Log(logger.Fields{"serial": run.DeviceId, "channelId": run.ChannelId, "profile": run.Previous}).Info("Interrupted fan calibration, speed profile is restored")
+		}
+	}
+
+	mutex.Lock()
+	save()
+	mutex.Unlock()
+}
+
+// GetRuns will return all calibration runs
+func GetRuns() []Run {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make([]Run, 0, len(runs))
+	for _, run := range runs {
+		result = append(result, *run)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DeviceId == result[j].DeviceId {
+			return result[i].ChannelId < result[j].ChannelId
+		}
+		return result[i].DeviceId < result[j].DeviceId
+	})
+	return result
+}
+
+// Start will start calibration of given fan channels. Pumps can not be calibrated
+func Start(deviceId string, channelIds []int) uint8 {
+	if config.GetConfig().Manual {
+		return 4
+	}
+
+	if devices.GetDevice(deviceId) == nil {
+		return 0
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, channelId := range channelIds {
+		if run, ok := runs[key(deviceId, channelId)]; ok && run.Status == StatusRunning {
+			return 2
+		}
+
+		pump := getChannelField(deviceId, channelId, "ContainsPump")
+		if !pump.IsValid() || pump.Kind() != reflect.Bool || pump.Bool() {
+			return 3
+		}
+
+		if len(getChannelProfile(deviceId, channelId)) == 0 {
+			return 3
+		}
+	}
+
+	for _, channelId := range channelIds {
+		run := &Run{
+			DeviceId:  deviceId,
+			ChannelId: channelId,
+			Status:    StatusRunning,
+			Started:   time.Now(),
+			previous:  getChannelProfile(deviceId, channelId),
+			cancel:    make(chan struct{}),
+		}
+		runs[key(deviceId, channelId)] = run
+	}
+
+	// Previous profiles are saved before first calibration step is saved by a device
+	save()
+	for _, channelId := range channelIds {
+		go calibrate(runs[key(deviceId, channelId)])
+	}
+	return 1
+}
+
+// Cancel will stop running calibration and restore previous speed profile
+func Cancel(deviceId string, channelId int) uint8 {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	run, ok := runs[key(deviceId, channelId)]
+	if !ok || run.Status != StatusRunning {
+		return 0
+	}
+	close(run.cancel)
+	run.Status = StatusCancelled
+	return 1
+}
+
+// calibrate will step through duty values, wait for RPM to settle and record duty to RPM table
+func calibrate(run *Run) {
+	result := &temperatures.FanCalibration{
+		Points:  make([]temperatures.CalibrationPoint, 0),
+		Created: time.Now(),
+	}
+	total := len(stopDuties) + len(startDuties)
+	step := 0
+
+	// Going down finds the lowest duty fan keeps spinning at
+	stopped := false
+	for _, duty := range stopDuties {
+		rpm, ok := measure(run, duty)
+		if !ok {
+			finish(run, nil)
+			return
+		}
+		step++
+		setProgress(run, step*100/total)
+
+		result.Points = append(result.Points, temperatures.CalibrationPoint{Duty: duty, Rpm: rpm})
+		if rpm > result.MaxRpm {
+			result.MaxRpm = rpm
+		}
+		if rpm > 0 {
+			result.StopDuty = duty
+		} else {
+			stopped = true
+			break
+		}
+	}
+
+	// Going up from standstill finds the duty fan starts at
+	result.StartDuty = result.StopDuty
+	if stopped {
+		result.StartDuty = 100
+		for _, duty := range startDuties {
+			rpm, ok := measure(run, duty)
+			if !ok {
+				finish(run, nil)
+				return
+			}
+			step++
+			setProgress(run, step*100/total)
+
+			if rpm > 0 {
+				result.StartDuty = duty
+				break
+			}
+		}
+	}
+
+	if result.MaxRpm == 0 {
+		mutex.Lock()
+		run.Error = "no RPM reported by a channel"
+		mutex.Unlock()
+		finish(run, nil)
+		return
+	}
+	finish(run, result)
+}
+
+// measure will apply duty and return average RPM after settle time. False is returned on cancel or failure
+func measure(run *Run, duty uint8) (int16, bool) {
+	profile := temperatures.AddCalibrationProfile(duty)
+	results := devices.CallDeviceMethod(run.DeviceId, "UpdateSpeedProfile", run.ChannelId, profile)
+	if len(results) == 0 || results[0].Uint() != 1 {
+		mutex.Lock()
+		run.Error = fmt.Sprintf("unable to apply duty %d", duty)
+		mutex.Unlock()
+		return 0, false
+	}
+
+	mutex.Lock()
+	run.Duty = duty
+	mutex.Unlock()
+
+	if !wait(run, settleTime) {
+		return 0, false
+	}
+
+	total := 0
+	for i := 0; i < sampleCount; i++ {
+		rpm := getChannelField(run.DeviceId, run.ChannelId, "Rpm")
+		if !rpm.IsValid() {
+			mutex.Lock()
+			run.Error = "device is not available"
+			mutex.Unlock()
+			return 0, false
+		}
+		total += int(rpm.Int())
+		if !wait(run, sampleInterval) {
+			return 0, false
+		}
+	}
+	return int16(total / sampleCount), true
+}
+
+// wait will sleep for given duration. False is returned when run is cancelled
+func wait(run *Run, duration time.Duration) bool {
+	select {
+	case <-run.cancel:
+		return false
+	case <-time.After(duration):
+		return true
+	}
+}
+
+// finish will restore previous speed profile and store calibration result
+func finish(run *Run, result *temperatures.FanCalibration) {
+	results := devices.CallDeviceMethod(run.DeviceId, "UpdateSpeedProfile", run.ChannelId, run.previous)
+	if len(results) == 0 || results[0].Uint() != 1 {
+		logger.Log(logger.Fields{"serial": run.DeviceId, "channelId": run.ChannelId, "profile": run.previous}).Warn("Unable to restore speed profile after calibration")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	run.Finished = time.Now()
+	if run.Status == StatusRunning && result == nil {
+		run.Status = StatusFailed
+	}
+	save()
+
+	if run.Status == StatusCancelled {
+		logger.Log(logger.Fields{"serial": run.DeviceId, "channelId": run.ChannelId}).Info("Fan calibration cancelled")
+		return
+	}
+
+	if result == nil {
+		logger.Log(logger.Fields{"serial": run.DeviceId, "channelId": run.ChannelId, "error": run.Error}).Warn("Fan calibration failed")
+		return
+	}
+
+	results = devices.CallDeviceMethod(run.DeviceId, "SetChannelCalibration", run.ChannelId, result)
+	if len(results) == 0 || results[0].Uint() != 1 {
+		run.Status = StatusFailed
+		run.Error = "unable to save calibration"
+		return
+	}
+
+	run.Status = StatusCompleted
+	run.Progress = 100
+	run.Result = result
+	logger.Log(logger.Fields{"serial": run.DeviceId, "channelId": run.ChannelId, "startDuty": result.StartDuty, "stopDuty": result.StopDuty, "maxRpm": result.MaxRpm}).Info("Fan calibration completed")
+}
+
+// save will save running calibrations with their previous speed profiles
+func save() {
+	pending := map[string]interrupted{}
+	for id, run := range runs {
+		if run.Status == StatusRunning {
+			pending[id] = interrupted{DeviceId: run.DeviceId, ChannelId: run.ChannelId, Previous: run.previous}
+		}
+	}
+
+	if err := common.SaveJsonData(location, pending); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to save calibration state")
+	}
+}
+
+// setProgress will update run progress
+func setProgress(run *Run, progress int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	run.Progress = progress
+}
+
+// key will return run key for device channel
+func key(deviceId string, channelId int) string {
+	return fmt.Sprintf("%s-%d", deviceId, channelId)
+}
+
+// getChannelField will return field of a device channel by name
+func getChannelField(deviceId string, channelId int, name string) reflect.Value {
+	instance := reflect.Indirect(reflect.ValueOf(devices.GetDevice(deviceId)))
+	if instance.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+
+	channels := instance.FieldByName("Devices")
+	if !channels.IsValid() || channels.Kind() != reflect.Map || channels.Type().Key().Kind() != reflect.Int {
+		return reflect.Value{}
+	}
+
+	channel := channels.MapIndex(reflect.ValueOf(channelId).Convert(channels.Type().Key()))
+	if !channel.IsValid() {
+		return reflect.Value{}
+	}
+
+	value := reflect.Indirect(channel)
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return value.FieldByName(name)
+}
+
+// getChannelProfile will return current speed profile of a device channel
+func getChannelProfile(deviceId string, channelId int) string {
+	value := getChannelField(deviceId, channelId, "Profile")
+	if !value.IsValid() || value.Kind() != reflect.String {
+		return ""
+	}
+	return value.String()
+}
@@ -0,0 +1,222 @@
+package cli
+
+// Package: cli
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/version"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// options holds global command line flags
+type options struct {
+	json    bool
+	address string
+	ttl     int
+}
+
+// command is a single subcommand handler
+type command func(c *client, opts options, args []string) error
+
+const usage = `Usage: OpenLinkHub [daemon flags]
+       OpenLinkHub <command> [flags]
+
+Without a command the daemon is started. Daemon settings are taken from, lowest precedence first:
+config.json, OPENLINKHUB_* environment variables, daemon flags.
+
+Commands:
+  devices list                                List connected devices
+  device <serial> channels                    List device channels
+  device <serial> rgb set <profile>           Set RGB profile of all device channels
+  device <serial> profile apply <name>        Switch device user profile
+  fan <serial> <channel> speed <pct>          Temporary override of channel speed (20 - 100)
+  backup create [file]                        Download configuration backup
+  backup restore <file>                       Upload and restore configuration backup
+  config validate [file]                      Validate config.json
+  config migrations                           Show schema versions and pending migrations (dry run)
+  reload                                      Apply config.json and profile changes to running daemon
+  status                                      Show daemon health
+  version                                     Show version
+  help                                        Show this help
+
+Flags:
+  --json                 Output JSON instead of table
+  --address <host:port>  Daemon API address, or unix:<path> for Unix socket.
+                         Default: socketPath when present, otherwise listenAddress and listenPort from config.json
+  --ttl <seconds>        Speed override duration. Default: 3600
+`
+
+var commands = map[string]command{
+	"devices": devicesCommand,
+	"device":  deviceCommand,
+	"fan":     fanCommand,
+	"backup":  backupCommand,
+	"status":  statusCommand,
+	"reload":  reloadCommand,
+}
+
+// Run will execute command line arguments and return process exit code
+func Run(args []string) int {
+	opts, args, err := parseFlags(args)
+	if err != nil {
+		return failure(err)
+	}
+
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		fmt.Println("\nDaemon flags:")
+		config.PrintFlags(os.Stdout)
+		return 0
+	case "version", "--version":
+		fmt.Println(version.Version)
+		return 0
+	case "config":
+		return configCommand(opts, args[1:])
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return failure(fmt.Errorf("unknown command %q, see OpenLinkHub help", args[0]))
+	}
+
+	if len(opts.address) == 0 {
+		value, err := config.Load()
+		if err != nil {
+			return failure(fmt.Errorf("unable to read config.json: %w", err))
+		}
+		switch {
+		case len(value.SocketPath) > 0 && isSocket(value.SocketPath):
+			opts.address = unixPrefix + value.SocketPath
+		case value.ListenPort > 0:
+			opts.address = fmt.Sprintf("%s:%d", value.ListenAddress, value.ListenPort)
+		default:
+			return failure(errors.New("API is disabled in config.json (listenPort, socketPath)"))
+		}
+	}
+
+	if err = cmd(newClient(opts.address), opts, args[1:]); err != nil {
+		return failure(err)
+	}
+	return 0
+}
+
+// IsCommand returns true if arguments start with a command instead of daemon flags
+func IsCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "--version":
+		return true
+	}
+	return !strings.HasPrefix(args[0], "-")
+}
+
+// parseFlags will extract global flags from arguments. Flags can be placed anywhere after command
+func parseFlags(args []string) (options, []string, error) {
+	opts := options{ttl: 3600}
+	positional := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "--") {
+			positional = append(positional, args[i])
+			continue
+		}
+
+		switch name {
+		case "json":
+			opts.json = true
+			continue
+		case "help", "version":
+			positional = append(positional, args[i])
+			continue
+		case "address", "ttl":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return opts, nil, fmt.Errorf("flag --%s requires a value", name)
+				}
+				i++
+				value = args[i]
+			}
+		default:
+			return opts, nil, fmt.Errorf("unknown flag %s", args[i])
+		}
+
+		if name == "address" {
+			opts.address = value
+		} else {
+			ttl, err := strconv.Atoi(value)
+			if err != nil {
+				return opts, nil, fmt.Errorf("invalid --ttl value %q", value)
+			}
+			opts.ttl = ttl
+		}
+	}
+	return opts, positional, nil
+}
+
+// isSocket returns true if path is an existing Unix socket
+func isSocket(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// failure will print error and return exit code
+func failure(err error) int {
+	_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+	return 1
+}
+
+// usageError will return error with command syntax
+func usageError(syntax string) error {
+	return fmt.Errorf("usage: OpenLinkHub %s", syntax)
+}
+
+// printJson will print value as indented JSON
+func printJson(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printTable will print rows as aligned table with header
+func printTable(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// printResult will print action result message
+func printResult(opts options, message string) error {
+	if opts.json {
+		return printJson(map[string]string{"message": message})
+	}
+	fmt.Println(message)
+	return nil
+}
+
+// formatTime will format optional time for table output
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
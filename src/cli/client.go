@@ -0,0 +1,144 @@
+package cli
+
+// Package: cli
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const unixPrefix = "unix:"
+
+// client talks to the running daemon over HTTP API
+type client struct {
+	base string
+	http *http.Client
+}
+
+// apiError is the error body of v2 API
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// legacyResponse is the response body of v1 API
+type legacyResponse struct {
+	Code    int             `json:"code"`
+	Status  int             `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// newClient will return client for daemon listening on address. Address with unix: prefix is a Unix socket path
+func newClient(address string) *client {
+	if path, ok := strings.CutPrefix(address, unixPrefix); ok {
+		dialer := &net.Dialer{}
+		return &client{
+			base: "http://localhost",
+			http: &http.Client{
+				Timeout: 60 * time.Second,
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						return dialer.DialContext(ctx, "unix", path)
+					},
+				},
+			},
+		}
+	}
+
+	base := address
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "http://" + base
+	}
+	return &client{
+		base: strings.TrimRight(base, "/"),
+		http: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// send will perform HTTP request and return response body of a successful request
+func (c *client) send(method, path, contentType string, body io.Reader) ([]byte, int, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to reach OpenLinkHub at %s, is the daemon running? (%w)", c.base, err)
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(res.Body)
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, res.StatusCode, err
+	}
+	return data, res.StatusCode, nil
+}
+
+// v2 will call v2 API endpoint and decode response into out
+func (c *client) v2(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	data, status, err := c.send(method, "/api/v2"+path, "application/json", body)
+	if err != nil {
+		return err
+	}
+
+	if status < 200 || status > 299 {
+		apiErr := apiError{}
+		if json.Unmarshal(data, &apiErr) == nil && len(apiErr.Message) > 0 {
+			return errors.New(apiErr.Message)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, http.StatusText(status))
+	}
+
+	if out == nil || status == http.StatusNoContent {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// v1 will call v1 API endpoint and return its response. Response with status 0 is returned as error
+func (c *client) v1(method, path string, in interface{}) (*legacyResponse, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	data, status, err := c.send(method, path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &legacyResponse{}
+	if err = json.Unmarshal(data, res); err != nil {
+		return nil, fmt.Errorf("%s %s: %s", method, path, http.StatusText(status))
+	}
+	if res.Status != 1 {
+		return res, errors.New(res.Message)
+	}
+	return res, nil
+}
@@ -0,0 +1,406 @@
+package cli
+
+// Package: cli
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/migration"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type device struct {
+	Serial   string `json:"serial"`
+	Product  string `json:"product"`
+	Firmware string `json:"firmware"`
+	Hidden   bool   `json:"hidden"`
+}
+
+type channel struct {
+	Id           int     `json:"id"`
+	Name         string  `json:"name"`
+	Label        string  `json:"label"`
+	Temperature  float64 `json:"temperature"`
+	Rpm          int64   `json:"rpm"`
+	SpeedProfile string  `json:"speedProfile"`
+	RgbProfile   string  `json:"rgbProfile"`
+	HasSpeed     bool    `json:"hasSpeed"`
+}
+
+type target struct {
+	DeviceId  string `json:"deviceId"`
+	ChannelId int    `json:"channelId"`
+}
+
+type lease struct {
+	Id      string    `json:"id"`
+	Speed   *uint8    `json:"speed,omitempty"`
+	Ttl     int       `json:"ttl"`
+	Expires time.Time `json:"expires"`
+	Targets []target  `json:"targets"`
+}
+
+type deviceHealth struct {
+	Serial            string     `json:"serial"`
+	Product           string     `json:"product"`
+	Status            string     `json:"status"`
+	Mode              string     `json:"mode"`
+	Critical          bool       `json:"critical"`
+	LastSuccess       *time.Time `json:"lastSuccess,omitempty"`
+	ConsecutiveErrors uint64     `json:"consecutiveErrors"`
+	QueueDepth        int        `json:"queueDepth"`
+	QueueSize         int        `json:"queueSize"`
+}
+
+type health struct {
+	Status     string         `json:"status"`
+	Ready      bool           `json:"ready"`
+	Version    string         `json:"version"`
+	Uptime     int64          `json:"uptime"`
+	Goroutines int            `json:"goroutines"`
+	Missing    []string       `json:"missing"`
+	Devices    []deviceHealth `json:"devices"`
+}
+
+type reloadReport struct {
+	Applied  []string `json:"applied"`
+	Restart  []string `json:"restart"`
+	Problems []string `json:"problems"`
+}
+
+// devicesCommand handles: devices list
+func devicesCommand(c *client, opts options, args []string) error {
+	if len(args) != 1 || args[0] != "list" {
+		return usageError("devices list")
+	}
+
+	var list []device
+	if err := c.v2(http.MethodGet, "/devices", nil, &list); err != nil {
+		return err
+	}
+	if opts.json {
+		return printJson(list)
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, d := range list {
+		rows = append(rows, []string{d.Serial, d.Product, d.Firmware, strconv.FormatBool(d.Hidden)})
+	}
+	return printTable([]string{"SERIAL", "PRODUCT", "FIRMWARE", "HIDDEN"}, rows)
+}
+
+// deviceCommand handles: device <serial> channels|rgb set|profile apply
+func deviceCommand(c *client, opts options, args []string) error {
+	if len(args) < 2 {
+		return usageError("device <serial> channels|rgb set <profile>|profile apply <name>")
+	}
+	serial := url.PathEscape(args[0])
+
+	switch args[1] {
+	case "channels":
+		var list []channel
+		if err := c.v2(http.MethodGet, "/devices/"+serial+"/channels", nil, &list); err != nil {
+			return err
+		}
+		if opts.json {
+			return printJson(list)
+		}
+
+		rows := make([][]string, 0, len(list))
+		for _, ch := range list {
+			rows = append(rows, []string{
+				strconv.Itoa(ch.Id),
+				ch.Name,
+				ch.Label,
+				strconv.FormatFloat(ch.Temperature, 'f', 1, 64),
+				strconv.FormatInt(ch.Rpm, 10),
+				ch.SpeedProfile,
+				ch.RgbProfile,
+			})
+		}
+		return printTable([]string{"ID", "NAME", "LABEL", "TEMP", "RPM", "SPEED", "RGB"}, rows)
+	case "rgb":
+		if len(args) != 4 || args[2] != "set" {
+			return usageError("device <serial> rgb set <profile>")
+		}
+		result := map[string]string{}
+		if err := c.v2(http.MethodPut, "/devices/"+serial+"/rgb", map[string]string{"profile": args[3]}, &result); err != nil {
+			return err
+		}
+		return printResult(opts, result["message"])
+	case "profile":
+		if len(args) != 4 || args[2] != "apply" {
+			return usageError("device <serial> profile apply <name>")
+		}
+		result := map[string]string{}
+		if err := c.v2(http.MethodPut, "/devices/"+serial+"/profile", map[string]string{"name": args[3]}, &result); err != nil {
+			return err
+		}
+		return printResult(opts, result["message"])
+	}
+	return fmt.Errorf("unknown device command %q", args[1])
+}
+
+// fanCommand handles: fan <serial> <channel> speed <pct>. Speed is applied as override lease, so it expires after --ttl
+func fanCommand(c *client, opts options, args []string) error {
+	const syntax = "fan <serial> <channel> speed <pct> [--ttl seconds]"
+	if len(args) != 4 || args[2] != "speed" {
+		return usageError(syntax)
+	}
+
+	channelId, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid channel %q", args[1])
+	}
+	value, err := strconv.Atoi(strings.TrimSuffix(args[3], "%"))
+	if err != nil || value < 0 || value > 100 {
+		return fmt.Errorf("speed must be between 0 and 100, got %q", args[3])
+	}
+
+	res, err := c.v1(http.MethodPut, "/api/speed/overrides/new", map[string]interface{}{
+		"targets":    []target{{DeviceId: args[0], ChannelId: channelId}},
+		"fixedSpeed": value,
+		"ttl":        opts.ttl,
+	})
+	if err != nil {
+		return err
+	}
+
+	l := lease{}
+	if err = json.Unmarshal(res.Data, &l); err != nil {
+		return err
+	}
+	if opts.json {
+		return printJson(l)
+	}
+	fmt.Printf("%s\nLease: %s, expires: %s\n", res.Message, l.Id, formatTime(&l.Expires))
+	return nil
+}
+
+// backupCommand handles: backup create [file] and backup restore <file>
+func backupCommand(c *client, opts options, args []string) error {
+	if len(args) < 1 {
+		return usageError("backup create [file]|restore <file>")
+	}
+
+	switch args[0] {
+	case "create":
+		file := fmt.Sprintf("openlinkhub-backup-%s.zip", time.Now().Format("20060102-150405"))
+		if len(args) > 2 {
+			return usageError("backup create [file]")
+		}
+		if len(args) == 2 {
+			file = args[1]
+		}
+
+		data, status, err := c.send(http.MethodGet, "/api/backup", "", nil)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("backup failed: %s", strings.TrimSpace(string(data)))
+		}
+		if err = os.WriteFile(file, data, 0600); err != nil {
+			return err
+		}
+		return printResult(opts, "Backup saved to "+file)
+	case "restore":
+		if len(args) != 2 {
+			return usageError("backup restore <file>")
+		}
+
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, err := form.CreateFormFile("backupFile", filepath.Base(args[1]))
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(part, f); err != nil {
+			return err
+		}
+		if err = form.Close(); err != nil {
+			return err
+		}
+
+		data, status, err := c.send(http.MethodPost, "/api/restore", form.FormDataContentType(), body)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("restore failed: %s", strings.TrimSpace(string(data)))
+		}
+		return printResult(opts, strings.TrimSpace(string(data)))
+	}
+	return fmt.Errorf("unknown backup command %q", args[0])
+}
+
+// statusCommand handles: status. Daemon responds with 503 when not ready, which is still a valid status
+func statusCommand(c *client, opts options, args []string) error {
+	if len(args) != 0 {
+		return usageError("status")
+	}
+
+	data, status, err := c.send(http.MethodGet, "/api/v2/health", "", nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusServiceUnavailable {
+		return fmt.Errorf("status failed: %s", http.StatusText(status))
+	}
+
+	value := health{}
+	if err = json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if opts.json {
+		return printJson(value)
+	}
+
+	fmt.Printf("Status: %s, ready: %t, version: %s, uptime: %s, goroutines: %d\n",
+		value.Status, value.Ready, value.Version, time.Duration(value.Uptime)*time.Second, value.Goroutines)
+	if len(value.Missing) > 0 {
+		fmt.Printf("Missing critical devices: %s\n", strings.Join(value.Missing, ", "))
+	}
+	fmt.Println()
+
+	rows := make([][]string, 0, len(value.Devices))
+	for _, d := range value.Devices {
+		rows = append(rows, []string{
+			d.Serial,
+			d.Product,
+			d.Status,
+			d.Mode,
+			strconv.FormatBool(d.Critical),
+			fmt.Sprintf("%d/%d", d.QueueDepth, d.QueueSize),
+			strconv.FormatUint(d.ConsecutiveErrors, 10),
+			formatTime(d.LastSuccess),
+		})
+	}
+	if err = printTable([]string{"SERIAL", "PRODUCT", "STATUS", "MODE", "CRITICAL", "QUEUE", "ERRORS", "LAST SUCCESS"}, rows); err != nil {
+		return err
+	}
+	if !value.Ready {
+		return errors.New("daemon is not ready")
+	}
+	return nil
+}
+
+// reloadCommand handles: reload. Daemon responds with 422 and the report when any file is invalid
+func reloadCommand(c *client, opts options, args []string) error {
+	if len(args) != 0 {
+		return usageError("reload")
+	}
+
+	res, err := c.v1(http.MethodPost, "/api/reload", nil)
+	if res == nil {
+		return err
+	}
+
+	value := reloadReport{}
+	if e := json.Unmarshal(res.Data, &value); e != nil {
+		return e
+	}
+	if opts.json {
+		if e := printJson(value); e != nil {
+			return e
+		}
+		return err
+	}
+
+	fmt.Println(res.Message)
+	list := func(title string, values []string) {
+		if len(values) > 0 {
+			fmt.Printf("%s:\n", title)
+			for _, v := range values {
+				fmt.Println("  -", v)
+			}
+		}
+	}
+	list("Applied", value.Applied)
+	list("Restart required", value.Restart)
+	list("Problems", value.Problems)
+	if err != nil {
+		return errors.New("some files were not applied")
+	}
+	return nil
+}
+
+// configCommand handles: config validate [file] and config migrations. Runs locally and does not require running daemon
+func configCommand(opts options, args []string) int {
+	if len(args) == 1 && args[0] == "migrations" {
+		return migrationsCommand(opts)
+	}
+	if len(args) < 1 || args[0] != "validate" || len(args) > 2 {
+		return failure(usageError("config validate [file] | config migrations"))
+	}
+
+	file, _ := config.GetLocation()
+	if len(args) == 2 {
+		file = args[1]
+	}
+
+	problems := config.Validate(file)
+	if opts.json {
+		_ = printJson(map[string]interface{}{"file": file, "valid": len(problems) == 0, "problems": problems})
+	} else if len(problems) == 0 {
+		fmt.Printf("%s: OK\n", file)
+	} else {
+		fmt.Printf("%s: %d problem(s)\n", file, len(problems))
+		for _, problem := range problems {
+			fmt.Println("  -", problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// migrationsCommand will print migrations which would run on next start, nothing is written
+func migrationsCommand(opts options) int {
+	report := migration.Run(true)
+	if opts.json {
+		_ = printJson(report)
+	} else if len(report.Steps) == 0 {
+		fmt.Println("All files are at current schema version")
+	} else {
+		for _, step := range report.Steps {
+			state := "no changes"
+			if len(step.Error) > 0 {
+				state = "error: " + step.Error
+			} else if step.Changed {
+				state = "changes"
+			}
+			fmt.Printf("%s: v%d %s (%s)\n", step.File, step.Version, step.Description, state)
+		}
+	}
+
+	for _, step := range report.Steps {
+		if len(step.Error) > 0 {
+			return 1
+		}
+	}
+	return 0
+}
@@ -19,10 +19,8 @@ import (
 )
 
 var (
-	pwd                   = ""
 	d                     *Device
 	deviceRefreshInterval = 1000
-	rgbProfileUpgrade     = []string{"gradient", "pastelrainbow", "pastelspiralrainbow", "rain"}
 )
 
 type DeviceProfile struct {
@@ -32,8 +30,10 @@ type DeviceProfile struct {
 }
 
 type Device struct {
-	Product         string `json:"product"`
-	Serial          string `json:"serial"`
+	Product         string   `json:"product"`
+	Serial          string   `json:"serial"`
+	Name            string   `json:"name"`
+	Members         []string `json:"members"`
 	DeviceProfile   *DeviceProfile
 	Rgb             *rgb.RGB
 	activeRgb       *rgb.ActiveRGB
@@ -48,11 +48,28 @@ type Device struct {
 }
 
 func Init() *Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-	d = &Device{
+	location = config.GetDatabasePath() + "/clusters.json"
+	loadClusters()
+
+	d = newDevice(defaultName, defaultSerial)
+	clusters[d.Serial] = d
+	for serial, definition := range definitions {
+		if serial == defaultSerial {
+			d.Members = definition.Members
+			continue
+		}
+		clusters[serial] = newDevice(definition.Name, serial)
+		clusters[serial].Members = definition.Members
+	}
+	return d
+}
+
+// newDevice will create a new cluster device with given name and serial
+func newDevice(name, serial string) *Device {
+	d := &Device{
 		Product: "Cluster",
-		Serial:  "cluster",
+		Serial:  serial,
+		Name:    name,
 		RGBModes: []string{
 			"circle",
 			"circleshift",
@@ -82,6 +99,7 @@ func Init() *Device {
 		autoRefreshChan: make(chan struct{}),
 		timer:           &time.Ticker{},
 		Controllers:     make([]*common.ClusterController, 0),
+		Members:         make([]string, 0),
 	}
 	d.loadRgb()
 	d.loadDeviceProfile()
@@ -126,6 +144,7 @@ func Get() *Device {
 func (d *Device) AddDeviceController(controller *common.ClusterController) {
 	d.mutex.Lock()
 	d.Controllers = append(d.Controllers, controller)
+	d.sortControllers()
 	d.mutex.Unlock()
 
 	if len(d.Controllers) == 1 {
@@ -152,6 +171,32 @@ func (d *Device) RemoveDeviceControllerBySerial(serial string) {
 	}
 }
 
+// hasController will return true if controller with given serial is part of the cluster
+func (d *Device) hasController(serial string) bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	for _, c := range d.Controllers {
+		if c.Serial == serial {
+			return true
+		}
+	}
+	return false
+}
+
+// sortControllers will order controllers by cluster member list. Controllers not in the list are kept at the end
+func (d *Device) sortControllers() {
+	position := func(serial string) int {
+		if index := slices.Index(d.Members, serial); index >= 0 {
+			return index
+		}
+		return len(d.Members)
+	}
+	slices.SortStableFunc(d.Controllers, func(a, b *common.ClusterController) int {
+		return position(a.Serial) - position(b.Serial)
+	})
+}
+
 // GetRgbProfiles will return RGB profiles for a target device
 func (d *Device) GetRgbProfiles() interface{} {
 	return d.Rgb
@@ -311,7 +356,7 @@ func (d *Device) SchedulerBrightness(value uint8) uint8 {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -323,7 +368,7 @@ func (d *Device) saveRgbProfile() {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -355,8 +400,6 @@ func (d *Device) loadRgb() {
 		logger.Log(logger.Fields{"location": rgbFilename, "serial": d.Serial}).Warn("Failed to close file handle")
 	}
 
-	d.upgradeRgbProfile(rgbFilename, rgbProfileUpgrade)
-
 	profiles := make(map[string]rgb.Profile, len(d.Rgb.Profiles))
 	for key, value := range d.Rgb.Profiles {
 		if slices.Contains(d.RGBModes, key) {
@@ -366,31 +409,6 @@ func (d *Device) loadRgb() {
 	d.Rgb.Profiles = profiles
 }
 
-// upgradeRgbProfile will upgrade current rgb profile list
-func (d *Device) upgradeRgbProfile(path string, profiles []string) {
-	save := false
-	for _, profile := range profiles {
-		pf := d.GetRgbProfile(profile)
-		if pf == nil {
-			save = true
-			logger.Log(logger.Fields{"profile": profile}).Info("Upgrading RGB profile")
-			template := rgb.GetRgbProfile(profile)
-			if template == nil {
-				d.Rgb.Profiles[profile] = rgb.Profile{}
-			} else {
-				d.Rgb.Profiles[profile] = *template
-			}
-		}
-	}
-
-	if save {
-		if err := common.SaveJsonData(path, d.Rgb); err != nil {
-			logger.Log(logger.Fields{"error": err, "location": path}).Error("Unable to save rgb profile data")
-			return
-		}
-	}
-}
-
 // distributeColors splits the generated buffer across all controllers
 func (d *Device) distributeColors(buff []byte) {
 	d.mutex.RLock()
@@ -645,7 +663,7 @@ func (d *Device) generateRgbEffect(channels int, startTime *time.Time, rgbProfil
 func (d *Device) saveDeviceProfile() {
 	var defaultBrightness = uint8(100)
 
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 	deviceProfile := &DeviceProfile{
 		BrightnessSlider:   &defaultBrightness,
 		OriginalBrightness: 100,
@@ -674,7 +692,7 @@ func (d *Device) saveDeviceProfile() {
 
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfile() {
-	profileLocation := pwd + "/database/profiles/" + d.Serial + ".json"
+	profileLocation := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	pf := &DeviceProfile{}
 
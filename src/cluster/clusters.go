@@ -0,0 +1,323 @@
+package cluster
+
+// Package: cluster
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/logger"
+	"encoding/json"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Definition struct {
+	Name    string   `json:"name"`
+	Serial  string   `json:"serial"`
+	Members []string `json:"members"`
+}
+
+type Info struct {
+	Name        string   `json:"name"`
+	Serial      string   `json:"serial"`
+	RGBProfile  string   `json:"rgbProfile"`
+	Brightness  uint8    `json:"brightness"`
+	Members     []string `json:"members"`
+	Controllers []string `json:"controllers"`
+	LedChannels uint32   `json:"ledChannels"`
+}
+
+var (
+	location      = ""
+	defaultName   = "Default"
+	defaultSerial = "cluster"
+	definitions   = map[string]Definition{}
+	clusters      = map[string]*Device{}
+	controllers   = map[string]*common.ClusterController{}
+	registryMutex sync.Mutex
+)
+
+// loadClusters will load cluster definitions from the database or create the initial file
+func loadClusters() {
+	if !common.FileExists(location) {
+		definitions = map[string]Definition{
+			defaultSerial: {Name: defaultName, Serial: defaultSerial, Members: make([]string, 0)},
+		}
+		if err := common.SaveJsonData(location, definitions); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to save cluster data")
+		}
+		return
+	}
+
+	file, err := os.Open(location)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to load cluster data")
+		return
+	}
+	defer func() {
+		if err = file.Close(); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": location}).Error("Failed to close file handle")
+		}
+	}()
+
+	if err = json.NewDecoder(file).Decode(&definitions); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to decode cluster data")
+		return
+	}
+}
+
+// saveClusters will save cluster definitions to the database
+func saveClusters() uint8 {
+	definitions = make(map[string]Definition, len(clusters))
+	for serial, c := range clusters {
+		definitions[serial] = Definition{Name: c.Name, Serial: serial, Members: c.Members}
+	}
+
+	if err := common.SaveJsonData(location, definitions); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to save cluster data")
+		return 0
+	}
+	return 1
+}
+
+// resolve will return a cluster which given device serial belongs to. Devices without explicit membership go to the default cluster
+func resolve(serial string) *Device {
+	for _, c := range clusters {
+		if slices.Contains(c.Members, serial) {
+			return c
+		}
+	}
+	return d
+}
+
+// rebalance will move active controllers to clusters defined by member lists
+func rebalance() {
+	for serial, controller := range controllers {
+		target := resolve(serial)
+		for _, c := range clusters {
+			if c != target && c.hasController(serial) {
+				c.RemoveDeviceControllerBySerial(serial)
+			}
+		}
+
+		if target.hasController(serial) {
+			target.mutex.Lock()
+			target.sortControllers()
+			target.mutex.Unlock()
+		} else {
+			target.AddDeviceController(controller)
+		}
+	}
+}
+
+// AddDeviceController will add a new Cluster Controller to the cluster it belongs to
+func AddDeviceController(controller *common.ClusterController) {
+	if controller == nil {
+		return
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if d == nil {
+		return
+	}
+
+	// Controller might be re-added, e.g. on profile change
+	for _, c := range clusters {
+		c.RemoveDeviceControllerBySerial(controller.Serial)
+	}
+	controllers[controller.Serial] = controller
+	resolve(controller.Serial).AddDeviceController(controller)
+}
+
+// RemoveDeviceControllerBySerial will remove a controller by its serial from any cluster
+func RemoveDeviceControllerBySerial(serial string) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	delete(controllers, serial)
+	for _, c := range clusters {
+		c.RemoveDeviceControllerBySerial(serial)
+	}
+}
+
+// GetCluster will return cluster by serial
+func GetCluster(serial string) *Device {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if c, ok := clusters[serial]; ok {
+		return c
+	}
+	return nil
+}
+
+// GetNamedClusters will return all clusters except the default one
+func GetNamedClusters() []*Device {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	result := make([]*Device, 0)
+	for serial, c := range clusters {
+		if serial != defaultSerial {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// GetClusters will return overview of all clusters
+func GetClusters() []Info {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	result := make([]Info, 0, len(clusters))
+	for serial, c := range clusters {
+		info := Info{
+			Name:        c.Name,
+			Serial:      serial,
+			Members:     c.Members,
+			Controllers: make([]string, 0),
+		}
+		if c.DeviceProfile != nil {
+			info.RGBProfile = c.DeviceProfile.RGBProfile
+			if c.DeviceProfile.BrightnessSlider != nil {
+				info.Brightness = *c.DeviceProfile.BrightnessSlider
+			}
+		}
+
+		c.mutex.RLock()
+		for _, controller := range c.Controllers {
+			info.Controllers = append(info.Controllers, controller.Serial)
+			info.LedChannels += controller.LedChannels
+		}
+		c.mutex.RUnlock()
+		result = append(result, info)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Serial == defaultSerial {
+			return true
+		}
+		if result[j].Serial == defaultSerial {
+			return false
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// NewCluster will create a new named cluster. Name has to be alphanumeric
+func NewCluster(name string) (*Device, uint8) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	serial := defaultSerial + strings.ToLower(name)
+	if _, ok := clusters[serial]; ok {
+		return nil, 2
+	}
+
+	for _, c := range clusters {
+		if strings.EqualFold(c.Name, name) {
+			return nil, 2
+		}
+	}
+
+	c := newDevice(name, serial)
+	clusters[serial] = c
+	if saveClusters() == 0 {
+		return nil, 0
+	}
+	return c, 1
+}
+
+// UpdateCluster will update cluster name and ordered member list. Members are removed from any other cluster
+func UpdateCluster(serial, name string, members []string) uint8 {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	c, ok := clusters[serial]
+	if !ok {
+		return 0
+	}
+
+	if len(name) > 0 && serial != defaultSerial {
+		for key, value := range clusters {
+			if key != serial && strings.EqualFold(value.Name, name) {
+				return 2
+			}
+		}
+		c.Name = name
+	}
+
+	unique := make([]string, 0, len(members))
+	for _, member := range members {
+		if !slices.Contains(unique, member) {
+			unique = append(unique, member)
+		}
+	}
+
+	for key, value := range clusters {
+		if key == serial {
+			continue
+		}
+		value.Members = slices.DeleteFunc(value.Members, func(member string) bool {
+			return slices.Contains(unique, member)
+		})
+	}
+	c.Members = unique
+
+	rebalance()
+	return saveClusters()
+}
+
+// AddMember will move a device into given cluster. Device is appended at the end of cluster order
+func AddMember(serial, deviceId string) uint8 {
+	registryMutex.Lock()
+	c, ok := clusters[serial]
+	if !ok {
+		registryMutex.Unlock()
+		return 0
+	}
+
+	members := slices.Clone(c.Members)
+	if !slices.Contains(members, deviceId) {
+		members = append(members, deviceId)
+	}
+	registryMutex.Unlock()
+	return UpdateCluster(serial, "", members)
+}
+
+// DeleteCluster will delete a named cluster. Its controllers are moved to the default cluster
+func DeleteCluster(serial string) uint8 {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if serial == defaultSerial {
+		return 2
+	}
+
+	c, ok := clusters[serial]
+	if !ok {
+		return 0
+	}
+
+	delete(clusters, serial)
+	c.Stop()
+	rebalance()
+
+	for _, filename := range []string{config.GetDatabasePath() + "/rgb/" + serial + ".json", config.GetDatabasePath() + "/profiles/" + serial + ".json"} {
+		if common.FileExists(filename) {
+			if err := os.Remove(filename); err != nil {
+				logger.Log(logger.Fields{"error": err, "location": filename}).Warn("Unable to remove cluster file")
+			}
+		}
+	}
+	return saveClusters()
+}
@@ -5,13 +5,13 @@ package common
 // License: GPL-3.0 or later
 
 import (
+	"OpenLinkHub/src/hid"
 	"bytes"
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/sstallion/go-hid"
 	"golang.org/x/image/draw"
 	"image"
 	"image/color"
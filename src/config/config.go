@@ -11,86 +11,105 @@ import (
 	"os/user"
 	"slices"
 	"strings"
+	"sync"
 )
 
 type Configuration struct {
-	Debug                     bool     `json:"debug"`
-	ListenPort                int      `json:"listenPort"`
-	ListenAddress             string   `json:"listenAddress"`
-	CPUSensorChip             string   `json:"cpuSensorChip"`
-	Manual                    bool     `json:"manual"`
-	Frontend                  bool     `json:"frontend"`
-	Metrics                   bool     `json:"metrics"`
-	Memory                    bool     `json:"memory"`
-	MemorySmBus               string   `json:"memorySmBus"`
-	MemoryType                int      `json:"memoryType"`
-	Exclude                   []uint16 `json:"exclude"`
-	MemorySku                 string   `json:"memorySku"`
-	ConfigPath                string   `json:",omitempty"`
-	ResumeDelay               int      `json:"resumeDelay"`
-	LogFile                   string   `json:"logFile"`
-	LogLevel                  string   `json:"logLevel"`
-	EnhancementKits           []byte   `json:"enhancementKits"`
-	TemperatureOffset         int      `json:"temperatureOffset"`
-	AMDGpuIndex               int      `json:"amdGpuIndex"`
-	AMDSmiPath                string   `json:"amdsmiPath"`
-	CheckDevicePermission     bool     `json:"checkDevicePermission"`
-	GraphProfiles             bool     `json:"graphProfiles"`
-	CpuTempFile               string   `json:"cpuTempFile"`
-	RamTempViaHwmon           bool     `json:"ramTempViaHwmon"`
-	NvidiaGpuIndex            []int    `json:"nvidiaGpuIndex"`
-	DefaultNvidiaGPU          int      `json:"defaultNvidiaGPU"`
-	OpenRGBPort               int      `json:"openRGBPort"`
-	EnableOpenRGBTargetServer bool     `json:"enableOpenRGBTargetServer"`
-	EnableGamepad             bool     `json:"enableGamepad"`
-	EnableMotherboard         bool     `json:"enableMotherboard"`
-	MotherboardBiosOnExit     bool     `json:"motherboardBiosOnExit"`
-	MemoryRegisterOverride    []byte   `json:"memoryRegisterOverride"`
+	Debug                     bool              `json:"debug"`
+	ListenPort                int               `json:"listenPort"`
+	ListenAddress             string            `json:"listenAddress"`
+	CPUSensorChip             string            `json:"cpuSensorChip"`
+	Manual                    bool              `json:"manual"`
+	Frontend                  bool              `json:"frontend"`
+	Metrics                   bool              `json:"metrics"`
+	Memory                    bool              `json:"memory"`
+	MemorySmBus               string            `json:"memorySmBus"`
+	MemoryType                int               `json:"memoryType"`
+	Exclude                   []uint16          `json:"exclude"`
+	MemorySku                 string            `json:"memorySku"`
+	ConfigPath                string            `json:",omitempty"`
+	ResumeDelay               int               `json:"resumeDelay"`
+	LogFile                   string            `json:"logFile"`
+	LogLevel                  string            `json:"logLevel"`
+	EnhancementKits           []byte            `json:"enhancementKits"`
+	TemperatureOffset         int               `json:"temperatureOffset"`
+	AMDGpuIndex               int               `json:"amdGpuIndex"`
+	AMDSmiPath                string            `json:"amdsmiPath"`
+	CheckDevicePermission     bool              `json:"checkDevicePermission"`
+	GraphProfiles             bool              `json:"graphProfiles"`
+	CpuTempFile               string            `json:"cpuTempFile"`
+	RamTempViaHwmon           bool              `json:"ramTempViaHwmon"`
+	NvidiaGpuIndex            []int             `json:"nvidiaGpuIndex"`
+	DefaultNvidiaGPU          int               `json:"defaultNvidiaGPU"`
+	OpenRGBPort               int               `json:"openRGBPort"`
+	EnableOpenRGBTargetServer bool              `json:"enableOpenRGBTargetServer"`
+	EnableGamepad             bool              `json:"enableGamepad"`
+	EnableMotherboard         bool              `json:"enableMotherboard"`
+	MotherboardBiosOnExit     bool              `json:"motherboardBiosOnExit"`
+	MemoryRegisterOverride    []byte            `json:"memoryRegisterOverride"`
+	KeyHeatmap                bool              `json:"keyHeatmap"`
+	CriticalCoolantTemp       float64           `json:"criticalCoolantTemp"`
+	CriticalCoolantHysteresis float64           `json:"criticalCoolantHysteresis"`
+	FanHealthDebounce         int               `json:"fanHealthDebounce"`
+	FanFailureProtection      bool              `json:"fanFailureProtection"`
+	History                   bool              `json:"history"`
+	HistoryTiers              []HistoryTier     `json:"historyTiers"`
+	AlertCommands             bool              `json:"alertCommands"`
+	MqttEnabled               bool              `json:"mqttEnabled"`
+	MqttBroker                string            `json:"mqttBroker"`
+	MqttUsername              string            `json:"mqttUsername"`
+	MqttPassword              string            `json:"mqttPassword"`
+	MqttTopic                 string            `json:"mqttTopic"`
+	MqttDiscoveryPrefix       string            `json:"mqttDiscoveryPrefix"`
+	MqttInterval              int               `json:"mqttInterval"`
+	InfluxEnabled             bool              `json:"influxEnabled"`
+	InfluxUrl                 string            `json:"influxUrl"`
+	InfluxOrg                 string            `json:"influxOrg"`
+	InfluxBucket              string            `json:"influxBucket"`
+	InfluxToken               string            `json:"influxToken"`
+	GraphiteEnabled           bool              `json:"graphiteEnabled"`
+	GraphiteAddress           string            `json:"graphiteAddress"`
+	GraphiteProtocol          string            `json:"graphiteProtocol"`
+	GraphitePrefix            string            `json:"graphitePrefix"`
+	ExporterInterval          int               `json:"exporterInterval"`
+	ExporterTags              map[string]string `json:"exporterTags"`
+	ExporterBatchSize         int               `json:"exporterBatchSize"`
+	ExporterBufferSize        int               `json:"exporterBufferSize"`
+	CriticalDevices           []string          `json:"criticalDevices"`
+	DBus                      bool              `json:"dbus"`
+	SocketPath                string            `json:"socketPath"`
+	SocketOwner               string            `json:"socketOwner"`
+	SocketGroup               string            `json:"socketGroup"`
+	SocketMode                string            `json:"socketMode"`
+	ConfigWatcher             bool              `json:"configWatcher"`
+}
+
+// HistoryTier is a downsampling tier of telemetry history
+type HistoryTier struct {
+	Resolution int64 `json:"resolution"` // Seconds per point
+	Retention  int64 `json:"retention"`  // Seconds of history kept
 }
 
 var (
 	location      = ""
+	database      = ""
 	configuration Configuration
-	upgrade       = map[string]any{
-		"memorySku":                 "",
-		"resumeDelay":               15000,
-		"logLevel":                  "info",
-		"logFile":                   "",
-		"enhancementKits":           make([]byte, 0),
-		"temperatureOffset":         0,
-		"amdGpuIndex":               0,
-		"amdsmiPath":                "",
-		"checkDevicePermission":     false,
-		"cpuTempFile":               "",
-		"graphProfiles":             false,
-		"ramTempViaHwmon":           false,
-		"nvidiaGpuIndex":            []int{0},
-		"defaultNvidiaGPU":          0,
-		"openRGBPort":               6743,
-		"enableOpenRGBTargetServer": false,
-		"enableGamepad":             true,
-		"enableMotherboard":         false,
-		"motherboardBiosOnExit":     false,
-		"memoryRegisterOverride":    make([]byte, 0),
-	}
+	mutex         sync.RWMutex // Guards configuration and stored, both are replaced as a whole
+	stored        Configuration
 	systemService = true
 )
 
+// DefaultHistoryTiers will return default history tiers, 1 second for an hour, 1 minute for a week and 15 minutes for a year
+func DefaultHistoryTiers() []HistoryTier {
+	return []HistoryTier{{1, 3600}, {60, 604800}, {900, 31536000}}
+}
+
 // Init will initialize a new config object
 func Init() {
 	setSystemService()
 
 	var configPath = ""
-
-	pwd, _ := os.Getwd()
-	isAtomic := common.FileExists(pwd + "/atomic")
-	if isAtomic {
-		pwd = "/etc/OpenLinkHub"
-		configPath = "/etc/OpenLinkHub"
-	} else {
-		configPath = pwd
-	}
-	location = pwd + "/config.json"
+	location, configPath = GetLocation()
 
 	// Create or upgrade
 	upgradeFile(location)
@@ -99,31 +118,90 @@ func Init() {
 	if err != nil {
 		panic(err.Error())
 	}
-	if err = json.NewDecoder(f).Decode(&configuration); err != nil {
+	value := Configuration{}
+	err = json.NewDecoder(f).Decode(&value)
+	_ = f.Close()
+	if err != nil {
+		panic(err.Error())
+	}
+	file := value
+
+	// Environment and command line overrides, never saved to config.json
+	if err = applyOverrides(&value, sources); err != nil {
 		panic(err.Error())
 	}
-	configuration.ConfigPath = configPath
+	value.ConfigPath = configPath
+
+	mutex.Lock()
+	configuration = value
+	stored = file
+	database = getDatabaseLocation(configPath)
+	mutex.Unlock()
+}
+
+// Load will read config.json with environment overrides, without creating or upgrading it
+func Load() (Configuration, error) {
+	value := Configuration{}
+	file, configPath := GetLocation()
+	f, err := os.Open(file)
+	if err != nil {
+		return value, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	if err = json.NewDecoder(f).Decode(&value); err != nil {
+		return value, err
+	}
+	if err = applyOverrides(&value, nil); err != nil {
+		return value, err
+	}
+	value.ConfigPath = configPath
+	return value, nil
 }
 
 // GetConfig will return structs.Configuration struct
 func GetConfig() Configuration {
+	mutex.RLock()
+	defer mutex.RUnlock()
 	return configuration
 }
 
+// GetDatabasePath will return location of database folder. Every package resolves its database files through it
+func GetDatabasePath() string {
+	mutex.RLock()
+	path := database
+	mutex.RUnlock()
+
+	if len(path) == 0 {
+		_, home := GetLocation()
+		path = getDatabaseLocation(home)
+	}
+	return path
+}
+
 // UpdateSupportedDevices will update the Exclude slice based on the enabled flag for each product ID
 func UpdateSupportedDevices(productIds map[uint16]bool) uint8 {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// Copies returned by GetConfig share the slice, so a new one is built
+	exclude := slices.Clone(configuration.Exclude)
 	for productId, enabled := range productIds {
 		if enabled {
-			if i := slices.Index(configuration.Exclude, productId); i != -1 {
-				configuration.Exclude = append(configuration.Exclude[:i], configuration.Exclude[i+1:]...)
+			if i := slices.Index(exclude, productId); i != -1 {
+				exclude = append(exclude[:i], exclude[i+1:]...)
 			}
 		} else {
-			if !slices.Contains(configuration.Exclude, productId) {
-				configuration.Exclude = append(configuration.Exclude, productId)
+			if !slices.Contains(exclude, productId) {
+				exclude = append(exclude, productId)
 			}
 		}
 	}
-	saveConfigSettings(configuration)
+	configuration.Exclude = exclude
+	stored.Exclude = exclude
+	saveConfigSettings(stored)
 	return 1
 }
 
@@ -132,7 +210,7 @@ func IsSystemService() bool {
 	return systemService
 }
 
-// upgradeFile will create or upgrade config file
+// upgradeFile will create initial config file. Existing file is upgraded by migration package
 func upgradeFile(cfg string) {
 	if !common.FileExists(cfg) {
 		value := &Configuration{
@@ -167,36 +245,43 @@ func upgradeFile(cfg string) {
 			EnableMotherboard:         false,
 			MotherboardBiosOnExit:     false,
 			MemoryRegisterOverride:    make([]byte, 0),
+			KeyHeatmap:                false,
+			CriticalCoolantTemp:       57,
+			CriticalCoolantHysteresis: 5,
+			FanHealthDebounce:         15,
+			FanFailureProtection:      false,
+			History:                   true,
+			HistoryTiers:              DefaultHistoryTiers(),
+			AlertCommands:             false,
+			MqttEnabled:               false,
+			MqttBroker:                "tcp://127.0.0.1:1883",
+			MqttUsername:              "",
+			MqttPassword:              "",
+			MqttTopic:                 "openlinkhub",
+			MqttDiscoveryPrefix:       "homeassistant",
+			MqttInterval:              10,
+			InfluxEnabled:             false,
+			InfluxUrl:                 "http://127.0.0.1:8086",
+			InfluxOrg:                 "",
+			InfluxBucket:              "openlinkhub",
+			InfluxToken:               "",
+			GraphiteEnabled:           false,
+			GraphiteAddress:           "127.0.0.1:2003",
+			GraphiteProtocol:          "tcp",
+			GraphitePrefix:            "openlinkhub",
+			ExporterInterval:          10,
+			ExporterTags:              map[string]string{},
+			ExporterBatchSize:         500,
+			ExporterBufferSize:        10000,
+			CriticalDevices:           []string{},
+			DBus:                      true,
+			SocketPath:                "",
+			SocketOwner:               "",
+			SocketGroup:               "",
+			SocketMode:                "0660",
+			ConfigWatcher:             true,
 		}
 		saveConfigSettings(value)
-	} else {
-		save := false
-		var data map[string]interface{}
-		file, err := os.Open(location)
-		defer func(file *os.File) {
-			err = file.Close()
-			if err != nil {
-				panic(err.Error())
-			}
-		}(file)
-
-		if err != nil {
-			panic(err.Error())
-		}
-		if err = json.NewDecoder(file).Decode(&data); err != nil {
-			panic(err.Error())
-		}
-
-		// Loop thru upgrade value
-		for key, value := range upgrade {
-			if _, ok := data[key]; !ok {
-				data[key] = value
-				save = true
-			}
-		}
-		if save {
-			saveConfigSettings(data)
-		}
 	}
 }
 
@@ -0,0 +1,292 @@
+package config
+
+// Package: config
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// setting maps a command line flag and environment variable to config.json key
+type setting struct {
+	flag  string
+	key   string
+	usage string
+}
+
+// flagValue stores raw value of a config key passed via command line
+type flagValue struct {
+	key     string
+	boolean bool
+}
+
+// Effective is running configuration with origin of each value: file, env or flag
+type Effective struct {
+	ConfigFile string                 `json:"configFile"`
+	Home       string                 `json:"home"`
+	Database   string                 `json:"database"`
+	Values     map[string]interface{} `json:"values"`
+	Sources    map[string]string      `json:"sources"`
+}
+
+const (
+	envPrefix = "OPENLINKHUB_"
+	redacted  = "[redacted]"
+)
+
+var (
+	settings = []setting{
+		{flag: "listen-address", key: "listenAddress", usage: "Address for HTTP server to listen on"},
+		{flag: "listen-port", key: "listenPort", usage: "HTTP server port"},
+		{flag: "socket-path", key: "socketPath", usage: "Unix socket for REST and WebUI, empty to disable"},
+		{flag: "socket-owner", key: "socketOwner", usage: "Unix socket owner, name or uid"},
+		{flag: "socket-group", key: "socketGroup", usage: "Unix socket group, name or gid"},
+		{flag: "socket-mode", key: "socketMode", usage: "Unix socket permissions, e.g. 0660"},
+		{flag: "log-level", key: "logLevel", usage: "Log level: info, warn, error, fatal, silent"},
+		{flag: "log-file", key: "logFile", usage: "Log file location, - for stderr"},
+		{flag: "debug", key: "debug", usage: "Enable debug mode"},
+		{flag: "manual", key: "manual", usage: "Disable temperature monitoring and automatic speed adjustments"},
+		{flag: "frontend", key: "frontend", usage: "Enable WebUI"},
+		{flag: "metrics", key: "metrics", usage: "Enable Prometheus metrics"},
+		{flag: "memory", key: "memory", usage: "Enable memory control"},
+		{flag: "history", key: "history", usage: "Record telemetry history"},
+		{flag: "key-heatmap", key: "keyHeatmap", usage: "Count key presses per keyboard"},
+		{flag: "gamepad", key: "enableGamepad", usage: "Enable virtual gamepad for SCUF controllers"},
+		{flag: "motherboard", key: "enableMotherboard", usage: "Enable control of motherboard PWM headers"},
+		{flag: "openrgb-server", key: "enableOpenRGBTargetServer", usage: "Enable OpenRGB target server"},
+		{flag: "fan-failure-protection", key: "fanFailureProtection", usage: "Switch device to critical speed while a fan is failing"},
+		{flag: "alert-commands", key: "alertCommands", usage: "Allow alert rules to run commands"},
+		{flag: "mqtt", key: "mqttEnabled", usage: "Enable MQTT bridge"},
+		{flag: "influx", key: "influxEnabled", usage: "Enable InfluxDB push"},
+		{flag: "graphite", key: "graphiteEnabled", usage: "Enable Graphite push"},
+		{flag: "dbus", key: "dbus", usage: "Enable D-Bus service"},
+		{flag: "config-watcher", key: "configWatcher", usage: "Reload config.json and profiles when they change on disk"},
+	}
+	flagConfig   = ""
+	flagHome     = ""
+	flagDatabase = ""
+	flagValues   = map[string]string{}
+	sources      = map[string]string{}
+	secretKeys   = []string{"password", "token", "secret", "username", "apikey"}
+)
+
+func (f *flagValue) String() string {
+	return ""
+}
+
+func (f *flagValue) Set(value string) error {
+	flagValues[f.key] = value
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.boolean
+}
+
+// newFlagSet will return flag set of all daemon flags
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("OpenLinkHub", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&flagConfig, "config", "", "Location of config.json. Default: <home>/config.json")
+	fs.StringVar(&flagHome, "home", "", "Directory with database, web and static folders. Default: working directory or /etc/OpenLinkHub")
+	fs.StringVar(&flagDatabase, "database", "", "Location of database folder. Default: <home>/database")
+
+	value := reflect.ValueOf(Configuration{})
+	for _, s := range settings {
+		field, _ := fieldOf(value, s.key)
+		fs.Var(&flagValue{key: s.key, boolean: field.Kind() == reflect.Bool}, s.flag, s.usage)
+	}
+	return fs
+}
+
+// ParseFlags will parse daemon command line flags and validate them together with OPENLINKHUB_* environment variables.
+// Precedence, from lowest: config.json, environment, command line
+func ParseFlags(args []string) error {
+	fs := newFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	value := Configuration{}
+	return applyOverrides(&value, nil)
+}
+
+// PrintFlags will write daemon flags and matching environment variables to w
+func PrintFlags(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	newFlagSet().VisitAll(func(f *flag.Flag) {
+		name := "--" + f.Name
+		if bf, ok := f.Value.(*flagValue); ok && bf.boolean {
+			name += "[=false]"
+		} else {
+			name += " <value>"
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\n", name, envName(f.Name), f.Usage)
+	})
+	_ = tw.Flush()
+}
+
+// GetLocation will return location of config.json and home directory
+func GetLocation() (string, string) {
+	home := flagHome
+	if len(home) == 0 {
+		home = os.Getenv(envName("home"))
+	}
+	if len(home) == 0 {
+		home, _ = os.Getwd()
+		if common.FileExists(home + "/atomic") {
+			home = "/etc/OpenLinkHub"
+		}
+	}
+
+	file := flagConfig
+	if len(file) == 0 {
+		file = os.Getenv(envName("config"))
+	}
+	if len(file) == 0 {
+		file = home + "/config.json"
+	}
+	return absolute(file), absolute(home)
+}
+
+// getDatabaseLocation will return location of database folder for given home directory
+func getDatabaseLocation(home string) string {
+	database := flagDatabase
+	if len(database) == 0 {
+		database = os.Getenv(envName("database"))
+	}
+	if len(database) == 0 {
+		database = filepath.Join(home, "database")
+	}
+	return absolute(database)
+}
+
+// GetEffective will return running configuration with secrets redacted
+func GetEffective() Effective {
+	running := GetConfig()
+	data, _ := json.Marshal(running)
+	values := map[string]interface{}{}
+	_ = json.Unmarshal(data, &values)
+	delete(values, "ConfigPath")
+
+	origin := make(map[string]string, len(values))
+	for key, value := range values {
+		if text, ok := value.(string); ok && len(text) > 0 && IsSecret(key) {
+			values[key] = redacted
+		}
+		origin[key] = "file"
+		if source, ok := sources[key]; ok {
+			origin[key] = source
+		}
+	}
+	return Effective{
+		ConfigFile: location,
+		Home:       running.ConfigPath,
+		Database:   GetDatabasePath(),
+		Values:     values,
+		Sources:    origin,
+	}
+}
+
+// IsSecret returns true if configuration key holds credentials
+func IsSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOverrides will apply environment variables and then command line flags to value. Origins are recorded in origin when not nil
+func applyOverrides(value *Configuration, origin map[string]string) error {
+	v := reflect.ValueOf(value).Elem()
+	for _, s := range settings {
+		field, ok := fieldOf(v, s.key)
+		if !ok {
+			return fmt.Errorf("unknown configuration key %s", s.key)
+		}
+
+		if raw, ok := os.LookupEnv(envName(s.flag)); ok {
+			if err := setField(field, raw); err != nil {
+				return fmt.Errorf("%s: %w", envName(s.flag), err)
+			}
+			if origin != nil {
+				origin[s.key] = "env"
+			}
+		}
+
+		if raw, ok := flagValues[s.key]; ok {
+			if err := setField(field, raw); err != nil {
+				return fmt.Errorf("--%s: %w", s.flag, err)
+			}
+			if origin != nil {
+				origin[s.key] = "flag"
+			}
+		}
+	}
+	return nil
+}
+
+// fieldOf will return struct field with given json key
+func fieldOf(v reflect.Value, key string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("json"), ",")[0] == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// setField will parse raw value according to field type
+func setField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		field.SetBool(value)
+	case reflect.Int:
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		field.SetInt(int64(value))
+	case reflect.String:
+		field.SetString(raw)
+	default:
+		return errors.New("value can not be overridden")
+	}
+	return nil
+}
+
+// envName will return environment variable name of a flag
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// absolute will return absolute path, or path itself when it can not be resolved
+func absolute(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,84 @@
+package config
+
+// Package: config
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// liveKeys are read on every use and can be changed without restart
+var liveKeys = []string{
+	"debug",
+	"logLevel",
+	"temperatureOffset",
+	"cpuTempFile",
+	"resumeDelay",
+	"criticalCoolantTemp",
+	"criticalCoolantHysteresis",
+	"fanHealthDebounce",
+	"fanFailureProtection",
+	"alertCommands",
+	"criticalDevices",
+}
+
+// Reload will validate config.json and apply changed settings which do not need a restart.
+// Returns applied keys, changed keys which need a restart and validation problems. Nothing is changed when config.json is invalid
+func Reload() ([]string, []string, []string) {
+	if problems := Validate(location); len(problems) > 0 {
+		return nil, nil, problems
+	}
+
+	f, err := os.Open(location)
+	if err != nil {
+		return nil, nil, []string{err.Error()}
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	file := Configuration{}
+	if err = json.NewDecoder(f).Decode(&file); err != nil {
+		return nil, nil, []string{err.Error()}
+	}
+
+	// Environment and command line overrides still take precedence
+	value := file
+	if err = applyOverrides(&value, nil); err != nil {
+		return nil, nil, []string{err.Error()}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// Changes are applied to a copy which then replaces running configuration, readers never see a partial update
+	applied := make([]string, 0)
+	restart := make([]string, 0)
+	next := configuration
+	running := reflect.ValueOf(&next).Elem()
+	loaded := reflect.ValueOf(&value).Elem()
+	for i := 0; i < running.NumField(); i++ {
+		key := strings.Split(running.Type().Field(i).Tag.Get("json"), ",")[0]
+		if len(key) == 0 || reflect.DeepEqual(running.Field(i).Interface(), loaded.Field(i).Interface()) {
+			continue
+		}
+		if slices.Contains(liveKeys, key) {
+			running.Field(i).Set(loaded.Field(i))
+			applied = append(applied, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	configuration = next
+	stored = file
+
+	sort.Strings(applied)
+	sort.Strings(restart)
+	return applied, restart, nil
+}
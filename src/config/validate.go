@@ -0,0 +1,145 @@
+package config
+
+// Package: config
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	logLevels    = []string{"info", "warn", "error", "fatal", "silent"}
+	serialRegex  = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	brokerScheme = []string{"tcp", "mqtt", "ssl", "tls", "mqtts"}
+)
+
+// Validate will check config.json syntax, keys, value types and ranges. Returns list of problems, empty when valid
+func Validate(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	value := Configuration{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&value); err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxError):
+			line, column := position(data, syntaxError.Offset)
+			return []string{fmt.Sprintf("line %d, column %d: %s", line, column, syntaxError.Error())}
+		case errors.As(err, &typeError):
+			line, column := position(data, typeError.Offset)
+			return []string{fmt.Sprintf("line %d, column %d: %s must be %s, got %s", line, column, typeError.Field, typeError.Type.String(), typeError.Value)}
+		}
+		return []string{err.Error()}
+	}
+
+	problems := make([]string, 0)
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if value.ListenPort < 0 || value.ListenPort > 65535 {
+		add("listenPort: %d is out of range 0 - 65535", value.ListenPort)
+	}
+	if value.ListenPort > 0 && len(value.ListenAddress) == 0 {
+		add("listenAddress: must be set when listenPort is enabled")
+	}
+	if len(value.SocketMode) > 0 {
+		if mode, err := strconv.ParseUint(value.SocketMode, 8, 32); err != nil || mode > 0777 {
+			add("socketMode: %s is not an octal permission, e.g. 0660", value.SocketMode)
+		}
+	}
+	if len(value.SocketPath) > 0 && !filepath.IsAbs(value.SocketPath) {
+		add("socketPath: %s must be an absolute path", value.SocketPath)
+	}
+	if value.OpenRGBPort < 0 || value.OpenRGBPort > 65535 {
+		add("openRGBPort: %d is out of range 0 - 65535", value.OpenRGBPort)
+	}
+	if len(value.LogLevel) > 0 && !slices.Contains(logLevels, strings.ToLower(value.LogLevel)) {
+		add("logLevel: %s is not one of %s", value.LogLevel, strings.Join(logLevels, ", "))
+	}
+	if value.ResumeDelay < 0 {
+		add("resumeDelay: must not be negative")
+	}
+	if value.CriticalCoolantTemp <= 0 {
+		add("criticalCoolantTemp: must be greater than 0")
+	}
+	if value.CriticalCoolantHysteresis < 0 {
+		add("criticalCoolantHysteresis: must not be negative")
+	}
+	if value.FanHealthDebounce < 0 {
+		add("fanHealthDebounce: must not be negative")
+	}
+
+	for i, tier := range value.HistoryTiers {
+		if tier.Resolution <= 0 || tier.Retention < tier.Resolution {
+			add("historyTiers[%d]: resolution must be greater than 0 and not greater than retention", i)
+		} else if i > 0 && tier.Resolution <= value.HistoryTiers[i-1].Resolution {
+			add("historyTiers[%d]: resolution must be greater than resolution of previous tier", i)
+		}
+	}
+
+	if value.MqttEnabled {
+		if u, err := url.Parse(value.MqttBroker); err != nil || !slices.Contains(brokerScheme, u.Scheme) || len(u.Hostname()) == 0 {
+			add("mqttBroker: %s is not a valid broker address, use %s://host:port", value.MqttBroker, strings.Join(brokerScheme, "|"))
+		}
+		if value.MqttInterval <= 0 {
+			add("mqttInterval: must be greater than 0")
+		}
+	}
+
+	if value.InfluxEnabled {
+		if u, err := url.Parse(value.InfluxUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			add("influxUrl: %s is not a valid http(s) URL", value.InfluxUrl)
+		}
+		if len(value.InfluxBucket) == 0 {
+			add("influxBucket: must be set when influxEnabled is true")
+		}
+	}
+
+	if value.GraphiteEnabled {
+		if _, _, err := net.SplitHostPort(value.GraphiteAddress); err != nil {
+			add("graphiteAddress: %s is not a valid host:port", value.GraphiteAddress)
+		}
+		if protocol := strings.ToLower(value.GraphiteProtocol); protocol != "tcp" && protocol != "udp" {
+			add("graphiteProtocol: %s is not one of tcp, udp", value.GraphiteProtocol)
+		}
+	}
+
+	if value.ExporterInterval < 0 || value.ExporterBatchSize < 0 || value.ExporterBufferSize < 0 {
+		add("exporterInterval, exporterBatchSize, exporterBufferSize: must not be negative")
+	}
+
+	for _, serial := range value.CriticalDevices {
+		if !serialRegex.MatchString(serial) {
+			add("criticalDevices: %q is not a valid device serial", serial)
+		}
+	}
+	return problems
+}
+
+// position will convert byte offset to line and column
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	column := int(offset) - bytes.LastIndex(data[:offset], []byte("\n"))
+	return line, column
+}
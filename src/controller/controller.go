@@ -5,28 +5,41 @@ package controller
 // License: GPL-3.0 or later
 
 import (
+	"OpenLinkHub/src/alerts"
 	"OpenLinkHub/src/audio"
+	"OpenLinkHub/src/calibration"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/dbusapi"
 	"OpenLinkHub/src/devices"
 	"OpenLinkHub/src/devices/lcd"
 	"OpenLinkHub/src/display"
+	"OpenLinkHub/src/exporter"
+	"OpenLinkHub/src/heatmap"
+	"OpenLinkHub/src/history"
 	"OpenLinkHub/src/inputmanager"
 	"OpenLinkHub/src/keyboards"
 	"OpenLinkHub/src/language"
+	"OpenLinkHub/src/ledstream"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/macro"
 	"OpenLinkHub/src/media"
 	"OpenLinkHub/src/metrics"
+	"OpenLinkHub/src/migration"
 	"OpenLinkHub/src/monitor"
 	"OpenLinkHub/src/motherboards"
+	"OpenLinkHub/src/mqtt"
+	"OpenLinkHub/src/overrides"
+	"OpenLinkHub/src/reload"
 	"OpenLinkHub/src/rgb"
+	"OpenLinkHub/src/scenes"
 	"OpenLinkHub/src/scheduler"
 	"OpenLinkHub/src/server"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/systeminfo"
 	"OpenLinkHub/src/temperatures"
 	"OpenLinkHub/src/version"
+	"OpenLinkHub/src/webhooks"
 )
 
 // Start will start new controller session
@@ -34,6 +47,7 @@ func Start() {
 	version.Init()      // Build info
 	config.Init()       // Configuration
 	logger.Init()       // Logger
+	migration.Init()    // Config and database migrations
 	display.Init()      // Displays
 	media.Init()        // Media client
 	audio.Init()        // Audio
@@ -44,20 +58,40 @@ func Start() {
 	lcd.Init()          // LCD
 	temperatures.Init() // Temperatures
 	keyboards.Init()    // Keyboards
+	heatmap.Init()      // Key usage statistics
 	inputmanager.Init() // Input Manager
 	stats.Init()        // Statistics
 	macro.Init()        // Macro
 	motherboards.Init() // Motherboards
+	overrides.Init()    // Speed override leases, reverted before devices load
+	calibration.Init()  // Interrupted fan calibrations, reverted before devices load
+	ledstream.Init()    // LED stream claims, reverted before devices load
 	devices.Init()      // Devices
+	scenes.Init()       // Scenes
+	history.Init()      // Telemetry history
+	alerts.Init()       // Alert rules
+	webhooks.Init()     // Webhooks
+	mqtt.Init()         // MQTT bridge
+	exporter.Init()     // InfluxDB and Graphite push
 	monitor.Init()      // Monitor
 	language.Init()     // Language
+	dbusapi.Init()      // D-Bus service
 	scheduler.Init()    // Scheduler
+	reload.Init()       // Config and profile watcher
 	server.Init()       // REST & WebUI
 }
 
 // Stop will stop device control
 func Stop() {
+	reload.Stop()       // Config and profile watcher
+	server.Stop()       // REST Unix socket
+	mqtt.Stop()         // MQTT bridge
+	dbusapi.Stop()      // D-Bus service
+	ledstream.Stop()    // LED streaming clients
 	devices.Stop()      // Devices
+	heatmap.Flush()     // Key usage statistics
+	history.Flush()     // Telemetry history
+	webhooks.Flush()    // Webhook delivery log
 	inputmanager.Stop() // Cleanup virtual devices
 	audio.StopAudio()   // Virtual Audio
 	media.Stop()        // Media client
@@ -0,0 +1,238 @@
+package coolant
+
+// Package: coolant
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/rgb"
+	"OpenLinkHub/src/webhooks"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Event struct {
+	Serial      string    `json:"serial"`
+	Product     string    `json:"product"`
+	Critical    bool      `json:"critical"`
+	Temperature float32   `json:"temperature"`
+	Threshold   float32   `json:"threshold"`
+	Time        time.Time `json:"time"`
+}
+
+type State struct {
+	Serial      string    `json:"serial"`
+	Product     string    `json:"product"`
+	Critical    bool      `json:"critical"`
+	Overheat    bool      `json:"overheat"`
+	Fault       bool      `json:"fault"`
+	Temperature float32   `json:"temperature"`
+	Since       time.Time `json:"since"`
+}
+
+// Channel is a device channel switched by Protect. Nil fields are not switched and empty saved values are not restored
+type Channel struct {
+	Profile      *string // Speed profile of a channel with speed control
+	RGB          *string // RGB mode of a channel with LEDs
+	SavedProfile string  // Speed profile restored once protection is cleared
+	SavedRGB     string  // RGB mode restored once protection is cleared
+}
+
+type Overview struct {
+	Critical float32 `json:"critical"`
+	Recovery float32 `json:"recovery"`
+	Devices  []State `json:"devices"`
+	Events   []Event `json:"events"`
+}
+
+const (
+	StateUnchanged uint8 = 0
+	StateTriggered uint8 = 1
+	StateCleared   uint8 = 2
+
+	// CriticalProfile is speed profile applied to all channels while coolant is critical
+	CriticalProfile = "aioCriticalTemperature"
+
+	// CriticalRgb is RGB mode applied to all LED channels while coolant is critical
+	CriticalRgb = "colorpulse"
+)
+
+// CriticalColor is color of CriticalRgb, used by devices supporting only static color
+var CriticalColor = rgb.Color{Red: 255, Green: 0, Blue: 0, Brightness: 1}
+
+var (
+	mutex             sync.Mutex
+	states            = map[string]*State{}
+	events            = make([]Event, 0)
+	maxEvents         = 100
+	defaultCritical   = float32(57)
+	defaultHysteresis = float32(5)
+)
+
+// GetThresholds will return critical and recovery coolant temperature
+func GetThresholds() (float32, float32) {
+	critical := float32(config.GetConfig().CriticalCoolantTemp)
+	if critical <= 0 {
+		critical = defaultCritical
+	}
+
+	hysteresis := float32(config.GetConfig().CriticalCoolantHysteresis)
+	if hysteresis <= 0 || hysteresis >= critical {
+		hysteresis = defaultHysteresis
+	}
+	return critical, critical - hysteresis
+}
+
+// Check will track coolant temperature of a device and return state transition.
+// Protection is triggered above critical temperature or on reported channel fault, and cleared once
+// temperature drops below recovery temperature and no fault is left. Devices without a liquid sensor pass 0
+func Check(serial, product string, temperature float32) uint8 {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	state := getState(serial, product)
+	critical, recovery := GetThresholds()
+	if temperature > 0 {
+		state.Temperature = temperature
+		if !state.Overheat && temperature > critical {
+			state.Overheat = true
+			logger.Log(logger.Fields{"serial": serial, "product": product, "temperature": temperature, "threshold": critical}).Warn("Critical coolant temperature, switching to protection mode")
+		} else if state.Overheat && temperature < recovery {
+			state.Overheat = false
+			logger.Log(logger.Fields{"serial": serial, "product": product, "temperature": temperature, "threshold": recovery}).Info("Coolant temperature recovered")
+		}
+	}
+
+	switch {
+	case !state.Critical && (state.Overheat || state.Fault):
+		state.Critical = true
+		state.Since = time.Now()
+		addEvent(state, critical)
+		webhooks.Publish(webhooks.EventCoolantCritical, serial, product, map[string]interface{}{"temperature": state.Temperature, "overheat": state.Overheat, "fault": state.Fault})
+		return StateTriggered
+	case state.Critical && !state.Overheat && !state.Fault:
+		state.Critical = false
+		state.Since = time.Now()
+		addEvent(state, recovery)
+		logger.Log(logger.Fields{"serial": serial, "product": product}).Info("Protection mode cleared, restoring device profile")
+		webhooks.Publish(webhooks.EventCoolantRecovered, serial, product, map[string]interface{}{"temperature": state.Temperature})
+		return StateCleared
+	}
+	return StateUnchanged
+}
+
+// Protect will check coolant temperature of a device and switch its channels to critical speed profile and RGB mode,
+// or restore their saved profiles once protection is cleared. Channels are requested only on state transition.
+// Returns true when channels are switched and device lighting has to be restarted
+func Protect(serial, product string, temperature float32, channels func() []Channel) bool {
+	state := Check(serial, product, temperature)
+	if state == StateUnchanged {
+		return false
+	}
+
+	for _, channel := range channels() {
+		if state == StateTriggered {
+			if channel.Profile != nil {
+				*channel.Profile = CriticalProfile
+			}
+			if channel.RGB != nil {
+				*channel.RGB = CriticalRgb
+			}
+			continue
+		}
+
+		if channel.Profile != nil && len(channel.SavedProfile) > 0 {
+			*channel.Profile = channel.SavedProfile
+		}
+		if channel.RGB != nil && len(channel.SavedRGB) > 0 {
+			*channel.RGB = channel.SavedRGB
+		}
+	}
+	return true
+}
+
+// SetFault will mark device as faulty, e.g. on stalled fan or failed pump. Protection is applied on next Check
+func SetFault(serial string, fault bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	state := getState(serial, "")
+	if state.Fault != fault {
+		state.Fault = fault
+		if fault {
+			logger.Log(logger.Fields{"serial": serial}).Warn("Channel fault reported, switching to protection mode")
+		}
+	}
+}
+
+// IsCritical will return true if device is currently in protection mode
+func IsCritical(serial string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if state, ok := states[serial]; ok {
+		return state.Critical
+	}
+	return false
+}
+
+// Reset will remove device state, e.g. when device is stopped
+func Reset(serial string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(states, serial)
+}
+
+// GetOverview will return thresholds, device states and recent protection events
+func GetOverview() Overview {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	critical, recovery := GetThresholds()
+	overview := Overview{
+		Critical: critical,
+		Recovery: recovery,
+		Devices:  make([]State, 0, len(states)),
+		Events:   make([]Event, len(events)),
+	}
+
+	for _, state := range states {
+		overview.Devices = append(overview.Devices, *state)
+	}
+	sort.Slice(overview.Devices, func(i, j int) bool {
+		return overview.Devices[i].Serial < overview.Devices[j].Serial
+	})
+	copy(overview.Events, events)
+	return overview
+}
+
+// getState will return device state, or create a new one
+func getState(serial, product string) *State {
+	state, ok := states[serial]
+	if !ok {
+		state = &State{Serial: serial, Product: product, Since: time.Now()}
+		states[serial] = state
+	}
+	if len(product) > 0 {
+		state.Product = product
+	}
+	return state
+}
+
+// addEvent will append protection event and keep only last maxEvents
+func addEvent(state *State, threshold float32) {
+	events = append(events, Event{
+		Serial:      state.Serial,
+		Product:     state.Product,
+		Critical:    state.Critical,
+		Temperature: state.Temperature,
+		Threshold:   threshold,
+		Time:        state.Since,
+	})
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+}
@@ -41,21 +41,6 @@ type Dashboard struct {
 var (
 	location  = ""
 	dashboard Dashboard
-	upgrade   = map[string]any{
-		"celsius":              true,
-		"showLabels":           true,
-		"showBattery":          false,
-		"languageCode":         "en_US",
-		"temperatureBar":       true,
-		"addDeviceToDashboard": true,
-		"rgbOff":               false,
-		"pageTitle":            "OPENLINKHUB WebUI",
-		"sidebarCollapsed":     false,
-		"devices":              []string{},
-		"theme":                "default",
-		"keyboardLayout":       0,
-		"keyboardLayouts":      map[int]string{0: "QWERTY", 1: "AZERTY"},
-	}
 )
 
 // Init will initialize a new config object
@@ -83,7 +68,7 @@ func Init() {
 	}
 }
 
-// upgradeFile will perform json file upgrade or create initial file
+// upgradeFile will create initial file. Existing file is upgraded by migration package
 func upgradeFile() {
 	if !common.FileExists(location) {
 		logger.Log(logger.Fields{"file": location}).Info("Dashboard file is missing, creating initial one.")
@@ -114,44 +99,6 @@ func upgradeFile() {
 		} else {
 			logger.Log(logger.Fields{"file": location}).Warn("Unable to create dashboard file.")
 		}
-	} else {
-		// File exists, check for possible upgrade
-		logger.Log(logger.Fields{"file": location}).Info("Dashboard file is found, checking for any upgrade...")
-
-		save := false
-		var data map[string]interface{}
-		file, err := os.Open(location)
-		defer func(file *os.File) {
-			err = file.Close()
-			if err != nil {
-				logger.Log(logger.Fields{"error": err, "file": location}).Error("Failed to close file.")
-			}
-		}(file)
-
-		if err != nil {
-			logger.Log(logger.Fields{"error": err, "file": location}).Error("Unable to open file.")
-			panic(err.Error())
-		}
-		if err = json.NewDecoder(file).Decode(&data); err != nil {
-			logger.Log(logger.Fields{"error": err, "file": location}).Error("Unable to decode file.")
-			panic(err.Error())
-		}
-
-		// Loop thru upgrade value
-		for key, value := range upgrade {
-			if _, ok := data[key]; !ok {
-				logger.Log(logger.Fields{"key": key, "value": value}).Info("Upgrading fields...")
-				data[key] = value
-				save = true
-			}
-		}
-
-		// Save on change
-		if save {
-			SaveDashboardSettings(data, false)
-		} else {
-			logger.Log(logger.Fields{"file": location}).Info("Nothing to upgrade.")
-		}
 	}
 }
 
@@ -0,0 +1,319 @@
+package dbusapi
+
+// Package: dbusapi
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/history"
+	"OpenLinkHub/src/language"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/stats"
+	"OpenLinkHub/src/temperatures"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// Daemon is exported on D-Bus. Every exported method is a D-Bus method
+type Daemon struct{}
+
+type Device struct {
+	Serial   string
+	Product  string
+	Firmware string
+}
+
+type Temperature struct {
+	DeviceId  string
+	ChannelId string
+	Name      string
+	Value     float64
+}
+
+type Battery struct {
+	Serial string
+	Device string
+	Level  uint16
+}
+
+// snapshot holds state compared on every poll to emit signals
+type snapshot struct {
+	devices  map[string]string // serial -> product
+	battery  map[string]uint16 // serial -> level
+	profiles map[string]string // serial -> active user profile
+}
+
+const (
+	busName       = "org.openlinkhub.Daemon"
+	objectPath    = dbus.ObjectPath("/org/openlinkhub/Daemon")
+	interfaceName = "org.openlinkhub.Daemon"
+	errorNotFound = interfaceName + ".Error.NotFound"
+	errorInvalid  = interfaceName + ".Error.InvalidArgs"
+	errorFailed   = interfaceName + ".Error.Failed"
+	pollInterval  = 2 * time.Second
+)
+
+var (
+	conn    *dbus.Conn
+	exit    chan struct{}
+	mutex   sync.Mutex
+	signals = []introspect.Signal{
+		{Name: "DeviceAdded", Args: []introspect.Arg{{Name: "serial", Type: "s"}, {Name: "product", Type: "s"}}},
+		{Name: "DeviceRemoved", Args: []introspect.Arg{{Name: "serial", Type: "s"}}},
+		{Name: "BatteryChanged", Args: []introspect.Arg{{Name: "serial", Type: "s"}, {Name: "level", Type: "q"}}},
+		{Name: "ProfileSwitched", Args: []introspect.Arg{{Name: "serial", Type: "s"}, {Name: "profile", Type: "s"}}},
+	}
+)
+
+// Init will export daemon on session bus, or system bus when running as system service
+func Init() {
+	if !config.GetConfig().DBus {
+		return
+	}
+
+	var err error
+	bus := "session"
+	if config.IsSystemService() {
+		bus = "system"
+		conn, err = dbus.ConnectSystemBus()
+	} else {
+		conn, err = dbus.ConnectSessionBus()
+	}
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "bus": bus}).Warn("Unable to connect to D-Bus")
+		return
+	}
+
+	daemon := &Daemon{}
+	node := &introspect.Node{
+		Name: string(objectPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			{Name: interfaceName, Methods: introspect.Methods(daemon), Signals: signals},
+		},
+	}
+	if err = conn.Export(daemon, objectPath, interfaceName); err == nil {
+		err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	}
+	if err != nil {
+		logger.Log(logger.Fields{"error": err}).Error("Unable to export D-Bus object")
+		closeConn()
+		return
+	}
+
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		logger.Log(logger.Fields{"error": err, "bus": bus, "name": busName}).Warn("Unable to own D-Bus name. On system bus install org.openlinkhub.Daemon.conf to /etc/dbus-1/system.d/")
+		closeConn()
+		return
+	}
+
+	exit = make(chan struct{})
+	go watch(exit, takeSnapshot())
+	logger.Log(logger.Fields{"bus": bus, "name": busName}).Info("D-Bus service started")
+}
+
+// Stop will release D-Bus name and stop signal polling
+func Stop() {
+	mutex.Lock()
+	if exit != nil {
+		close(exit)
+		exit = nil
+	}
+	mutex.Unlock()
+	closeConn()
+}
+
+// closeConn will close D-Bus connection
+func closeConn() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if conn == nil {
+		return
+	}
+	if err := conn.Close(); err != nil {
+		logger.Log(logger.Fields{"error": err}).Warn("Unable to close D-Bus connection")
+	}
+	conn = nil
+}
+
+// ListDevices will return all devices sorted by serial
+func (d *Daemon) ListDevices() ([]Device, *dbus.Error) {
+	list := make([]Device, 0)
+	for _, device := range devices.GetDevicesEx() {
+		list = append(list, Device{Serial: device.Serial, Product: device.Product, Firmware: device.Firmware})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Serial < list[j].Serial
+	})
+	return list, nil
+}
+
+// GetUserProfiles will return user profile names of a device and the active one
+func (d *Daemon) GetUserProfiles(serial string) ([]string, string, *dbus.Error) {
+	if devices.GetDevice(serial) == nil {
+		return nil, "", notFound()
+	}
+
+	profiles := devices.GetUserProfiles(serial)
+	if profiles == nil {
+		profiles = make([]string, 0)
+	}
+	return profiles, devices.GetActiveProfile(serial), nil
+}
+
+// SwitchProfile will switch device user profile
+func (d *Daemon) SwitchProfile(serial, name string) *dbus.Error {
+	if devices.GetDevice(serial) == nil {
+		return notFound()
+	}
+	if !common.AlphanumericRegex.MatchString(name) {
+		return dbus.NewError(errorInvalid, []interface{}{language.GetValue("txtProfileOnlyLettersNumbers")})
+	}
+	if !devices.HasMethod(serial, "ChangeDeviceProfile", reflect.String) || devices.GetMethodStatus(devices.CallDeviceMethod(serial, "ChangeDeviceProfile", name)) != 1 {
+		return dbus.NewError(errorFailed, []interface{}{language.GetValue("txtUnableToChangeUserProfile")})
+	}
+	return nil
+}
+
+// SetRgbProfile will change RGB profile of all device channels
+func (d *Daemon) SetRgbProfile(serial, profile string) *dbus.Error {
+	if devices.GetDevice(serial) == nil {
+		return notFound()
+	}
+	if !common.AlphanumericDashRegex.MatchString(profile) {
+		return dbus.NewError(errorInvalid, []interface{}{language.GetValue("txtNonExistingRgbProfile")})
+	}
+	if !devices.HasMethod(serial, "UpdateRgbProfile", reflect.Int, reflect.String) || devices.GetMethodStatus(devices.CallDeviceMethod(serial, "UpdateRgbProfile", -1, profile)) != 1 {
+		return dbus.NewError(errorFailed, []interface{}{language.GetValue("txtUnableToChangeRgbProfile")})
+	}
+	return nil
+}
+
+// SetBrightness will change device brightness, 0 - 100
+func (d *Daemon) SetBrightness(serial string, value uint8) *dbus.Error {
+	if devices.GetDevice(serial) == nil {
+		return notFound()
+	}
+	if value > 100 {
+		return dbus.NewError(errorInvalid, []interface{}{language.GetValue("txtBrightnessTooHigh")})
+	}
+	if !devices.HasMethod(serial, "ChangeDeviceBrightnessValue", reflect.Uint8) || devices.GetMethodStatus(devices.CallDeviceMethod(serial, "ChangeDeviceBrightnessValue", value)) != 1 {
+		return dbus.NewError(errorFailed, []interface{}{language.GetValue("txtUnableToChangeBrightness")})
+	}
+	return nil
+}
+
+// GetTemperatures will return device channel, CPU, GPU and storage temperatures
+func (d *Daemon) GetTemperatures() ([]Temperature, *dbus.Error) {
+	list := make([]Temperature, 0)
+	for _, sample := range history.Collect() {
+		if sample.Metric == history.MetricTemperature {
+			list = append(list, Temperature{DeviceId: sample.DeviceId, ChannelId: sample.ChannelId, Name: sample.Name, Value: float64(sample.Value)})
+		}
+	}
+	for _, storage := range temperatures.GetStorageTemperatures() {
+		list = append(list, Temperature{DeviceId: "storage", ChannelId: storage.Key, Name: storage.Model, Value: float64(storage.Temperature)})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].DeviceId != list[j].DeviceId {
+			return list[i].DeviceId < list[j].DeviceId
+		}
+		return list[i].ChannelId < list[j].ChannelId
+	})
+	return list, nil
+}
+
+// GetBatteryLevels will return battery levels of wireless devices
+func (d *Daemon) GetBatteryLevels() ([]Battery, *dbus.Error) {
+	list := make([]Battery, 0)
+	for serial, battery := range stats.GetBatteryStats() {
+		list = append(list, Battery{Serial: serial, Device: battery.Device, Level: battery.Level})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Serial < list[j].Serial
+	})
+	return list, nil
+}
+
+// watch will poll device state and emit signals on change
+func watch(done chan struct{}, previous snapshot) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			current := takeSnapshot()
+			for serial, product := range current.devices {
+				if _, ok := previous.devices[serial]; !ok {
+					emit("DeviceAdded", serial, product)
+				}
+			}
+			for serial := range previous.devices {
+				if _, ok := current.devices[serial]; !ok {
+					emit("DeviceRemoved", serial)
+				}
+			}
+			for serial, level := range current.battery {
+				if value, ok := previous.battery[serial]; !ok || value != level {
+					emit("BatteryChanged", serial, level)
+				}
+			}
+			for serial, profile := range current.profiles {
+				if value, ok := previous.profiles[serial]; ok && value != profile {
+					emit("ProfileSwitched", serial, profile)
+				}
+			}
+			previous = current
+		}
+	}
+}
+
+// takeSnapshot will return current devices, battery levels and active user profiles
+func takeSnapshot() snapshot {
+	current := snapshot{
+		devices:  make(map[string]string),
+		battery:  make(map[string]uint16),
+		profiles: make(map[string]string),
+	}
+	for serial, device := range devices.GetDevicesEx() {
+		current.devices[serial] = device.Product
+		if profile := devices.GetActiveProfile(serial); len(profile) > 0 {
+			current.profiles[serial] = profile
+		}
+	}
+	for serial, battery := range stats.GetBatteryStats() {
+		current.battery[serial] = battery.Level
+	}
+	return current
+}
+
+// emit will send a signal on daemon interface
+func emit(name string, values ...interface{}) {
+	mutex.Lock()
+	c := conn
+	mutex.Unlock()
+
+	if c == nil {
+		return
+	}
+	if err := c.Emit(objectPath, interfaceName+"."+name, values...); err != nil {
+		logger.Log(logger.Fields{"error": err, "signal": name}).Warn("Unable to emit D-Bus signal")
+	}
+}
+
+// notFound will return D-Bus error for unknown device
+func notFound() *dbus.Error {
+	return dbus.NewError(errorNotFound, []interface{}{language.GetValue("txtNonExistingDevice")})
+}
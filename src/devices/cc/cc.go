@@ -8,8 +8,11 @@ import (
 	"OpenLinkHub/src/cluster"
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/coolant"
 	"OpenLinkHub/src/dashboard"
 	"OpenLinkHub/src/devices/lcd"
+	"OpenLinkHub/src/fanhealth"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/metrics"
 	"OpenLinkHub/src/openrgb"
@@ -17,6 +20,7 @@ import (
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/systeminfo"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -29,12 +33,9 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/sstallion/go-hid"
 )
 
 var (
-	pwd                        = ""
 	cmdOpenEndpoint            = []byte{0x0d, 0x01}
 	cmdOpenColorEndpoint       = []byte{0x0d, 0x00}
 	cmdCloseEndpoint           = []byte{0x05, 0x01, 0x01}
@@ -240,6 +241,7 @@ type DeviceProfile struct {
 	OpenRGBIntegration bool
 	RGBCluster         bool
 	RgbOff             bool
+	Calibration        map[int]*temperatures.FanCalibration
 }
 
 type TemperatureProbe struct {
@@ -345,9 +347,6 @@ func (d *Device) hardLedReset() {
 
 // Init will initialize a new device
 func Init(vendorId, productId uint16, serial, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := hid.Open(vendorId, productId, serial)
 	if err != nil {
@@ -511,6 +510,8 @@ func (d *Device) Stop() {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -578,6 +579,8 @@ func (d *Device) StopDirty() uint8 {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -627,7 +630,7 @@ func (d *Device) StopDirty() uint8 {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -717,7 +720,7 @@ func (d *Device) GetDeviceTemplate() string {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -1010,7 +1013,7 @@ func (d *Device) setupClusterController() {
 		WriteColorEx: d.writeColorCluster,
 	}
 
-	cluster.Get().AddDeviceController(clusterController)
+	cluster.AddDeviceController(clusterController)
 }
 
 // setupOpenRGBController will create RGBController object for OpenRGB Client Integration
@@ -1668,6 +1671,12 @@ func (d *Device) setSpeed(data map[int]byte, mode uint8) {
 	if d.Exit {
 		return
 	}
+
+	if mode == 0 {
+		for channelId, duty := range data {
+			fanhealth.SetDuty(d.Serial, channelId, duty)
+		}
+	}
 	buffer := make([]byte, len(data)*4+1)
 	buffer[0] = byte(len(data))
 	i := 1
@@ -1763,6 +1772,36 @@ func (d *Device) getLiquidTemperature() float32 {
 	return 0
 }
 
+// protectLiquidCooler will try to protect your liquid cooler when the temperature reaches critical point
+func (d *Device) protectLiquidCooler() {
+	if d.DeviceProfile == nil {
+		return
+	}
+
+	if !coolant.Protect(d.Serial, d.Product, d.getLiquidTemperature(), func() []coolant.Channel {
+		channels := make([]coolant.Channel, 0, len(d.Devices)+len(d.RgbDevices))
+		for key, device := range d.Devices {
+			if device.HasSpeed {
+				channels = append(channels, coolant.Channel{Profile: &d.Devices[key].Profile, SavedProfile: d.DeviceProfile.SpeedProfiles[key]})
+			}
+		}
+		for key, device := range d.RgbDevices {
+			if device.LedChannels > 0 {
+				channels = append(channels, coolant.Channel{RGB: &d.RgbDevices[key].RGB, SavedRGB: d.DeviceProfile.RGBProfiles[key]})
+			}
+		}
+		return channels
+	}) {
+		return
+	}
+
+	if d.activeRgb != nil {
+		d.activeRgb.Exit <- true
+		d.activeRgb = nil
+	}
+	d.setDeviceColor()
+}
+
 // updateDeviceSpeed will update device speed based on a temperature reading
 func (d *Device) updateDeviceSpeed() {
 	d.timerSpeed = time.NewTicker(time.Duration(temperaturePullingInterval) * time.Millisecond)
@@ -1910,12 +1949,27 @@ func (d *Device) updateDeviceSpeed() {
 						temp = 50
 					}
 
-					if config.GetConfig().GraphProfiles {
+					if profiles.Pid.Enabled {
+						duty := temperatures.GetPidSpeed(d.Serial, device.ChannelId, device.Profile, profiles, temp, device.ContainsPump)
+						cp := fmt.Sprintf("%s-%d-pid-%d", device.Profile, device.ChannelId, duty)
+						if ok := tmp[device.ChannelId]; ok != cp {
+							tmp[device.ChannelId] = cp
+							channelSpeeds[device.ChannelId] = byte(duty)
+							d.setSpeed(channelSpeeds, 0)
+						}
+
+						if d.Debug {
+							logger.Log(logger.Fields{"serial": d.Serial, "duty": duty, "temp": temp, "setpoint": profiles.Pid.Setpoint, "device": device.Name, "zeroRpm": profiles.ZeroRpm}).Info("updateDeviceSpeed()")
+						}
+					} else if config.GetConfig().GraphProfiles {
 						pumpValue := temperatures.Interpolate(profiles.Points[0], temp)
 						fansValue := temperatures.Interpolate(profiles.Points[1], temp)
 
 						pump := int(math.Round(float64(pumpValue)))
 						fans := int(math.Round(float64(fansValue)))
+						if profiles.Rpm {
+							fans = temperatures.RpmToDuty(d.getCalibration(device.ChannelId), fans)
+						}
 
 						// Failsafe
 						if fans < 20 && !profiles.ZeroRpm {
@@ -1965,6 +2019,10 @@ func (d *Device) updateDeviceSpeed() {
 										profile.Mode = 0
 									}
 
+									if profiles.Rpm {
+										profile.Fans = uint16(temperatures.RpmToDuty(d.getCalibration(device.ChannelId), int(profile.Fans)))
+									}
+
 									if profile.Fans < 20 && !profiles.ZeroRpm {
 										profile.Fans = 20
 									}
@@ -2002,6 +2060,7 @@ func (d *Device) setHardwareMode() {
 	if err != nil {
 		logger.Log(logger.Fields{"error": err}).Fatal("Unable to change device mode")
 	}
+	metrics.SetMode(d.Serial, metrics.ModeHardware)
 }
 
 // setSoftwareMode will switch a device to software mode
@@ -2010,6 +2069,7 @@ func (d *Device) setSoftwareMode() {
 	if err != nil {
 		logger.Log(logger.Fields{"error": err}).Fatal("Unable to change device mode")
 	}
+	metrics.SetMode(d.Serial, metrics.ModeSoftware)
 }
 
 // getDeviceType will set a type of AIO
@@ -2173,6 +2233,13 @@ func (d *Device) getDeviceData() {
 		}
 	}
 
+	// Channel health
+	for key, value := range d.Devices {
+		if value.HasSpeed {
+			fanhealth.Update(d.Serial, d.Product, key, value.Name, value.Rpm, value.ContainsPump)
+		}
+	}
+
 	// Update stats
 	for key, value := range d.Devices {
 		temperatureString := ""
@@ -2217,8 +2284,11 @@ func (d *Device) setAutoRefresh() {
 				if d.Exit {
 					return
 				}
+				start := time.Now()
 				d.setTemperatures()
 				d.getDeviceData()
+				d.protectLiquidCooler()
+				metrics.ObserveLoop(d.Serial, start)
 			case <-d.autoRefreshChan:
 				d.timer.Stop()
 				return
@@ -2253,6 +2323,32 @@ func (d *Device) UpdateDeviceSpeed(channelId int, value uint16) uint8 {
 	return 0
 }
 
+// SetChannelCalibration will save fan calibration of a device channel
+func (d *Device) SetChannelCalibration(channelId int, calibration *temperatures.FanCalibration) uint8 {
+	if d.DeviceProfile == nil {
+		return 0
+	}
+
+	if _, ok := d.Devices[channelId]; !ok {
+		return 0
+	}
+
+	if d.DeviceProfile.Calibration == nil {
+		d.DeviceProfile.Calibration = make(map[int]*temperatures.FanCalibration)
+	}
+	d.DeviceProfile.Calibration[channelId] = calibration
+	d.saveDeviceProfile()
+	return 1
+}
+
+// getCalibration will return fan calibration of a device channel, or nil if channel is not calibrated
+func (d *Device) getCalibration(channelId int) *temperatures.FanCalibration {
+	if d.DeviceProfile == nil || d.DeviceProfile.Calibration == nil {
+		return nil
+	}
+	return d.DeviceProfile.Calibration[channelId]
+}
+
 // UpdateDeviceLabel will set / update device label
 func (d *Device) UpdateDeviceLabel(channelId int, label string) uint8 {
 	if _, ok := d.Devices[channelId]; !ok {
@@ -2491,6 +2587,9 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 		}
 
 		newProfile := profile
+		if newProfile.Calibration == nil {
+			newProfile.Calibration = d.DeviceProfile.Calibration
+		}
 		newProfile.Active = true
 		d.DeviceProfile = newProfile
 		d.saveDeviceProfile()
@@ -2500,6 +2599,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 			d.timerSpeed.Stop()
 			d.updateDeviceSpeed()
 		}
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -2532,9 +2632,9 @@ func (d *Device) DeleteDeviceProfile(profileName string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -2732,7 +2832,7 @@ func (d *Device) setLcdRotation() {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -2976,9 +3076,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 			LedChannels:  uint32(lightChannels),
 			WriteColorEx: d.writeColorCluster,
 		}
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	} else {
-		cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+		cluster.RemoveDeviceControllerBySerial(d.Serial)
 	}
 	return 1
 }
@@ -3103,6 +3203,17 @@ func (d *Device) UpdateDeviceMetrics() {
 			TemperatureProbe: strconv.FormatBool(device.IsTemperatureProbe),
 		}
 		metrics.Populate(header)
+
+		if d.HasLCD && device.ContainsPump && d.DeviceProfile != nil {
+			metrics.PopulateLcd(&metrics.Lcd{
+				Product:    d.Product,
+				Serial:     d.Serial,
+				ChannelId:  header.ChannelId,
+				Mode:       d.DeviceProfile.LCDMode,
+				Rotation:   d.DeviceProfile.LCDRotation,
+				Brightness: d.DeviceProfile.LCDBrightness,
+			})
+		}
 	}
 }
 
@@ -3169,7 +3280,7 @@ func (d *Device) saveDeviceProfile() {
 
 	noOverride := false
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	rgbProfiles := make(map[int]string, len(d.Devices))
@@ -3321,6 +3432,7 @@ func (d *Device) saveDeviceProfile() {
 			deviceProfile.BrightnessSlider = d.DeviceProfile.BrightnessSlider
 		}
 		deviceProfile.OriginalBrightness = d.DeviceProfile.OriginalBrightness
+		deviceProfile.Calibration = d.DeviceProfile.Calibration
 
 		if d.DeviceProfile.CustomLEDs == nil {
 			for i := 1; i < 7; i++ {
@@ -3351,7 +3463,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
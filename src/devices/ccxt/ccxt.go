@@ -8,13 +8,17 @@ import (
 	"OpenLinkHub/src/cluster"
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/coolant"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/fanhealth"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/metrics"
 	"OpenLinkHub/src/openrgb"
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -27,12 +31,9 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/sstallion/go-hid"
 )
 
 var (
-	pwd                        = ""
 	cmdOpenEndpoint            = []byte{0x0d, 0x01}
 	cmdOpenColorEndpoint       = []byte{0x0d, 0x00}
 	cmdCloseEndpoint           = []byte{0x05, 0x01, 0x01}
@@ -157,6 +158,7 @@ type DeviceProfile struct {
 	OpenRGBIntegration      bool
 	RGBCluster              bool
 	RgbOff                  bool
+	Calibration             map[int]*temperatures.FanCalibration
 }
 
 type TemperatureProbe struct {
@@ -239,9 +241,6 @@ type Device struct {
 
 // Init will initialize a new device
 func Init(vendorId, productId uint16, serial, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := hid.Open(vendorId, productId, serial)
 	if err != nil {
@@ -364,6 +363,8 @@ func (d *Device) Stop() {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -401,6 +402,8 @@ func (d *Device) StopDirty() uint8 {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -424,7 +427,7 @@ func (d *Device) StopDirty() uint8 {
 
 // loadExternalDevices will load external device definitions
 func (d *Device) loadExternalDevices() {
-	externalDevicesFile := pwd + "/database/external/ccxt.json"
+	externalDevicesFile := config.GetDatabasePath() + "/external/ccxt.json"
 	if common.FileExists(externalDevicesFile) {
 		file, err := os.Open(externalDevicesFile)
 		if err != nil {
@@ -526,7 +529,7 @@ func (d *Device) loadExternalDevices() {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -616,7 +619,7 @@ func (d *Device) GetDeviceTemplate() string {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -727,6 +730,7 @@ func (d *Device) setHardwareMode() {
 	if err != nil {
 		logger.Log(logger.Fields{"error": err}).Fatal("Unable to change device mode")
 	}
+	metrics.SetMode(d.Serial, metrics.ModeHardware)
 }
 
 // setSoftwareMode will switch a device to software mode
@@ -735,6 +739,7 @@ func (d *Device) setSoftwareMode() {
 	if err != nil {
 		logger.Log(logger.Fields{"error": err}).Fatal("Unable to change device mode")
 	}
+	metrics.SetMode(d.Serial, metrics.ModeSoftware)
 }
 
 // getManufacturer will return device manufacturer
@@ -824,7 +829,7 @@ func (d *Device) setupClusterController() {
 		WriteColorEx: d.writeColorCluster,
 	}
 
-	cluster.Get().AddDeviceController(clusterController)
+	cluster.AddDeviceController(clusterController)
 }
 
 // setupOpenRGBController will create RGBController object for OpenRGB Client Integration
@@ -1367,6 +1372,24 @@ func (d *Device) getExternalLedDevice(index int) *ExternalLedDevice {
 	return nil
 }
 
+// protectChannels will switch all channels to critical speed profile when channel failure is reported
+// Commander Core XT has no pump or liquid temperature sensor, so it is protected only on channel failure, without RGB change
+func (d *Device) protectChannels() {
+	if d.DeviceProfile == nil {
+		return
+	}
+
+	fanhealth.Protect(d.Serial, d.Product, func() []coolant.Channel {
+		channels := make([]coolant.Channel, 0, len(d.Devices))
+		for key, device := range d.Devices {
+			if device.HasSpeed {
+				channels = append(channels, coolant.Channel{Profile: &d.Devices[key].Profile, SavedProfile: d.DeviceProfile.SpeedProfiles[key]})
+			}
+		}
+		return channels
+	})
+}
+
 // setDefaults will set default mode for all devices
 func (d *Device) getDeviceData() {
 	if d.Exit {
@@ -1444,6 +1467,13 @@ func (d *Device) getDeviceData() {
 		m++
 	}
 
+	// Channel health
+	for key, value := range d.Devices {
+		if value.HasSpeed {
+			fanhealth.Update(d.Serial, d.Product, key, value.Name, value.Rpm, value.ContainsPump)
+		}
+	}
+
 	// Update stats
 	for key, value := range d.Devices {
 		temperatureString := ""
@@ -1487,8 +1517,11 @@ func (d *Device) setAutoRefresh() {
 				if d.Exit {
 					return
 				}
+				start := time.Now()
 				d.setTemperatures()
 				d.getDeviceData()
+				d.protectChannels()
+				metrics.ObserveLoop(d.Serial, start)
 			case <-d.autoRefreshChan:
 				d.timer.Stop()
 				return
@@ -1684,7 +1717,7 @@ func (d *Device) getTemperatureProbeTemperature(channelId int) float32 {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -1982,9 +2015,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 			WriteColorEx: d.writeColorCluster,
 		}
 
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	} else {
-		cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+		cluster.RemoveDeviceControllerBySerial(d.Serial)
 	}
 	return 1
 }
@@ -2672,7 +2705,7 @@ func (d *Device) saveDeviceProfile() {
 
 	noOverride := false
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	rgbProfiles := make(map[int]string, len(d.Devices))
@@ -2825,6 +2858,7 @@ func (d *Device) saveDeviceProfile() {
 			deviceProfile.BrightnessSlider = d.DeviceProfile.BrightnessSlider
 		}
 		deviceProfile.OriginalBrightness = d.DeviceProfile.OriginalBrightness
+		deviceProfile.Calibration = d.DeviceProfile.Calibration
 
 		if d.DeviceProfile.CustomLEDs == nil {
 			for i := 0; i < 6; i++ {
@@ -2856,7 +2890,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -2877,6 +2911,12 @@ func (d *Device) setSpeed(data map[int]byte, mode uint8) {
 		return
 	}
 
+	if mode == 0 {
+		for channelId, duty := range data {
+			fanhealth.SetDuty(d.Serial, channelId, duty)
+		}
+	}
+
 	buffer := make([]byte, len(data)*4+1)
 	buffer[0] = byte(len(data))
 	i := 1
@@ -3055,12 +3095,27 @@ func (d *Device) updateDeviceSpeed() {
 						temp = 50
 					}
 
-					if config.GetConfig().GraphProfiles {
+					if profiles.Pid.Enabled {
+						duty := temperatures.GetPidSpeed(d.Serial, device.ChannelId, device.Profile, profiles, temp, device.ContainsPump)
+						cp := fmt.Sprintf("%s-%d-pid-%d", device.Profile, device.ChannelId, duty)
+						if ok := tmp[device.ChannelId]; ok != cp {
+							tmp[device.ChannelId] = cp
+							channelSpeeds[device.ChannelId] = byte(duty)
+							d.setSpeed(channelSpeeds, 0)
+						}
+
+						if d.Debug {
+							logger.Log(logger.Fields{"serial": d.Serial, "duty": duty, "temp": temp, "setpoint": profiles.Pid.Setpoint, "device": device.Name, "zeroRpm": profiles.ZeroRpm}).Info("updateDeviceSpeed()")
+						}
+					} else if config.GetConfig().GraphProfiles {
 						pumpValue := temperatures.Interpolate(profiles.Points[0], temp)
 						fansValue := temperatures.Interpolate(profiles.Points[1], temp)
 
 						pump := int(math.Round(float64(pumpValue)))
 						fans := int(math.Round(float64(fansValue)))
+						if profiles.Rpm {
+							fans = temperatures.RpmToDuty(d.getCalibration(device.ChannelId), fans)
+						}
 
 						// Failsafe
 						if fans < 20 && !profiles.ZeroRpm {
@@ -3104,6 +3159,10 @@ func (d *Device) updateDeviceSpeed() {
 										profile.Mode = 0
 									}
 
+									if profiles.Rpm {
+										profile.Fans = uint16(temperatures.RpmToDuty(d.getCalibration(device.ChannelId), int(profile.Fans)))
+									}
+
 									if profile.Fans < 20 && !profiles.ZeroRpm {
 										profile.Fans = 20
 									}
@@ -3154,6 +3213,32 @@ func (d *Device) UpdateDeviceSpeed(channelId int, value uint16) uint8 {
 	return 0
 }
 
+// SetChannelCalibration will save fan calibration of a device channel
+func (d *Device) SetChannelCalibration(channelId int, calibration *temperatures.FanCalibration) uint8 {
+	if d.DeviceProfile == nil {
+		return 0
+	}
+
+	if _, ok := d.Devices[channelId]; !ok {
+		return 0
+	}
+
+	if d.DeviceProfile.Calibration == nil {
+		d.DeviceProfile.Calibration = make(map[int]*temperatures.FanCalibration)
+	}
+	d.DeviceProfile.Calibration[channelId] = calibration
+	d.saveDeviceProfile()
+	return 1
+}
+
+// getCalibration will return fan calibration of a device channel, or nil if channel is not calibrated
+func (d *Device) getCalibration(channelId int) *temperatures.FanCalibration {
+	if d.DeviceProfile == nil || d.DeviceProfile.Calibration == nil {
+		return nil
+	}
+	return d.DeviceProfile.Calibration[channelId]
+}
+
 // UpdateDeviceLabel will set / update device label
 func (d *Device) UpdateDeviceLabel(channelId int, label string) uint8 {
 	if _, ok := d.Devices[channelId]; !ok {
@@ -3180,9 +3265,9 @@ func (d *Device) UpdateRGBDeviceLabel(channelId int, label string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -3297,6 +3382,9 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 		}
 
 		newProfile := profile
+		if newProfile.Calibration == nil {
+			newProfile.Calibration = d.DeviceProfile.Calibration
+		}
 		newProfile.Active = true
 		d.DeviceProfile = newProfile
 		d.saveDeviceProfile()
@@ -3305,6 +3393,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 			d.timerSpeed.Stop()
 			d.updateDeviceSpeed()
 		}
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -9,12 +9,14 @@ import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/metrics"
 	"OpenLinkHub/src/openrgb"
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -27,12 +29,9 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/sstallion/go-hid"
 )
 
 var (
-	pwd                        = ""
 	cmdOpenEndpoint            = []byte{0x0d, 0x01}
 	cmdOpenColorEndpoint       = []byte{0x0d, 0x00}
 	cmdCloseEndpoint           = []byte{0x05, 0x01, 0x01}
@@ -237,9 +236,6 @@ type Device struct {
 
 // Init will initialize a new device
 func Init(vendorId, productId uint16, serial, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := hid.Open(vendorId, productId, serial)
 	if err != nil {
@@ -400,7 +396,7 @@ func (d *Device) StopDirty() uint8 {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -490,7 +486,7 @@ func (d *Device) GetDeviceTemplate() string {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -698,7 +694,7 @@ func (d *Device) setupClusterController() {
 		WriteColorEx: d.writeColorCluster,
 	}
 
-	cluster.Get().AddDeviceController(clusterController)
+	cluster.AddDeviceController(clusterController)
 }
 
 // setupOpenRGBController will create RGBController object for OpenRGB Client Integration
@@ -1497,7 +1493,7 @@ func (d *Device) getTemperatureProbeTemperature(channelId int) float32 {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -1795,9 +1791,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 			WriteColorEx: d.writeColorCluster,
 		}
 
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	} else {
-		cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+		cluster.RemoveDeviceControllerBySerial(d.Serial)
 	}
 	return 1
 }
@@ -2164,7 +2160,7 @@ func (d *Device) saveDeviceProfile() {
 
 	noOverride := false
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	rgbProfiles := make(map[int]string, len(d.Devices))
@@ -2350,7 +2346,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -2682,9 +2678,9 @@ func (d *Device) UpdateRGBDeviceLabel(channelId int, label string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -2806,6 +2802,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 			d.timerSpeed.Stop()
 			d.updateDeviceSpeed()
 		}
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -9,12 +9,15 @@ import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/dispatcher"
+	"OpenLinkHub/src/heatmap"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/inputmanager"
 	"OpenLinkHub/src/keyboards"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/macro"
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -28,7 +31,6 @@ import (
 	"time"
 
 	"crypto/rand"
-	"github.com/sstallion/go-hid"
 )
 
 type KeyPos struct {
@@ -118,7 +120,6 @@ type Device struct {
 }
 
 var (
-	pwd                   = ""
 	cmdLogin              = []byte{0x1b, 0x01}
 	cmdSoftwareMode       = []byte{0x01, 0x03, 0x00, 0x02}
 	cmdHardwareMode       = []byte{0x01, 0x03, 0x00, 0x01}
@@ -166,7 +167,7 @@ var (
 	keyboardKey             = "clipperpromini60-default"
 	defaultLayout           = "clipperpromini60-default-US"
 	keyAssignmentLength     = 137
-	rgbProfileUpgrade       = []string{"gradient", "pastelrainbow", "pastelspiralrainbow"}
+	rgbProfileUpgrade       = []string{"gradient", "pastelrainbow", "pastelspiralrainbow", "heatmap"}
 	keyActuations           = []byte{
 		0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
 		0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13,
@@ -197,6 +198,7 @@ var (
 		"flickering",
 		"gpu-temperature",
 		"gradient",
+		"heatmap",
 		"keyboard",
 		"off",
 		"rainbow",
@@ -211,9 +213,6 @@ var (
 )
 
 func Init(vendorId, productId uint16, _, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	dev, err := hid.OpenPath(path)
 	if err != nil {
 		logger.Log(logger.Fields{"error": err, "vendorId": vendorId, "productId": productId, "path": path}).Error("Unable to open HID device")
@@ -653,7 +652,7 @@ func (d *Device) getKeyData(keyHash string) *keyboards.Key {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -924,7 +923,7 @@ func (d *Device) initLeds() {
 
 // saveDeviceProfile will save device profile for persistent configuration
 func (d *Device) saveDeviceProfile() {
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 	keyboardMap := make(map[string]*keyboards.Keyboard)
 
 	deviceProfile := &DeviceProfile{
@@ -1049,7 +1048,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -1067,7 +1066,7 @@ func (d *Device) saveDeviceProfile() {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -1182,7 +1181,7 @@ func (d *Device) UpdateDeviceLabel(_ int, label string) uint8 {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -1371,7 +1370,7 @@ func (d *Device) setupClusterController() {
 		WriteColorEx: d.writeColorCluster,
 	}
 
-	cluster.Get().AddDeviceController(clusterController)
+	cluster.AddDeviceController(clusterController)
 }
 
 // ProcessSetRgbCluster will update OpenRGB integration status
@@ -1396,9 +1395,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 			WriteColorEx: d.writeColorCluster,
 		}
 
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	} else {
-		cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+		cluster.RemoveDeviceControllerBySerial(d.Serial)
 	}
 	return 1
 }
@@ -1435,6 +1434,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 		d.saveDeviceProfile()
 		d.setDeviceColor()
 		d.setupPerformance()
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -1614,9 +1614,9 @@ func (d *Device) DeleteKeyboardProfile(profileName string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -2455,6 +2455,10 @@ func (d *Device) setDeviceColor() {
 						r.Colorwarp(&startTime, d.activeRgb)
 						buff = append(buff, r.Output...)
 					}
+				case "heatmap":
+					{
+						buff = append(buff, heatmap.Render(d.Serial, d.DeviceProfile.Keyboards[d.DeviceProfile.Profile], r, colorPacketLength)...)
+					}
 				}
 
 				if len(buff) == 0 {
@@ -2824,6 +2828,7 @@ func (d *Device) triggerKeyAssignment(value []byte, functionKey bool, modifierKe
 	d.ModifierIndex = val
 
 	if val.Cmp(big.NewInt(0)) > 0 {
+		heatmap.Track(d.Serial, d.Product, d.DeviceProfile.Keyboards[d.DeviceProfile.Profile], val.String())
 		key := d.getKeyData(val.String())
 		if key == nil {
 			return
@@ -9,12 +9,14 @@ import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/metrics"
 	"OpenLinkHub/src/openrgb"
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -28,7 +30,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/sstallion/go-hid"
 )
 
 type Shutdown struct {
@@ -234,7 +235,6 @@ var shutdown = map[int]Shutdown{
 }
 
 var (
-	pwd                        = ""
 	cmdGetState                = []byte{0xff, 0x00}
 	modeSetSpeed               = []byte{0x00, 0x03}
 	cmdState                   = byte(0x00)
@@ -305,9 +305,6 @@ var (
 )
 
 func Init(vendorId, productId uint16, _, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := hid.OpenFirst(vendorId, productId)
 	if err != nil {
@@ -479,7 +476,7 @@ func (d *Device) StopDirty() uint8 {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -604,7 +601,7 @@ func (d *Device) initLeds() {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -695,7 +692,7 @@ func (d *Device) setupClusterController() {
 		WriteColorEx: d.writeColorCluster,
 	}
 
-	cluster.Get().AddDeviceController(clusterController)
+	cluster.AddDeviceController(clusterController)
 }
 
 // setupOpenRGBController will create RGBController object for OpenRGB Client Integration
@@ -1137,7 +1134,7 @@ func (d *Device) saveDeviceProfile() {
 	noRgbPerLed := false
 
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	rgbProfiles := make(map[int]string, len(d.Devices))
@@ -1321,7 +1318,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -1439,6 +1436,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 			d.timerSpeed.Stop()
 			d.updateDeviceSpeed()
 		}
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -1471,9 +1469,9 @@ func (d *Device) DeleteDeviceProfile(profileName string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -1570,7 +1568,7 @@ func (d *Device) getTemperatureProbe() {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -1814,9 +1812,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 			WriteColorEx: d.writeColorCluster,
 		}
 
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	} else {
-		cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+		cluster.RemoveDeviceControllerBySerial(d.Serial)
 	}
 	return 1
 }
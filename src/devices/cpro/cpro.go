@@ -8,13 +8,17 @@ import (
 	"OpenLinkHub/src/cluster"
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/coolant"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/fanhealth"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/metrics"
 	"OpenLinkHub/src/openrgb"
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -27,8 +31,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/sstallion/go-hid"
 )
 
 // ExternalLedDevice contains a list of supported external-LED devices connected to a HUB
@@ -62,6 +64,7 @@ type DeviceProfile struct {
 	ExternalHubs       map[int]*ExternalHubData
 	Labels             map[int]string
 	RgbOff             bool
+	Calibration        map[int]*temperatures.FanCalibration
 }
 
 type TemperatureProbe struct {
@@ -137,7 +140,6 @@ type Devices struct {
 }
 
 var (
-	pwd                        = ""
 	cmdGetFirmware             = byte(0x02)
 	cmdInitDevice              = byte(0x03)
 	cmdGetConnectedFans        = byte(0x20)
@@ -198,9 +200,6 @@ var (
 
 // Init will initialize a new device
 func Init(vendorId, productId uint16, serial, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := hid.Open(vendorId, productId, serial)
 	if err != nil {
@@ -347,6 +346,8 @@ func (d *Device) Stop() {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -376,6 +377,8 @@ func (d *Device) StopDirty() uint8 {
 	d.Exit = true
 	logger.Log(logger.Fields{"serial": d.Serial, "product": d.Product}).Info("Stopping device (dirty)...")
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -396,7 +399,7 @@ func (d *Device) StopDirty() uint8 {
 
 // loadExternalDevices will load external device definitions
 func (d *Device) loadExternalDevices() {
-	externalDevicesFile := pwd + "/database/external/cpro.json"
+	externalDevicesFile := config.GetDatabasePath() + "/external/cpro.json"
 	if common.FileExists(externalDevicesFile) {
 		file, err := os.Open(externalDevicesFile)
 		if err != nil {
@@ -501,7 +504,7 @@ func (d *Device) loadExternalDevices() {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -591,7 +594,7 @@ func (d *Device) GetDeviceTemplate() string {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -743,6 +746,10 @@ func (d *Device) setSpeed(data map[int]byte) {
 	if d.Exit {
 		return
 	}
+
+	for channelId, duty := range data {
+		fanhealth.SetDuty(d.Serial, channelId, duty)
+	}
 	for channel, value := range data {
 		if d.Exit {
 			return
@@ -800,7 +807,7 @@ func (d *Device) getTemperatureProbe() {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -1020,7 +1027,7 @@ func (d *Device) setupClusterController() {
 			ChannelId:    int(externalHub.PortId),
 		}
 
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	}
 }
 
@@ -1134,9 +1141,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 				ChannelId:    int(externalHub.PortId),
 			}
 
-			cluster.Get().AddDeviceController(clusterController)
+			cluster.AddDeviceController(clusterController)
 		} else {
-			cluster.Get().RemoveDeviceControllerBySerial(serial)
+			cluster.RemoveDeviceControllerBySerial(serial)
 		}
 	}
 
@@ -1174,6 +1181,32 @@ func (d *Device) GetTemperatureProbes() *[]TemperatureProbe {
 	return d.TemperatureProbes
 }
 
+// SetChannelCalibration will save fan calibration of a device channel
+func (d *Device) SetChannelCalibration(channelId int, calibration *temperatures.FanCalibration) uint8 {
+	if d.DeviceProfile == nil {
+		return 0
+	}
+
+	if _, ok := d.Devices[channelId]; !ok {
+		return 0
+	}
+
+	if d.DeviceProfile.Calibration == nil {
+		d.DeviceProfile.Calibration = make(map[int]*temperatures.FanCalibration)
+	}
+	d.DeviceProfile.Calibration[channelId] = calibration
+	d.saveDeviceProfile()
+	return 1
+}
+
+// getCalibration will return fan calibration of a device channel, or nil if channel is not calibrated
+func (d *Device) getCalibration(channelId int) *temperatures.FanCalibration {
+	if d.DeviceProfile == nil || d.DeviceProfile.Calibration == nil {
+		return nil
+	}
+	return d.DeviceProfile.Calibration[channelId]
+}
+
 // UpdateDeviceLabel will set / update device label
 func (d *Device) UpdateDeviceLabel(channelId int, label string) uint8 {
 	if _, ok := d.Devices[channelId]; !ok {
@@ -1188,9 +1221,9 @@ func (d *Device) UpdateDeviceLabel(channelId int, label string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -1304,6 +1337,9 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 		}
 
 		newProfile := profile
+		if newProfile.Calibration == nil {
+			newProfile.Calibration = d.DeviceProfile.Calibration
+		}
 		newProfile.Active = true
 		d.DeviceProfile = newProfile
 		d.saveDeviceProfile()
@@ -1312,6 +1348,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 			d.timerSpeed.Stop()
 			d.updateDeviceSpeed()
 		}
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -1457,7 +1494,7 @@ func (d *Device) saveDeviceProfile() {
 	defer d.deviceLock.Unlock()
 
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	rgbProfiles := make(map[int]string, len(d.Devices))
@@ -1514,6 +1551,7 @@ func (d *Device) saveDeviceProfile() {
 		}
 		deviceProfile.ExternalHubs = d.DeviceProfile.ExternalHubs
 		deviceProfile.OriginalBrightness = d.DeviceProfile.OriginalBrightness
+		deviceProfile.Calibration = d.DeviceProfile.Calibration
 		deviceProfile.Active = d.DeviceProfile.Active
 		deviceProfile.Brightness = d.DeviceProfile.Brightness
 		deviceProfile.RGBCluster = d.DeviceProfile.RGBCluster
@@ -1529,7 +1567,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -1544,6 +1582,23 @@ func (d *Device) saveDeviceProfile() {
 	d.loadDeviceProfiles()
 }
 
+// protectChannels will switch all channels to critical speed profile when channel failure is reported
+func (d *Device) protectChannels() {
+	if d.DeviceProfile == nil {
+		return
+	}
+
+	fanhealth.Protect(d.Serial, d.Product, func() []coolant.Channel {
+		channels := make([]coolant.Channel, 0, len(d.Devices))
+		for key, device := range d.Devices {
+			if device.HasSpeed {
+				channels = append(channels, coolant.Channel{Profile: &d.Devices[key].Profile, SavedProfile: d.DeviceProfile.SpeedProfiles[key]})
+			}
+		}
+		return channels
+	})
+}
+
 // getDeviceData will fetch device data
 func (d *Device) getDeviceData() {
 	d.deviceLock.Lock()
@@ -1621,6 +1676,13 @@ func (d *Device) getDeviceData() {
 		d.RailVoltages[i] = &RailVoltage{Name: rail, Value: railVoltage}
 	}
 
+	// Channel health
+	for key, value := range d.Devices {
+		if value.HasSpeed {
+			fanhealth.Update(d.Serial, d.Product, key, value.Name, value.Rpm, value.ContainsPump)
+		}
+	}
+
 	// Update stats
 	for key, value := range d.Devices {
 		if value.Rpm > 0 || value.Temperature > 0 {
@@ -2344,8 +2406,11 @@ func (d *Device) setAutoRefresh() {
 				if d.Exit {
 					return
 				}
+				start := time.Now()
 				d.setTemperatures()
 				d.getDeviceData()
+				d.protectChannels()
+				metrics.ObserveLoop(d.Serial, start)
 			case <-d.autoRefreshChan:
 				d.timer.Stop()
 				return
@@ -2484,9 +2549,20 @@ func (d *Device) updateDeviceSpeed() {
 							temp = 50
 						}
 
-						if config.GetConfig().GraphProfiles {
+						if profiles.Pid.Enabled {
+							duty := temperatures.GetPidSpeed(d.Serial, device.ChannelId, device.Profile, profiles, temp, device.ContainsPump)
+							cp := fmt.Sprintf("%s-%d-pid-%d", device.Profile, device.ChannelId, duty)
+							if ok := tmp[device.ChannelId]; ok != cp {
+								tmp[device.ChannelId] = cp
+								channelSpeeds[device.ChannelId] = byte(duty)
+								d.setSpeed(channelSpeeds)
+							}
+						} else if config.GetConfig().GraphProfiles {
 							fansValue := temperatures.Interpolate(profiles.Points[1], temp)
 							fans := int(math.Round(float64(fansValue)))
+							if profiles.Rpm {
+								fans = temperatures.RpmToDuty(d.getCalibration(device.ChannelId), fans)
+							}
 
 							// Failsafe
 							if fans < 20 && !profiles.ZeroRpm {
@@ -2511,6 +2587,10 @@ func (d *Device) updateDeviceSpeed() {
 									if ok := tmp[device.ChannelId]; ok != cp {
 										tmp[device.ChannelId] = cp
 
+										if profiles.Rpm {
+											profile.Fans = uint16(temperatures.RpmToDuty(d.getCalibration(device.ChannelId), int(profile.Fans)))
+										}
+
 										if profile.Fans < 20 && !profiles.ZeroRpm {
 											profile.Fans = 20
 										}
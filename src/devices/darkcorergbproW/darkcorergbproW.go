@@ -15,6 +15,7 @@ import (
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -126,7 +127,6 @@ type Device struct {
 }
 
 var (
-	pwd                       = ""
 	cmdSoftwareMode           = []byte{0x01, 0x03, 0x00, 0x02}
 	cmdHardwareMode           = []byte{0x01, 0x03, 0x00, 0x01}
 	cmdSleepMode              = []byte{0x01, 0x03, 0x00, 0x04}
@@ -173,9 +173,6 @@ var (
 )
 
 func Init(vendorId, slipstreamId, productId uint16, dev *common.Slipstream, endpoint byte, serial string) *Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Init new struct with HID device
 	d := &Device{
 		dev:          dev,
@@ -223,7 +220,7 @@ func Init(vendorId, slipstreamId, productId uint16, dev *common.Slipstream, endp
 			11: "Profile Switch",
 		},
 		InputActions:      inputmanager.GetInputActions(),
-		keyAssignmentFile: "/database/key-assignments/darkcorergbproW.json",
+		keyAssignmentFile: "/key-assignments/darkcorergbproW.json",
 		MacroTracker:      make(map[int]uint16),
 		MinDPI:            minDpiValue,
 		MaxDPI:            maxDpiValue,
@@ -313,7 +310,7 @@ func (d *Device) StopDirty() uint8 {
 func (d *Device) SetConnected(value bool) {
 	if d.Connected {
 		if !value {
-			cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+			cluster.RemoveDeviceControllerBySerial(d.Serial)
 			openrgb.RemoveDeviceControllerBySerial(d.Serial)
 		}
 
@@ -374,7 +371,7 @@ func (d *Device) Connect() {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -486,6 +483,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 		d.toggleDPI()
 		d.loadKeyAssignments()
 		d.setupKeyAssignment()
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -557,7 +555,7 @@ func (d *Device) rotateDeviceProfile() {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -733,7 +731,7 @@ func (d *Device) setupClusterController() {
 		WriteColorEx: d.writeColorCluster,
 	}
 
-	cluster.Get().AddDeviceController(clusterController)
+	cluster.AddDeviceController(clusterController)
 }
 
 // setupOpenRGBController will create RGBController object for OpenRGB Client Integration
@@ -816,9 +814,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 			WriteColorEx: d.writeColorCluster,
 		}
 
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	} else {
-		cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+		cluster.RemoveDeviceControllerBySerial(d.Serial)
 	}
 	return 1
 }
@@ -880,10 +878,10 @@ func (d *Device) SchedulerBrightness(value uint8) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 		keyAssignmentHash := common.GenerateRandomMD5()
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 		newProfile.KeyAssignmentHash = keyAssignmentHash
@@ -912,7 +910,13 @@ func (d *Device) SaveUserProfile(profileName string) uint8 {
 			logger.Log(logger.Fields{"error": err, "location": newProfile.Path}).Error("Unable to close file handle")
 			return 0
 		}
-		d.saveKeyAssignments()
+
+		// Key assignments of the new profile, current profile keeps its own
+		keyAssignmentsFile := config.GetDatabasePath() + fmt.Sprintf("/key-assignments/%s.json", keyAssignmentHash)
+		if err = common.SaveJsonData(keyAssignmentsFile, d.KeyAssignment); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": keyAssignmentsFile}).Error("Unable to write key assignment data")
+			return 0
+		}
 		d.loadDeviceProfiles()
 		return 1
 	}
@@ -1128,7 +1132,7 @@ func (d *Device) getDeviceFirmware() {
 // saveDeviceProfile will save device profile for persistent configuration
 func (d *Device) saveDeviceProfile() {
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	deviceProfile := &DeviceProfile{
 		Product:            d.Product,
@@ -1330,7 +1334,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -1393,10 +1397,10 @@ func (d *Device) UpdateDeviceKeyAssignment(keyIndex int, keyAssignment inputmana
 }
 
 func (d *Device) saveKeyAssignments() {
-	keyAssignmentsFile := pwd + d.keyAssignmentFile
+	keyAssignmentsFile := config.GetDatabasePath() + d.keyAssignmentFile
 	if len(d.DeviceProfile.KeyAssignmentHash) > 0 {
-		fileFormat := fmt.Sprintf("/database/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
-		keyAssignmentsFile = pwd + fileFormat
+		fileFormat := fmt.Sprintf("/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
+		keyAssignmentsFile = config.GetDatabasePath() + fileFormat
 	}
 
 	if err := common.SaveJsonData(keyAssignmentsFile, d.KeyAssignment); err != nil {
@@ -1410,10 +1414,10 @@ func (d *Device) loadKeyAssignments() {
 	if d.DeviceProfile == nil {
 		return
 	}
-	keyAssignmentsFile := pwd + d.keyAssignmentFile
+	keyAssignmentsFile := config.GetDatabasePath() + d.keyAssignmentFile
 	if len(d.DeviceProfile.KeyAssignmentHash) > 0 {
-		fileFormat := fmt.Sprintf("/database/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
-		keyAssignmentsFile = pwd + fileFormat
+		fileFormat := fmt.Sprintf("/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
+		keyAssignmentsFile = config.GetDatabasePath() + fileFormat
 	}
 
 	if common.FileExists(keyAssignmentsFile) {
@@ -1557,7 +1561,7 @@ func (d *Device) setSleepTimer() uint8 {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 	"OpenLinkHub/src/cluster"
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/inputmanager"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/macro"
@@ -15,6 +16,7 @@ import (
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -26,8 +28,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/sstallion/go-hid"
 )
 
 type ZoneColors struct {
@@ -130,7 +130,6 @@ type Device struct {
 }
 
 var (
-	pwd                   = ""
 	cmdSoftwareMode       = []byte{0x01, 0x03, 0x00, 0x02}
 	cmdHardwareMode       = []byte{0x01, 0x03, 0x00, 0x01}
 	cmdGetFirmware        = []byte{0x02, 0x13}
@@ -177,9 +176,6 @@ var (
 )
 
 func Init(vendorId, productId uint16, _, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	dev, err := hid.OpenPath(path)
 	if err != nil {
 		logger.Log(logger.Fields{"error": err, "vendorId": vendorId, "productId": productId, "path": path}).Error("Unable to open HID device")
@@ -241,7 +237,7 @@ func Init(vendorId, productId uint16, _, path string) *common.Device {
 			11: "Profile Switch",
 		},
 		InputActions:      inputmanager.GetInputActions(),
-		keyAssignmentFile: "/database/key-assignments/darkcorergbproW.json",
+		keyAssignmentFile: "/key-assignments/darkcorergbproW.json",
 		MacroTracker:      make(map[int]uint16),
 		MinDPI:            minDpiValue,
 		MaxDPI:            maxDpiValue,
@@ -401,7 +397,7 @@ func (d *Device) getSerial() {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -510,6 +506,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 		d.toggleDPI()
 		d.loadKeyAssignments()
 		d.setupKeyAssignment()
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -581,7 +578,7 @@ func (d *Device) rotateDeviceProfile() {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -747,7 +744,7 @@ func (d *Device) setupClusterController() {
 		WriteColorEx: d.writeColorCluster,
 	}
 
-	cluster.Get().AddDeviceController(clusterController)
+	cluster.AddDeviceController(clusterController)
 }
 
 // setupOpenRGBController will create RGBController object for OpenRGB Client Integration
@@ -824,9 +821,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 			WriteColorEx: d.writeColorCluster,
 		}
 
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	} else {
-		cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+		cluster.RemoveDeviceControllerBySerial(d.Serial)
 	}
 	return 1
 }
@@ -885,10 +882,10 @@ func (d *Device) SchedulerBrightness(value uint8) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 		keyAssignmentHash := common.GenerateRandomMD5()
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 		newProfile.KeyAssignmentHash = keyAssignmentHash
@@ -917,7 +914,13 @@ func (d *Device) SaveUserProfile(profileName string) uint8 {
 			logger.Log(logger.Fields{"error": err, "location": newProfile.Path}).Error("Unable to close file handle")
 			return 0
 		}
-		d.saveKeyAssignments()
+
+		// Key assignments of the new profile, current profile keeps its own
+		keyAssignmentsFile := config.GetDatabasePath() + fmt.Sprintf("/key-assignments/%s.json", keyAssignmentHash)
+		if err = common.SaveJsonData(keyAssignmentsFile, d.KeyAssignment); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": keyAssignmentsFile}).Error("Unable to write key assignment data")
+			return 0
+		}
 		d.loadDeviceProfiles()
 		return 1
 	}
@@ -1098,7 +1101,7 @@ func (d *Device) getDeviceFirmware() {
 // saveDeviceProfile will save device profile for persistent configuration
 func (d *Device) saveDeviceProfile() {
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	deviceProfile := &DeviceProfile{
 		Product:            d.Product,
@@ -1300,7 +1303,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -1360,10 +1363,10 @@ func (d *Device) UpdateDeviceKeyAssignment(keyIndex int, keyAssignment inputmana
 }
 
 func (d *Device) saveKeyAssignments() {
-	keyAssignmentsFile := pwd + d.keyAssignmentFile
+	keyAssignmentsFile := config.GetDatabasePath() + d.keyAssignmentFile
 	if len(d.DeviceProfile.KeyAssignmentHash) > 0 {
-		fileFormat := fmt.Sprintf("/database/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
-		keyAssignmentsFile = pwd + fileFormat
+		fileFormat := fmt.Sprintf("/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
+		keyAssignmentsFile = config.GetDatabasePath() + fileFormat
 	}
 
 	if err := common.SaveJsonData(keyAssignmentsFile, d.KeyAssignment); err != nil {
@@ -1377,10 +1380,10 @@ func (d *Device) loadKeyAssignments() {
 	if d.DeviceProfile == nil {
 		return
 	}
-	keyAssignmentsFile := pwd + d.keyAssignmentFile
+	keyAssignmentsFile := config.GetDatabasePath() + d.keyAssignmentFile
 	if len(d.DeviceProfile.KeyAssignmentHash) > 0 {
-		fileFormat := fmt.Sprintf("/database/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
-		keyAssignmentsFile = pwd + fileFormat
+		fileFormat := fmt.Sprintf("/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
+		keyAssignmentsFile = config.GetDatabasePath() + fileFormat
 	}
 
 	if common.FileExists(keyAssignmentsFile) {
@@ -1504,7 +1507,7 @@ func (d *Device) setAutoRefresh() {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
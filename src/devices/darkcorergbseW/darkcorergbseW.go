@@ -7,12 +7,14 @@ package darkcorergbseW
 import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/inputmanager"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/macro"
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -25,8 +27,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/sstallion/go-hid"
 )
 
 // DeviceProfile struct contains all device profile
@@ -121,7 +121,6 @@ type Device struct {
 }
 
 var (
-	pwd                   = ""
 	cmdSoftwareMode       = []byte{0x04, 0x02}
 	cmdHardwareMode       = []byte{0x04, 0x01}
 	cmdWriteColor         = []byte{0xaa}
@@ -149,9 +148,6 @@ var (
 )
 
 func Init(vendorId, productId uint16, dev *hid.Device, serial string) *Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Init new struct with HID device
 	d := &Device{
 		dev:       dev,
@@ -198,7 +194,7 @@ func Init(vendorId, productId uint16, dev *hid.Device, serial string) *Device {
 		Product:           "DARK CORE SE",
 		RGBModes:          rgbModes,
 		InputActions:      inputmanager.GetInputActions(),
-		keyAssignmentFile: "/database/key-assignments/darkcorergbse.json",
+		keyAssignmentFile: "/key-assignments/darkcorergbse.json",
 		MacroTracker:      make(map[int]uint16),
 		MinDPI:            minDpiValue,
 		MaxDPI:            maxDpiValue,
@@ -306,7 +302,7 @@ func (d *Device) StopDirty() uint8 {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -576,7 +572,7 @@ func (d *Device) setAutoRefresh() {
 // saveDeviceProfile will save device profile for persistent configuration
 func (d *Device) saveDeviceProfile() {
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	deviceProfile := &DeviceProfile{
 		Product:          d.Product,
@@ -717,7 +713,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -776,10 +772,10 @@ func (d *Device) UpdateDeviceKeyAssignment(keyIndex int, keyAssignment inputmana
 
 // saveKeyAssignments will save new key assignments
 func (d *Device) saveKeyAssignments() {
-	keyAssignmentsFile := pwd + d.keyAssignmentFile
+	keyAssignmentsFile := config.GetDatabasePath() + d.keyAssignmentFile
 	if len(d.DeviceProfile.KeyAssignmentHash) > 0 {
-		fileFormat := fmt.Sprintf("/database/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
-		keyAssignmentsFile = pwd + fileFormat
+		fileFormat := fmt.Sprintf("/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
+		keyAssignmentsFile = config.GetDatabasePath() + fileFormat
 	}
 
 	if err := common.SaveJsonData(keyAssignmentsFile, d.KeyAssignment); err != nil {
@@ -793,10 +789,10 @@ func (d *Device) loadKeyAssignments() {
 	if d.DeviceProfile == nil {
 		return
 	}
-	keyAssignmentsFile := pwd + d.keyAssignmentFile
+	keyAssignmentsFile := config.GetDatabasePath() + d.keyAssignmentFile
 	if len(d.DeviceProfile.KeyAssignmentHash) > 0 {
-		fileFormat := fmt.Sprintf("/database/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
-		keyAssignmentsFile = pwd + fileFormat
+		fileFormat := fmt.Sprintf("/key-assignments/%s.json", d.DeviceProfile.KeyAssignmentHash)
+		keyAssignmentsFile = config.GetDatabasePath() + fileFormat
 	}
 
 	if common.FileExists(keyAssignmentsFile) {
@@ -938,7 +934,7 @@ func (d *Device) setSleepTimer() {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -1029,10 +1025,10 @@ func (d *Device) getDeviceProfile() {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 		keyAssignmentHash := common.GenerateRandomMD5()
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 		newProfile.KeyAssignmentHash = keyAssignmentHash
@@ -1061,7 +1057,13 @@ func (d *Device) SaveUserProfile(profileName string) uint8 {
 			logger.Log(logger.Fields{"error": err, "location": newProfile.Path}).Error("Unable to close file handle")
 			return 0
 		}
-		d.saveKeyAssignments()
+
+		// Key assignments of the new profile, current profile keeps its own
+		keyAssignmentsFile := config.GetDatabasePath() + fmt.Sprintf("/key-assignments/%s.json", keyAssignmentHash)
+		if err = common.SaveJsonData(keyAssignmentsFile, d.KeyAssignment); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": keyAssignmentsFile}).Error("Unable to write key assignment data")
+			return 0
+		}
 		d.loadDeviceProfiles()
 		return 1
 	}
@@ -1232,6 +1234,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 		d.toggleDPI(false)
 		d.loadKeyAssignments()
 		d.setupKeyAssignment()
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -1303,7 +1306,7 @@ func (d *Device) rotateDeviceProfile() {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -8,10 +8,10 @@ import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/devices/darkcorergbseW"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"encoding/binary"
 	"fmt"
-	"github.com/sstallion/go-hid"
 	"strconv"
 	"sync"
 	"time"
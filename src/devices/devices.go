@@ -122,6 +122,7 @@ import (
 	"OpenLinkHub/src/devices/voidelitedongle"
 	"OpenLinkHub/src/devices/xc7"
 	"OpenLinkHub/src/dispatcher"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/inputmanager"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/metrics"
@@ -131,12 +132,15 @@ import (
 	"OpenLinkHub/src/smbus"
 	"OpenLinkHub/src/usb"
 	"OpenLinkHub/src/version"
-	"github.com/sstallion/go-hid"
+	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"reflect"
 	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -181,6 +185,9 @@ var (
 func Stop() {
 	// Stop all cluster operations
 	cls.Stop()
+	for _, c := range cluster.GetNamedClusters() {
+		c.Stop()
+	}
 
 	for _, device := range devices {
 		CallDeviceMethod(device.Serial, "Stop")
@@ -205,7 +212,7 @@ func StopDirty(deviceId string, productId uint16) {
 	if config.GetConfig().EnableOpenRGBTargetServer {
 		openrgb.RemoveDeviceControllerBySerial(device.Serial)
 	}
-	cluster.Get().RemoveDeviceControllerBySerial(device.Serial)
+	cluster.RemoveDeviceControllerBySerial(device.Serial)
 
 	res := CallDeviceMethod(device.Serial, "StopDirty")
 	if res != nil {
@@ -263,6 +270,16 @@ func ScheduleDeviceBrightness(mode uint8) {
 	}
 }
 
+// ScheduleClusterBrightness will change brightness level of given RGB clusters based on scheduler
+func ScheduleClusterBrightness(mode uint8, clusters []string) {
+	for _, serial := range clusters {
+		if cluster.GetCluster(serial) == nil {
+			continue
+		}
+		CallDeviceMethod(serial, "SchedulerBrightness", mode)
+	}
+}
+
 // ScheduleDeviceLcdBrightness will change LCD backlight brightness based on scheduler
 func ScheduleDeviceLcdBrightness(mode uint8) {
 	for _, device := range GetDevices() {
@@ -317,6 +334,76 @@ func ResetSpeedProfiles(profile string) {
 	}
 }
 
+// RestoreSpeedProfile will replace channel speed profile in saved profiles of a device, when it matches current profile.
+// It runs before devices are loaded, to undo temporary profiles left by interrupted speed override or calibration
+func RestoreSpeedProfile(serial string, channelId int, current, previous string) int {
+	key := strconv.Itoa(channelId)
+	return UpdateSavedProfiles(serial, func(profile map[string]interface{}) bool {
+		speedProfiles, ok := profile["SpeedProfiles"].(map[string]interface{})
+		if !ok || speedProfiles[key] != current {
+			return false
+		}
+		speedProfiles[key] = previous
+		return true
+	})
+}
+
+// UpdateSavedProfiles will apply update to device profile and all user profiles of a device saved on disk and return
+// number of written profiles. Profiles are written only when update returns true
+func UpdateSavedProfiles(serial string, update func(profile map[string]interface{}) bool) int {
+	folder := filepath.Join(config.GetDatabasePath(), "profiles")
+	files, err := os.ReadDir(folder)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "location": folder}).Error("Unable to read content of a folder")
+		return 0
+	}
+
+	updated := 0
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || filepath.Ext(name) != ".json" || (name != serial+".json" && !strings.HasPrefix(name, serial+"-")) {
+			continue
+		}
+		if updateProfileFile(filepath.Join(folder, name), update) {
+			updated++
+		}
+	}
+	return updated
+}
+
+// UpdateUserProfile will apply update to a single user profile of a device saved on disk
+func UpdateUserProfile(serial, profileName string, update func(profile map[string]interface{}) bool) bool {
+	location := filepath.Join(config.GetDatabasePath(), "profiles", serial+"-"+profileName+".json")
+	return updateProfileFile(location, update)
+}
+
+// updateProfileFile will decode profile at location, apply update and write it back when update returns true
+func updateProfileFile(location string, update func(profile map[string]interface{}) bool) bool {
+	buf, err := os.ReadFile(location)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to read device profile")
+		return false
+	}
+
+	profile := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	if err = decoder.Decode(&profile); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to decode device profile")
+		return false
+	}
+
+	if !update(profile) {
+		return false
+	}
+
+	if err = common.SaveJsonData(location, profile); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to write device profile data")
+		return false
+	}
+	return true
+}
+
 // GetDevicesLedData will return led data for all devices
 func GetDevicesLedData() interface{} {
 	var leds []interface{}
@@ -369,6 +456,7 @@ func deleteDevice(serial string) {
 	mutex.Lock()
 	defer mutex.Unlock()
 	delete(devices, serial)
+	metrics.SetConnected(serial, "", false)
 }
 
 // addDevice will add device to device list
@@ -380,10 +468,37 @@ func addDevice(device *common.Device) {
 	mutex.Lock()
 	devices[device.Serial] = device
 	mutex.Unlock()
+	metrics.SetConnected(device.Serial, device.Product, true)
 
 	CallDeviceMethod(device.Serial, "SetDispatcher", Dispatch)
 }
 
+// AddRgbCluster will create a new named RGB cluster and register it as a device
+func AddRgbCluster(name string) (string, uint8) {
+	c, status := cluster.NewCluster(name)
+	if status != 1 {
+		return "", status
+	}
+
+	addDevice(&common.Device{
+		ProductType: common.ProductTypeCluster,
+		Product:     c.Product,
+		Serial:      c.Serial,
+		Hidden:      true,
+		Instance:    c,
+	})
+	return c.Serial, 1
+}
+
+// DeleteRgbCluster will delete a named RGB cluster and remove it from device list
+func DeleteRgbCluster(serial string) uint8 {
+	status := cluster.DeleteCluster(serial)
+	if status == 1 {
+		deleteDevice(serial)
+	}
+	return status
+}
+
 // CallDeviceMethod will call device method based on method name and arguments
 func CallDeviceMethod(deviceId string, methodName string, args ...interface{}) []reflect.Value {
 	mutex.Lock()
@@ -408,6 +523,81 @@ func CallDeviceMethod(deviceId string, methodName string, args ...interface{}) [
 	return method.Call(reflectArgs)
 }
 
+// HasMethod will return true if device has a method with given argument kinds
+func HasMethod(deviceId string, methodName string, kinds ...reflect.Kind) bool {
+	instance := GetDevice(deviceId)
+	if instance == nil {
+		return false
+	}
+
+	method := reflect.ValueOf(instance).MethodByName(methodName)
+	if !method.IsValid() || method.Type().NumIn() != len(kinds) {
+		return false
+	}
+	for i, kind := range kinds {
+		if method.Type().In(i).Kind() != kind {
+			return false
+		}
+	}
+	return true
+}
+
+// GetMethodStatus will return status code of CallDeviceMethod results, or 255 when method returned no status
+func GetMethodStatus(results []reflect.Value) uint64 {
+	if len(results) == 0 || !results[0].CanUint() {
+		return 255
+	}
+	return results[0].Uint()
+}
+
+// GetUserProfiles will return sorted user profile names of a device
+func GetUserProfiles(deviceId string) []string {
+	users := getUserProfiles(deviceId)
+	if !users.IsValid() {
+		return nil
+	}
+
+	profiles := make([]string, 0, users.Len())
+	for _, key := range users.MapKeys() {
+		profiles = append(profiles, key.String())
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// GetActiveProfile will return name of active user profile of a device
+func GetActiveProfile(deviceId string) string {
+	users := getUserProfiles(deviceId)
+	if !users.IsValid() {
+		return ""
+	}
+
+	for _, key := range users.MapKeys() {
+		profile := reflect.Indirect(users.MapIndex(key))
+		if profile.Kind() != reflect.Struct {
+			continue
+		}
+		if active := profile.FieldByName("Active"); active.IsValid() && active.Kind() == reflect.Bool && active.Bool() {
+			return key.String()
+		}
+	}
+	return ""
+}
+
+// getUserProfiles will return UserProfiles map of a device
+func getUserProfiles(deviceId string) reflect.Value {
+	instance := reflect.Indirect(reflect.ValueOf(GetDevice(deviceId)))
+	if instance.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+
+	users := instance.FieldByName("UserProfiles")
+	if !users.IsValid() || users.Kind() != reflect.Map || users.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}
+	}
+	return users
+}
+
 // GetProducts will return all available products
 func GetProducts() map[string]Device {
 	return deviceList
@@ -666,6 +856,15 @@ func Init() {
 		Hidden:      true,
 		Instance:    cls,
 	}
+	for _, c := range cluster.GetNamedClusters() {
+		devices[c.Serial] = &common.Device{
+			ProductType: common.ProductTypeCluster,
+			Product:     c.Product,
+			Serial:      c.Serial,
+			Hidden:      true,
+			Instance:    c,
+		}
+	}
 
 	// Legacy devices
 	res := usb.Init(legacyDevices)
@@ -8,13 +8,17 @@ import (
 	"OpenLinkHub/src/cluster"
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/coolant"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/fanhealth"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/metrics"
 	"OpenLinkHub/src/openrgb"
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -28,7 +32,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/sstallion/go-hid"
 )
 
 type Shutdown struct {
@@ -226,7 +229,6 @@ var shutdown = map[int]Shutdown{
 }
 
 var (
-	pwd                        = ""
 	cmdGetState                = []byte{0xff, 0x00}
 	modeSetSpeed               = []byte{0x00, 0x03}
 	cmdState                   = byte(0x00)
@@ -338,9 +340,6 @@ var (
 )
 
 func Init(vendorId, productId uint16, _, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := hid.OpenFirst(vendorId, productId)
 	if err != nil {
@@ -447,6 +446,8 @@ func (d *Device) Stop() {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -486,6 +487,8 @@ func (d *Device) StopDirty() uint8 {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -511,7 +514,7 @@ func (d *Device) StopDirty() uint8 {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -636,7 +639,7 @@ func (d *Device) initLeds() {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -727,7 +730,7 @@ func (d *Device) setupClusterController() {
 		WriteColorEx: d.writeColorCluster,
 	}
 
-	cluster.Get().AddDeviceController(clusterController)
+	cluster.AddDeviceController(clusterController)
 }
 
 // setupOpenRGBController will create RGBController object for OpenRGB Client Integration
@@ -1179,7 +1182,7 @@ func (d *Device) saveDeviceProfile() {
 	noRgbPerLed := false
 
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	rgbProfiles := make(map[int]string, len(d.Devices))
@@ -1344,7 +1347,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -1455,6 +1458,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 			d.timerSpeed.Stop()
 			d.updateDeviceSpeed()
 		}
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -1487,9 +1491,9 @@ func (d *Device) DeleteDeviceProfile(profileName string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -1586,7 +1590,7 @@ func (d *Device) getTemperatureProbe() {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -1825,9 +1829,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 			WriteColorEx: d.writeColorCluster,
 		}
 
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	} else {
-		cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+		cluster.RemoveDeviceControllerBySerial(d.Serial)
 	}
 	return 1
 }
@@ -2072,6 +2076,7 @@ func (d *Device) getDeviceData() {
 			if rpm > 0 {
 				d.Devices[deviceList[device].Index].Rpm = rpm
 			}
+			fanhealth.Update(d.Serial, d.Product, deviceList[device].Index, d.Devices[deviceList[device].Index].Name, int16(rpm), deviceList[device].Pump)
 
 			if temperature > 0 {
 				temp := math.Floor(temperature*100) / 100
@@ -2112,6 +2117,7 @@ func (d *Device) setAutoRefresh() {
 				}
 				d.setTemperatures()
 				d.getDeviceData()
+				d.protectLiquidCooler()
 			case <-d.autoRefreshChan:
 				d.timer.Stop()
 				return
@@ -2296,6 +2302,13 @@ func (d *Device) setSpeed(data map[int]*SpeedMode) {
 
 	buf := d.deviceSpeedPacket(buffer)
 	d.transfer(modeSetSpeed[0], buf)
+
+	// Pump is set by pump mode, so only fans are checked against duty
+	for key, value := range data {
+		if value != nil && !value.Pump {
+			fanhealth.SetDuty(d.Serial, key, value.Value)
+		}
+	}
 }
 
 // ResetSpeedProfiles will reset channel speed profile if it matches with the current speed profile
@@ -2329,6 +2342,38 @@ func (d *Device) getLiquidTemperature() float32 {
 	return 0
 }
 
+// protectLiquidCooler will try to protect your liquid cooler when the temperature reaches critical point
+func (d *Device) protectLiquidCooler() {
+	if d.DeviceProfile == nil {
+		return
+	}
+
+	if !coolant.Protect(d.Serial, d.Product, d.getLiquidTemperature(), func() []coolant.Channel {
+		channels := make([]coolant.Channel, 0, len(d.Devices))
+		for key, device := range d.Devices {
+			channel := coolant.Channel{}
+			if device.HasSpeed {
+				channel.Profile = &d.Devices[key].Profile
+				channel.SavedProfile = d.DeviceProfile.SpeedProfiles[key]
+			}
+			if device.LedChannels > 0 {
+				channel.RGB = &d.Devices[key].RGB
+				channel.SavedRGB = d.DeviceProfile.RGBProfiles[key]
+			}
+			channels = append(channels, channel)
+		}
+		return channels
+	}) {
+		return
+	}
+
+	if d.activeRgb != nil {
+		d.activeRgb.Exit <- true
+		d.activeRgb = nil
+	}
+	d.setDeviceColor()
+}
+
 // updateDeviceSpeed will update device speed based on a temperature reading
 func (d *Device) updateDeviceSpeed() {
 	d.timerSpeed = time.NewTicker(time.Duration(temperaturePullingInterval) * time.Millisecond)
@@ -11,10 +11,10 @@ import (
 	"OpenLinkHub/src/devices/virtuosoSEW"
 	"OpenLinkHub/src/devices/virtuosoW"
 	"OpenLinkHub/src/devices/virtuosorgbXTW"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"encoding/binary"
 	"fmt"
-	"github.com/sstallion/go-hid"
 	"slices"
 	"strconv"
 	"strings"
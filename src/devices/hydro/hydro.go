@@ -7,7 +7,9 @@ package hydro
 import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/coolant"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/fanhealth"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
@@ -148,7 +150,6 @@ type Device struct {
 }
 
 var (
-	pwd                        = ""
 	cmdSetFanSpeed             = byte(0x11)
 	cmdSetPumpSpeed            = byte(0x13)
 	cmdSetConfiguration        = byte(0x10)
@@ -195,9 +196,6 @@ var (
 )
 
 func Init(vendorId, productId uint16, _, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := usb.Open(path)
 	if err != nil {
@@ -301,6 +299,8 @@ func (d *Device) Stop() {
 	d.Exit = true
 	logger.Log(logger.Fields{"serial": d.Serial, "product": d.Product}).Info("Stopping device...")
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -332,6 +332,8 @@ func (d *Device) StopDirty() uint8 {
 	logger.Log(logger.Fields{"serial": d.Serial, "product": d.Product}).Info("Stopping device (dirty)...")
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -553,7 +555,7 @@ func (d *Device) getTemperatureProbe() {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -617,7 +619,7 @@ func (d *Device) getDeviceFirmware() {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -701,7 +703,7 @@ func (d *Device) saveDeviceProfile() {
 	defer d.deviceLock.Unlock()
 
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	rgbProfiles := make(map[int]string, len(d.Devices))
@@ -756,7 +758,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -892,7 +894,7 @@ func (d *Device) getDevices() int {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -1014,6 +1016,7 @@ func (d *Device) getDeviceData() {
 			if rpm > 0 {
 				d.Devices[deviceList[device].Index].Rpm = rpm
 			}
+			fanhealth.Update(d.Serial, d.Product, deviceList[device].Index, d.Devices[deviceList[device].Index].Name, int16(rpm), deviceList[device].Pump)
 
 			if temp > 0 {
 				d.Devices[deviceList[device].Index].Temperature = temp
@@ -1053,6 +1056,7 @@ func (d *Device) setAutoRefresh() {
 				}
 				d.setTemperatures()
 				d.getDeviceData()
+				d.protectLiquidCooler()
 			case <-d.autoRefreshChan:
 				d.timer.Stop()
 				return
@@ -1066,7 +1070,11 @@ func (d *Device) setConfiguration() {
 	buf := make([]byte, 18)
 
 	profile := d.GetRgbProfile("static")
-	if profile == nil {
+	if coolant.IsCritical(d.Serial) {
+		buf[0] = byte(coolant.CriticalColor.Red)   // R
+		buf[1] = byte(coolant.CriticalColor.Green) // G
+		buf[2] = byte(coolant.CriticalColor.Blue)  // B
+	} else if profile == nil {
 		// Set to white if profile fails
 		buf[0] = 0xff // R
 		buf[1] = 0xff // G
@@ -1107,7 +1115,7 @@ func (d *Device) setSpeed(data map[int]*SpeedMode) {
 	if d.Exit {
 		return
 	}
-	for _, value := range data {
+	for key, value := range data {
 		if value.Pump {
 			// Pump
 			buf := make([]byte, 1)
@@ -1120,6 +1128,9 @@ func (d *Device) setSpeed(data map[int]*SpeedMode) {
 			buf[7] = value.Value
 			buf[8] = value.Value
 			d.transfer(cmdSetFanSpeed, buf)
+
+			// Pump is set by pump mode, so only fans are checked against duty
+			fanhealth.SetDuty(d.Serial, key, value.Value)
 		}
 	}
 }
@@ -1155,6 +1166,27 @@ func (d *Device) getLiquidTemperature() float32 {
 	return 0
 }
 
+// protectLiquidCooler will try to protect your liquid cooler when the temperature reaches critical point.
+// Pump LED supports static color only, so critical color is applied by setConfiguration
+func (d *Device) protectLiquidCooler() {
+	if d.DeviceProfile == nil {
+		return
+	}
+
+	if !coolant.Protect(d.Serial, d.Product, d.getLiquidTemperature(), func() []coolant.Channel {
+		channels := make([]coolant.Channel, 0, len(d.Devices))
+		for key, device := range d.Devices {
+			if device.HasSpeed {
+				channels = append(channels, coolant.Channel{Profile: &d.Devices[key].Profile, SavedProfile: d.DeviceProfile.SpeedProfiles[key]})
+			}
+		}
+		return channels
+	}) {
+		return
+	}
+	d.setConfiguration()
+}
+
 // getPumpMode will return byte pump mode based on a profile name
 func (d *Device) getPumpMode(index int, profile string) byte {
 	for device := range deviceList {
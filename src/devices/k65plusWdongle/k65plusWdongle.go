@@ -8,11 +8,11 @@ import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/devices/k65plusW"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/stats"
 	"encoding/binary"
 	"fmt"
-	"github.com/sstallion/go-hid"
 	"reflect"
 	"sync"
 	"time"
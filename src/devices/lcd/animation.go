@@ -39,7 +39,7 @@ var (
 )
 
 func InitAnimation() {
-	animationProfile := config.GetConfig().ConfigPath + "/database/lcd/animation.json"
+	animationProfile := config.GetDatabasePath() + "/lcd/animation.json"
 	if common.FileExists(animationProfile) {
 		file, err := os.Open(animationProfile)
 		if err != nil {
@@ -111,7 +111,7 @@ func InitAnimation() {
 		}
 	}
 
-	animationsFolder := pwd + "/database/lcd/images/"
+	animationsFolder := images
 	files, err := os.ReadDir(animationsFolder)
 	if err != nil {
 		logger.Log(logger.Fields{"error": err, "location": animationsFolder}).Error("Unable to read content of a folder")
@@ -231,7 +231,7 @@ func GetAnimation() *Animation {
 // SaveAnimation will save animation profile
 func SaveAnimation(value *Animation) uint8 {
 	animation = value
-	profile := config.GetConfig().ConfigPath + "/database/lcd/animation.json"
+	profile := config.GetDatabasePath() + "/lcd/animation.json"
 
 	if err := common.SaveJsonData(profile, animation); err != nil {
 		logger.Log(logger.Fields{"error": err, "location": profile}).Error("Unable to write lcd profile data")
@@ -6,7 +6,9 @@ package lcd
 
 import (
 	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/rgb"
 	"bytes"
@@ -29,7 +31,6 @@ import (
 
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
-	"github.com/sstallion/go-hid"
 	"golang.org/x/image/bmp"
 	"golang.org/x/image/draw"
 	"golang.org/x/image/font"
@@ -65,10 +66,9 @@ const (
 )
 
 var (
-	pwd, _       = os.Getwd()
-	location     = pwd + "/database/lcd/background.jpg"
-	images       = pwd + "/database/lcd/images/"
-	fontLocation = pwd + "/static/fonts/teko.ttf"
+	location     = ""
+	images       = ""
+	fontLocation = ""
 	mutex        sync.Mutex
 	imgWidth            = 480
 	imgHeight           = 480
@@ -124,6 +124,10 @@ var lcd LCD
 
 // Init will initialize LCD data
 func Init() {
+	pwd := config.GetConfig().ConfigPath
+	location = config.GetDatabasePath() + "/lcd/background.jpg"
+	images = config.GetDatabasePath() + "/lcd/images/"
+	fontLocation = pwd + "/static/fonts/teko.ttf"
 	lcdDevices = make(map[string]uint16)
 	lcdPresent = false
 
@@ -8,8 +8,11 @@ import (
 	"OpenLinkHub/src/cluster"
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/coolant"
 	"OpenLinkHub/src/dashboard"
 	"OpenLinkHub/src/devices/lcd"
+	"OpenLinkHub/src/fanhealth"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/led"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/metrics"
@@ -18,6 +21,7 @@ import (
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/systeminfo"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
@@ -32,8 +36,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/sstallion/go-hid"
 )
 
 type RGBOverride struct {
@@ -76,6 +78,7 @@ type DeviceProfile struct {
 	OpenRGBIntegration   bool
 	RGBCluster           bool
 	RgbOff               bool
+	Calibration          map[int]*temperatures.FanCalibration
 }
 
 // LinkAdapter contains a list of supported external-LED devices connected to a LINK adapter
@@ -242,7 +245,6 @@ type Device struct {
 }
 
 var (
-	pwd                         = ""
 	cmdOpenEndpoint             = []byte{0x0d, 0x01}
 	cmdOpenColorEndpoint        = []byte{0x0d, 0x00}
 	cmdCloseEndpoint            = []byte{0x05, 0x01, 0x01}
@@ -257,6 +259,7 @@ var (
 	cmdResetLedPower            = []byte{0x15, 0x01}
 	cmdDeviceCommandCodes       = []byte{0x1e}
 	cmdDeviceCommandLeds        = []byte{0x1d}
+	psuRails                    = map[int]string{0: "3.3V Rail", 1: "5V Rail", 2: "12V Rail"}
 	cmdLcdPower                 = []byte{0x03, 0x19, 0x00, 0x01}
 	cmdLcdOff                   = []byte{0x03, 0x0b, 0x00, 0x01}
 	cmdLcdBrightness            = []byte{0x03, 0x0b, 0x64, 0x01}
@@ -294,7 +297,6 @@ var (
 	portProtectionMaximumStage1 = 238
 	portProtectionMaximumStage2 = 340
 	portProtectionMaximumStage3 = 442
-	criticalAioCoolantTemp      = 57.0
 	i2cPrefix                   = "i2c"
 	voltsScale                  = 1000.0
 	ampsScale                   = 100.0
@@ -347,9 +349,6 @@ var (
 
 // Init will initialize a new device
 func Init(vendorId, productId uint16, serial, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := hid.Open(vendorId, productId, serial)
 	if err != nil {
@@ -628,6 +627,8 @@ func (d *Device) Stop() {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -695,6 +696,8 @@ func (d *Device) StopDirty() uint8 {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -744,7 +747,7 @@ func (d *Device) StopDirty() uint8 {
 
 // loadDeviceMetadata will load device meta data
 func (d *Device) loadDeviceMetadata() {
-	deviceMetadata := pwd + "/database/external/lsh.json"
+	deviceMetadata := config.GetDatabasePath() + "/external/lsh.json"
 	if common.FileExists(deviceMetadata) {
 		file, err := os.Open(deviceMetadata)
 		if err != nil {
@@ -766,7 +769,7 @@ func (d *Device) loadDeviceMetadata() {
 
 // loadExternalDevices will load external device definitions
 func (d *Device) loadExternalDevices() {
-	externalDevicesFile := pwd + "/database/external/linkadapter.json"
+	externalDevicesFile := config.GetDatabasePath() + "/external/linkadapter.json"
 	if common.FileExists(externalDevicesFile) {
 		file, err := os.Open(externalDevicesFile)
 		if err != nil {
@@ -818,7 +821,7 @@ func (d *Device) getLcdImages() {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -911,7 +914,7 @@ func (d *Device) GetDeviceTemplate() string {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -1036,7 +1039,7 @@ func (d *Device) saveDeviceProfile() {
 	noRgbPerLed := false
 
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	rgbProfiles := make(map[int]string, len(d.Devices))
@@ -1516,6 +1519,7 @@ func (d *Device) saveDeviceProfile() {
 		deviceProfile.Active = d.DeviceProfile.Active
 		deviceProfile.Brightness = d.DeviceProfile.Brightness
 		deviceProfile.OriginalBrightness = d.DeviceProfile.OriginalBrightness
+		deviceProfile.Calibration = d.DeviceProfile.Calibration
 		if len(d.DeviceProfile.Path) < 1 {
 			deviceProfile.Path = profilePath
 			d.DeviceProfile.Path = profilePath
@@ -1534,7 +1538,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -1783,6 +1787,32 @@ func (d *Device) UpdateDeviceSpeed(channelId int, value uint16) uint8 {
 	return 0
 }
 
+// SetChannelCalibration will save fan calibration of a device channel
+func (d *Device) SetChannelCalibration(channelId int, calibration *temperatures.FanCalibration) uint8 {
+	if d.DeviceProfile == nil {
+		return 0
+	}
+
+	if _, ok := d.Devices[channelId]; !ok {
+		return 0
+	}
+
+	if d.DeviceProfile.Calibration == nil {
+		d.DeviceProfile.Calibration = make(map[int]*temperatures.FanCalibration)
+	}
+	d.DeviceProfile.Calibration[channelId] = calibration
+	d.saveDeviceProfile()
+	return 1
+}
+
+// getCalibration will return fan calibration of a device channel, or nil if channel is not calibrated
+func (d *Device) getCalibration(channelId int) *temperatures.FanCalibration {
+	if d.DeviceProfile == nil || d.DeviceProfile.Calibration == nil {
+		return nil
+	}
+	return d.DeviceProfile.Calibration[channelId]
+}
+
 // UpdateDeviceLabel will set / update device label
 func (d *Device) UpdateDeviceLabel(channelId int, label string) uint8 {
 	if _, ok := d.Devices[channelId]; !ok {
@@ -2090,6 +2120,9 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 		}
 
 		newProfile := profile
+		if newProfile.Calibration == nil {
+			newProfile.Calibration = d.DeviceProfile.Calibration
+		}
 		newProfile.Active = true
 		d.DeviceProfile = newProfile
 		d.saveDeviceProfile()
@@ -2098,6 +2131,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 			d.timerSpeed.Stop()
 			d.updateDeviceSpeed()
 		}
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -2130,9 +2164,9 @@ func (d *Device) DeleteDeviceProfile(profileName string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -2328,6 +2362,42 @@ func (d *Device) UpdateDeviceMetrics() {
 			TemperatureProbe: strconv.FormatBool(device.IsTemperatureProbe),
 		}
 		metrics.Populate(header)
+
+		if len(device.LCDSerial) > 0 && d.DeviceProfile != nil {
+			metrics.PopulateLcd(&metrics.Lcd{
+				Product:    d.Product,
+				Serial:     d.Serial,
+				ChannelId:  header.ChannelId,
+				Mode:       d.DeviceProfile.LCDModes[device.ChannelId],
+				Rotation:   d.DeviceProfile.LCDRotations[device.ChannelId],
+				Brightness: d.DeviceProfile.LCDBrightness[device.ChannelId],
+			})
+		}
+
+		if device.IsPSU {
+			for rail, volts := range device.Volts {
+				metrics.PopulateRail(&metrics.Rail{
+					Product:   d.Product,
+					Serial:    d.Serial,
+					ChannelId: header.ChannelId,
+					Rail:      psuRails[rail],
+					Volts:     volts.Value,
+					Amps:      device.Amps[rail].Value,
+					Watts:     device.Watts[rail].Value,
+					HasVolts:  true,
+					HasAmps:   true,
+					HasWatts:  true,
+				})
+			}
+			metrics.PopulateRail(&metrics.Rail{
+				Product:   d.Product,
+				Serial:    d.Serial,
+				ChannelId: header.ChannelId,
+				Rail:      "Power Out",
+				Watts:     device.PowerOut,
+				HasWatts:  true,
+			})
+		}
 	}
 }
 
@@ -2370,7 +2440,7 @@ func (d *Device) getTemperatureProbeTemperature(channelId int) float32 {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -2786,9 +2856,9 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 			WriteColorEx: d.writeColorCluster,
 		}
 
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	} else {
-		cluster.Get().RemoveDeviceControllerBySerial(d.Serial)
+		cluster.RemoveDeviceControllerBySerial(d.Serial)
 	}
 	return 1
 }
@@ -3233,13 +3303,28 @@ func (d *Device) updateDeviceSpeed() {
 						temp = 50
 					}
 
-					if config.GetConfig().GraphProfiles {
+					if profiles.Pid.Enabled {
+						duty := temperatures.GetPidSpeed(d.Serial, d.Devices[k].ChannelId, d.Devices[k].Profile, profiles, temp, d.Devices[k].ContainsPump)
+						cp := fmt.Sprintf("%s-%d-pid-%d", d.Devices[k].Profile, d.Devices[k].ChannelId, duty)
+						if ok := tmp[d.Devices[k].ChannelId]; ok != cp {
+							tmp[d.Devices[k].ChannelId] = cp
+							channelSpeeds[d.Devices[k].ChannelId] = byte(duty)
+							d.setSpeed(channelSpeeds, 0)
+						}
+
+						if d.Debug {
+							logger.Log(logger.Fields{"serial": d.Serial, "duty": duty, "temp": temp, "setpoint": profiles.Pid.Setpoint, "device": d.Devices[k].Name, "zeroRpm": profiles.ZeroRpm}).Info("updateDeviceSpeed()")
+						}
+					} else if config.GetConfig().GraphProfiles {
 						var speed byte = 0x00
 						pumpValue := temperatures.Interpolate(profiles.Points[0], temp)
 						fansValue := temperatures.Interpolate(profiles.Points[1], temp)
 
 						pump := int(math.Round(float64(pumpValue)))
 						fans := int(math.Round(float64(fansValue)))
+						if profiles.Rpm {
+							fans = temperatures.RpmToDuty(d.getCalibration(d.Devices[k].ChannelId), fans)
+						}
 
 						// Failsafe
 						if fans < 20 && !profiles.ZeroRpm {
@@ -3297,6 +3382,10 @@ func (d *Device) updateDeviceSpeed() {
 										profile.Mode = 0
 									}
 
+									if profiles.Rpm {
+										profile.Fans = uint16(temperatures.RpmToDuty(d.getCalibration(d.Devices[k].ChannelId), int(profile.Fans)))
+									}
+
 									if profile.Fans < 20 && !profiles.ZeroRpm {
 										profile.Fans = 20
 									}
@@ -3353,6 +3442,12 @@ func (d *Device) setSpeed(data map[int]byte, mode uint8) {
 		return
 	}
 
+	if mode == 0 {
+		for channelId, duty := range data {
+			fanhealth.SetDuty(d.Serial, channelId, duty)
+		}
+	}
+
 	buffer := make([]byte, len(data)*4+1)
 	buffer[0] = byte(len(data))
 	i := 1
@@ -3403,8 +3498,10 @@ func (d *Device) setAutoRefresh() {
 				if d.Exit {
 					return
 				}
+				start := time.Now()
 				d.setTemperatures()
 				d.getDeviceData()
+				metrics.ObserveLoop(d.Serial, start)
 			case <-d.autoRefreshChan:
 				d.timer.Stop()
 				return
@@ -3513,6 +3610,13 @@ func (d *Device) getDeviceData() {
 		}
 	}
 
+	// Channel health
+	for key, value := range d.Devices {
+		if value.HasSpeed {
+			fanhealth.Update(d.Serial, d.Product, key, value.Name, value.Rpm, value.ContainsPump)
+		}
+	}
+
 	// Update stats
 	for key, value := range d.Devices {
 		if value.Rpm > 0 || value.Temperature > 0 {
@@ -3613,45 +3717,41 @@ func (d *Device) getDeviceData() {
 
 // protectLiquidCooler will try to protect your liquid cooler when the temperature reaches critical point
 func (d *Device) protectLiquidCooler() {
+	var temperature float32 = 0
 	for _, device := range d.Devices {
 		if device.AIO {
-			if device.Temperature > float32(criticalAioCoolantTemp) {
-				if d.IsCritical == false {
-					d.IsCritical = true
-					d.DeviceProfile.LCDMode = 0
-					for _, devCritical := range d.Devices {
-						d.Devices[devCritical.ChannelId].Profile = "aioCriticalTemperature"
-						if devCritical.LedChannels > 0 {
-							d.Devices[devCritical.ChannelId].RGB = "colorpulse"
-						}
-					}
-					if d.activeRgb != nil {
-						d.activeRgb.Exit <- true
-						d.activeRgb = nil
-					}
-					d.setDeviceColor()
-				}
-			} else {
-				if d.IsCritical == true {
-					if device.Temperature < float32(criticalAioCoolantTemp-5) {
-						d.IsCritical = false
-						d.DeviceProfile.LCDMode = d.OriginalProfile.LCDMode
-						for _, devCritical := range d.Devices {
-							d.Devices[devCritical.ChannelId].Profile = d.OriginalProfile.SpeedProfiles[devCritical.ChannelId]
-							if devCritical.LedChannels > 0 {
-								d.Devices[devCritical.ChannelId].RGB = d.OriginalProfile.RGBProfiles[devCritical.ChannelId]
-							}
-						}
-						if d.activeRgb != nil {
-							d.activeRgb.Exit <- true
-							d.activeRgb = nil
-						}
-						d.setDeviceColor()
-					}
-				}
+			temperature = device.Temperature
+			break
+		}
+	}
+
+	if !coolant.Protect(d.Serial, d.Product, temperature, func() []coolant.Channel {
+		channels := make([]coolant.Channel, 0, len(d.Devices))
+		for key, device := range d.Devices {
+			channel := coolant.Channel{Profile: &d.Devices[key].Profile, SavedProfile: d.OriginalProfile.SpeedProfiles[key]}
+			if device.LedChannels > 0 {
+				channel.RGB = &d.Devices[key].RGB
+				channel.SavedRGB = d.OriginalProfile.RGBProfiles[key]
 			}
+			channels = append(channels, channel)
 		}
+		return channels
+	}) {
+		return
+	}
+
+	d.IsCritical = coolant.IsCritical(d.Serial)
+	if d.IsCritical {
+		d.DeviceProfile.LCDMode = 0
+	} else {
+		d.DeviceProfile.LCDMode = d.OriginalProfile.LCDMode
 	}
+
+	if d.activeRgb != nil {
+		d.activeRgb.Exit <- true
+		d.activeRgb = nil
+	}
+	d.setDeviceColor()
 }
 
 // getSupportedDevice will return supported device or nil pointer
@@ -4197,7 +4297,7 @@ func (d *Device) setupClusterController() {
 		WriteColorEx: d.writeColorCluster,
 	}
 
-	cluster.Get().AddDeviceController(clusterController)
+	cluster.AddDeviceController(clusterController)
 }
 
 // setupOpenRGBController will create RGBController object for OpenRGB Client Integration
@@ -4856,7 +4956,9 @@ func (d *Device) setHardwareMode() {
 	_, err := d.transfer(cmdHardwareMode, nil, false)
 	if err != nil {
 		logger.Log(logger.Fields{"error": err}).Error("Unable to change device mode")
+		return
 	}
+	metrics.SetMode(d.Serial, metrics.ModeHardware)
 }
 
 // setSoftwareMode will switch a device to software mode
@@ -4864,7 +4966,9 @@ func (d *Device) setSoftwareMode() {
 	_, err := d.transfer(cmdSoftwareMode, nil, false)
 	if err != nil {
 		logger.Log(logger.Fields{"error": err}).Error("Unable to change device mode")
+		return
 	}
+	metrics.SetMode(d.Serial, metrics.ModeSoftware)
 	time.Sleep(time.Duration(transferTimeout) * time.Millisecond)
 }
 
@@ -15,6 +15,7 @@ import (
 	"OpenLinkHub/src/smbus"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -141,7 +142,6 @@ var crcTable = [256]uint8{
 }
 
 var (
-	pwd                   = ""
 	deviceRefreshInterval = 1000
 	maximumRegisters      = 8
 	colorAddresses        = []byte{0x58, 0x59, 0x5a, 0x5b, 0x5c, 0x5d, 0x5e, 0x5f} // DDR4
@@ -182,9 +182,6 @@ func Init(_, _ uint16, _, path string) *common.Device {
 		return nil
 	}
 
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	dev, err := smbus.Open(path)
 	if err != nil {
 		logger.Log(logger.Fields{"error": err, "device": path}).Error("Unable to open I2C device")
@@ -373,7 +370,7 @@ func (d *Device) getHwMonTemperatureFile(baseId int, driver string) string {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -791,7 +788,7 @@ func (d *Device) getI2cSensor() int {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -865,7 +862,7 @@ func (d *Device) saveDeviceProfile() {
 	noRgbPerLed := false
 
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	rgbProfiles := make(map[int]string, len(d.Devices))
 	labels := make(map[int]string, len(d.Devices))
@@ -1024,7 +1021,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -1605,7 +1602,7 @@ func (d *Device) setupClusterController() {
 			WriteColorEx: d.writeColorCluster,
 			ChannelId:    device.ChannelId,
 		}
-		cluster.Get().AddDeviceController(clusterController)
+		cluster.AddDeviceController(clusterController)
 	}
 }
 
@@ -1730,6 +1727,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 		d.DeviceProfile = newProfile
 		d.saveDeviceProfile()
 		d.setDeviceColor()
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -1761,7 +1759,7 @@ func (d *Device) DeleteDeviceProfile(profileName string) uint8 {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -1980,10 +1978,10 @@ func (d *Device) ProcessSetRgbCluster(enabled bool) uint8 {
 					WriteColorEx: d.writeColorCluster,
 					ChannelId:    device.ChannelId,
 				}
-				cluster.Get().AddDeviceController(clusterController)
+				cluster.AddDeviceController(clusterController)
 			}
 		} else {
-			cluster.Get().RemoveDeviceControllerBySerial(
+			cluster.RemoveDeviceControllerBySerial(
 				fmt.Sprintf("%s-%d", d.Serial, k),
 			)
 		}
@@ -2097,9 +2095,9 @@ func (d *Device) UpdateDeviceLabel(channelId int, label string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
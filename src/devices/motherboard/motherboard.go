@@ -13,6 +13,7 @@ import (
 	"OpenLinkHub/src/motherboards"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -25,7 +26,6 @@ import (
 )
 
 var (
-	pwd                        = ""
 	defaultSpeedValue          = 70
 	temperaturePullingInterval = 3000
 	i2cPrefix                  = "i2c"
@@ -112,9 +112,6 @@ type Device struct {
 
 // Init will initialize a new device
 func Init() *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	dev := motherboards.GetMotherboard()
 	if dev == nil {
 		logger.Log(logger.Fields{}).Error("Unable to open motherboard device")
@@ -234,7 +231,7 @@ func (d *Device) GetDeviceTemplate() string {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -641,7 +638,7 @@ func (d *Device) saveDeviceProfile() {
 	d.deviceLock.Lock()
 	defer d.deviceLock.Unlock()
 
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	headerModes := make(map[int]int, len(d.Devices))
@@ -686,7 +683,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -858,7 +855,19 @@ func (d *Device) updateDeviceSpeed() {
 						temp = 50
 					}
 
-					if config.GetConfig().GraphProfiles {
+					if profiles.Pid.Enabled {
+						duty := temperatures.GetPidSpeed(d.Serial, device.ChannelId, device.Profile, profiles, temp, device.ContainsPump)
+						cp := fmt.Sprintf("%s-%d-pid-%d", device.Profile, device.ChannelId, duty)
+						if ok := tmp[device.ChannelId]; ok != cp {
+							tmp[device.ChannelId] = cp
+							channelSpeeds[device.ChannelId] = byte(duty)
+							d.setSpeed(channelSpeeds)
+						}
+
+						if d.Debug {
+							logger.Log(logger.Fields{"serial": d.Serial, "duty": duty, "temp": temp, "setpoint": profiles.Pid.Setpoint, "device": device.Name, "zeroRpm": profiles.ZeroRpm}).Info("updateDeviceSpeed()")
+						}
+					} else if config.GetConfig().GraphProfiles {
 						pumpValue := temperatures.Interpolate(profiles.Points[0], temp)
 						fansValue := temperatures.Interpolate(profiles.Points[1], temp)
 
@@ -983,9 +992,9 @@ func (d *Device) UpdateDeviceLabel(channelId int, label string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -1042,6 +1051,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 			d.timerSpeed.Stop()
 			d.updateDeviceSpeed()
 		}
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
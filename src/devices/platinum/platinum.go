@@ -7,13 +7,16 @@ package platinum
 import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/coolant"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/fanhealth"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/openrgb"
 	"OpenLinkHub/src/rgb"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
 	"OpenLinkHub/src/usb"
+	"OpenLinkHub/src/webhooks"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -145,7 +148,6 @@ type Device struct {
 }
 
 var (
-	pwd                        = ""
 	cmdGetFirmware             = byte(0xaa)
 	cmdGetLiquidTemperature    = byte(0xa9)
 	cmdSetPumpSpeed            = byte(0x30)
@@ -235,9 +237,6 @@ var (
 )
 
 func Init(vendorId, productId uint16, _, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := usb.Open(path)
 	if err != nil {
@@ -347,6 +346,8 @@ func (d *Device) Stop() {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -386,6 +387,8 @@ func (d *Device) StopDirty() uint8 {
 	}
 
 	d.timer.Stop()
+	coolant.Reset(d.Serial)
+	fanhealth.Reset(d.Serial)
 	var once sync.Once
 	go func() {
 		once.Do(func() {
@@ -423,9 +426,9 @@ func (d *Device) UpdateDeviceLabel(channelId int, label string) uint8 {
 // SaveUserProfile will generate a new user profile configuration and save it to a file
 func (d *Device) SaveUserProfile(profileName string) uint8 {
 	if d.DeviceProfile != nil {
-		profilePath := pwd + "/database/profiles/" + d.Serial + "-" + profileName + ".json"
+		profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + "-" + profileName + ".json"
 
-		newProfile := d.DeviceProfile
+		newProfile := *d.DeviceProfile
 		newProfile.Path = profilePath
 		newProfile.Active = false
 
@@ -738,6 +741,7 @@ func (d *Device) ChangeDeviceProfile(profileName string) uint8 {
 			d.timerSpeed.Stop()
 			d.updateDeviceSpeed()
 		}
+		webhooks.PublishProfileSwitched(d.Serial, d.Product, profileName)
 		return 1
 	}
 	return 0
@@ -793,7 +797,7 @@ func (d *Device) getTemperatureProbe() {
 
 // saveRgbProfile will save rgb profile data
 func (d *Device) saveRgbProfile() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 	if common.FileExists(rgbFilename) {
 		if err := common.SaveJsonData(rgbFilename, d.Rgb); err != nil {
@@ -1204,7 +1208,7 @@ func (d *Device) initLeds() {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -1288,7 +1292,7 @@ func (d *Device) saveDeviceProfile() {
 	defer d.deviceLock.Unlock()
 
 	var defaultBrightness = uint8(100)
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 
 	speedProfiles := make(map[int]string, len(d.Devices))
 	rgbProfiles := make(map[int]string, len(d.Devices))
@@ -1344,7 +1348,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -1477,7 +1481,7 @@ func (d *Device) getDevices() int {
 
 // loadRgb will load RGB file if found, or create the default.
 func (d *Device) loadRgb() {
-	rgbDirectory := pwd + "/database/rgb/"
+	rgbDirectory := config.GetDatabasePath() + "/rgb/"
 	rgbFilename := rgbDirectory + d.Serial + ".json"
 
 	// Check if filename has .json extension
@@ -1612,6 +1616,7 @@ func (d *Device) getDeviceData() {
 			if rpm > 0 {
 				d.Devices[deviceList[device].Index].Rpm = rpm
 			}
+			fanhealth.Update(d.Serial, d.Product, deviceList[device].Index, d.Devices[deviceList[device].Index].Name, int16(rpm), deviceList[device].Pump)
 
 			if temp > 0 {
 				d.Devices[deviceList[device].Index].Temperature = temp
@@ -1651,6 +1656,7 @@ func (d *Device) setAutoRefresh() {
 				}
 				d.setTemperatures()
 				d.getDeviceData()
+				d.protectLiquidCooler()
 			case <-d.autoRefreshChan:
 				d.timer.Stop()
 				return
@@ -1673,6 +1679,13 @@ func (d *Device) setSpeed(data map[int]*SpeedMode) {
 		} else {
 			d.transfer(cmdSetFanSpeed, []byte{byte(key), value.Value})
 		}
+
+		// Speeds are keyed by hardware channel, health by device index
+		for _, device := range deviceList {
+			if device.Channel == key {
+				fanhealth.SetDuty(d.Serial, device.Index, value.Value)
+			}
+		}
 	}
 }
 
@@ -1707,6 +1720,38 @@ func (d *Device) getLiquidTemperature() float32 {
 	return 0
 }
 
+// protectLiquidCooler will try to protect your liquid cooler when the temperature reaches critical point
+func (d *Device) protectLiquidCooler() {
+	if d.DeviceProfile == nil {
+		return
+	}
+
+	if !coolant.Protect(d.Serial, d.Product, d.getLiquidTemperature(), func() []coolant.Channel {
+		channels := make([]coolant.Channel, 0, len(d.Devices))
+		for key, device := range d.Devices {
+			channel := coolant.Channel{}
+			if device.HasSpeed {
+				channel.Profile = &d.Devices[key].Profile
+				channel.SavedProfile = d.DeviceProfile.SpeedProfiles[key]
+			}
+			if device.LedChannels > 0 {
+				channel.RGB = &d.Devices[key].RGB
+				channel.SavedRGB = d.DeviceProfile.RGBProfiles[key]
+			}
+			channels = append(channels, channel)
+		}
+		return channels
+	}) {
+		return
+	}
+
+	if d.activeRgb != nil {
+		d.activeRgb.Exit <- true
+		d.activeRgb = nil
+	}
+	d.setDeviceColor()
+}
+
 // getPumpMode will return byte pump mode based on a profile name
 func (d *Device) getPumpMode(index int, profile string) byte {
 	for device := range deviceList {
@@ -85,7 +85,6 @@ type Device struct {
 }
 
 var (
-	pwd                   = ""
 	dataRail12V           = byte(0x00)
 	dataRail5V            = byte(0x01)
 	dataRail3V            = byte(0x02)
@@ -136,9 +135,6 @@ var decodeTable = [256]uint8{
 }
 
 func Init(vendorId, productId uint16, _, _ string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	devs, err := common.FindTtyByUsbId(vendorId, productId)
 	if err != nil {
 		logger.Log(logger.Fields{"error": err, "vendorId": vendorId, "productId": productId}).Warn("Unable to find TTY device")
@@ -516,7 +512,7 @@ func (d *Device) getDeviceData() {
 
 // saveDeviceProfile will save device profile for persistent configuration
 func (d *Device) saveDeviceProfile() {
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 	deviceProfile := &DeviceProfile{
 		Product: d.Product,
 		Serial:  d.Serial,
@@ -534,7 +530,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -552,7 +548,7 @@ func (d *Device) saveDeviceProfile() {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -8,7 +8,9 @@ import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/dashboard"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/metrics"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -17,11 +19,10 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/sstallion/go-hid"
 )
 
 type Devices struct {
@@ -85,7 +86,6 @@ type Device struct {
 }
 
 var (
-	pwd                   = ""
 	cmdInit               = byte(0xfe)
 	cmdRead               = byte(0x03)
 	cmdWrite              = byte(0x02)
@@ -115,9 +115,6 @@ var (
 )
 
 func Init(vendorId, productId uint16, _, path string) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := hid.OpenPath(path)
 	if err != nil {
@@ -591,7 +588,7 @@ func (d *Device) getDeviceData() {
 
 // saveDeviceProfile will save device profile for persistent configuration
 func (d *Device) saveDeviceProfile() {
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 	deviceProfile := &DeviceProfile{
 		Product: d.Product,
 		Serial:  d.Serial,
@@ -609,7 +606,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
@@ -627,7 +624,7 @@ func (d *Device) saveDeviceProfile() {
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfiles() {
 	profileList := make(map[string]*DeviceProfile)
-	userProfileDirectory := pwd + "/database/profiles/"
+	userProfileDirectory := config.GetDatabasePath() + "/profiles/"
 
 	files, err := os.ReadDir(userProfileDirectory)
 	if err != nil {
@@ -714,6 +711,41 @@ func (d *Device) init() {
 	}
 }
 
+// UpdateDeviceMetrics will update device metrics
+func (d *Device) UpdateDeviceMetrics() {
+	for _, device := range d.Devices {
+		header := &metrics.Header{
+			Product:          d.Product,
+			Serial:           d.Serial,
+			Firmware:         d.Firmware,
+			ChannelId:        strconv.Itoa(device.ChannelId),
+			Name:             device.Name,
+			Description:      device.Description,
+			Label:            device.Label,
+			ContainsPump:     strconv.FormatBool(device.ContainsPump),
+			Temperature:      float64(device.Temperature),
+			Rpm:              device.Rpm,
+			TemperatureProbe: strconv.FormatBool(device.IsTemperatureProbe),
+		}
+		metrics.Populate(header)
+
+		if device.Rail || device.Output {
+			metrics.PopulateRail(&metrics.Rail{
+				Product:   d.Product,
+				Serial:    d.Serial,
+				ChannelId: header.ChannelId,
+				Rail:      device.Name,
+				Volts:     float64(device.Volts),
+				Amps:      float64(device.Amps),
+				Watts:     float64(device.Watts),
+				HasVolts:  device.HasVolts,
+				HasAmps:   device.HasAmps,
+				HasWatts:  device.HasWatts,
+			})
+		}
+	}
+}
+
 // setAutoRefresh will refresh device data
 func (d *Device) setAutoRefresh() {
 	timer = time.NewTicker(time.Duration(deviceRefreshInterval) * time.Millisecond)
@@ -722,7 +754,9 @@ func (d *Device) setAutoRefresh() {
 		for {
 			select {
 			case <-timer.C:
+				start := time.Now()
 				d.getDeviceData()
+				metrics.ObserveLoop(d.Serial, start)
 			case <-autoRefreshChan:
 				timer.Stop()
 				return
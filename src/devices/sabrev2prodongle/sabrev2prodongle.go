@@ -9,9 +9,9 @@ import (
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/devices/m55W"
 	"OpenLinkHub/src/devices/sabrev2proW"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/inputmanager"
 	"OpenLinkHub/src/logger"
-	"github.com/sstallion/go-hid"
 	"os"
 	"strconv"
 	"sync"
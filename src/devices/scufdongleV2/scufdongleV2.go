@@ -8,11 +8,11 @@ import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/devices/scufenvisionproV2W"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"github.com/sstallion/go-hid"
 	"strconv"
 	"sync"
 	"time"
@@ -26,12 +26,12 @@ import (
 	"OpenLinkHub/src/devices/sabrergbproW"
 	"OpenLinkHub/src/devices/scimitarSEW"
 	"OpenLinkHub/src/devices/scimitarW"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/sstallion/go-hid"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -83,7 +83,6 @@ type DeviceProfile struct {
 }
 
 var (
-	pwd                = ""
 	bufferSize         = 64
 	bufferSizeWrite    = bufferSize + 1
 	headerSize         = 2
@@ -107,9 +106,6 @@ var (
 )
 
 func Init(vendorId, productId uint16, _, path string, callback func(device *common.Device)) *common.Device {
-	// Set global working directory
-	pwd = config.GetConfig().ConfigPath
-
 	// Open device, return if failure
 	dev, err := hid.OpenPath(path)
 	if err != nil {
@@ -806,7 +802,7 @@ func (d *Device) getDevices() {
 
 // loadDeviceProfiles will load custom user profiles
 func (d *Device) loadDeviceProfile() {
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 	pf := &DeviceProfile{}
 
 	// Check if filename has .json extension
@@ -832,7 +828,7 @@ func (d *Device) loadDeviceProfile() {
 
 // saveDeviceProfile will save device profile for persistent configuration
 func (d *Device) saveDeviceProfile() {
-	profilePath := pwd + "/database/profiles/" + d.Serial + ".json"
+	profilePath := config.GetDatabasePath() + "/profiles/" + d.Serial + ".json"
 	deviceProfile := &DeviceProfile{
 		Product: d.Product,
 		Serial:  d.Serial,
@@ -860,7 +856,7 @@ func (d *Device) saveDeviceProfile() {
 
 	// Fix profile paths if folder database/ folder is moved
 	filename := filepath.Base(deviceProfile.Path)
-	path := fmt.Sprintf("%s/database/profiles/%s", pwd, filename)
+	path := fmt.Sprintf("%s/profiles/%s", config.GetDatabasePath(), filename)
 	if deviceProfile.Path != path {
 		logger.Log(logger.Fields{"original": deviceProfile.Path, "new": path}).Warn("Detected mismatching device profile path. Fixing paths...")
 		deviceProfile.Path = path
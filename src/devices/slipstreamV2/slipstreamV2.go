@@ -10,12 +10,12 @@ import (
 	"OpenLinkHub/src/devices/m65rgbultraW"
 	"OpenLinkHub/src/devices/vanguard96W"
 	"OpenLinkHub/src/devices/vanguard99airW"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"github.com/sstallion/go-hid"
 	"reflect"
 	"sync"
 	"time"
@@ -8,8 +8,8 @@ import (
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/devices/voideliteW"
+	"OpenLinkHub/src/hid"
 	"OpenLinkHub/src/logger"
-	"github.com/sstallion/go-hid"
 	"strconv"
 	"sync"
 	"time"
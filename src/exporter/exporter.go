@@ -0,0 +1,206 @@
+package exporter
+
+// Package: exporter
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/metrics"
+	"os"
+	"sync"
+	"time"
+)
+
+// Point is a single measurement with tags and numeric fields
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]float64
+	Time        time.Time
+}
+
+// Sink is a push destination for points
+type Sink interface {
+	Name() string
+	Write(points []Point) error
+}
+
+// queue holds points of a single sink while its endpoint is unavailable
+type queue struct {
+	sink        Sink
+	points      []Point
+	dropped     uint64
+	failing     bool
+	backoff     time.Duration
+	nextAttempt time.Time
+}
+
+const (
+	MeasurementTemperature = "temperature"
+	MeasurementSpeed       = "speed"
+	MeasurementStorage     = "storage_temperature"
+	MeasurementSystem      = "system_temperature"
+)
+
+var (
+	mutex      sync.Mutex
+	queues     []*queue
+	minBackoff = 5 * time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// Init will start configured push exporters
+func Init() {
+	cfg := config.GetConfig()
+	if cfg.InfluxEnabled {
+		queues = append(queues, &queue{sink: newInflux(cfg)})
+	}
+	if cfg.GraphiteEnabled {
+		queues = append(queues, &queue{sink: newGraphite(cfg)})
+	}
+	if len(queues) == 0 {
+		return
+	}
+
+	interval := cfg.ExporterInterval
+	if interval <= 0 {
+		interval = 10
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			push(Collect())
+		}
+	}()
+}
+
+// Collect will return current device, storage and CPU/GPU measurements
+func Collect() []Point {
+	devices.UpdateDeviceMetrics()
+
+	now := time.Now()
+	global := globalTags()
+	points := make([]Point, 0)
+
+	for _, d := range metrics.GetDeviceMetrics() {
+		tags := mergeTags(global, map[string]string{
+			"product": d.Product,
+			"serial":  d.Serial,
+			"channel": d.ChannelId,
+			"name":    d.Name,
+		})
+		if d.Temperature > 0 {
+			points = append(points, Point{Measurement: MeasurementTemperature, Tags: tags, Fields: map[string]float64{"value": d.Temperature}, Time: now})
+		}
+		if d.Rpm > 0 {
+			points = append(points, Point{Measurement: MeasurementSpeed, Tags: tags, Fields: map[string]float64{"value": float64(d.Rpm)}, Time: now})
+		}
+	}
+
+	for _, s := range metrics.GetStorageMetrics() {
+		tags := mergeTags(global, map[string]string{"device": s.HwmonDevice, "model": s.Model})
+		points = append(points, Point{Measurement: MeasurementStorage, Tags: tags, Fields: map[string]float64{"value": s.Temperature}, Time: now})
+	}
+
+	for _, s := range metrics.GetDefaultMetrics() {
+		tags := mergeTags(global, map[string]string{"model": s.Model})
+		points = append(points, Point{Measurement: MeasurementSystem, Tags: tags, Fields: map[string]float64{"value": s.Temperature}, Time: now})
+	}
+	return points
+}
+
+// push will queue points to every sink and flush queues which are due
+func push(points []Point) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	cfg := config.GetConfig()
+	bufferSize := cfg.ExporterBufferSize
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
+	batchSize := cfg.ExporterBatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	for _, q := range queues {
+		q.points = append(q.points, points...)
+		if overflow := len(q.points) - bufferSize; overflow > 0 {
+			// Oldest points are dropped first
+			q.points = q.points[overflow:]
+			q.dropped += uint64(overflow)
+		}
+
+		if time.Now().Before(q.nextAttempt) {
+			continue
+		}
+		q.flush(batchSize)
+	}
+}
+
+// flush will write queued points in batches until queue is empty or endpoint fails
+func (q *queue) flush(batchSize int) {
+	for len(q.points) > 0 {
+		size := batchSize
+		if size > len(q.points) {
+			size = len(q.points)
+		}
+
+		if err := q.sink.Write(q.points[:size]); err != nil {
+			if q.backoff == 0 {
+				q.backoff = minBackoff
+			} else if q.backoff *= 2; q.backoff > maxBackoff {
+				q.backoff = maxBackoff
+			}
+			q.nextAttempt = time.Now().Add(q.backoff)
+
+			if !q.failing {
+				logger.Log(logger.Fields{"error": err, "exporter": q.sink.Name(), "queued": len(q.points)}).Warn("Unable to push metrics, will retry")
+			}
+			q.failing = true
+			return
+		}
+		q.points = q.points[size:]
+	}
+
+	if q.failing {
+		logger.Log(logger.Fields{"exporter": q.sink.Name(), "dropped": q.dropped}).Info("Metrics push recovered")
+	}
+	q.points = nil
+	q.failing = false
+	q.dropped = 0
+	q.backoff = 0
+	q.nextAttempt = time.Time{}
+}
+
+// globalTags will return tags from configuration, with host tag unless configured
+func globalTags() map[string]string {
+	tags := make(map[string]string)
+	if hostname, err := os.Hostname(); err == nil {
+		tags["host"] = hostname
+	}
+	for key, value := range config.GetConfig().ExporterTags {
+		tags[key] = value
+	}
+	return tags
+}
+
+// mergeTags will return copy of global tags extended with point tags. Empty values are skipped
+func mergeTags(global, tags map[string]string) map[string]string {
+	merged := make(map[string]string, len(global)+len(tags))
+	for key, value := range global {
+		merged[key] = value
+	}
+	for key, value := range tags {
+		if len(value) > 0 {
+			merged[key] = value
+		}
+	}
+	return merged
+}
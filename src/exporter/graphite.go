@@ -0,0 +1,119 @@
+package exporter
+
+// Package: exporter
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"bytes"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type graphite struct {
+	network string
+	address string
+	prefix  string
+	conn    net.Conn
+}
+
+const maxDatagram = 1400
+
+var pathRegex = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// newGraphite will return Graphite plaintext sink over TCP or UDP
+func newGraphite(cfg config.Configuration) *graphite {
+	network := strings.ToLower(cfg.GraphiteProtocol)
+	if network != "udp" {
+		network = "tcp"
+	}
+	return &graphite{
+		network: network,
+		address: cfg.GraphiteAddress,
+		prefix:  strings.Trim(cfg.GraphitePrefix, "."),
+	}
+}
+
+// Name will return sink name
+func (g *graphite) Name() string {
+	return "graphite"
+}
+
+// Write will send points in plaintext protocol. TCP connection is kept open between writes
+func (g *graphite) Write(points []Point) error {
+	if g.conn == nil {
+		conn, err := net.DialTimeout(g.network, g.address, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+
+	var b bytes.Buffer
+	for _, p := range points {
+		line := g.line(p)
+		if g.network == "udp" && b.Len() > 0 && b.Len()+len(line) > maxDatagram {
+			if err := g.send(b.Bytes()); err != nil {
+				return err
+			}
+			b.Reset()
+		}
+		b.WriteString(line)
+	}
+	return g.send(b.Bytes())
+}
+
+// send will write data and drop connection on failure
+func (g *graphite) send(data []byte) error {
+	_ = g.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := g.conn.Write(data); err != nil {
+		_ = g.conn.Close()
+		g.conn = nil
+		return err
+	}
+	return nil
+}
+
+// line will format a point as prefix.measurement.serial.channel;tag=value value timestamp
+func (g *graphite) line(p Point) string {
+	path := make([]string, 0, 4)
+	if len(g.prefix) > 0 {
+		path = append(path, g.prefix)
+	}
+	path = append(path, p.Measurement)
+	keys := []string{"serial", "channel"}
+	if _, ok := p.Tags["serial"]; !ok {
+		keys = []string{"device", "model"}
+	}
+	for _, key := range keys {
+		if value, ok := p.Tags[key]; ok {
+			path = append(path, pathRegex.ReplaceAllString(value, "_"))
+			if key == "device" {
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, key := range sortedKeys(p.Tags) {
+		b.WriteByte(';')
+		b.WriteString(pathRegex.ReplaceAllString(key, "_"))
+		b.WriteByte('=')
+		b.WriteString(strings.NewReplacer(";", "_", "~", "_", " ", "_").Replace(p.Tags[key]))
+	}
+	tags := b.String()
+
+	var lines strings.Builder
+	for field, value := range p.Fields {
+		name := strings.Join(path, ".")
+		if field != "value" {
+			name += "." + pathRegex.ReplaceAllString(field, "_")
+		}
+		lines.WriteString(name + tags + " " + strconv.FormatFloat(value, 'f', -1, 64) + " " + strconv.FormatInt(p.Time.Unix(), 10) + "\n")
+	}
+	return lines.String()
+}
@@ -0,0 +1,121 @@
+package exporter
+
+// Package: exporter
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type influx struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	tagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
+
+// newInflux will return InfluxDB v2 write API sink
+func newInflux(cfg config.Configuration) *influx {
+	query := url.Values{}
+	query.Set("org", cfg.InfluxOrg)
+	query.Set("bucket", cfg.InfluxBucket)
+	query.Set("precision", "s")
+
+	return &influx{
+		endpoint: strings.TrimSuffix(cfg.InfluxUrl, "/") + "/api/v2/write?" + query.Encode(),
+		token:    cfg.InfluxToken,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name will return sink name
+func (i *influx) Name() string {
+	return "influx"
+}
+
+// Write will send points in line protocol
+func (i *influx) Write(points []Point) error {
+	var b bytes.Buffer
+	for _, p := range points {
+		writeLine(&b, p)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, i.endpoint, &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if len(i.token) > 0 {
+		req.Header.Set("Authorization", "Token "+i.token)
+	}
+
+	res, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("influx returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// writeLine will append a point in InfluxDB line protocol
+func writeLine(b *bytes.Buffer, p Point) {
+	b.WriteString(measurementEscaper.Replace(p.Measurement))
+	for _, key := range sortedKeys(p.Tags) {
+		b.WriteByte(',')
+		b.WriteString(tagEscaper.Replace(key))
+		b.WriteByte('=')
+		b.WriteString(tagEscaper.Replace(p.Tags[key]))
+	}
+
+	fields := make([]string, 0, len(p.Fields))
+	for key := range p.Fields {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+	for n, key := range fields {
+		if n == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(tagEscaper.Replace(key))
+		b.WriteByte('=')
+		b.WriteString(strconv.FormatFloat(p.Fields[key], 'f', -1, 64))
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(p.Time.Unix(), 10))
+	b.WriteByte('\n')
+}
+
+// sortedKeys will return sorted keys of non-empty tags
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key, value := range tags {
+		if len(key) > 0 && len(value) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
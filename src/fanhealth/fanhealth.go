@@ -0,0 +1,325 @@
+package fanhealth
+
+// Package: fanhealth
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/coolant"
+	"OpenLinkHub/src/logger"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Channel struct {
+	Serial    string    `json:"serial"`
+	Product   string    `json:"product"`
+	ChannelId int       `json:"channelId"`
+	Name      string    `json:"name"`
+	Pump      bool      `json:"pump"`
+	Duty      uint8     `json:"duty"`
+	Rpm       int16     `json:"rpm"`
+	Expected  int16     `json:"expected"`
+	Status    string    `json:"status"`
+	Since     time.Time `json:"since"`
+	dutySet   bool
+	dutyTime  time.Time
+	pending   string
+	pendingAt time.Time
+	ratio     float64
+	samples   int
+}
+
+type Event struct {
+	Serial    string    `json:"serial"`
+	Product   string    `json:"product"`
+	ChannelId int       `json:"channelId"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Duty      uint8     `json:"duty"`
+	Rpm       int16     `json:"rpm"`
+	Expected  int16     `json:"expected"`
+	Time      time.Time `json:"time"`
+}
+
+const (
+	StatusUnknown     = "unknown"
+	StatusOk          = "ok"
+	StatusStalled     = "stalled"
+	StatusDegraded    = "degraded"
+	StatusPumpFailure = "pumpFailure"
+)
+
+var (
+	mutex           sync.Mutex
+	channels        = map[string]map[int]*Channel{}
+	events          = make([]Event, 0)
+	maxEvents       = 100
+	defaultDebounce = 15   // seconds
+	settleTime      = 8.0  // seconds, RPM needs time to follow new duty
+	stallDuty       = 30   // below this duty fans with zero RPM mode may stop on their own
+	lowRpmRatio     = 0.5  // RPM below this fraction of expected RPM is degraded
+	pumpMinRpm      = 300  // absolute minimum for a running pump
+	learnAlpha      = 0.05 // weight of new sample in expected RPM learning
+	minSamples      = 10   // samples needed before expected RPM is used
+)
+
+// SetDuty will store last duty in percent sent to a device channel
+func SetDuty(serial string, channelId int, duty uint8) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	channel := getChannel(serial, channelId)
+	if !channel.dutySet || channel.Duty != duty {
+		channel.dutyTime = time.Now()
+	}
+	channel.Duty = duty
+	channel.dutySet = true
+}
+
+// Update will evaluate channel RPM against last duty and change channel status after debounce time
+func Update(serial, product string, channelId int, name string, rpm int16, pump bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	channel := getChannel(serial, channelId)
+	channel.Product = product
+	channel.Name = name
+	channel.Pump = pump
+	channel.Rpm = rpm
+	if !channel.dutySet {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(channel.dutyTime).Seconds() < settleTime {
+		return
+	}
+
+	if channel.samples >= minSamples {
+		channel.Expected = int16(channel.ratio * float64(channel.Duty))
+	}
+
+	status := evaluate(channel)
+	if status == StatusOk {
+		learn(channel)
+	}
+
+	if status == channel.Status {
+		channel.pending = ""
+		return
+	}
+
+	if channel.pending != status {
+		channel.pending = status
+		channel.pendingAt = now
+	}
+
+	// The first healthy reading is accepted right away, everything else has to persist
+	initial := channel.Status == StatusUnknown && status == StatusOk
+	if now.Sub(channel.pendingAt) < getDebounce() && !initial {
+		return
+	}
+
+	channel.Status = status
+	channel.Since = now
+	channel.pending = ""
+
+	if initial {
+		return
+	}
+
+	addEvent(channel)
+	fields := logger.Fields{"serial": serial, "product": product, "channelId": channelId, "name": name, "status": status, "duty": channel.Duty, "rpm": rpm, "expected": channel.Expected}
+	if status == StatusOk {
+		logger.Log(fields).Info("Channel speed recovered")
+	} else {
+		logger.Log(fields).Warn("Channel failure detected")
+	}
+
+	if config.GetConfig().FanFailureProtection {
+		coolant.SetFault(serial, isFailing(serial))
+	}
+}
+
+// Protect will switch channels of a device without liquid temperature sensor to critical speed profile while any of its
+// channels is failing, and restore saved profiles once all channels recover. Returns true when channels are switched
+func Protect(serial, product string, channels func() []coolant.Channel) bool {
+	return coolant.Protect(serial, product, 0, channels)
+}
+
+// IsFailing will return true if any channel of a device is not healthy
+func IsFailing(serial string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return isFailing(serial)
+}
+
+// GetDeviceHealth will return health of all device channels
+func GetDeviceHealth(serial string) []Channel {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return getDeviceHealth(serial)
+}
+
+// GetHealth will return channel health of all devices
+func GetHealth() map[string][]Channel {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make(map[string][]Channel, len(channels))
+	for serial := range channels {
+		result[serial] = getDeviceHealth(serial)
+	}
+	return result
+}
+
+// GetDuties will return last duty in percent sent to device channels. Channels without known duty are left out
+func GetDuties() map[string]map[int]uint8 {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make(map[string]map[int]uint8, len(channels))
+	for serial, list := range channels {
+		for channelId, channel := range list {
+			if !channel.dutySet {
+				continue
+			}
+			if _, ok := result[serial]; !ok {
+				result[serial] = make(map[int]uint8)
+			}
+			result[serial][channelId] = channel.Duty
+		}
+	}
+	return result
+}
+
+// GetEvents will return recent channel health events
+func GetEvents() []Event {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make([]Event, len(events))
+	copy(result, events)
+	return result
+}
+
+// Reset will remove device channels, e.g. when device is stopped
+func Reset(serial string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(channels, serial)
+}
+
+// getChannel will return channel state, or create a new one
+func getChannel(serial string, channelId int) *Channel {
+	if _, ok := channels[serial]; !ok {
+		channels[serial] = make(map[int]*Channel)
+	}
+
+	channel, ok := channels[serial][channelId]
+	if !ok {
+		channel = &Channel{
+			Serial:    serial,
+			ChannelId: channelId,
+			Status:    StatusUnknown,
+			Since:     time.Now(),
+		}
+		channels[serial][channelId] = channel
+	}
+	return channel
+}
+
+// evaluate will return channel status for current duty and RPM
+func evaluate(channel *Channel) string {
+	expected := channel.Expected
+	if channel.Pump {
+		if channel.Duty == 0 {
+			return StatusOk
+		}
+		if channel.Rpm < int16(pumpMinRpm) {
+			return StatusPumpFailure
+		}
+		if expected > 0 && float64(channel.Rpm) < float64(expected)*lowRpmRatio {
+			return StatusPumpFailure
+		}
+		return StatusOk
+	}
+
+	if channel.Duty < uint8(stallDuty) {
+		return StatusOk
+	}
+	if channel.Rpm == 0 {
+		return StatusStalled
+	}
+	if expected > 0 && float64(channel.Rpm) < float64(expected)*lowRpmRatio {
+		return StatusDegraded
+	}
+	return StatusOk
+}
+
+// learn will update expected RPM per duty percent from healthy samples
+func learn(channel *Channel) {
+	if channel.Duty == 0 || channel.Rpm <= 0 {
+		return
+	}
+
+	ratio := float64(channel.Rpm) / float64(channel.Duty)
+	if channel.samples == 0 {
+		channel.ratio = ratio
+	} else {
+		channel.ratio = channel.ratio*(1-learnAlpha) + ratio*learnAlpha
+	}
+	channel.samples++
+}
+
+// getDebounce will return time a failure or recovery has to persist before status is changed
+func getDebounce() time.Duration {
+	debounce := config.GetConfig().FanHealthDebounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+	return time.Duration(debounce) * time.Second
+}
+
+// isFailing will return true if any channel of a device has a failure status
+func isFailing(serial string) bool {
+	for _, channel := range channels[serial] {
+		if channel.Status != StatusOk && channel.Status != StatusUnknown {
+			return true
+		}
+	}
+	return false
+}
+
+// getDeviceHealth will return sorted copy of device channels
+func getDeviceHealth(serial string) []Channel {
+	result := make([]Channel, 0, len(channels[serial]))
+	for _, channel := range channels[serial] {
+		result = append(result, *channel)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ChannelId < result[j].ChannelId
+	})
+	return result
+}
+
+// addEvent will append health event and keep only last maxEvents
+func addEvent(channel *Channel) {
+	events = append(events, Event{
+		Serial:    channel.Serial,
+		Product:   channel.Product,
+		ChannelId: channel.ChannelId,
+		Name:      channel.Name,
+		Status:    channel.Status,
+		Duty:      channel.Duty,
+		Rpm:       channel.Rpm,
+		Expected:  channel.Expected,
+		Time:      channel.Since,
+	})
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+}
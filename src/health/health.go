@@ -0,0 +1,158 @@
+package health
+
+// Package: health
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/metrics"
+	"OpenLinkHub/src/version"
+	"reflect"
+	"runtime"
+	"sort"
+	"time"
+)
+
+type Device struct {
+	Serial            string     `json:"serial"`
+	Product           string     `json:"product"`
+	Firmware          string     `json:"firmware"`
+	Status            string     `json:"status"`
+	Mode              string     `json:"mode"`
+	Critical          bool       `json:"critical"`
+	Connected         bool       `json:"connected"`
+	LastSuccess       *time.Time `json:"lastSuccess,omitempty"`
+	LastError         *time.Time `json:"lastError,omitempty"`
+	ConsecutiveErrors uint64     `json:"consecutiveErrors"`
+	Transfers         uint64     `json:"transfers"`
+	Errors            uint64     `json:"errors"`
+	QueueDepth        int        `json:"queueDepth"`
+	QueueSize         int        `json:"queueSize"`
+	LastLoop          float64    `json:"lastLoop"`
+}
+
+type Health struct {
+	Status     string    `json:"status"`
+	Ready      bool      `json:"ready"`
+	Version    string    `json:"version"`
+	Uptime     int64     `json:"uptime"`
+	Goroutines int       `json:"goroutines"`
+	Missing    []string  `json:"missing"`
+	Devices    []Device  `json:"devices"`
+	Checked    time.Time `json:"checked"`
+}
+
+const (
+	StatusOk          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusUnknown     = "unknown" // Device has no recorded transfers, e.g. SMBus, motherboard or wireless device behind a receiver
+	ModeUnknown       = "unknown" // Driver does not report software or hardware mode
+	errorThreshold    = 3         // Consecutive transfer errors before a device is reported as degraded
+)
+
+var started = time.Now()
+
+// GetHealth will return daemon health with per-device diagnostics. Transfers are recorded for every HID device,
+// wireless devices are recorded under their receiver. Mode is reported by iCUE Link hub, Commander Core and
+// Commander Core XT, last loop duration by those and Commander Pro and HID PSUs
+func GetHealth() Health {
+	cfg := config.GetConfig()
+	transfers := metrics.GetTransfers()
+	loops := metrics.GetLoops()
+	modes := metrics.GetModes()
+	connections := metrics.GetConnections()
+
+	critical := make(map[string]bool, len(cfg.CriticalDevices))
+	for _, serial := range cfg.CriticalDevices {
+		critical[serial] = true
+	}
+
+	result := Health{
+		Status:     StatusOk,
+		Ready:      true,
+		Version:    version.Version,
+		Uptime:     int64(time.Since(started).Seconds()),
+		Goroutines: runtime.NumGoroutine(),
+		Missing:    make([]string, 0),
+		Devices:    make([]Device, 0),
+		Checked:    time.Now(),
+	}
+
+	present := make(map[string]bool)
+	for serial, device := range devices.GetDevices() {
+		present[serial] = true
+		item := Device{
+			Serial:    serial,
+			Product:   device.Product,
+			Firmware:  device.Firmware,
+			Status:    StatusUnknown,
+			Mode:      ModeUnknown,
+			Critical:  critical[serial],
+			Connected: true,
+		}
+		if c, ok := connections[serial]; ok {
+			item.Connected = c.Connected
+		}
+		if mode, ok := modes[serial]; ok {
+			item.Mode = mode
+		}
+
+		if t, ok := transfers[serial]; ok {
+			item.Status = StatusOk
+			item.Transfers = t.Writes
+			item.Errors = t.Errors
+			item.ConsecutiveErrors = t.ConsecutiveErrors
+			if !t.LastSuccess.IsZero() {
+				lastSuccess := t.LastSuccess
+				item.LastSuccess = &lastSuccess
+			}
+			if !t.LastError.IsZero() {
+				lastError := t.LastError
+				item.LastError = &lastError
+			}
+		}
+		if l, ok := loops[serial]; ok {
+			item.LastLoop = l.Last
+		}
+		item.QueueDepth, item.QueueSize = getQueue(device.Instance)
+
+		if item.ConsecutiveErrors >= errorThreshold || !item.Connected {
+			item.Status = StatusDegraded
+			result.Status = StatusDegraded
+		}
+		result.Devices = append(result.Devices, item)
+	}
+
+	for _, serial := range cfg.CriticalDevices {
+		if !present[serial] {
+			result.Missing = append(result.Missing, serial)
+		}
+	}
+
+	if len(result.Missing) > 0 {
+		result.Ready = false
+		result.Status = StatusUnavailable
+	}
+
+	sort.Slice(result.Devices, func(i, j int) bool {
+		return result.Devices[i].Serial < result.Devices[j].Serial
+	})
+	return result
+}
+
+// getQueue will return number of pending packets and capacity of driver write queue
+func getQueue(instance interface{}) (int, int) {
+	value := reflect.Indirect(reflect.ValueOf(instance))
+	if value.Kind() != reflect.Struct {
+		return 0, 0
+	}
+
+	queue := value.FieldByName("queue")
+	if !queue.IsValid() || queue.Kind() != reflect.Chan || queue.IsNil() {
+		return 0, 0
+	}
+	return queue.Len(), queue.Cap()
+}
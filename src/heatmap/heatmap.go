@@ -0,0 +1,399 @@
+package heatmap
+
+// Package: heatmap
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/keyboards"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/rgb"
+	"encoding/json"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Key struct {
+	KeyName string `json:"keyName"`
+	Total   uint64 `json:"total"`
+}
+
+type Heatmap struct {
+	Serial  string                    `json:"serial"`
+	Device  string                    `json:"device"`
+	Created string                    `json:"created"`
+	Updated string                    `json:"updated"`
+	Keys    map[int]Key               `json:"keys"`
+	Days    map[string]map[int]uint64 `json:"days"`
+}
+
+type KeyCount struct {
+	KeyId   int    `json:"keyId"`
+	KeyName string `json:"keyName"`
+	Count   uint64 `json:"count"`
+}
+
+var (
+	location        = ""
+	heatmaps        = map[string]*Heatmap{}
+	dirty           = map[string]bool{}
+	mutex           sync.RWMutex
+	flushInterval   = 60 * time.Second
+	retentionDays   = 90
+	dayLayout       = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05"
+)
+
+// Init will load all available key heatmaps and start the flush loop
+func Init() {
+	location = config.GetDatabasePath() + "/heatmap/"
+
+	files, err := os.ReadDir(location)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to read content of a folder")
+		return
+	}
+
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue // Exclude folders if any
+		}
+
+		// Define a full path of filename
+		heatmapLocation := location + fi.Name()
+
+		// Check if filename has .json extension
+		if !common.IsValidExtension(heatmapLocation, ".json") {
+			continue
+		}
+
+		file, err := os.Open(heatmapLocation)
+		if err != nil {
+			logger.Log(logger.Fields{"error": err, "location": heatmapLocation}).Error("Unable to read key heatmap")
+			continue
+		}
+
+		var value Heatmap
+		if err = json.NewDecoder(file).Decode(&value); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": heatmapLocation}).Error("Unable to decode key heatmap")
+			_ = file.Close()
+			continue
+		}
+		_ = file.Close()
+
+		if value.Keys == nil {
+			value.Keys = make(map[int]Key)
+		}
+		if value.Days == nil {
+			value.Days = make(map[string]map[int]uint64)
+		}
+		heatmaps[value.Serial] = &value
+	}
+
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			Flush()
+		}
+	}()
+}
+
+// IsEnabled will return true if key usage statistics are enabled
+func IsEnabled() bool {
+	return config.GetConfig().KeyHeatmap
+}
+
+// Track will increment press counter of a key matching given key hash. It is called only by keyboards supporting heatmap RGB mode
+func Track(serial, device string, keyboard *keyboards.Keyboard, keyHash string) {
+	if !IsEnabled() || keyboard == nil {
+		return
+	}
+
+	for _, row := range keyboard.Row {
+		for keyId, key := range row.Keys {
+			for _, hash := range key.KeyHash {
+				if hash == keyHash {
+					increment(serial, device, keyId, key.KeyName)
+					return
+				}
+			}
+		}
+	}
+}
+
+// increment will increment press counter for given key
+func increment(serial, device string, keyId int, keyName string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	now := time.Now()
+	value, ok := heatmaps[serial]
+	if !ok {
+		value = &Heatmap{
+			Serial:  serial,
+			Device:  device,
+			Created: now.Format(timestampLayout),
+			Keys:    make(map[int]Key),
+			Days:    make(map[string]map[int]uint64),
+		}
+		heatmaps[serial] = value
+	}
+
+	day := now.Format(dayLayout)
+	if _, found := value.Days[day]; !found {
+		value.Days[day] = make(map[int]uint64)
+		prune(value, now)
+	}
+	value.Days[day][keyId]++
+
+	key := value.Keys[keyId]
+	key.KeyName = keyName
+	key.Total++
+	value.Keys[keyId] = key
+	value.Updated = now.Format(timestampLayout)
+	dirty[serial] = true
+}
+
+// prune will remove daily buckets older than retention period
+func prune(value *Heatmap, now time.Time) {
+	cutoff := now.AddDate(0, 0, -retentionDays).Format(dayLayout)
+	for day := range value.Days {
+		if day < cutoff {
+			delete(value.Days, day)
+		}
+	}
+}
+
+// GetHeatmap will return key heatmap for given serial
+func GetHeatmap(serial string) *Heatmap {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if value, ok := heatmaps[serial]; ok {
+		cp := copyHeatmap(value)
+		return &cp
+	}
+	return nil
+}
+
+// GetHeatmaps will return all key heatmaps
+func GetHeatmaps() map[string]Heatmap {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	cp := make(map[string]Heatmap, len(heatmaps))
+	for key, value := range heatmaps {
+		cp[key] = copyHeatmap(value)
+	}
+	return cp
+}
+
+// GetKeyCounts will return key press counts for given serial, limited to the last number of days. Use 0 for all-time totals
+func GetKeyCounts(serial string, days int) []KeyCount {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	value, ok := heatmaps[serial]
+	if !ok {
+		return nil
+	}
+
+	counts := make(map[int]uint64)
+	if days > 0 {
+		cutoff := time.Now().AddDate(0, 0, -(days - 1)).Format(dayLayout)
+		for day, keys := range value.Days {
+			if day < cutoff {
+				continue
+			}
+			for keyId, count := range keys {
+				counts[keyId] += count
+			}
+		}
+	} else {
+		for keyId, key := range value.Keys {
+			counts[keyId] = key.Total
+		}
+	}
+
+	result := make([]KeyCount, 0, len(counts))
+	for keyId, count := range counts {
+		result = append(result, KeyCount{
+			KeyId:   keyId,
+			KeyName: value.Keys[keyId].KeyName,
+			Count:   count,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count == result[j].Count {
+			return result[i].KeyId < result[j].KeyId
+		}
+		return result[i].Count > result[j].Count
+	})
+	return result
+}
+
+// Reset will reset all key counters for given serial
+func Reset(serial string) uint8 {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, ok := heatmaps[serial]; !ok {
+		return 0
+	}
+	delete(heatmaps, serial)
+	delete(dirty, serial)
+
+	heatmapLocation := location + serial + ".json"
+	if common.FileExists(heatmapLocation) {
+		if err := os.Remove(heatmapLocation); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": heatmapLocation}).Error("Unable to delete key heatmap")
+			return 0
+		}
+	}
+	return 1
+}
+
+// Flush will save all changed heatmaps to the disk
+func Flush() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for serial := range dirty {
+		value, ok := heatmaps[serial]
+		if !ok {
+			continue
+		}
+
+		heatmapLocation := location + serial + ".json"
+		if err := common.SaveJsonData(heatmapLocation, value); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": heatmapLocation}).Error("Unable to save key heatmap")
+			continue
+		}
+	}
+	dirty = make(map[string]bool)
+}
+
+// Render will build color buffer for heatmap lighting, for keyboards where key packet index is an offset in color buffer.
+// Each key is colored by its press count, relative to the most pressed key
+func Render(serial string, keyboard *keyboards.Keyboard, r *rgb.ActiveRGB, length int) []byte {
+	if keyboard == nil {
+		return make([]byte, length)
+	}
+
+	for _, row := range keyboard.Row {
+		for _, key := range row.Keys {
+			for _, packetIndex := range key.PacketIndex {
+				if packetIndex+3 > length {
+					length = packetIndex + 3
+				}
+			}
+		}
+	}
+	buf := make([]byte, length)
+
+	paint(serial, keyboard, r, func(packetIndex int, color *rgb.Color) {
+		buf[packetIndex] = byte(color.Red)
+		buf[packetIndex+1] = byte(color.Green)
+		buf[packetIndex+2] = byte(color.Blue)
+	})
+	return buf
+}
+
+// Fill will set heatmap colors of r, for keyboards where key packet index is LED index. Like RGB effects, colors
+// are written to r.Buffer when it is allocated, otherwise to r.Raw and r.Output
+func Fill(serial string, keyboard *keyboards.Keyboard, r *rgb.ActiveRGB) {
+	raw := make(map[int][]byte, r.LightChannels)
+	for j := 0; j < r.LightChannels; j++ {
+		raw[j] = []byte{0, 0, 0}
+	}
+
+	if keyboard != nil {
+		paint(serial, keyboard, r, func(packetIndex int, color *rgb.Color) {
+			if len(r.Buffer) > 0 {
+				if packetIndex+(r.ColorOffset*2) < len(r.Buffer) {
+					r.Buffer[packetIndex] = byte(color.Red)
+					r.Buffer[packetIndex+r.ColorOffset] = byte(color.Green)
+					r.Buffer[packetIndex+(r.ColorOffset*2)] = byte(color.Blue)
+				}
+				return
+			}
+
+			if packetIndex < r.LightChannels {
+				raw[packetIndex] = []byte{byte(color.Red), byte(color.Green), byte(color.Blue)}
+			}
+		})
+	}
+
+	r.Raw = raw
+	if r.Inverted {
+		r.Output = rgb.SetColorInverted(raw)
+	} else {
+		r.Output = rgb.SetColor(raw)
+	}
+}
+
+// paint will call apply with heatmap color of every colored key packet index
+func paint(serial string, keyboard *keyboards.Keyboard, r *rgb.ActiveRGB, apply func(packetIndex int, color *rgb.Color)) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var totals map[int]Key
+	if value, ok := heatmaps[serial]; ok {
+		totals = value.Keys
+	}
+
+	var highest uint64 = 0
+	for _, key := range totals {
+		if key.Total > highest {
+			highest = key.Total
+		}
+	}
+
+	for _, row := range keyboard.Row {
+		for keyId, key := range row.Keys {
+			if key.NoColor {
+				continue
+			}
+
+			intensity := 0.0
+			if highest > 0 {
+				// Logarithmic scale, otherwise space and vowels wash out everything else
+				intensity = math.Log1p(float64(totals[keyId].Total)) / math.Log1p(float64(highest))
+			}
+
+			color := r.HeatmapColor(intensity)
+			for _, packetIndex := range key.PacketIndex {
+				apply(packetIndex, color)
+			}
+		}
+	}
+}
+
+// copyHeatmap will create a deep copy of a heatmap
+func copyHeatmap(value *Heatmap) Heatmap {
+	cp := Heatmap{
+		Serial:  value.Serial,
+		Device:  value.Device,
+		Created: value.Created,
+		Updated: value.Updated,
+		Keys:    make(map[int]Key, len(value.Keys)),
+		Days:    make(map[string]map[int]uint64, len(value.Days)),
+	}
+	for keyId, key := range value.Keys {
+		cp.Keys[keyId] = key
+	}
+	for day, keys := range value.Days {
+		counts := make(map[int]uint64, len(keys))
+		for keyId, count := range keys {
+			counts[keyId] = count
+		}
+		cp.Days[day] = counts
+	}
+	return cp
+}
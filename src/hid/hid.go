@@ -0,0 +1,148 @@
+package hid
+
+// Package: hid
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+// Package hid wraps github.com/sstallion/go-hid, so every HID transfer of every driver is reported to a single
+// observer, set by metrics. Transfers are attributed to device serial number, or device path when device has no serial
+// number, the same key devices are registered with. Writes and feature reports are recorded as transfers.
+// Reads are recorded only when they fail, since listeners block on them while waiting for device events.
+
+import (
+	"errors"
+	gohid "github.com/sstallion/go-hid"
+	"time"
+)
+
+const (
+	VendorIDAny  = gohid.VendorIDAny
+	ProductIDAny = gohid.ProductIDAny
+)
+
+type DeviceInfo = gohid.DeviceInfo
+type EnumFunc = gohid.EnumFunc
+
+var (
+	ErrTimeout = gohid.ErrTimeout
+	observer   func(serial string, start time.Time, err error)
+)
+
+// Device is an open HID device
+type Device struct {
+	*gohid.Device
+	serial string
+}
+
+// SetObserver will set function called after every HID transfer. Observer is set once, before devices are loaded
+func SetObserver(fn func(serial string, start time.Time, err error)) {
+	observer = fn
+}
+
+// Init will initialize HID library
+func Init() error {
+	return gohid.Init()
+}
+
+// Exit will finalize HID library
+func Exit() error {
+	return gohid.Exit()
+}
+
+// Enumerate will call enumFn for every HID device matching vendor and product id
+func Enumerate(vid, pid uint16, enumFn EnumFunc) error {
+	return gohid.Enumerate(vid, pid, enumFn)
+}
+
+// Open will open a HID device with matching vendor id, product id and serial number
+func Open(vid, pid uint16, serial string) (*Device, error) {
+	dev, err := gohid.Open(vid, pid, serial)
+	if err != nil {
+		return nil, err
+	}
+	return &Device{Device: dev, serial: serial}, nil
+}
+
+// OpenFirst will open first HID device with matching vendor id and product id
+func OpenFirst(vid, pid uint16) (*Device, error) {
+	dev, err := gohid.OpenFirst(vid, pid)
+	if err != nil {
+		return nil, err
+	}
+	return wrap(dev, ""), nil
+}
+
+// OpenPath will open a HID device with given path
+func OpenPath(path string) (*Device, error) {
+	dev, err := gohid.OpenPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return wrap(dev, path), nil
+}
+
+// wrap will resolve serial number of an opened device, falling back to its path
+func wrap(dev *gohid.Device, path string) *Device {
+	serial := ""
+	if info, err := dev.GetDeviceInfo(); err == nil {
+		serial = info.SerialNbr
+		if len(path) == 0 {
+			path = info.Path
+		}
+	}
+	if len(serial) == 0 {
+		serial = path
+	}
+	return &Device{Device: dev, serial: serial}
+}
+
+// Write will send an output report to a device
+func (d *Device) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := d.Device.Write(p)
+	d.observe(start, err)
+	return n, err
+}
+
+// SendFeatureReport will send a feature report to a device
+func (d *Device) SendFeatureReport(p []byte) (int, error) {
+	start := time.Now()
+	n, err := d.Device.SendFeatureReport(p)
+	d.observe(start, err)
+	return n, err
+}
+
+// GetFeatureReport will retrieve a feature report from a device
+func (d *Device) GetFeatureReport(p []byte) (int, error) {
+	start := time.Now()
+	n, err := d.Device.GetFeatureReport(p)
+	d.observe(start, err)
+	return n, err
+}
+
+// Read will read an input report from a device
+func (d *Device) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := d.Device.Read(p)
+	if err != nil {
+		d.observe(start, err)
+	}
+	return n, err
+}
+
+// ReadWithTimeout will read an input report from a device. Timeouts are expected while waiting for events
+func (d *Device) ReadWithTimeout(p []byte, timeout time.Duration) (int, error) {
+	start := time.Now()
+	n, err := d.Device.ReadWithTimeout(p, timeout)
+	if err != nil && !errors.Is(err, ErrTimeout) {
+		d.observe(start, err)
+	}
+	return n, err
+}
+
+// observe will report a finished transfer
+func (d *Device) observe(start time.Time, err error) {
+	if observer != nil {
+		observer(d.serial, start, err)
+	}
+}
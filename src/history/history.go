@@ -0,0 +1,648 @@
+package history
+
+// Package: history
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/fanhealth"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/stats"
+	"OpenLinkHub/src/systeminfo"
+	"OpenLinkHub/src/temperatures"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Series struct {
+	DeviceId  string `json:"deviceId"`
+	ChannelId string `json:"channelId"`
+	Metric    string `json:"metric"`
+	Name      string `json:"name"`
+	Product   string `json:"product"`
+	File      string `json:"file"`
+	rings     []*ring
+}
+
+type Point struct {
+	Time  int64   `json:"time"`
+	Value float32 `json:"value"`
+	Max   float32 `json:"max"`
+}
+
+type Result struct {
+	DeviceId   string  `json:"deviceId"`
+	ChannelId  string  `json:"channelId"`
+	Metric     string  `json:"metric"`
+	Name       string  `json:"name"`
+	Product    string  `json:"product"`
+	Resolution int64   `json:"resolution"`
+	Points     []Point `json:"points"`
+}
+
+// ring is fixed size buffer of one downsampling tier. Slot of a sample is bucket % size
+type ring struct {
+	step    int64
+	size    int64
+	offset  int64
+	buckets []int64
+	values  []float32
+	maxs    []float32
+	counts  []uint32
+	dirty   map[int64]bool
+}
+
+type Sample struct {
+	DeviceId  string  `json:"deviceId"`
+	ChannelId string  `json:"channelId"`
+	Metric    string  `json:"metric"`
+	Name      string  `json:"name"`
+	Product   string  `json:"product"`
+	Value     float32 `json:"value"`
+}
+
+type tier struct {
+	step int64 // seconds per bucket
+	size int64 // number of buckets
+}
+
+const (
+	MetricTemperature = "temperature"
+	MetricRpm         = "rpm"
+	MetricBattery     = "battery"
+	MetricPower       = "power"
+	MetricLoad        = "load" // CPU and default GPU utilization in percent
+	MetricDuty        = "duty" // Last duty in percent sent to a fan or pump
+	SystemDeviceId    = "system"
+)
+
+var (
+	mutex          sync.Mutex
+	location       = ""
+	indexLocation  = ""
+	tiersLocation  = ""
+	series         = map[string]*Series{}
+	tiers          []tier
+	recordSize     = int64(20) // bucket int64, average float32, max float32, count uint32
+	sampleInterval = time.Second
+	flushInterval  = 30 * time.Second
+	fileRegex      = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+)
+
+// Init will load stored series and start sampling
+func Init() {
+	if !config.GetConfig().History {
+		return
+	}
+
+	tiers = getTiers(config.GetConfig().HistoryTiers)
+	location = config.GetDatabasePath() + "/history/"
+	indexLocation = location + "index.json"
+	tiersLocation = location + "tiers.json"
+	if err := os.MkdirAll(location, 0755); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to create history directory")
+		return
+	}
+	load()
+	if err := common.SaveJsonData(tiersLocation, tiersConfig()); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": tiersLocation}).Error("Unable to save history tiers")
+	}
+
+	go func() {
+		sampleTicker := time.NewTicker(sampleInterval)
+		flushTicker := time.NewTicker(flushInterval)
+		defer sampleTicker.Stop()
+		defer flushTicker.Stop()
+		for {
+			select {
+			case <-sampleTicker.C:
+				record(time.Now().Unix(), Collect())
+			case <-flushTicker.C:
+				Flush()
+			}
+		}
+	}()
+}
+
+// Flush will write changed buckets of all series to disk
+func Flush() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(location) == 0 {
+		return
+	}
+
+	for _, value := range series {
+		if err := writeSeries(value); err != nil {
+			logger.Log(logger.Fields{"error": err, "file": value.File}).Error("Unable to save history")
+		}
+	}
+}
+
+// GetSeries will return all recorded series
+func GetSeries() []Series {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make([]Series, 0, len(series))
+	for _, value := range series {
+		result = append(result, Series{
+			DeviceId:  value.DeviceId,
+			ChannelId: value.ChannelId,
+			Metric:    value.Metric,
+			Name:      value.Name,
+			Product:   value.Product,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DeviceId != result[j].DeviceId {
+			return result[i].DeviceId < result[j].DeviceId
+		}
+		if result[i].ChannelId != result[j].ChannelId {
+			return result[i].ChannelId < result[j].ChannelId
+		}
+		return result[i].Metric < result[j].Metric
+	})
+	return result
+}
+
+// Query will return points of a series between from and to unix time. When resolution is 0, the finest tier
+// still holding from is used
+func Query(deviceId, channelId, metric string, from, to, resolution int64) *Result {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	value, ok := series[key(deviceId, channelId, metric)]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	if to <= 0 || to > now {
+		to = now
+	}
+	if from <= 0 {
+		from = to - 3600
+	}
+
+	var selected *ring
+	for _, r := range value.rings {
+		if resolution > 0 {
+			if r.step == resolution {
+				selected = r
+				break
+			}
+			continue
+		}
+		if now-from <= r.step*r.size {
+			selected = r
+			break
+		}
+	}
+
+	if selected == nil {
+		if resolution > 0 {
+			return nil
+		}
+		selected = value.rings[len(value.rings)-1]
+	}
+
+	result := &Result{
+		DeviceId:   value.DeviceId,
+		ChannelId:  value.ChannelId,
+		Metric:     value.Metric,
+		Name:       value.Name,
+		Product:    value.Product,
+		Resolution: selected.step,
+		Points:     make([]Point, 0),
+	}
+
+	for slot := int64(0); slot < selected.size; slot++ {
+		if selected.counts[slot] == 0 {
+			continue
+		}
+		timestamp := selected.buckets[slot] * selected.step
+		if timestamp < from-selected.step || timestamp > to {
+			continue
+		}
+		result.Points = append(result.Points, Point{
+			Time:  timestamp,
+			Value: selected.values[slot],
+			Max:   selected.maxs[slot],
+		})
+	}
+	sort.Slice(result.Points, func(i, j int) bool {
+		return result.Points[i].Time < result.Points[j].Time
+	})
+	return result
+}
+
+// Collect will return current temperatures, fan speeds, fan duties, battery levels and PSU power of all devices,
+// and system temperatures and load
+func Collect() []Sample {
+	samples := make([]Sample, 0)
+
+	// Temperatures, fan speeds and PSU power, as cached by device drivers
+	connected := devices.GetDevices()
+	for serial, device := range connected {
+		samples = append(samples, getChannels(serial, device)...)
+	}
+
+	// Fan and pump duties of drivers reporting channel health
+	for serial, duties := range fanhealth.GetDuties() {
+		device, ok := connected[serial]
+		if !ok {
+			continue
+		}
+		for channelId, duty := range duties {
+			samples = append(samples, Sample{serial, strconv.Itoa(channelId), MetricDuty, getChannelName(device, channelId), device.Product, float32(duty)})
+		}
+	}
+
+	// System temperatures
+	if temp := temperatures.GetCpuTemperature(); temp > 0 {
+		samples = append(samples, Sample{SystemDeviceId, "cpu", MetricTemperature, systeminfo.GetInfo().CPU.Model, "CPU", temp})
+	}
+	for index, gpu := range systeminfo.GetInfo().GPU {
+		if temp := temperatures.GetGpuTemperatureIndex(index); temp > 0 {
+			samples = append(samples, Sample{SystemDeviceId, "gpu" + strconv.Itoa(index), MetricTemperature, gpu.Model, "GPU", temp})
+		}
+	}
+
+	for _, storage := range temperatures.GetStorageTemperatures() {
+		if storage.Temperature > 0 {
+			samples = append(samples, Sample{SystemDeviceId, storage.Key, MetricTemperature, storage.Model, "Storage", storage.Temperature})
+		}
+	}
+
+	// System load
+	samples = append(samples, Sample{SystemDeviceId, "cpu", MetricLoad, systeminfo.GetInfo().CPU.Model, "CPU", float32(systeminfo.GetCpuUtilization())})
+	if len(systeminfo.GetInfo().GPU) > 0 {
+		samples = append(samples, Sample{SystemDeviceId, "gpu", MetricLoad, "Default GPU", "GPU", float32(systeminfo.GetGPUUtilization())})
+	}
+
+	// Battery levels
+	for serial, battery := range stats.GetBatteryStats() {
+		if battery.Level > 0 {
+			samples = append(samples, Sample{serial, "0", MetricBattery, battery.Device, battery.Device, float32(battery.Level)})
+		}
+	}
+	return samples
+}
+
+// getChannels will return temperature, fan speed and power of all device channels
+func getChannels(serial string, device *common.Device) []Sample {
+	samples := make([]Sample, 0)
+	instance := reflect.Indirect(reflect.ValueOf(device.Instance))
+	if instance.Kind() != reflect.Struct {
+		return samples
+	}
+
+	channels := instance.FieldByName("Devices")
+	if !channels.IsValid() || channels.Kind() != reflect.Map {
+		return samples
+	}
+
+	for _, channelKey := range channels.MapKeys() {
+		channel := reflect.Indirect(channels.MapIndex(channelKey))
+		if channel.Kind() != reflect.Struct {
+			continue
+		}
+
+		channelId := fmt.Sprintf("%v", channelKey.Interface())
+		name := ""
+		if value := channel.FieldByName("Name"); value.IsValid() && value.Kind() == reflect.String {
+			name = value.String()
+		}
+
+		if temperature, ok := numeric(channel.FieldByName("Temperature")); ok && temperature > 0 {
+			samples = append(samples, Sample{serial, channelId, MetricTemperature, name, device.Product, float32(temperature)})
+		}
+		if rpm, ok := numeric(channel.FieldByName("Rpm")); ok && (rpm > 0 || exists(serial, channelId, MetricRpm)) {
+			samples = append(samples, Sample{serial, channelId, MetricRpm, name, device.Product, float32(rpm)})
+		}
+
+		hasWatts := channel.FieldByName("HasWatts")
+		if hasWatts.IsValid() && hasWatts.Kind() == reflect.Bool && hasWatts.Bool() {
+			if watts, ok := numeric(channel.FieldByName("Watts")); ok {
+				samples = append(samples, Sample{serial, channelId, MetricPower, name, device.Product, float32(watts)})
+			}
+		}
+	}
+	return samples
+}
+
+// getChannelName will return name of a device channel, or empty string when device has no such channel
+func getChannelName(device *common.Device, channelId int) string {
+	instance := reflect.Indirect(reflect.ValueOf(device.Instance))
+	if instance.Kind() != reflect.Struct {
+		return ""
+	}
+
+	channels := instance.FieldByName("Devices")
+	if !channels.IsValid() || channels.Kind() != reflect.Map || channels.Type().Key().Kind() != reflect.Int {
+		return ""
+	}
+
+	channel := reflect.Indirect(channels.MapIndex(reflect.ValueOf(channelId).Convert(channels.Type().Key())))
+	if channel.Kind() != reflect.Struct {
+		return ""
+	}
+	if value := channel.FieldByName("Name"); value.IsValid() && value.Kind() == reflect.String {
+		return value.String()
+	}
+	return ""
+}
+
+// numeric will return value of a numeric struct field
+func numeric(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+// record will add samples to all tiers of their series
+func record(now int64, samples []Sample) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	created := false
+	for _, s := range samples {
+		k := key(s.DeviceId, s.ChannelId, s.Metric)
+		value, ok := series[k]
+		if !ok {
+			value = &Series{
+				DeviceId:  s.DeviceId,
+				ChannelId: s.ChannelId,
+				Metric:    s.Metric,
+				File:      fileRegex.ReplaceAllString(k, "_") + ".bin",
+				rings:     newRings(),
+			}
+			series[k] = value
+			created = true
+		}
+		value.Name = s.Name
+		value.Product = s.Product
+
+		for _, r := range value.rings {
+			r.add(now, s.Value)
+		}
+	}
+
+	if created {
+		saveIndex()
+	}
+}
+
+// add will merge value into bucket of given time, averaging samples within the same bucket
+func (r *ring) add(now int64, value float32) {
+	bucket := now / r.step
+	slot := bucket % r.size
+	if r.buckets[slot] != bucket || r.counts[slot] == 0 {
+		r.buckets[slot] = bucket
+		r.values[slot] = value
+		r.maxs[slot] = value
+		r.counts[slot] = 1
+	} else {
+		count := r.counts[slot]
+		r.values[slot] = (r.values[slot]*float32(count) + value) / float32(count+1)
+		if value > r.maxs[slot] {
+			r.maxs[slot] = value
+		}
+		r.counts[slot] = count + 1
+	}
+	r.dirty[slot] = true
+}
+
+// getTiers will convert configured history tiers, default tiers are used when configuration is not valid.
+// Memory of every series is 20 bytes per point of all tiers, about 1 MB with default tiers
+func getTiers(configured []config.HistoryTier) []tier {
+	values := make([]tier, 0, len(configured))
+	for i, t := range configured {
+		if t.Resolution <= 0 || t.Retention < t.Resolution || (i > 0 && t.Resolution <= configured[i-1].Resolution) {
+			logger.Log(logger.Fields{"tiers": configured}).Warn("Invalid history tiers, using default tiers")
+			return getTiers(config.DefaultHistoryTiers())
+		}
+		values = append(values, tier{step: t.Resolution, size: t.Retention / t.Resolution})
+	}
+	if len(values) == 0 {
+		return getTiers(config.DefaultHistoryTiers())
+	}
+	return values
+}
+
+// newRings will return empty rings for all tiers
+func newRings() []*ring {
+	rings := make([]*ring, len(tiers))
+	offset := int64(0)
+	for i, t := range tiers {
+		rings[i] = &ring{
+			step:    t.step,
+			size:    t.size,
+			offset:  offset,
+			buckets: make([]int64, t.size),
+			values:  make([]float32, t.size),
+			maxs:    make([]float32, t.size),
+			counts:  make([]uint32, t.size),
+			dirty:   make(map[int64]bool),
+		}
+		offset += t.size * recordSize
+	}
+	return rings
+}
+
+// fileSize will return size of a series file
+func fileSize() int64 {
+	size := int64(0)
+	for _, t := range tiers {
+		size += t.size * recordSize
+	}
+	return size
+}
+
+// writeSeries will write changed buckets of a series to its file
+func writeSeries(value *Series) error {
+	dirty := false
+	for _, r := range value.rings {
+		if len(r.dirty) > 0 {
+			dirty = true
+			break
+		}
+	}
+	if !dirty {
+		return nil
+	}
+
+	file, err := os.OpenFile(location+value.File, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != fileSize() {
+		if err = file.Truncate(fileSize()); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, recordSize)
+	for _, r := range value.rings {
+		for slot := range r.dirty {
+			binary.LittleEndian.PutUint64(buf[0:8], uint64(r.buckets[slot]))
+			binary.LittleEndian.PutUint32(buf[8:12], math.Float32bits(r.values[slot]))
+			binary.LittleEndian.PutUint32(buf[12:16], math.Float32bits(r.maxs[slot]))
+			binary.LittleEndian.PutUint32(buf[16:20], r.counts[slot])
+			if _, err = file.WriteAt(buf, r.offset+slot*recordSize); err != nil {
+				return err
+			}
+		}
+		r.dirty = make(map[int64]bool)
+	}
+	return nil
+}
+
+// readSeries will load buckets of a series from its file
+func readSeries(value *Series) error {
+	file, err := os.Open(location + value.File)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != fileSize() {
+		return fmt.Errorf("invalid file size %d", len(data))
+	}
+
+	for _, r := range value.rings {
+		for slot := int64(0); slot < r.size; slot++ {
+			pos := r.offset + slot*recordSize
+			r.buckets[slot] = int64(binary.LittleEndian.Uint64(data[pos : pos+8]))
+			r.values[slot] = math.Float32frombits(binary.LittleEndian.Uint32(data[pos+8 : pos+12]))
+			r.maxs[slot] = math.Float32frombits(binary.LittleEndian.Uint32(data[pos+12 : pos+16]))
+			r.counts[slot] = binary.LittleEndian.Uint32(data[pos+16 : pos+20])
+		}
+	}
+	return nil
+}
+
+// load will read series index and data of all series
+func load() {
+	if !common.FileExists(indexLocation) {
+		return
+	}
+
+	file, err := os.Open(indexLocation)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "location": indexLocation}).Error("Unable to load history index")
+		return
+	}
+
+	var index []Series
+	err = json.NewDecoder(file).Decode(&index)
+	_ = file.Close()
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "location": indexLocation}).Error("Unable to decode history index")
+		return
+	}
+
+	// Series files are laid out by tiers, so recorded history is discarded when tiers are changed
+	changed := tiersChanged()
+	if changed {
+		logger.Log(logger.Fields{"tiers": tiersConfig()}).Warn("History tiers are changed, recorded history is discarded")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, item := range index {
+		value := item
+		value.rings = newRings()
+		if changed {
+			_ = os.Remove(location + value.File)
+		} else if common.FileExists(location + value.File) {
+			if err = readSeries(&value); err != nil {
+				logger.Log(logger.Fields{"error": err, "file": value.File}).Warn("Unable to read history, starting empty")
+				value.rings = newRings()
+			}
+		}
+		series[key(value.DeviceId, value.ChannelId, value.Metric)] = &value
+	}
+}
+
+// tiersChanged will return true when tiers differ from tiers of recorded series. History recorded before tiers were
+// configurable uses default tiers
+func tiersChanged() bool {
+	stored := config.DefaultHistoryTiers()
+	if buf, err := os.ReadFile(tiersLocation); err == nil {
+		if err = json.Unmarshal(buf, &stored); err != nil {
+			return true
+		}
+	}
+	return !reflect.DeepEqual(stored, tiersConfig())
+}
+
+// tiersConfig will return current tiers in configuration format
+func tiersConfig() []config.HistoryTier {
+	values := make([]config.HistoryTier, len(tiers))
+	for i, t := range tiers {
+		values[i] = config.HistoryTier{Resolution: t.step, Retention: t.step * t.size}
+	}
+	return values
+}
+
+// saveIndex will save list of recorded series
+func saveIndex() {
+	index := make([]Series, 0, len(series))
+	for _, value := range series {
+		index = append(index, *value)
+	}
+	if err := common.SaveJsonData(indexLocation, index); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": indexLocation}).Error("Unable to save history index")
+	}
+}
+
+// exists will return true if series is already recorded. Caller must not hold the mutex
+func exists(deviceId, channelId, metric string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	_, ok := series[key(deviceId, channelId, metric)]
+	return ok
+}
+
+// key will return series key
+func key(deviceId, channelId, metric string) string {
+	return deviceId + ":" + channelId + ":" + metric
+}
@@ -15,7 +15,6 @@ import (
 )
 
 var (
-	pwd       = ""
 	location  = ""
 	keyboards = map[string]Keyboard{}
 )
@@ -135,8 +134,7 @@ type Key struct {
 
 // Init will load and initialize keyboard data
 func Init() {
-	pwd = config.GetConfig().ConfigPath
-	location = pwd + "/database/keyboard/"
+	location = config.GetDatabasePath() + "/keyboard/"
 
 	files, err := os.ReadDir(location)
 	if err != nil {
@@ -185,6 +183,46 @@ func Init() {
 	}
 }
 
+// Reload will re-read keyboard layouts. Layouts are used by keyboards connected after reload.
+// Running layouts are kept when any file is invalid
+func Reload() []string {
+	files, err := os.ReadDir(location)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	loaded := make(map[string]Keyboard)
+	problems := make([]string, 0)
+	for _, fileInfo := range files {
+		pullPath := location + fileInfo.Name()
+		if fileInfo.IsDir() || !common.IsValidExtension(pullPath, ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(pullPath)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		var keyboard Keyboard
+		if err = json.Unmarshal(data, &keyboard); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", pullPath, err.Error()))
+			continue
+		}
+		if len(keyboard.Layout) < 1 {
+			problems = append(problems, fmt.Sprintf("%s: keyboard has no layout field defined", pullPath))
+			continue
+		}
+		loaded[fmt.Sprintf("%s-%s", keyboard.Key, keyboard.Layout)] = keyboard
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	keyboards = loaded
+	return nil
+}
+
 // GetKeyboard will return Keyboard struct for a given keyboard type
 func GetKeyboard(key string) *Keyboard {
 	if keyboard, ok := keyboards[key]; ok {
@@ -14,7 +14,6 @@ import (
 )
 
 var (
-	pwd       = ""
 	location  = ""
 	languages = map[string]Language{}
 )
@@ -27,8 +26,7 @@ type Language struct {
 
 // Init will initialize a new language object
 func Init() {
-	pwd = config.GetConfig().ConfigPath
-	location = pwd + "/database/language/"
+	location = config.GetDatabasePath() + "/language/"
 
 	files, err := os.ReadDir(location)
 	if err != nil {
@@ -0,0 +1,498 @@
+package ledstream
+
+// Package: ledstream
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/language"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/openrgb"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxTargets       = 256                   // Target index is a single byte
+	minFrameInterval = 15 * time.Millisecond // 60 fps, with some tolerance for client timer jitter
+	pingInterval     = 20 * time.Second
+)
+
+// Target is a claimed device or channel
+type Target struct {
+	Index  int    `json:"index"`
+	Serial string `json:"serial"`
+	Name   string `json:"name"`
+	Leds   int    `json:"leds"`
+
+	device     string
+	controller *common.OpenRGBController
+	last       time.Time
+}
+
+// Available is a device or channel that can be claimed
+type Available struct {
+	Serial  string `json:"serial"`
+	Name    string `json:"name"`
+	Leds    int    `json:"leds"`
+	Claimed bool   `json:"claimed"`
+}
+
+// command is a text message sent by a client
+type command struct {
+	Claim   []string `json:"claim"`
+	Release []string `json:"release"`
+}
+
+// reply is a text message sent to a client. Type is available, claimed, released or error
+type reply struct {
+	Type    string      `json:"type"`
+	Data    interface{} `json:"data,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+// deviceClaim tracks claimed targets of a device
+type deviceClaim struct {
+	count   int
+	restore bool // OpenRGB integration was enabled by the stream and is disabled on release
+}
+
+// session is a single WebSocket client
+type session struct {
+	conn    *wsConn
+	address string
+	targets []*Target
+}
+
+var (
+	location = ""
+	mutex    sync.Mutex
+	sessions = map[*session]bool{}
+	owners   = map[string]*session{}
+	claimed  = map[string]*deviceClaim{}
+)
+
+// Init will disable OpenRGB integration left enabled by streams of previous run. It runs before devices are loaded,
+// so devices start on their own effects even when previous run was interrupted
+func Init() {
+	location = config.GetDatabasePath() + "/ledstream.json"
+	if !common.FileExists(location) {
+		return
+	}
+
+	var previous []string
+	file, err := os.Open(location)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to load LED stream claims")
+	} else {
+		if err = json.NewDecoder(file).Decode(&previous); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to decode LED stream claims")
+		}
+		_ = file.Close()
+	}
+
+	for _, serial := range previous {
+		if devices.UpdateSavedProfiles(serial, DisableIntegration) > 0 {
+			logger.Log(logger.Fields{"serial": serial}).Info("OpenRGB integration from previous LED stream is disabled")
+		}
+	}
+	save()
+}
+
+// IsStreaming will return true if OpenRGB integration of a device is enabled only for an active stream
+func IsStreaming(device string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	dc, ok := claimed[device]
+	return ok && dc.restore
+}
+
+// DisableIntegration will turn off OpenRGB integration in a saved device profile
+func DisableIntegration(profile map[string]interface{}) bool {
+	if enabled, ok := profile["OpenRGBIntegration"].(bool); !ok || !enabled {
+		return false
+	}
+	profile["OpenRGBIntegration"] = false
+	return true
+}
+
+// save will persist devices whose OpenRGB integration is enabled by a stream, so it can be reverted after a crash
+func save() {
+	if len(location) == 0 {
+		return
+	}
+
+	list := make([]string, 0, len(claimed))
+	for device, dc := range claimed {
+		if dc.restore {
+			list = append(list, device)
+		}
+	}
+	slices.Sort(list)
+	if err := common.SaveJsonData(location, list); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to save LED stream claims")
+	}
+}
+
+// Handle will upgrade the request to WebSocket and stream LED frames until the client disconnects
+func Handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrade(w, r)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "client": r.RemoteAddr}).Warn("Unable to open LED stream")
+		return
+	}
+
+	s := &session{conn: conn, address: r.RemoteAddr}
+	mutex.Lock()
+	sessions[s] = true
+	mutex.Unlock()
+
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		s.close()
+	}()
+	go s.keepAlive(done)
+
+	s.send(&reply{Type: "available", Data: available()})
+	for {
+		op, data, err := conn.read()
+		if err != nil {
+			return
+		}
+
+		switch op {
+		case opText:
+			s.command(data)
+		case opBinary:
+			if err = s.frame(data); err != nil {
+				s.fail(err)
+			}
+		}
+	}
+}
+
+// Stop will disconnect all clients and restore device effects
+func Stop() {
+	mutex.Lock()
+	list := make([]*session, 0, len(sessions))
+	for s := range sessions {
+		list = append(list, s)
+	}
+	mutex.Unlock()
+
+	for _, s := range list {
+		s.close()
+	}
+}
+
+// command will process claim and release requests
+func (s *session) command(data []byte) {
+	req := command{}
+	if err := json.Unmarshal(data, &req); err != nil {
+		s.send(&reply{Type: "error", Message: language.GetValue("txtUnableToValidateRequest")})
+		return
+	}
+
+	if len(req.Release) > 0 {
+		s.send(&reply{Type: "released", Data: s.release(req.Release)})
+	}
+
+	if len(req.Claim) > 0 {
+		targets, err := s.claim(req.Claim)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		s.send(&reply{Type: "claimed", Data: targets})
+	}
+}
+
+// claim will take exclusive ownership of all serials, or none of them
+func (s *session) claim(serials []string) ([]*Target, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	acquired := make([]*Target, 0, len(serials))
+	for _, serial := range serials {
+		target, err := s.acquire(serial)
+		if err != nil {
+			for _, t := range acquired {
+				s.releaseTarget(t)
+			}
+			return nil, err
+		}
+		acquired = append(acquired, target)
+	}
+	return acquired, nil
+}
+
+// acquire will claim a single device or channel and switch its device to external control
+func (s *session) acquire(serial string) (*Target, error) {
+	if owner, ok := owners[serial]; ok {
+		if owner == s {
+			return nil, fmt.Errorf("%s is already claimed by this client", serial)
+		}
+		return nil, fmt.Errorf("%s is claimed by another client", serial)
+	}
+	// Slots of released targets are reused, so indexes stay within a single byte
+	index := slices.Index(s.targets, nil)
+	if index < 0 {
+		if len(s.targets) >= maxTargets {
+			return nil, fmt.Errorf("a client can claim at most %d targets", maxTargets)
+		}
+		index = len(s.targets)
+	}
+
+	controller := findController(serial)
+	device := deviceOf(serial)
+	if controller == nil || len(device) == 0 {
+		return nil, fmt.Errorf("%s: %s", serial, language.GetValue("txtNonExistingDevice"))
+	}
+
+	leds := len(controller.Colors) / 3
+	if leds == 0 {
+		return nil, fmt.Errorf("%s has no addressable LEDs", serial)
+	}
+
+	dc, ok := claimed[device]
+	if !ok {
+		dc = &deviceClaim{}
+		if !integrationEnabled(device) {
+			if !devices.HasMethod(device, "ProcessSetOpenRgbIntegration", reflect.Bool) {
+				return nil, fmt.Errorf("%s: %s", serial, language.GetValue("txtOpenRGBIntegrationError"))
+			}
+			// Claim is persisted first, so integration enabled right before a crash is still reverted
+			dc.restore = true
+			claimed[device] = dc
+			save()
+
+			result := devices.GetMethodStatus(devices.CallDeviceMethod(device, "ProcessSetOpenRgbIntegration", true))
+			if result != 1 {
+				delete(claimed, device)
+				save()
+				if result == 2 {
+					return nil, fmt.Errorf("%s: %s", serial, language.GetValue("txtOpenRGBClusterEnabled"))
+				}
+				return nil, fmt.Errorf("%s: %s", serial, language.GetValue("txtOpenRGBIntegrationError"))
+			}
+		}
+		claimed[device] = dc
+	}
+	dc.count++
+
+	target := &Target{
+		Index:      index,
+		Serial:     serial,
+		Name:       controller.Name,
+		Leds:       leds,
+		device:     device,
+		controller: controller,
+	}
+	if index < len(s.targets) {
+		s.targets[index] = target
+	} else {
+		s.targets = append(s.targets, target)
+	}
+	owners[serial] = s
+
+	logger.Log(logger.Fields{"serial": serial, "client": s.address}).Info("LED stream claimed device")
+	return target, nil
+}
+
+// release will release given serials owned by the session and return released ones
+func (s *session) release(serials []string) []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	released := make([]string, 0, len(serials))
+	for _, target := range s.targets {
+		if target != nil && slices.Contains(serials, target.Serial) {
+			s.releaseTarget(target)
+			released = append(released, target.Serial)
+		}
+	}
+	return released
+}
+
+// releaseTarget will drop ownership and restore device effect when its last target is released
+func (s *session) releaseTarget(target *Target) {
+	delete(owners, target.Serial)
+	s.targets[target.Index] = nil
+
+	if dc, ok := claimed[target.device]; ok {
+		dc.count--
+		if dc.count < 1 {
+			delete(claimed, target.device)
+			if dc.restore {
+				devices.CallDeviceMethod(target.device, "ProcessSetOpenRgbIntegration", false)
+				save()
+			}
+		}
+	}
+	logger.Log(logger.Fields{"serial": target.Serial, "client": s.address}).Info("LED stream released device")
+}
+
+// frame will apply a binary frame. Frame is one or more blocks of target index byte followed by RGB bytes of all target LEDs
+func (s *session) frame(data []byte) error {
+	now := time.Now()
+	for pos := 0; pos < len(data); {
+		index := int(data[pos])
+
+		mutex.Lock()
+		var target *Target
+		if index < len(s.targets) {
+			target = s.targets[index]
+		}
+		mutex.Unlock()
+
+		if target == nil {
+			return fmt.Errorf("target index %d is not claimed", index)
+		}
+
+		end := pos + 1 + target.Leds*3
+		if end > len(data) {
+			return fmt.Errorf("target %d expects %d bytes, got %d", index, target.Leds*3, len(data)-pos-1)
+		}
+
+		// Frames above 60 fps are dropped
+		if now.Sub(target.last) >= minFrameInterval {
+			target.last = now
+			target.controller.WriteColorEx(data[pos+1:end], target.controller.ChannelId)
+		}
+		pos = end
+	}
+	return nil
+}
+
+// keepAlive will ping the client to detect dead connections
+func (s *session) keepAlive(done chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := s.conn.write(opPing, nil); err != nil {
+				s.conn.close()
+				return
+			}
+		}
+	}
+}
+
+// send will send reply as a text message
+func (s *session) send(value *reply) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if err = s.conn.write(opText, data); err != nil {
+		s.conn.close()
+	}
+}
+
+// fail will send error message
+func (s *session) fail(err error) {
+	s.send(&reply{Type: "error", Message: err.Error()})
+}
+
+// close will release all targets of the session and close its connection
+func (s *session) close() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !sessions[s] {
+		return
+	}
+	delete(sessions, s)
+
+	for _, target := range s.targets {
+		if target != nil {
+			s.releaseTarget(target)
+		}
+	}
+	s.conn.close()
+}
+
+// available will return all devices and channels that can be streamed to
+func available() []Available {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	list := make([]Available, 0)
+	seen := make(map[string]bool)
+	controllers := openrgb.GetDeviceControllers()
+	for i := len(controllers) - 1; i >= 0; i-- {
+		controller := controllers[i]
+		if len(controller.Serial) == 0 || seen[controller.Serial] || len(controller.Colors) < 3 || len(deviceOf(controller.Serial)) == 0 {
+			continue
+		}
+		seen[controller.Serial] = true
+
+		_, busy := owners[controller.Serial]
+		list = append(list, Available{
+			Serial:  controller.Serial,
+			Name:    controller.Name,
+			Leds:    len(controller.Colors) / 3,
+			Claimed: busy,
+		})
+	}
+	return list
+}
+
+// findController will return controller registered for serial. Latest registration wins, since controllers
+// of a replugged device are appended to the list
+func findController(serial string) *common.OpenRGBController {
+	controllers := openrgb.GetDeviceControllers()
+	for i := len(controllers) - 1; i >= 0; i-- {
+		if controllers[i].Serial == serial {
+			return controllers[i]
+		}
+	}
+	return nil
+}
+
+// deviceOf will return serial of device owning the controller. Channel controllers use <device serial>-<channel> format
+func deviceOf(serial string) string {
+	if devices.GetDevice(serial) != nil {
+		return serial
+	}
+	if i := strings.LastIndex(serial, "-"); i > 0 && devices.GetDevice(serial[:i]) != nil {
+		return serial[:i]
+	}
+	return ""
+}
+
+// integrationEnabled will return true if OpenRGB integration is already enabled on the device
+func integrationEnabled(serial string) bool {
+	instance := reflect.Indirect(reflect.ValueOf(devices.GetDevice(serial)))
+	if instance.Kind() != reflect.Struct {
+		return false
+	}
+
+	profile := instance.FieldByName("DeviceProfile")
+	if !profile.IsValid() {
+		return false
+	}
+	profile = reflect.Indirect(profile)
+	if profile.Kind() != reflect.Struct {
+		return false
+	}
+
+	enabled := profile.FieldByName("OpenRGBIntegration")
+	return enabled.IsValid() && enabled.Kind() == reflect.Bool && enabled.Bool()
+}
@@ -0,0 +1,246 @@
+package ledstream
+
+// Package: ledstream
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RFC 6455 opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const (
+	websocketGuid  = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxMessageSize = 1 << 20
+	readTimeout    = 60 * time.Second
+	writeTimeout   = 5 * time.Second
+)
+
+// wsConn is a minimal server side WebSocket connection
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex
+}
+
+// upgrade will perform WebSocket handshake and take over HTTP connection
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, reject(w, http.StatusBadRequest, "not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, reject(w, http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	if !localOrigin(r) {
+		return nil, reject(w, http.StatusForbidden, "origin not allowed")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if len(key) == 0 {
+		return nil, reject(w, http.StatusBadRequest, "missing Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, reject(w, http.StatusInternalServerError, "connection does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha1.Sum([]byte(key + websocketGuid))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n"
+
+	_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err = conn.Write([]byte(response)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+// reject will respond with HTTP error and return it
+func reject(w http.ResponseWriter, code int, message string) error {
+	http.Error(w, message, code)
+	return errors.New(message)
+}
+
+// localOrigin will return true for clients without Origin header, pages served by this daemon and pages served
+// from the local machine. Browsers always send Origin, so other web pages can not take over the LEDs
+func localOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || len(u.Host) == 0 {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	switch strings.ToLower(u.Hostname()) {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// headerContains will return true if comma separated header contains token
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// read will return next text or binary message. Control frames are handled internally
+func (c *wsConn) read() (byte, []byte, error) {
+	var message []byte
+	var messageOp byte
+
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err = c.write(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = c.write(opClose, payload)
+			return 0, nil, io.EOF
+		case opText, opBinary:
+			if messageOp != 0 {
+				return 0, nil, errors.New("unexpected data frame inside fragmented message")
+			}
+			messageOp = op
+		case opContinuation:
+			if messageOp == 0 {
+				return 0, nil, errors.New("unexpected continuation frame")
+			}
+		default:
+			return 0, nil, errors.New("unknown opcode")
+		}
+
+		if len(message)+len(payload) > maxMessageSize {
+			return 0, nil, errors.New("message too large")
+		}
+		message = append(message, payload...)
+		if fin {
+			return messageOp, message, nil
+		}
+	}
+}
+
+// readFrame will read a single client frame and unmask its payload
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	op := header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("client frame is not masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, errors.New("frame too large")
+	}
+
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(c.reader, mask); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// write will send a single unmasked server frame
+func (c *wsConn) write(op byte, payload []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	header := []byte{0x80 | op, 0}
+	switch {
+	case len(payload) < 126:
+		header[1] = byte(len(payload))
+	case len(payload) <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// close will close underlying connection
+func (c *wsConn) close() {
+	_ = c.conn.Close()
+}
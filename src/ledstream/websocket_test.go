@@ -0,0 +1,33 @@
+package ledstream
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocalOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"no origin", "", true},
+		{"daemon page", "http://192.168.1.10:27003", true},
+		{"localhost", "http://localhost:8080", true},
+		{"loopback", "http://127.0.0.1:3000", true},
+		{"loopback v6", "http://[::1]:3000", true},
+		{"foreign page", "https://example.com", false},
+		{"foreign page on daemon port", "http://192.168.1.10:8080", false},
+		{"malformed", "null", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "http://192.168.1.10:27003/api/color/stream", nil)
+		if len(test.origin) > 0 {
+			r.Header.Set("Origin", test.origin)
+		}
+		if got := localOrigin(r); got != test.want {
+			t.Errorf("%s: localOrigin(%q) = %v, want %v", test.name, test.origin, got, test.want)
+		}
+	}
+}
@@ -132,6 +132,11 @@ func archiveLog(logFilename string) error {
 	return os.Remove(logFilename)
 }
 
+// SetLevel will change log level of running logger
+func SetLevel(level string) {
+	logLevel = levelFromString(level)
+}
+
 // levelFromString will convert string level to integer
 func levelFromString(level string) common.LogLevel {
 	switch strings.ToLower(level) {
@@ -42,7 +42,6 @@ type Tracker struct {
 }
 
 var (
-	pwd      = ""
 	location = ""
 	macros   = map[int]Macro{}
 	mutex    sync.Mutex
@@ -50,8 +49,7 @@ var (
 
 // Init will load all available macro profiles
 func Init() {
-	pwd = config.GetConfig().ConfigPath
-	location = pwd + "/database/macros/"
+	location = config.GetDatabasePath() + "/macros/"
 
 	files, err := os.ReadDir(location)
 	if err != nil {
@@ -89,6 +87,48 @@ func Init() {
 	}
 }
 
+// Reload will re-read all macro profiles. Running macros are kept when any profile is invalid
+func Reload() []string {
+	files, err := os.ReadDir(location)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	loaded := make(map[int]Macro)
+	problems := make([]string, 0)
+	for _, fi := range files {
+		profileLocation := location + fi.Name()
+		if fi.IsDir() || !common.IsValidExtension(profileLocation, ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(profileLocation)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		var profile Macro
+		if err = json.Unmarshal(data, &profile); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", profileLocation, err.Error()))
+			continue
+		}
+		if _, ok := loaded[profile.Id]; ok {
+			problems = append(problems, fmt.Sprintf("%s: duplicate macro id %d", profileLocation, profile.Id))
+			continue
+		}
+		loaded[profile.Id] = profile
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	macros = loaded
+	return nil
+}
+
 // GetProfile will return macro profile based on macro ID
 func GetProfile(macroId int) *Macro {
 	if val, ok := macros[macroId]; ok {
@@ -108,7 +148,7 @@ func DeleteMacroValue(macroId, macroIndex int) uint8 {
 	defer mutex.Unlock()
 
 	if val, ok := macros[macroId]; ok {
-		profile := fmt.Sprintf("%s/database/macros/%s.json", config.GetConfig().ConfigPath, strings.ToLower(val.Name))
+		profile := fmt.Sprintf("%s/macros/%s.json", config.GetDatabasePath(), strings.ToLower(val.Name))
 		if _, ok := val.Actions[macroIndex]; ok {
 			delete(val.Actions, macroIndex)
 			macros[macroId] = val
@@ -147,7 +187,7 @@ func UpdateMacroValue(macroId, macroIndex int, macroAction *Actions) uint8 {
 	mutex.Lock()
 	defer mutex.Unlock()
 	if val, ok := macros[macroId]; ok {
-		profile := fmt.Sprintf("%s/database/macros/%s.json", config.GetConfig().ConfigPath, strings.ToLower(val.Name))
+		profile := fmt.Sprintf("%s/macros/%s.json", config.GetDatabasePath(), strings.ToLower(val.Name))
 		if action, ok := val.Actions[macroIndex]; ok {
 			if macroAction.ActionHold {
 				if !validatePressAndHold(macroId) {
@@ -174,7 +214,7 @@ func UpdateMacroSettings(macroId, macroRepeat, macroDelay int) uint8 {
 	mutex.Lock()
 	defer mutex.Unlock()
 	if val, ok := macros[macroId]; ok {
-		profile := fmt.Sprintf("%s/database/macros/%s.json", config.GetConfig().ConfigPath, strings.ToLower(val.Name))
+		profile := fmt.Sprintf("%s/macros/%s.json", config.GetDatabasePath(), strings.ToLower(val.Name))
 		val.Repeat = macroRepeat
 		val.RepeatDelay = macroDelay
 		macros[macroId] = val
@@ -190,7 +230,7 @@ func DeleteMacroProfile(macroId int) uint8 {
 	defer mutex.Unlock()
 
 	if val, ok := macros[macroId]; ok {
-		profile := fmt.Sprintf("%s/database/macros/%s.json", config.GetConfig().ConfigPath, strings.ToLower(val.Name))
+		profile := fmt.Sprintf("%s/macros/%s.json", config.GetDatabasePath(), strings.ToLower(val.Name))
 		if common.FileExists(profile) {
 			err := os.Remove(profile)
 			if err != nil {
@@ -209,7 +249,7 @@ func NewMacroProfile(macroName string) uint8 {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	profile := fmt.Sprintf("%s/database/macros/%s.json", config.GetConfig().ConfigPath, strings.ToLower(macroName))
+	profile := fmt.Sprintf("%s/macros/%s.json", config.GetDatabasePath(), strings.ToLower(macroName))
 	if common.FileExists(profile) {
 		return 0
 	}
@@ -241,7 +281,7 @@ func NewMacroProfileValue(macroId int, macroAction *Actions) uint8 {
 	defer mutex.Unlock()
 
 	if val, ok := macros[macroId]; ok {
-		profile := fmt.Sprintf("%s/database/macros/%s.json", config.GetConfig().ConfigPath, strings.ToLower(val.Name))
+		profile := fmt.Sprintf("%s/macros/%s.json", config.GetDatabasePath(), strings.ToLower(val.Name))
 		if common.FileExists(profile) {
 			length := len(val.Actions)
 			val.Actions[length] = Actions{
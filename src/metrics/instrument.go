@@ -0,0 +1,190 @@
+package metrics
+
+// Package: metrics
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Histogram struct {
+	Buckets []uint64
+	Count   uint64
+	Sum     float64
+}
+
+type Transfer struct {
+	Writes            uint64
+	Errors            uint64
+	ConsecutiveErrors uint64
+	LastSuccess       time.Time
+	LastError         time.Time
+	Latency           Histogram
+}
+
+type Loop struct {
+	Last     float64
+	Duration Histogram
+}
+
+type Connection struct {
+	Product   string
+	Connected bool
+	Changed   time.Time
+}
+
+const (
+	ModeSoftware = "software"
+	ModeHardware = "hardware"
+)
+
+var (
+	im sync.Mutex
+
+	// Upper bounds in seconds, shared by all histograms
+	latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+	transfers      = make(map[string]*Transfer)  // key: serial
+	loops          = make(map[string]*Loop)      // key: serial
+	connections    = make(map[string]Connection) // key: serial
+	modes          = make(map[string]string)     // key: serial
+	started        = time.Now()
+)
+
+// ObserveTransfer records a single HID transfer to a device. Call with time when transfer started
+func ObserveTransfer(serial string, start time.Time, err error) {
+	elapsed := time.Since(start).Seconds()
+
+	im.Lock()
+	defer im.Unlock()
+
+	t, ok := transfers[serial]
+	if !ok {
+		t = &Transfer{Latency: newHistogram()}
+		transfers[serial] = t
+	}
+	t.Writes++
+	if err != nil {
+		t.Errors++
+		t.ConsecutiveErrors++
+		t.LastError = time.Now()
+	} else {
+		t.ConsecutiveErrors = 0
+		t.LastSuccess = time.Now()
+	}
+	t.Latency.observe(elapsed)
+}
+
+// ObserveLoop records duration of a single device refresh loop. Call with time when loop started
+func ObserveLoop(serial string, start time.Time) {
+	elapsed := time.Since(start).Seconds()
+
+	im.Lock()
+	defer im.Unlock()
+
+	l, ok := loops[serial]
+	if !ok {
+		l = &Loop{Duration: newHistogram()}
+		loops[serial] = l
+	}
+	l.Last = elapsed
+	l.Duration.observe(elapsed)
+}
+
+// SetConnected updates device connection state. Empty product keeps previously known product
+func SetConnected(serial, product string, connected bool) {
+	im.Lock()
+	defer im.Unlock()
+
+	c := connections[serial]
+	if len(product) > 0 {
+		c.Product = product
+	}
+	if c.Connected != connected || c.Changed.IsZero() {
+		c.Changed = time.Now()
+	}
+	c.Connected = connected
+	connections[serial] = c
+}
+
+// SetMode records whether device is in software or hardware mode
+func SetMode(serial, mode string) {
+	im.Lock()
+	defer im.Unlock()
+	modes[serial] = mode
+}
+
+// GetModes return copy of known device modes
+func GetModes() map[string]string {
+	im.Lock()
+	defer im.Unlock()
+	cp := make(map[string]string, len(modes))
+	for k, v := range modes {
+		cp[k] = v
+	}
+	return cp
+}
+
+// GetTransfers return copy of HID transfer metrics
+func GetTransfers() map[string]Transfer {
+	im.Lock()
+	defer im.Unlock()
+	cp := make(map[string]Transfer, len(transfers))
+	for k, v := range transfers {
+		cp[k] = Transfer{
+			Writes:            v.Writes,
+			Errors:            v.Errors,
+			ConsecutiveErrors: v.ConsecutiveErrors,
+			LastSuccess:       v.LastSuccess,
+			LastError:         v.LastError,
+			Latency:           v.Latency.clone(),
+		}
+	}
+	return cp
+}
+
+// GetLoops return copy of device loop metrics
+func GetLoops() map[string]Loop {
+	im.Lock()
+	defer im.Unlock()
+	cp := make(map[string]Loop, len(loops))
+	for k, v := range loops {
+		cp[k] = Loop{Last: v.Last, Duration: v.Duration.clone()}
+	}
+	return cp
+}
+
+// GetConnections return copy of device connection states
+func GetConnections() map[string]Connection {
+	im.Lock()
+	defer im.Unlock()
+	cp := make(map[string]Connection, len(connections))
+	for k, v := range connections {
+		cp[k] = v
+	}
+	return cp
+}
+
+// newHistogram returns empty histogram with default buckets
+func newHistogram() Histogram {
+	return Histogram{Buckets: make([]uint64, len(latencyBuckets))}
+}
+
+// observe adds value to histogram. Buckets are cumulative
+func (h *Histogram) observe(value float64) {
+	i := sort.SearchFloat64s(latencyBuckets, value)
+	for ; i < len(h.Buckets); i++ {
+		h.Buckets[i]++
+	}
+	h.Count++
+	h.Sum += value
+}
+
+// clone returns deep copy of histogram
+func (h *Histogram) clone() Histogram {
+	buckets := make([]uint64, len(h.Buckets))
+	copy(buckets, h.Buckets)
+	return Histogram{Buckets: buckets, Count: h.Count, Sum: h.Sum}
+}
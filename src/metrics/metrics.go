@@ -5,12 +5,17 @@ package metrics
 // License: GPL-3.0 or later
 
 import (
+	"OpenLinkHub/src/fanhealth"
+	"OpenLinkHub/src/hid"
+	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/systeminfo"
 	"OpenLinkHub/src/temperatures"
 	"fmt"
 	"net/http"
+	"runtime"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Header struct {
@@ -48,6 +53,28 @@ type DefaultTemp struct {
 	Temperature float64
 }
 
+type Rail struct {
+	Product   string
+	Serial    string
+	ChannelId string
+	Rail      string
+	Volts     float64
+	Amps      float64
+	Watts     float64
+	HasVolts  bool
+	HasAmps   bool
+	HasWatts  bool
+}
+
+type Lcd struct {
+	Product    string
+	Serial     string
+	ChannelId  string
+	Mode       uint8
+	Rotation   uint8
+	Brightness uint8
+}
+
 var (
 	mu sync.RWMutex
 
@@ -55,6 +82,11 @@ var (
 	deviceMetrics  = make(map[string]Header)      // key: serial:channel
 	storageMetrics = make(map[string]StorageTemp) // key: hwmonDevice
 	defaultMetrics = make(map[string]DefaultTemp) // key: model
+	railMetrics    = make(map[string]Rail)        // key: serial:channel:rail
+	lcdMetrics     = make(map[string]Lcd)         // key: serial:channel
+
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
 )
 
 // Init initializes internal maps (optional in Go, but for symmetry)
@@ -63,6 +95,9 @@ func Init() {
 	deviceMetrics = make(map[string]Header)
 	storageMetrics = make(map[string]StorageTemp)
 	defaultMetrics = make(map[string]DefaultTemp)
+	railMetrics = make(map[string]Rail)
+	lcdMetrics = make(map[string]Lcd)
+	hid.SetObserver(ObserveTransfer)
 }
 
 // PopulateDefault adds default temperature metrics (e.g., CPU, GPU)
@@ -105,6 +140,46 @@ func Populate(header *Header) {
 	mu.Unlock()
 }
 
+// PopulateRail fills in PSU rail voltage, current and power
+func PopulateRail(rail *Rail) {
+	key := rail.Serial + ":" + rail.ChannelId + ":" + rail.Rail
+
+	mu.Lock()
+	railMetrics[key] = *rail
+	mu.Unlock()
+}
+
+// PopulateLcd fills in LCD state
+func PopulateLcd(lcd *Lcd) {
+	key := lcd.Serial + ":" + lcd.ChannelId
+
+	mu.Lock()
+	lcdMetrics[key] = *lcd
+	mu.Unlock()
+}
+
+// GetRailMetrics return PSU rail metrics
+func GetRailMetrics() map[string]Rail {
+	mu.RLock()
+	defer mu.RUnlock()
+	cp := make(map[string]Rail, len(railMetrics))
+	for k, v := range railMetrics {
+		cp[k] = v
+	}
+	return cp
+}
+
+// GetLcdMetrics return LCD metrics
+func GetLcdMetrics() map[string]Lcd {
+	mu.RLock()
+	defer mu.RUnlock()
+	cp := make(map[string]Lcd, len(lcdMetrics))
+	for k, v := range lcdMetrics {
+		cp[k] = v
+	}
+	return cp
+}
+
 // GetProductMetrics return product info
 func GetProductMetrics() map[string]Header {
 	mu.RLock()
@@ -157,51 +232,150 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var b strings.Builder
+	connected := GetConnections()
 
 	// Device info
-	b.WriteString("# HELP openlinkhub Product information\n")
-	b.WriteString("# TYPE openlinkhub gauge\n")
+	writeHeader(&b, "openlinkhub", "Product information", "gauge")
 	for _, p := range GetProductMetrics() {
-		b.WriteString(fmt.Sprintf(`openlinkhub{product="%s",serial="%s",firmware="%s"} 1`+"\n",
-			p.Product, p.Serial, p.Firmware))
+		writeSample(&b, "openlinkhub", labels("product", p.Product, "serial", p.Serial, "firmware", p.Firmware), "1")
+	}
+
+	// Connection state
+	writeHeader(&b, "openlinkhub_device_connected", "Device connection state (1 - connected, 0 - disconnected).", "gauge")
+	for serial, c := range connected {
+		writeSample(&b, "openlinkhub_device_connected", labels("serial", serial, "product", c.Product), boolValue(c.Connected))
 	}
 
 	// Temperature data
-	b.WriteString("# HELP openlinkhub_temperature Current temperature of devices.\n")
-	b.WriteString("# TYPE openlinkhub_temperature gauge\n")
+	writeHeader(&b, "openlinkhub_temperature", "Current temperature of devices.", "gauge")
 	for _, d := range GetDeviceMetrics() {
 		if d.Temperature > 0 {
-			b.WriteString(fmt.Sprintf(`openlinkhub_temperature{serial="%s",channelId="%s",name="%s",description="%s",profile="%s",label="%s",rgb="%s",aio="%s",pump="%s",probe="%s",led="%s"} %.2f`+"\n",
-				d.Serial, d.ChannelId, d.Name, d.Description, d.Profile, d.Label, d.RGB, d.AIO, d.ContainsPump, d.TemperatureProbe, d.LedChannels, d.Temperature))
+			writeSample(&b, "openlinkhub_temperature", channelLabels(d), fmt.Sprintf("%.2f", d.Temperature))
 		}
 	}
 
 	// RPM data
-	b.WriteString("# HELP openlinkhub_speed Current speed (RPM) of devices.\n")
-	b.WriteString("# TYPE openlinkhub_speed gauge\n")
+	writeHeader(&b, "openlinkhub_speed", "Current speed (RPM) of devices.", "gauge")
 	for _, d := range GetDeviceMetrics() {
 		if d.Rpm > 0 {
-			b.WriteString(fmt.Sprintf(`openlinkhub_speed{serial="%s",channelId="%s",name="%s",description="%s",profile="%s",label="%s",rgb="%s",aio="%s",pump="%s",probe="%s",led="%s"} %d`+"\n",
-				d.Serial, d.ChannelId, d.Name, d.Description, d.Profile, d.Label, d.RGB, d.AIO, d.ContainsPump, d.TemperatureProbe, d.LedChannels, d.Rpm))
+			writeSample(&b, "openlinkhub_speed", channelLabels(d), fmt.Sprintf("%d", d.Rpm))
 		}
 	}
 
+	// Channel health
+	health := fanhealth.GetHealth()
+	writeHeader(&b, "openlinkhub_channel_health", "Channel health of fans and pumps (0 - ok, 1 - failure).", "gauge")
+	for serial, list := range health {
+		for _, c := range list {
+			if c.Status == fanhealth.StatusUnknown {
+				continue
+			}
+			value := "0"
+			if c.Status != fanhealth.StatusOk {
+				value = "1"
+			}
+			writeSample(&b, "openlinkhub_channel_health",
+				labels("serial", serial, "channelId", fmt.Sprintf("%d", c.ChannelId), "name", c.Name, "pump", fmt.Sprintf("%t", c.Pump), "status", c.Status), value)
+		}
+	}
+
+	// Channel duty
+	writeHeader(&b, "openlinkhub_channel_duty", "Last duty (percent) sent to fans and pumps.", "gauge")
+	for serial, list := range health {
+		for _, c := range list {
+			writeSample(&b, "openlinkhub_channel_duty",
+				labels("serial", serial, "channelId", fmt.Sprintf("%d", c.ChannelId), "name", c.Name, "pump", fmt.Sprintf("%t", c.Pump)), fmt.Sprintf("%d", c.Duty))
+		}
+	}
+
+	// Battery level
+	writeHeader(&b, "openlinkhub_battery_level", "Battery level (percent) of wireless devices.", "gauge")
+	for serial, s := range stats.GetBatteryStats() {
+		writeSample(&b, "openlinkhub_battery_level", labels("serial", serial, "device", s.Device), fmt.Sprintf("%d", s.Level))
+	}
+
+	// PSU rails
+	rails := GetRailMetrics()
+	writeHeader(&b, "openlinkhub_psu_volts", "PSU rail voltage.", "gauge")
+	for _, p := range rails {
+		if p.HasVolts {
+			writeSample(&b, "openlinkhub_psu_volts", railLabels(p), fmt.Sprintf("%.2f", p.Volts))
+		}
+	}
+	writeHeader(&b, "openlinkhub_psu_amps", "PSU rail current.", "gauge")
+	for _, p := range rails {
+		if p.HasAmps {
+			writeSample(&b, "openlinkhub_psu_amps", railLabels(p), fmt.Sprintf("%.2f", p.Amps))
+		}
+	}
+	writeHeader(&b, "openlinkhub_psu_watts", "PSU rail power.", "gauge")
+	for _, p := range rails {
+		if p.HasWatts {
+			writeSample(&b, "openlinkhub_psu_watts", railLabels(p), fmt.Sprintf("%.2f", p.Watts))
+		}
+	}
+
+	// LCD state
+	lcds := GetLcdMetrics()
+	writeHeader(&b, "openlinkhub_lcd_mode", "Current LCD mode.", "gauge")
+	for _, l := range lcds {
+		writeSample(&b, "openlinkhub_lcd_mode", lcdLabels(l), fmt.Sprintf("%d", l.Mode))
+	}
+	writeHeader(&b, "openlinkhub_lcd_brightness", "Current LCD brightness.", "gauge")
+	for _, l := range lcds {
+		writeSample(&b, "openlinkhub_lcd_brightness", lcdLabels(l), fmt.Sprintf("%d", l.Brightness))
+	}
+
 	// Storage temps
-	b.WriteString("# HELP openlinkhub_storage_temp Current temperature of storage devices.\n")
-	b.WriteString("# TYPE openlinkhub_storage_temp gauge\n")
+	writeHeader(&b, "openlinkhub_storage_temp", "Current temperature of storage devices.", "gauge")
 	for _, s := range GetStorageMetrics() {
-		b.WriteString(fmt.Sprintf(`openlinkhub_storage_temp{hwmonDevice="%s",model="%s"} %.2f`+"\n",
-			s.HwmonDevice, s.Model, s.Temperature))
+		writeSample(&b, "openlinkhub_storage_temp", labels("hwmonDevice", s.HwmonDevice, "model", s.Model), fmt.Sprintf("%.2f", s.Temperature))
 	}
 
 	// Default temps
-	b.WriteString("# HELP openlinkhub_default_temp Current temperature of default devices.\n")
-	b.WriteString("# TYPE openlinkhub_default_temp gauge\n")
+	writeHeader(&b, "openlinkhub_default_temp", "Current temperature of default devices.", "gauge")
 	for _, d := range GetDefaultMetrics() {
-		b.WriteString(fmt.Sprintf(`openlinkhub_default_temp{model="%s"} %.2f`+"\n",
-			d.Model, d.Temperature))
+		writeSample(&b, "openlinkhub_default_temp", labels("model", d.Model), fmt.Sprintf("%.2f", d.Temperature))
+	}
+
+	// System load
+	writeHeader(&b, "openlinkhub_cpu_load", "CPU utilization (percent).", "gauge")
+	writeSample(&b, "openlinkhub_cpu_load", labels("model", systeminfo.GetInfo().CPU.Model), fmt.Sprintf("%.2f", systeminfo.GetCpuUtilization()))
+	writeHeader(&b, "openlinkhub_gpu_load", "GPU utilization (percent) of default GPU.", "gauge")
+	writeSample(&b, "openlinkhub_gpu_load", "", fmt.Sprintf("%d", systeminfo.GetGPUUtilization()))
+
+	// HID transfers
+	transfers := GetTransfers()
+	writeHeader(&b, "openlinkhub_hid_transfers_total", "HID transfers to a device.", "counter")
+	for serial, t := range transfers {
+		writeSample(&b, "openlinkhub_hid_transfers_total", labels("serial", serial, "product", connected[serial].Product), fmt.Sprintf("%d", t.Writes))
+	}
+	writeHeader(&b, "openlinkhub_hid_write_errors_total", "Failed HID writes and reads.", "counter")
+	for serial, t := range transfers {
+		writeSample(&b, "openlinkhub_hid_write_errors_total", labels("serial", serial, "product", connected[serial].Product), fmt.Sprintf("%d", t.Errors))
+	}
+	writeHeader(&b, "openlinkhub_hid_transfer_seconds", "HID transfer latency.", "histogram")
+	for serial, t := range transfers {
+		writeHistogram(&b, "openlinkhub_hid_transfer_seconds", []string{"serial", serial, "product", connected[serial].Product}, t.Latency)
 	}
 
+	// Device loops
+	loops := GetLoops()
+	writeHeader(&b, "openlinkhub_device_loop_seconds", "Duration of device refresh loop.", "histogram")
+	for serial, l := range loops {
+		writeHistogram(&b, "openlinkhub_device_loop_seconds", []string{"serial", serial, "product", connected[serial].Product}, l.Duration)
+	}
+	writeHeader(&b, "openlinkhub_device_loop_last_seconds", "Duration of last device refresh loop.", "gauge")
+	for serial, l := range loops {
+		writeSample(&b, "openlinkhub_device_loop_last_seconds", labels("serial", serial, "product", connected[serial].Product), fmt.Sprintf("%f", l.Last))
+	}
+
+	// Daemon
+	writeHeader(&b, "openlinkhub_goroutines", "Number of goroutines.", "gauge")
+	writeSample(&b, "openlinkhub_goroutines", "", fmt.Sprintf("%d", runtime.NumGoroutine()))
+	writeHeader(&b, "openlinkhub_uptime_seconds", "Time since daemon start.", "gauge")
+	writeSample(&b, "openlinkhub_uptime_seconds", "", fmt.Sprintf("%.0f", time.Since(started).Seconds()))
+
 	// Send it
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
 	_, err := w.Write([]byte(b.String()))
@@ -210,3 +384,87 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// writeHeader writes HELP and TYPE lines of a metric
+func writeHeader(b *strings.Builder, name, help, kind string) {
+	b.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+	b.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// writeSample writes a single sample line
+func writeSample(b *strings.Builder, name, labelSet, value string) {
+	b.WriteString(name + labelSet + " " + value + "\n")
+}
+
+// writeHistogram writes buckets, sum and count of a histogram
+func writeHistogram(b *strings.Builder, name string, pairs []string, h Histogram) {
+	for i, bound := range latencyBuckets {
+		writeSample(b, name+"_bucket", labels(append(pairs, "le", fmt.Sprintf("%g", bound))...), fmt.Sprintf("%d", h.Buckets[i]))
+	}
+	writeSample(b, name+"_bucket", labels(append(pairs, "le", "+Inf")...), fmt.Sprintf("%d", h.Count))
+	writeSample(b, name+"_sum", labels(pairs...), fmt.Sprintf("%f", h.Sum))
+	writeSample(b, name+"_count", labels(pairs...), fmt.Sprintf("%d", h.Count))
+}
+
+// labels formats label pairs (name, value, name, value...) with escaped values
+func labels(pairs ...string) string {
+	if len(pairs) < 2 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(pairs[i] + `="` + escapeLabel(pairs[i+1]) + `"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// channelLabels returns labels of a device channel
+func channelLabels(d Header) string {
+	return labels(
+		"serial", d.Serial,
+		"channelId", d.ChannelId,
+		"name", d.Name,
+		"description", d.Description,
+		"profile", d.Profile,
+		"label", d.Label,
+		"rgb", d.RGB,
+		"aio", d.AIO,
+		"pump", d.ContainsPump,
+		"probe", d.TemperatureProbe,
+		"led", d.LedChannels,
+	)
+}
+
+// railLabels returns labels of a PSU rail
+func railLabels(p Rail) string {
+	return labels("product", p.Product, "serial", p.Serial, "channelId", p.ChannelId, "rail", p.Rail)
+}
+
+// lcdLabels returns labels of an LCD
+func lcdLabels(l Lcd) string {
+	return labels("product", l.Product, "serial", l.Serial, "channelId", l.ChannelId, "rotation", fmt.Sprintf("%d", l.Rotation))
+}
+
+// escapeLabel escapes backslash, double quote and line feed in label value
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// escapeHelp escapes backslash and line feed in help text
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
+
+// boolValue returns sample value of a bool
+func boolValue(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,367 @@
+package migration
+
+// Package: migration
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/backup"
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/logger"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileType is a versioned file format with ordered migrations. Schema version of a file is the version of its last applied migration
+type FileType struct {
+	Name       string
+	Indent     string                                          // JSON indentation used when file is saved
+	Paths      func(home string) []string                      // Files of this type, missing files are skipped
+	Defaults   func(data map[string]interface{}) (bool, error) // Fills missing keys on every start, after migrations
+	Migrations []Migration                                     // Ordered by version, starting at 1
+}
+
+// Migration changes decoded JSON document in place and returns true when anything was changed
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(data map[string]interface{}) (bool, error)
+}
+
+// Step is a migration of a single file
+type Step struct {
+	File        string    `json:"file"`
+	Type        string    `json:"type"`
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	Changed     bool      `json:"changed"`
+	Error       string    `json:"error,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Report is outcome of a migration run
+type Report struct {
+	DryRun  bool      `json:"dryRun"`
+	Backup  string    `json:"backup,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Steps   []Step    `json:"steps"`
+	Changed []string  `json:"changed"` // Files changed, or to be changed in dry run
+	Time    time.Time `json:"time"`
+}
+
+// State holds schema version of every migrated file and migration history
+type State struct {
+	Versions map[string]int `json:"versions"`
+	History  []Step         `json:"history"`
+}
+
+// Status is current schema state with pending migrations and result of the startup run
+type Status struct {
+	Versions map[string]int `json:"versions"`
+	Latest   map[string]int `json:"latest"`
+	History  []Step         `json:"history"`
+	Startup  *Report        `json:"startup"`
+	Pending  Report         `json:"pending"`
+}
+
+// pendingFile is a file with migrations applied in memory
+type pendingFile struct {
+	key     string
+	path    string
+	kind    FileType
+	data    map[string]interface{}
+	version int
+	changed bool
+}
+
+var (
+	mutex               sync.Mutex
+	startup             *Report
+	maxHistory          = 200
+	defaultsDescription = "Add missing keys with default values"
+	backupExclude       = []string{"history"} // Telemetry history is large and not migrated
+)
+
+// Init will run pending migrations before packages load their files, and log a summary
+func Init() {
+	report := Run(false)
+
+	mutex.Lock()
+	startup = &report
+	mutex.Unlock()
+
+	failed := 0
+	for _, step := range report.Steps {
+		fields := logger.Fields{"file": step.File, "type": step.Type, "version": step.Version, "description": step.Description, "changed": step.Changed}
+		if len(step.Error) > 0 {
+			failed++
+			fields["error"] = step.Error
+			logger.Log(fields).Error("Migration failed, file is left unchanged")
+			continue
+		}
+		logger.Log(fields).Info("Migration applied")
+	}
+
+	switch {
+	case len(report.Error) > 0:
+		logger.Log(logger.Fields{"error": report.Error, "pending": len(report.Steps)}).Error("Migrations were not applied")
+	case len(report.Steps) == 0:
+		logger.Log(logger.Fields{}).Info("All files are at current schema version")
+	case failed > 0:
+		logger.Log(logger.Fields{"steps": len(report.Steps), "failed": failed, "changed": report.Changed, "backup": report.Backup}).Warn("Migrations completed with errors")
+	default:
+		logger.Log(logger.Fields{"steps": len(report.Steps), "changed": report.Changed, "backup": report.Backup}).Info("Migrations completed")
+	}
+
+	// Migrated config.json is loaded again, so new settings are in effect
+	location, _ := config.GetLocation()
+	for _, file := range report.Changed {
+		if file == key(location) {
+			config.Init()
+			logger.SetLevel(config.GetConfig().LogLevel)
+			break
+		}
+	}
+}
+
+// GetStatus will return schema versions, history, startup summary and pending migrations
+func GetStatus() Status {
+	_, home := config.GetLocation()
+	state := loadState(home)
+
+	latest := make(map[string]int, len(registry))
+	for _, kind := range registry {
+		latest[kind.Name] = kind.latest()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	return Status{
+		Versions: state.Versions,
+		Latest:   latest,
+		History:  state.History,
+		Startup:  startup,
+		Pending:  Run(true),
+	}
+}
+
+// Run will apply pending migrations of all registered file types. Nothing is written in dry run.
+// Files are written only after all of them are migrated in memory and a backup is created
+func Run(dryRun bool) Report {
+	_, home := config.GetLocation()
+	state := loadState(home)
+	report := Report{
+		DryRun:  dryRun,
+		Steps:   make([]Step, 0),
+		Changed: make([]string, 0),
+		Time:    time.Now(),
+	}
+
+	files := make([]*pendingFile, 0)
+	for _, kind := range registry {
+		for _, path := range kind.Paths(home) {
+			if !common.FileExists(path) {
+				continue
+			}
+
+			file, steps := migrate(kind, path, state.Versions[key(path)])
+			report.Steps = append(report.Steps, steps...)
+			if file != nil {
+				files = append(files, file)
+				if file.changed {
+					report.Changed = append(report.Changed, file.key)
+				}
+			}
+		}
+	}
+	if dryRun || len(files) == 0 {
+		return report
+	}
+
+	// Backup everything before first change
+	if len(report.Changed) > 0 {
+		name, err := createBackup(home)
+		if err != nil {
+			report.Error = "backup failed: " + err.Error()
+			return report
+		}
+		report.Backup = name
+	}
+
+	for _, file := range files {
+		if file.changed {
+			if err := write(file.path, file.data, file.kind.Indent); err != nil {
+				report.Error = fmt.Sprintf("%s: %s", file.key, err.Error())
+				break
+			}
+		}
+		state.Versions[file.key] = file.version
+	}
+
+	for _, step := range report.Steps {
+		state.History = append(state.History, step)
+	}
+	if len(state.History) > maxHistory {
+		state.History = state.History[len(state.History)-maxHistory:]
+	}
+	if err := common.SaveJsonData(filepath.Join(config.GetDatabasePath(), "migrations.json"), state); err != nil && len(report.Error) == 0 {
+		report.Error = "unable to save migration state: " + err.Error()
+	}
+	return report
+}
+
+// migrate will apply migrations newer than current version and fill missing keys of a single file in memory.
+// Returns nil when nothing needs to be saved or a migration failed
+func migrate(kind FileType, path string, current int) (*pendingFile, []Step) {
+	steps := make([]Step, 0)
+	file := &pendingFile{key: key(path), path: path, kind: kind, version: current}
+	data, err := read(path)
+	if err != nil {
+		steps = append(steps, Step{File: file.key, Type: kind.Name, Version: current, Error: err.Error(), Time: time.Now()})
+		return nil, steps
+	}
+	file.data = data
+
+	for _, m := range kind.Migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		step := Step{File: file.key, Type: kind.Name, Version: m.Version, Description: m.Description, Time: time.Now()}
+		changed, e := m.Apply(file.data)
+		if e != nil {
+			step.Error = e.Error()
+			steps = append(steps, step)
+			return nil, steps
+		}
+		step.Changed = changed
+		steps = append(steps, step)
+		file.changed = file.changed || changed
+		file.version = m.Version
+	}
+
+	// Files restored from backup or edited by hand can miss keys regardless of their version
+	if kind.Defaults != nil {
+		changed, e := kind.Defaults(file.data)
+		if e != nil {
+			steps = append(steps, Step{File: file.key, Type: kind.Name, Version: file.version, Description: defaultsDescription, Error: e.Error(), Time: time.Now()})
+			return nil, steps
+		}
+		if changed {
+			steps = append(steps, Step{File: file.key, Type: kind.Name, Version: file.version, Description: defaultsDescription, Changed: true, Time: time.Now()})
+			file.changed = true
+		}
+	}
+
+	if !file.changed && file.version == current {
+		return nil, steps
+	}
+	return file, steps
+}
+
+// latest will return schema version of the last migration, or 0 when file type has no migrations
+func (kind FileType) latest() int {
+	if len(kind.Migrations) == 0 {
+		return 0
+	}
+	return kind.Migrations[len(kind.Migrations)-1].Version
+}
+
+// read will decode JSON object. Numbers are kept as in source
+func read(path string) (map[string]interface{}, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	if err = decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// write will replace file with migrated data, via temporary file and rename
+func write(path string, data map[string]interface{}, indent string) error {
+	buf, err := json.MarshalIndent(data, "", indent)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if info, e := os.Stat(path); e == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp := path + ".migrate"
+	if err = os.WriteFile(tmp, buf, mode); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// createBackup will save backup of database, without telemetry history, config.json and dashboard.json to backups folder
+func createBackup(home string) (string, error) {
+	folder := filepath.Join(home, "backups")
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return "", err
+	}
+
+	name := filepath.Join(folder, "pre-migration-"+time.Now().Format("2006-01-02-15-04-05")+".zip")
+	file, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+
+	extra := make([]string, 0)
+	if dashboard := filepath.Join(home, "dashboard.json"); common.FileExists(dashboard) {
+		extra = append(extra, dashboard)
+	}
+
+	err = backup.WriteArchive(file, backupExclude, extra...)
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+// loadState will load schema versions and history
+func loadState(home string) State {
+	state := State{}
+	if buf, err := os.ReadFile(filepath.Join(config.GetDatabasePath(), "migrations.json")); err == nil {
+		_ = json.Unmarshal(buf, &state)
+	}
+	if state.Versions == nil {
+		state.Versions = map[string]int{}
+	}
+	if state.History == nil {
+		state.History = make([]Step, 0)
+	}
+	return state
+}
+
+// key will return file path relative to home, or absolute path of files outside of it
+func key(path string) string {
+	_, home := config.GetLocation()
+	if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
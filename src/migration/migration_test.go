@@ -0,0 +1,95 @@
+package migration
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, "database"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeJson(t, filepath.Join(home, "config.json"), map[string]any{"listenPort": 27003, "listenAddress": "127.0.0.1"})
+	writeJson(t, filepath.Join(home, "dashboard.json"), map[string]any{"celsius": false})
+
+	if err := config.ParseFlags([]string{"--home", home}); err != nil {
+		t.Fatal(err)
+	}
+	config.Init()
+
+	dryRun := Run(true)
+	if len(dryRun.Changed) != 2 || len(dryRun.Backup) > 0 {
+		t.Fatalf("dry run changed %v, backup %q", dryRun.Changed, dryRun.Backup)
+	}
+	if data := readJson(t, filepath.Join(home, "dashboard.json")); len(data) != 1 {
+		t.Fatalf("dry run wrote dashboard.json: %v", data)
+	}
+
+	report := Run(false)
+	if len(report.Error) > 0 {
+		t.Fatalf("run failed: %s", report.Error)
+	}
+	if len(report.Backup) == 0 || !common.FileExists(report.Backup) {
+		t.Fatalf("backup %q was not created", report.Backup)
+	}
+
+	upgraded := map[string]bool{}
+	for _, step := range report.Steps {
+		if len(step.Error) > 0 {
+			t.Errorf("%s: %s", step.File, step.Error)
+		}
+		if step.Version == 1 && step.Description == upgradeDescription {
+			upgraded[step.File] = step.Changed
+		}
+	}
+	if !upgraded["config.json"] || !upgraded["dashboard.json"] {
+		t.Errorf("version 1 was not applied to all files: %v", upgraded)
+	}
+
+	cfg := readJson(t, filepath.Join(home, "config.json"))
+	for _, name := range []string{"memorySku", "historyTiers", "configWatcher"} {
+		if _, ok := cfg[name]; !ok {
+			t.Errorf("config.json is missing %s", name)
+		}
+	}
+	dashboard := readJson(t, filepath.Join(home, "dashboard.json"))
+	if dashboard["celsius"] != false || dashboard["showLabels"] != true {
+		t.Errorf("dashboard.json is not migrated: %v", dashboard)
+	}
+
+	state := loadState(home)
+	if state.Versions["config.json"] != 1 || state.Versions["dashboard.json"] != 1 {
+		t.Errorf("schema versions are not saved: %v", state.Versions)
+	}
+
+	if again := Run(false); len(again.Steps) > 0 || len(again.Changed) > 0 {
+		t.Errorf("second run is not empty: %v", again.Steps)
+	}
+}
+
+func writeJson(t *testing.T, path string, data map[string]any) {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readJson(t *testing.T, path string) map[string]any {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]any{}
+	if err = json.Unmarshal(buf, &data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
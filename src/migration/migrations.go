@@ -0,0 +1,227 @@
+package migration
+
+// Package: migration
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/rgb"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// registry holds every versioned file type. Defaults fill keys added over time and run on every start, so they cover
+// files restored from backup or edited by hand. Migrations are for changes which can not be done by adding keys, such as
+// renamed or converted values. New migrations are appended to the end of a type with the next version.
+// Version 1 of every type is the upgrade each package did on its own before migrations were introduced.
+// RGB profiles of devices are upgraded by device drivers on load and are not part of registry, their profile list
+// depends on modes supported by each device and is defined in each driver
+var registry = []FileType{
+	{
+		Name:   "config",
+		Indent: "  ",
+		Paths: func(string) []string {
+			location, _ := config.GetLocation()
+			return []string{location}
+		},
+		Defaults: addDefaults(configUpgrade, map[string]any{
+			"keyHeatmap":                false,
+			"criticalCoolantTemp":       57.0,
+			"criticalCoolantHysteresis": 5.0,
+			"fanHealthDebounce":         15,
+			"fanFailureProtection":      false,
+			"history":                   true,
+			"historyTiers":              config.DefaultHistoryTiers(),
+			"alertCommands":             false,
+			"mqttEnabled":               false,
+			"mqttBroker":                "tcp://127.0.0.1:1883",
+			"mqttUsername":              "",
+			"mqttPassword":              "",
+			"mqttTopic":                 "openlinkhub",
+			"mqttDiscoveryPrefix":       "homeassistant",
+			"mqttInterval":              10,
+			"influxEnabled":             false,
+			"influxUrl":                 "http://127.0.0.1:8086",
+			"influxOrg":                 "",
+			"influxBucket":              "openlinkhub",
+			"influxToken":               "",
+			"graphiteEnabled":           false,
+			"graphiteAddress":           "127.0.0.1:2003",
+			"graphiteProtocol":          "tcp",
+			"graphitePrefix":            "openlinkhub",
+			"exporterInterval":          10,
+			"exporterTags":              map[string]string{},
+			"exporterBatchSize":         500,
+			"exporterBufferSize":        10000,
+			"criticalDevices":           []string{},
+			"dbus":                      true,
+			"socketPath":                "",
+			"socketOwner":               "",
+			"socketGroup":               "",
+			"socketMode":                "0660",
+			"configWatcher":             true,
+		}),
+		Migrations: []Migration{
+			{Version: 1, Description: upgradeDescription, Apply: addDefaults(configUpgrade)},
+		},
+	},
+	{
+		Name:   "dashboard",
+		Indent: "    ",
+		Paths: func(home string) []string {
+			return []string{filepath.Join(home, "dashboard.json")}
+		},
+		Defaults: addDefaults(dashboardUpgrade),
+		Migrations: []Migration{
+			{Version: 1, Description: upgradeDescription, Apply: addDefaults(dashboardUpgrade)},
+		},
+	},
+	{
+		Name:   "scheduler",
+		Indent: "    ",
+		Paths: func(home string) []string {
+			return []string{filepath.Join(config.GetDatabasePath(), "scheduler.json")}
+		},
+		Defaults: addDefaults(schedulerUpgrade, map[string]any{"clusters": []string{}}),
+		Migrations: []Migration{
+			{Version: 1, Description: upgradeDescription, Apply: addDefaults(schedulerUpgrade)},
+		},
+	},
+	{
+		Name:     "clusterRgb",
+		Indent:   "    ",
+		Paths:    clusterRgbFiles,
+		Defaults: addRgbProfiles(clusterRgbUpgrade...),
+		Migrations: []Migration{
+			{Version: 1, Description: upgradeDescription, Apply: addRgbProfiles(clusterRgbUpgrade...)},
+		},
+	},
+}
+
+var (
+	upgradeDescription = "Add keys of upgrade done before migrations"
+
+	// configUpgrade are keys config.json was upgraded with before migrations
+	configUpgrade = map[string]any{
+		"memorySku":                 "",
+		"resumeDelay":               15000,
+		"logLevel":                  "info",
+		"logFile":                   "",
+		"enhancementKits":           make([]byte, 0),
+		"temperatureOffset":         0,
+		"amdGpuIndex":               0,
+		"amdsmiPath":                "",
+		"checkDevicePermission":     false,
+		"cpuTempFile":               "",
+		"graphProfiles":             false,
+		"ramTempViaHwmon":           false,
+		"nvidiaGpuIndex":            []int{0},
+		"defaultNvidiaGPU":          0,
+		"openRGBPort":               6743,
+		"enableOpenRGBTargetServer": false,
+		"enableGamepad":             true,
+		"enableMotherboard":         false,
+		"motherboardBiosOnExit":     false,
+		"memoryRegisterOverride":    make([]byte, 0),
+	}
+
+	// dashboardUpgrade are keys dashboard.json was upgraded with before migrations
+	dashboardUpgrade = map[string]any{
+		"celsius":              true,
+		"showLabels":           true,
+		"showBattery":          false,
+		"languageCode":         "en_US",
+		"temperatureBar":       true,
+		"addDeviceToDashboard": true,
+		"rgbOff":               false,
+		"pageTitle":            "OPENLINKHUB WebUI",
+		"sidebarCollapsed":     false,
+		"devices":              []string{},
+		"theme":                "default",
+		"keyboardLayout":       0,
+		"keyboardLayouts":      map[int]string{0: "QWERTY", 1: "AZERTY"},
+	}
+
+	// schedulerUpgrade are keys scheduler.json was upgraded with before migrations
+	schedulerUpgrade = map[string]any{"lcdControl": false}
+
+	// clusterRgbUpgrade are RGB profiles cluster RGB files were upgraded with before migrations
+	clusterRgbUpgrade = []string{"gradient", "pastelrainbow", "pastelspiralrainbow", "rain"}
+)
+
+// addDefaults will return step which adds missing keys with values of given sets
+func addDefaults(sets ...map[string]any) func(map[string]interface{}) (bool, error) {
+	return func(data map[string]interface{}) (bool, error) {
+		changed := false
+		for _, values := range sets {
+			for key, value := range values {
+				if _, ok := data[key]; !ok {
+					data[key] = value
+					changed = true
+				}
+			}
+		}
+		return changed, nil
+	}
+}
+
+// addRgbProfiles will return step which adds missing RGB profiles from rgb.json templates
+func addRgbProfiles(names ...string) func(map[string]interface{}) (bool, error) {
+	return func(data map[string]interface{}) (bool, error) {
+		profiles, ok := data["profiles"].(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("missing profiles")
+		}
+
+		templates := map[string]json.RawMessage{}
+		if buf, err := os.ReadFile(filepath.Join(config.GetDatabasePath(), "rgb.json")); err == nil {
+			var file struct {
+				Profiles map[string]json.RawMessage `json:"profiles"`
+			}
+			if err = json.Unmarshal(buf, &file); err == nil {
+				templates = file.Profiles
+			}
+		}
+
+		changed := false
+		for _, name := range names {
+			if _, ok = profiles[name]; ok {
+				continue
+			}
+
+			template, found := templates[name]
+			if !found {
+				template, _ = json.Marshal(rgb.Profile{})
+			}
+			profiles[name] = template
+			changed = true
+		}
+		return changed, nil
+	}
+}
+
+// clusterRgbFiles will return RGB profile files of all RGB clusters
+func clusterRgbFiles(home string) []string {
+	serials := []string{"cluster"}
+	if buf, err := os.ReadFile(filepath.Join(config.GetDatabasePath(), "clusters.json")); err == nil {
+		definitions := map[string]json.RawMessage{}
+		if err = json.Unmarshal(buf, &definitions); err == nil {
+			for serial := range definitions {
+				if serial != "cluster" {
+					serials = append(serials, serial)
+				}
+			}
+		}
+	}
+	sort.Strings(serials[1:])
+
+	files := make([]string, 0, len(serials))
+	for _, serial := range serials {
+		files = append(files, filepath.Join(config.GetDatabasePath(), "rgb", serial+".json"))
+	}
+	return files
+}
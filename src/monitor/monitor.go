@@ -12,6 +12,7 @@ import (
 	"OpenLinkHub/src/inputmanager"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/openrgb"
+	"OpenLinkHub/src/webhooks"
 	"github.com/godbus/dbus/v5"
 	"os"
 	"slices"
@@ -206,6 +207,7 @@ func Init() {
 						}
 						logger.Log(logger.Fields{"vendorId": vid, "productId": pid, "serial": serial}).Info("Init USB device...")
 						devices.InitManual(pid, serial)
+						webhooks.Publish(webhooks.EventDeviceConnected, serial, "", map[string]interface{}{"vendorId": vid, "productId": pid})
 						switchHeadsetAudioSink(pid)
 					}
 				}
@@ -228,6 +230,7 @@ func Init() {
 							logger.Log(logger.Fields{"vendorId": info.VendorID, "productId": info.ProductID, "serial": serial}).Info("Dirty USB removal...")
 
 							devices.StopDirty(serial, info.ProductID)
+							webhooks.Publish(webhooks.EventDeviceDisconnected, serial, "", map[string]interface{}{"vendorId": info.VendorID, "productId": info.ProductID})
 							delete(cache, devPath)
 							openrgb.NotifyControllerChange(serial)
 							fallbackHeadsetAudioSink(info.ProductID)
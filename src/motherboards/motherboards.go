@@ -41,7 +41,6 @@ type Motherboards struct {
 }
 
 var (
-	pwd         = ""
 	boardName   = ""
 	hwmonPath   = ""
 	boardSerial = ""
@@ -50,8 +49,6 @@ var (
 )
 
 func Init() {
-	pwd = config.GetConfig().ConfigPath
-
 	// Read actual board name from DMI
 	dmiBoardNamePath := "/sys/class/dmi/id/board_name"
 	if b, err := os.ReadFile(dmiBoardNamePath); err != nil {
@@ -62,7 +59,7 @@ func Init() {
 		boardSerial = hex.EncodeToString(sum[:])
 	}
 
-	location := pwd + "/database/motherboard/motherboard.json"
+	location := config.GetDatabasePath() + "/motherboard/motherboard.json"
 
 	file, fe := os.Open(location)
 	if fe != nil {
@@ -0,0 +1,348 @@
+package mqtt
+
+// Package: mqtt
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Minimal MQTT 3.1.1 client. Only QoS 0 is published, incoming QoS 1 messages are acknowledged.
+
+const (
+	packetConnect     = 0x10
+	packetConnAck     = 0x20
+	packetPublish     = 0x30
+	packetPubAck      = 0x40
+	packetSubscribe   = 0x82
+	packetSubAck      = 0x90
+	packetPingReq     = 0xc0
+	packetPingResp    = 0xd0
+	packetDisconnect  = 0xe0
+	protocolLevel     = 0x04
+	flagCleanSession  = 0x02
+	flagWill          = 0x04
+	flagWillRetain    = 0x20
+	flagPassword      = 0x40
+	flagUsername      = 0x80
+	maxRemainingBytes = 268435455
+)
+
+type Will struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+type Options struct {
+	Broker    string // tcp://host:1883, mqtt://host:1883, ssl://host:8883 or tls://host:8883
+	ClientId  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+	Will      *Will
+	OnMessage func(topic string, payload []byte)
+}
+
+type Client struct {
+	options  Options
+	conn     net.Conn
+	reader   *bufio.Reader
+	write    sync.Mutex
+	done     chan struct{}
+	once     sync.Once
+	err      error
+	packetId uint16
+}
+
+// Connect will open connection to a broker and wait for CONNACK
+func Connect(options Options) (*Client, error) {
+	u, err := url.Parse(options.Broker)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Host
+	if len(u.Port()) == 0 {
+		switch u.Scheme {
+		case "ssl", "tls", "mqtts":
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		default:
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	switch u.Scheme {
+	case "tcp", "mqtt", "":
+		conn, err = dialer.Dial("tcp", host)
+	case "ssl", "tls", "mqtts":
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported broker scheme %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if options.KeepAlive <= 0 {
+		options.KeepAlive = 30 * time.Second
+	}
+
+	c := &Client{
+		options: options,
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		done:    make(chan struct{}),
+	}
+
+	if err = c.connect(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	go c.readLoop()
+	go c.keepAlive()
+	return c, nil
+}
+
+// Done will return channel closed when connection is lost
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err will return error which closed the connection
+func (c *Client) Err() error {
+	return c.err
+}
+
+// Publish will send QoS 0 message
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	var flags byte
+	if retain {
+		flags = 0x01
+	}
+
+	body := appendString(nil, topic)
+	body = append(body, payload...)
+	return c.send(packetPublish|flags, body)
+}
+
+// Subscribe will subscribe to topic filter with QoS 0
+func (c *Client) Subscribe(filter string) error {
+	c.write.Lock()
+	c.packetId++
+	if c.packetId == 0 {
+		c.packetId = 1
+	}
+	id := c.packetId
+	c.write.Unlock()
+
+	body := binary.BigEndian.AppendUint16(nil, id)
+	body = appendString(body, filter)
+	body = append(body, 0x00)
+	return c.send(packetSubscribe, body)
+}
+
+// Close will send DISCONNECT and close connection
+func (c *Client) Close() {
+	_ = c.send(packetDisconnect, nil)
+	c.close(nil)
+}
+
+// connect will send CONNECT packet and validate CONNACK
+func (c *Client) connect() error {
+	flags := byte(flagCleanSession)
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel, 0)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.options.KeepAlive.Seconds()))
+
+	payload := appendString(nil, c.options.ClientId)
+	if c.options.Will != nil {
+		flags |= flagWill
+		if c.options.Will.Retain {
+			flags |= flagWillRetain
+		}
+		payload = appendString(payload, c.options.Will.Topic)
+		payload = binary.BigEndian.AppendUint16(payload, uint16(len(c.options.Will.Payload)))
+		payload = append(payload, c.options.Will.Payload...)
+	}
+	if len(c.options.Username) > 0 {
+		flags |= flagUsername
+		payload = appendString(payload, c.options.Username)
+		if len(c.options.Password) > 0 {
+			flags |= flagPassword
+			payload = appendString(payload, c.options.Password)
+		}
+	}
+	body[7] = flags
+	body = append(body, payload...)
+
+	if err := c.send(packetConnect, body); err != nil {
+		return err
+	}
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	header, data, err := c.readPacket()
+	_ = c.conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return err
+	}
+	if header != packetConnAck || len(data) != 2 {
+		return errors.New("invalid CONNACK")
+	}
+	if data[1] != 0 {
+		return fmt.Errorf("connection refused, return code %d", data[1])
+	}
+	return nil
+}
+
+// readLoop will process incoming packets until connection is lost
+func (c *Client) readLoop() {
+	for {
+		header, data, err := c.readPacket()
+		if err != nil {
+			c.close(err)
+			return
+		}
+
+		switch header & 0xf0 {
+		case packetPublish:
+			c.handlePublish(header, data)
+		case packetPingResp, packetSubAck, packetPubAck:
+		}
+	}
+}
+
+// handlePublish will decode incoming PUBLISH and pass it to message handler
+func (c *Client) handlePublish(header byte, data []byte) {
+	if len(data) < 2 {
+		return
+	}
+
+	length := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+length {
+		return
+	}
+	topic := string(data[2 : 2+length])
+	data = data[2+length:]
+
+	qos := (header >> 1) & 0x03
+	if qos > 0 {
+		if len(data) < 2 {
+			return
+		}
+		id := data[0:2]
+		data = data[2:]
+		if qos == 1 {
+			_ = c.send(packetPubAck, id)
+		}
+	}
+
+	if c.options.OnMessage != nil {
+		c.options.OnMessage(topic, data)
+	}
+}
+
+// keepAlive will send PINGREQ within keep alive interval
+func (c *Client) keepAlive() {
+	ticker := time.NewTicker(c.options.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.send(packetPingReq, nil); err != nil {
+				c.close(err)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// send will write a packet with fixed header
+func (c *Client) send(header byte, body []byte) error {
+	if len(body) > maxRemainingBytes {
+		return errors.New("packet too large")
+	}
+
+	packet := []byte{header}
+	packet = appendLength(packet, len(body))
+	packet = append(packet, body...)
+
+	c.write.Lock()
+	defer c.write.Unlock()
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(packet)
+	return err
+}
+
+// readPacket will read a single packet and return its first byte and body
+func (c *Client) readPacket() (byte, []byte, error) {
+	header, err := c.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		b, e := c.reader.ReadByte()
+		if e != nil {
+			return 0, nil, e
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+
+	data := make([]byte, length)
+	if _, err = io.ReadFull(c.reader, data); err != nil {
+		return 0, nil, err
+	}
+	return header, data, nil
+}
+
+// close will close connection once and signal Done
+func (c *Client) close(err error) {
+	c.once.Do(func() {
+		c.err = err
+		_ = c.conn.Close()
+		close(c.done)
+	})
+}
+
+// appendString will append length-prefixed UTF-8 string
+func appendString(buf []byte, value string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(value)))
+	return append(buf, value...)
+}
+
+// appendLength will append remaining length encoded as variable byte integer
+func appendLength(buf []byte, length int) []byte {
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			return buf
+		}
+	}
+}
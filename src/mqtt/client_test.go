@@ -0,0 +1,220 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// broker is a loopback MQTT broker serving a single client
+type broker struct {
+	t        *testing.T
+	listener net.Listener
+	conn     net.Conn
+	reader   *bufio.Reader
+}
+
+func newBroker(t *testing.T) *broker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	return &broker{t: t, listener: listener}
+}
+
+// accept will wait for client connection, validate CONNECT and reply with CONNACK
+func (b *broker) accept(clientId string, done chan<- error) {
+	conn, err := b.listener.Accept()
+	if err != nil {
+		done <- err
+		return
+	}
+	b.conn = conn
+	b.reader = bufio.NewReader(conn)
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	header, body, err := b.read()
+	if err != nil {
+		done <- err
+		return
+	}
+	if header != packetConnect {
+		b.t.Errorf("first packet = %#x, want CONNECT", header)
+	}
+
+	name, body := readString(body)
+	if name != "MQTT" || body[0] != protocolLevel {
+		b.t.Errorf("protocol = %s level %d, want MQTT level %d", name, body[0], protocolLevel)
+	}
+	flags := body[1]
+	if flags&flagCleanSession == 0 || flags&flagWill == 0 || flags&flagWillRetain == 0 {
+		b.t.Errorf("connect flags = %#x, want clean session and retained will", flags)
+	}
+	if flags&flagUsername == 0 || flags&flagPassword == 0 {
+		b.t.Errorf("connect flags = %#x, want username and password", flags)
+	}
+
+	payload := body[4:]
+	id, payload := readString(payload)
+	if id != clientId {
+		b.t.Errorf("client id = %s, want %s", id, clientId)
+	}
+	willTopic, payload := readString(payload)
+	willPayload, payload := readString(payload)
+	if willTopic != "openlinkhub/status" || willPayload != "offline" {
+		b.t.Errorf("will = %s %s, want openlinkhub/status offline", willTopic, willPayload)
+	}
+	username, payload := readString(payload)
+	password, _ := readString(payload)
+	if username != "user" || password != "secret" {
+		b.t.Errorf("credentials = %s %s, want user secret", username, password)
+	}
+
+	done <- b.write(packetConnAck, []byte{0x00, 0x00})
+}
+
+// read will read a single packet
+func (b *broker) read() (byte, []byte, error) {
+	header, err := b.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		v, e := b.reader.ReadByte()
+		if e != nil {
+			return 0, nil, e
+		}
+		length += int(v&0x7f) * multiplier
+		if v&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(b.reader, body)
+	return header, body, err
+}
+
+// expect will read next packet, skipping keep alive pings, and fail when its type does not match
+func (b *broker) expect(packet byte) (byte, []byte) {
+	for {
+		header, body, err := b.read()
+		if err != nil {
+			b.t.Fatalf("waiting for %#x: %v", packet, err)
+		}
+		if header == packetPingReq {
+			continue
+		}
+		if header&0xf0 != packet&0xf0 {
+			b.t.Fatalf("packet = %#x, want %#x", header, packet)
+		}
+		return header, body
+	}
+}
+
+// write will send a packet to the client
+func (b *broker) write(header byte, body []byte) error {
+	packet := appendLength([]byte{header}, len(body))
+	_, err := b.conn.Write(append(packet, body...))
+	return err
+}
+
+func readString(buf []byte) (string, []byte) {
+	length := int(binary.BigEndian.Uint16(buf[0:2]))
+	return string(buf[2 : 2+length]), buf[2+length:]
+}
+
+func TestClient(t *testing.T) {
+	b := newBroker(t)
+	accepted := make(chan error, 1)
+	go b.accept("openlinkhub-test", accepted)
+
+	type message struct {
+		topic   string
+		payload []byte
+	}
+	messages := make(chan message, 2)
+	c, err := Connect(Options{
+		Broker:   "tcp://" + b.listener.Addr().String(),
+		ClientId: "openlinkhub-test",
+		Username: "user",
+		Password: "secret",
+		Will:     &Will{Topic: "openlinkhub/status", Payload: []byte("offline"), Retain: true},
+		OnMessage: func(topic string, payload []byte) {
+			messages <- message{topic, payload}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = <-accepted; err != nil {
+		t.Fatal(err)
+	}
+
+	// SUBSCRIBE with packet id and QoS 0
+	if err = c.Subscribe("openlinkhub/+/+/set"); err != nil {
+		t.Fatal(err)
+	}
+	_, body := b.expect(packetSubscribe)
+	packetId := binary.BigEndian.Uint16(body[0:2])
+	filter, rest := readString(body[2:])
+	if packetId == 0 || filter != "openlinkhub/+/+/set" || !bytes.Equal(rest, []byte{0x00}) {
+		t.Errorf("subscribe = id %d filter %s qos %v", packetId, filter, rest)
+	}
+	if err = b.write(packetSubAck, append(body[0:2], 0x00)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Retained PUBLISH from client
+	if err = c.Publish("openlinkhub/status", []byte("online"), true); err != nil {
+		t.Fatal(err)
+	}
+	header, body := b.expect(packetPublish)
+	topic, payload := readString(body)
+	if header&0x01 == 0 || topic != "openlinkhub/status" || string(payload) != "online" {
+		t.Errorf("publish = %#x %s %s, want retained openlinkhub/status online", header, topic, payload)
+	}
+
+	// QoS 0 and QoS 1 PUBLISH from broker, QoS 1 is acknowledged
+	if err = b.write(packetPublish, append(appendString(nil, "openlinkhub/ABC/speed/set"), "50"...)); err != nil {
+		t.Fatal(err)
+	}
+	qos1 := appendString(nil, "openlinkhub/ABC/profile/set")
+	qos1 = append(qos1, 0x12, 0x34)
+	if err = b.write(packetPublish|0x02, append(qos1, "Silent"...)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []message{{"openlinkhub/ABC/speed/set", []byte("50")}, {"openlinkhub/ABC/profile/set", []byte("Silent")}} {
+		select {
+		case got := <-messages:
+			if got.topic != want.topic || !bytes.Equal(got.payload, want.payload) {
+				t.Errorf("message = %s %s, want %s %s", got.topic, got.payload, want.topic, want.payload)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %s was not delivered", want.topic)
+		}
+	}
+	if _, body = b.expect(packetPubAck); !bytes.Equal(body, []byte{0x12, 0x34}) {
+		t.Errorf("puback id = %v, want [18 52]", body)
+	}
+
+	// DISCONNECT on close
+	c.Close()
+	b.expect(packetDisconnect)
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("client is not closed")
+	}
+}
@@ -0,0 +1,652 @@
+package mqtt
+
+// Package: mqtt
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/history"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/overrides"
+	"OpenLinkHub/src/rgb"
+	"OpenLinkHub/src/temperatures"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LightCommand struct {
+	State      string `json:"state"`
+	Brightness *int   `json:"brightness"`
+	Color      *struct {
+		R uint8 `json:"r"`
+		G uint8 `json:"g"`
+		B uint8 `json:"b"`
+	} `json:"color"`
+	Effect string `json:"effect"`
+}
+
+type LightState struct {
+	State      string         `json:"state"`
+	Brightness int            `json:"brightness"`
+	ColorMode  string         `json:"color_mode"`
+	Color      map[string]int `json:"color"`
+	Effect     string         `json:"effect,omitempty"`
+}
+
+type DeviceInfo struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+const (
+	entityLight        = "light"
+	entityProfile      = "profile"
+	entitySpeedProfile = "speedProfile"
+	entitySpeed        = "speed"
+	minSpeed           = 20
+)
+
+var (
+	mutex           sync.Mutex
+	client          *Client
+	base            = "openlinkhub"
+	discoveryPrefix = "homeassistant"
+	discovered      = map[string]bool{}
+	lights          = map[string]*LightState{}
+	speedLeases     = map[string]string{} // key: serial, value: id of speed override lease
+	idRegex         = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+	minBackoff      = time.Second
+	maxBackoff      = time.Minute
+	units           = map[string]string{
+		history.MetricTemperature: "°C",
+		history.MetricRpm:         "RPM",
+		history.MetricBattery:     "%",
+		history.MetricPower:       "W",
+		history.MetricLoad:        "%",
+		history.MetricDuty:        "%",
+	}
+	deviceClasses = map[string]string{
+		history.MetricTemperature: "temperature",
+		history.MetricBattery:     "battery",
+		history.MetricPower:       "power",
+	}
+)
+
+// Init will start MQTT bridge when enabled in configuration
+func Init() {
+	cfg := config.GetConfig()
+	if !cfg.MqttEnabled || len(cfg.MqttBroker) == 0 {
+		return
+	}
+
+	if len(cfg.MqttTopic) > 0 {
+		base = strings.TrimSuffix(cfg.MqttTopic, "/")
+	}
+	if len(cfg.MqttDiscoveryPrefix) > 0 {
+		discoveryPrefix = strings.TrimSuffix(cfg.MqttDiscoveryPrefix, "/")
+	}
+	go run()
+}
+
+// Stop will publish offline availability and disconnect from broker
+func Stop() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if client != nil {
+		_ = client.Publish(availabilityTopic(), []byte("offline"), true)
+		client.Close()
+		client = nil
+	}
+
+	for serial, id := range speedLeases {
+		overrides.ReleaseLease(id)
+		delete(speedLeases, serial)
+	}
+}
+
+// run will keep connection to a broker, reconnecting with backoff
+func run() {
+	backoff := minBackoff
+	for {
+		c, err := connect()
+		if err != nil {
+			logger.Log(logger.Fields{"error": err, "broker": config.GetConfig().MqttBroker, "retry": backoff.String()}).Warn("Unable to connect to MQTT broker")
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+
+		backoff = minBackoff
+		logger.Log(logger.Fields{"broker": config.GetConfig().MqttBroker}).Info("Connected to MQTT broker")
+		serve(c)
+		logger.Log(logger.Fields{"error": c.Err(), "broker": config.GetConfig().MqttBroker}).Warn("MQTT connection lost")
+
+		mutex.Lock()
+		client = nil
+		mutex.Unlock()
+	}
+}
+
+// connect will connect to a broker, announce availability and subscribe to command topics
+func connect() (*Client, error) {
+	cfg := config.GetConfig()
+	hostname, _ := os.Hostname()
+	c, err := Connect(Options{
+		Broker:    cfg.MqttBroker,
+		ClientId:  "openlinkhub-" + idRegex.ReplaceAllString(hostname, "_"),
+		Username:  cfg.MqttUsername,
+		Password:  cfg.MqttPassword,
+		KeepAlive: 30 * time.Second,
+		Will:      &Will{Topic: availabilityTopic(), Payload: []byte("offline"), Retain: true},
+		OnMessage: onMessage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filter := range []string{base + "/+/+/set", discoveryPrefix + "/status"} {
+		if err = c.Subscribe(filter); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if err = c.Publish(availabilityTopic(), []byte("online"), true); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	mutex.Lock()
+	client = c
+	discovered = map[string]bool{}
+	mutex.Unlock()
+	return c, nil
+}
+
+// serve will publish discovery and telemetry until connection is lost
+func serve(c *Client) {
+	interval := config.GetConfig().MqttInterval
+	if interval <= 0 {
+		interval = 10
+	}
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	publishDevices()
+	publishTelemetry()
+	for {
+		select {
+		case <-ticker.C:
+			renewSpeeds(interval)
+			publishDevices()
+			publishTelemetry()
+		case <-c.Done():
+			return
+		}
+	}
+}
+
+// publishTelemetry will publish sensor discovery for new series and current values of all series
+func publishTelemetry() {
+	for _, sample := range history.Collect() {
+		objectId := id(sample.ChannelId + "_" + sample.Metric)
+		stateTopic := fmt.Sprintf("%s/%s/%s/%s", base, id(sample.DeviceId), id(sample.ChannelId), sample.Metric)
+
+		discovery := map[string]interface{}{
+			"name":                sensorName(sample),
+			"unique_id":           "openlinkhub_" + id(sample.DeviceId) + "_" + objectId,
+			"state_topic":         stateTopic,
+			"availability_topic":  availabilityTopic(),
+			"unit_of_measurement": units[sample.Metric],
+			"state_class":         "measurement",
+			"device":              deviceInfo(sample.DeviceId, sample.Product),
+		}
+		if class, ok := deviceClasses[sample.Metric]; ok {
+			discovery["device_class"] = class
+		}
+		publishDiscovery("sensor", sample.DeviceId, objectId, discovery)
+		publish(stateTopic, []byte(strconv.FormatFloat(float64(sample.Value), 'f', 2, 32)), false)
+	}
+}
+
+// publishDevices will publish light, select and number discovery of all devices
+func publishDevices() {
+	for serial, device := range devices.GetDevices() {
+		info := deviceInfo(serial, device.Product)
+
+		if devices.HasMethod(serial, "UpdateRgbProfile", reflect.Int, reflect.String) {
+			discovery := map[string]interface{}{
+				"name":                  "RGB",
+				"unique_id":             "openlinkhub_" + id(serial) + "_rgb",
+				"schema":                "json",
+				"command_topic":         commandTopic(serial, entityLight),
+				"state_topic":           stateTopic(serial, entityLight),
+				"availability_topic":    availabilityTopic(),
+				"brightness":            devices.HasMethod(serial, "ChangeDeviceBrightnessValue", reflect.Uint8),
+				"supported_color_modes": []string{"rgb"},
+				"device":                info,
+			}
+			if effects := getRgbProfiles(serial); len(effects) > 0 {
+				discovery["effect"] = true
+				discovery["effect_list"] = effects
+			}
+			publishDiscovery("light", serial, "rgb", discovery)
+			publishLightState(serial)
+		}
+
+		if profiles := devices.GetUserProfiles(serial); len(profiles) > 0 && devices.HasMethod(serial, "ChangeDeviceProfile", reflect.String) {
+			publishDiscovery("select", serial, "profile", map[string]interface{}{
+				"name":               "User profile",
+				"unique_id":          "openlinkhub_" + id(serial) + "_profile",
+				"command_topic":      commandTopic(serial, entityProfile),
+				"availability_topic": availabilityTopic(),
+				"options":            profiles,
+				"device":             info,
+			})
+		}
+
+		if len(getFanChannels(serial)) > 0 && devices.HasMethod(serial, "UpdateSpeedProfile", reflect.Int, reflect.String) {
+			publishDiscovery("select", serial, "speed_profile", map[string]interface{}{
+				"name":               "Fan profile",
+				"unique_id":          "openlinkhub_" + id(serial) + "_speed_profile",
+				"command_topic":      commandTopic(serial, entitySpeedProfile),
+				"availability_topic": availabilityTopic(),
+				"options":            getSpeedProfiles(),
+				"device":             info,
+			})
+			publishDiscovery("number", serial, "speed", map[string]interface{}{
+				"name":                "Fan speed",
+				"unique_id":           "openlinkhub_" + id(serial) + "_speed",
+				"command_topic":       commandTopic(serial, entitySpeed),
+				"availability_topic":  availabilityTopic(),
+				"min":                 minSpeed,
+				"max":                 100,
+				"step":                5,
+				"unit_of_measurement": "%",
+				"mode":                "slider",
+				"device":              info,
+			})
+		}
+	}
+}
+
+// onMessage will route commands to devices
+func onMessage(topic string, payload []byte) {
+	if topic == discoveryPrefix+"/status" {
+		if string(payload) == "online" {
+			// Home Assistant restarted, discovery has to be sent again
+			mutex.Lock()
+			discovered = map[string]bool{}
+			mutex.Unlock()
+			go publishDevices()
+		}
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(topic, base+"/"), "/")
+	if len(parts) != 3 || parts[2] != "set" {
+		return
+	}
+
+	serial, entity := parts[0], parts[1]
+	if devices.GetDevice(serial) == nil {
+		logger.Log(logger.Fields{"serial": serial, "topic": topic}).Warn("MQTT command for non-existing device")
+		return
+	}
+
+	value := strings.TrimSpace(string(payload))
+	switch entity {
+	case entityLight:
+		setLight(serial, payload)
+	case entityProfile:
+		if devices.HasMethod(serial, "ChangeDeviceProfile", reflect.String) {
+			call(serial, "ChangeDeviceProfile", value)
+		}
+	case entitySpeedProfile:
+		if temperatures.GetTemperatureProfile(value) == nil {
+			logger.Log(logger.Fields{"serial": serial, "profile": value}).Warn("MQTT command with non-existing speed profile")
+			return
+		}
+		// Selected profile replaces fixed speed, so it must not be reverted when speed lease expires
+		releaseSpeed(serial)
+		for _, channelId := range getFanChannels(serial) {
+			call(serial, "UpdateSpeedProfile", channelId, value)
+		}
+	case entitySpeed:
+		speed, err := strconv.ParseFloat(value, 64)
+		if err != nil || speed < minSpeed || speed > 100 {
+			logger.Log(logger.Fields{"serial": serial, "speed": value}).Warn("MQTT command with invalid fan speed")
+			return
+		}
+		setSpeed(serial, uint8(speed))
+	}
+}
+
+// setSpeed will apply fixed speed to fan channels of a device through a speed override lease, so it is never saved
+// to device profile. Lease is renewed while connected to a broker, fans go back to their saved profiles when
+// connection is lost or daemon stops
+func setSpeed(serial string, speed uint8) {
+	targets := make([]overrides.Target, 0)
+	for _, channelId := range getFanChannels(serial) {
+		targets = append(targets, overrides.Target{DeviceId: serial, ChannelId: channelId})
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	interval := config.GetConfig().MqttInterval
+	if interval <= 0 {
+		interval = 10
+	}
+	lease, status := overrides.NewLease(targets, &speed, "", speedTtl(interval))
+	if status != 1 {
+		logger.Log(logger.Fields{"serial": serial, "speed": speed, "status": status}).Warn("Unable to apply MQTT fan speed")
+		return
+	}
+
+	// Targets of previous lease are moved to the new one, so releasing it leaves fans on new speed
+	mutex.Lock()
+	previous, ok := speedLeases[serial]
+	speedLeases[serial] = lease.Id
+	mutex.Unlock()
+	if ok {
+		overrides.ReleaseLease(previous)
+	}
+}
+
+// releaseSpeed will revert fixed speed set over MQTT
+func releaseSpeed(serial string) {
+	mutex.Lock()
+	id, ok := speedLeases[serial]
+	delete(speedLeases, serial)
+	mutex.Unlock()
+
+	if ok {
+		overrides.ReleaseLease(id)
+	}
+}
+
+// renewSpeeds will extend speed leases for another publish interval. Leases reverted in the meantime are dropped
+func renewSpeeds(interval int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for serial, id := range speedLeases {
+		if _, status := overrides.RenewLease(id, speedTtl(interval)); status != 1 {
+			delete(speedLeases, serial)
+		}
+	}
+}
+
+// speedTtl will return lease ttl which survives two missed publish intervals
+func speedTtl(interval int) int {
+	return min(max(interval*3, overrides.MinTtl), overrides.MaxTtl)
+}
+
+// setLight will apply Home Assistant JSON light command
+func setLight(serial string, payload []byte) {
+	var command LightCommand
+	if err := json.Unmarshal(payload, &command); err != nil {
+		logger.Log(logger.Fields{"serial": serial, "error": err}).Warn("Unable to decode MQTT light command")
+		return
+	}
+
+	mutex.Lock()
+	state := getLightState(serial)
+	mutex.Unlock()
+
+	if command.State == "OFF" {
+		call(serial, "UpdateRgbProfile", -1, "off")
+		state.State = "OFF"
+	} else {
+		state.State = "ON"
+		if command.Brightness != nil && devices.HasMethod(serial, "ChangeDeviceBrightnessValue", reflect.Uint8) {
+			value := *command.Brightness
+			if value < 0 {
+				value = 0
+			}
+			if value > 255 {
+				value = 255
+			}
+			call(serial, "ChangeDeviceBrightnessValue", uint8(value*100/255))
+			state.Brightness = value
+		}
+
+		switch {
+		case command.Color != nil:
+			color := rgb.Color{Red: float64(command.Color.R), Green: float64(command.Color.G), Blue: float64(command.Color.B), Brightness: 1}
+			profile := rgb.Profile{StartColor: color, EndColor: color, Brightness: 1}
+			if devices.HasMethod(serial, "UpdateRgbProfileData", reflect.String, reflect.Struct) {
+				call(serial, "UpdateRgbProfileData", "static", profile)
+			}
+			call(serial, "UpdateRgbProfile", -1, "static")
+			state.Color = map[string]int{"r": int(command.Color.R), "g": int(command.Color.G), "b": int(command.Color.B)}
+			state.Effect = "static"
+		case len(command.Effect) > 0:
+			call(serial, "UpdateRgbProfile", -1, command.Effect)
+			state.Effect = command.Effect
+		case state.Effect == "" || state.Effect == "off":
+			call(serial, "UpdateRgbProfile", -1, "static")
+			state.Effect = "static"
+		}
+	}
+
+	mutex.Lock()
+	lights[serial] = state
+	mutex.Unlock()
+	publishLightState(serial)
+}
+
+// publishLightState will publish last known light state
+func publishLightState(serial string) {
+	mutex.Lock()
+	state := *getLightState(serial)
+	mutex.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	publish(stateTopic(serial, entityLight), data, true)
+}
+
+// getLightState will return light state of a device, or a default one. Caller must hold the mutex
+func getLightState(serial string) *LightState {
+	state, ok := lights[serial]
+	if !ok {
+		state = &LightState{
+			State:      "ON",
+			Brightness: 255,
+			ColorMode:  "rgb",
+			Color:      map[string]int{"r": 255, "g": 255, "b": 255},
+		}
+		lights[serial] = state
+	}
+	copied := *state
+	return &copied
+}
+
+// publishDiscovery will publish retained Home Assistant discovery payload once per connection
+func publishDiscovery(component, serial, objectId string, payload map[string]interface{}) {
+	topic := fmt.Sprintf("%s/%s/openlinkhub_%s/%s/config", discoveryPrefix, component, id(serial), id(objectId))
+
+	mutex.Lock()
+	if discovered[topic] {
+		mutex.Unlock()
+		return
+	}
+	discovered[topic] = true
+	mutex.Unlock()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "topic": topic}).Error("Unable to encode MQTT discovery")
+		return
+	}
+	publish(topic, data, true)
+}
+
+// publish will send a message if connected
+func publish(topic string, payload []byte, retain bool) {
+	mutex.Lock()
+	c := client
+	mutex.Unlock()
+
+	if c == nil {
+		return
+	}
+	if err := c.Publish(topic, payload, retain); err != nil {
+		logger.Log(logger.Fields{"error": err, "topic": topic}).Warn("Unable to publish MQTT message")
+	}
+}
+
+// call will call device method and log non-success status
+func call(serial, method string, args ...interface{}) {
+	results := devices.CallDeviceMethod(serial, method, args...)
+	if len(results) > 0 && results[0].CanUint() && results[0].Uint() != 1 {
+		logger.Log(logger.Fields{"serial": serial, "method": method, "status": results[0].Uint()}).Warn("MQTT command was not applied")
+	}
+}
+
+// getFanChannels will return channels with speed control that are not pumps
+func getFanChannels(serial string) []int {
+	channels := make([]int, 0)
+	instance := reflect.Indirect(reflect.ValueOf(devices.GetDevice(serial)))
+	if instance.Kind() != reflect.Struct {
+		return channels
+	}
+
+	list := instance.FieldByName("Devices")
+	if !list.IsValid() || list.Kind() != reflect.Map || list.Type().Key().Kind() != reflect.Int {
+		return channels
+	}
+
+	for _, key := range list.MapKeys() {
+		channel := reflect.Indirect(list.MapIndex(key))
+		if channel.Kind() != reflect.Struct {
+			continue
+		}
+		hasSpeed := channel.FieldByName("HasSpeed")
+		pump := channel.FieldByName("ContainsPump")
+		if !hasSpeed.IsValid() || hasSpeed.Kind() != reflect.Bool || !hasSpeed.Bool() {
+			continue
+		}
+		if pump.IsValid() && pump.Kind() == reflect.Bool && pump.Bool() {
+			continue
+		}
+		channels = append(channels, int(key.Int()))
+	}
+	sort.Ints(channels)
+	return channels
+}
+
+// getRgbProfiles will return names of RGB profiles supported by device
+func getRgbProfiles(serial string) []string {
+	if !devices.HasMethod(serial, "GetRgbProfiles") {
+		return nil
+	}
+
+	results := devices.CallDeviceMethod(serial, "GetRgbProfiles")
+	if len(results) == 0 {
+		return nil
+	}
+	return mapKeys(reflect.ValueOf(results[0].Interface()))
+}
+
+// getSpeedProfiles will return names of user visible speed profiles
+func getSpeedProfiles() []string {
+	profiles := make([]string, 0)
+	for name, profile := range temperatures.GetTemperatureProfiles() {
+		if !profile.Hidden {
+			profiles = append(profiles, name)
+		}
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// mapKeys will return sorted string keys of a map value
+func mapKeys(value reflect.Value) []string {
+	if !value.IsValid() || value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+
+	keys := make([]string, 0, value.Len())
+	for _, key := range value.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// deviceInfo will return Home Assistant device block
+func deviceInfo(serial, product string) DeviceInfo {
+	name := product
+	if len(name) == 0 {
+		name = serial
+	}
+	return DeviceInfo{
+		Identifiers:  []string{"openlinkhub_" + id(serial)},
+		Name:         name,
+		Manufacturer: "Corsair",
+		Model:        product,
+	}
+}
+
+// sensorName will return Home Assistant sensor name of a sample
+func sensorName(sample history.Sample) string {
+	name := sample.Name
+	if len(name) == 0 {
+		name = "Channel " + sample.ChannelId
+	}
+	switch sample.Metric {
+	case history.MetricRpm:
+		return name + " speed"
+	case history.MetricBattery:
+		return "Battery"
+	case history.MetricPower:
+		return name + " power"
+	case history.MetricLoad:
+		return name + " load"
+	case history.MetricDuty:
+		return name + " duty"
+	}
+	return name + " temperature"
+}
+
+// availabilityTopic will return bridge availability topic
+func availabilityTopic() string {
+	return base + "/status"
+}
+
+// commandTopic will return command topic of a device entity
+func commandTopic(serial, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/set", base, serial, entity)
+}
+
+// stateTopic will return state topic of a device entity
+func stateTopic(serial, entity string) string {
+	return fmt.Sprintf("%s/%s/%s/state", base, serial, entity)
+}
+
+// id will return value usable in topics and Home Assistant object ids
+func id(value string) string {
+	return idRegex.ReplaceAllString(value, "_")
+}
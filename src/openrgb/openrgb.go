@@ -127,6 +127,13 @@ func GetDeviceController(serial string) *common.OpenRGBController {
 	return nil
 }
 
+// GetDeviceControllers will return a copy of registered controllers, regardless of OpenRGB server state
+func GetDeviceControllers() []*common.OpenRGBController {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return append([]*common.OpenRGBController(nil), controllers...)
+}
+
 // NotifyControllerChange will notify OpenRGB about controller change
 func NotifyControllerChange(serial string) {
 	if enabled {
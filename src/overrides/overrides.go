@@ -0,0 +1,343 @@
+package overrides
+
+// Package: overrides
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/temperatures"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Target struct {
+	DeviceId        string `json:"deviceId"`
+	ChannelId       int    `json:"channelId"`
+	PreviousProfile string `json:"previousProfile"`
+}
+
+type Lease struct {
+	Id      string    `json:"id"`
+	Profile string    `json:"profile"`
+	Speed   *uint8    `json:"speed,omitempty"`
+	Ttl     int       `json:"ttl"`
+	Created time.Time `json:"created"`
+	Renewed time.Time `json:"renewed"`
+	Expires time.Time `json:"expires"`
+	Expired bool      `json:"expired"`
+	Targets []Target  `json:"targets"`
+}
+
+const (
+	MinTtl = 5
+	MaxTtl = 86400
+)
+
+var (
+	location        = ""
+	leases          = map[string]*Lease{}
+	mutex           sync.Mutex
+	refreshInterval = 1000
+)
+
+// Init will revert leases left from previous run and start expiry loop. It runs before devices are loaded,
+// so channels start on their saved speed profiles even when previous run was interrupted
+func Init() {
+	location = config.GetDatabasePath() + "/overrides.json"
+	if common.FileExists(location) {
+		previous := map[string]*Lease{}
+		file, err := os.Open(location)
+		if err != nil {
+			logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to load speed overrides")
+		} else {
+			if err = json.NewDecoder(file).Decode(&previous); err != nil {
+				logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to decode speed overrides")
+			}
+			_ = file.Close()
+		}
+
+		for _, lease := range previous {
+			for _, target := range lease.Targets {
+				if devices.RestoreSpeedProfile(target.DeviceId, target.ChannelId, lease.Profile, target.PreviousProfile) > 0 {
+					logger.Log(logger.Fields{"lease": lease.Id, "serial": target.DeviceId, "channelId": target.ChannelId, "profile": target.PreviousProfile}).Info("Speed override from previous run is reverted")
+				}
+			}
+		}
+		save()
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(refreshInterval) * time.Millisecond)
+		defer ticker.Stop()
+		for range ticker.C {
+			expire()
+		}
+	}()
+}
+
+// GetLeases will return all active leases
+func GetLeases() []Lease {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make([]Lease, 0, len(leases))
+	for _, lease := range leases {
+		result = append(result, *lease)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+	return result
+}
+
+// NewLease will apply fixed speed, or temperature profile when speed is nil, to given targets for ttl seconds.
+// Targets already held by another lease are moved to the new one
+func NewLease(targets []Target, speed *uint8, profile string, ttl int) (*Lease, uint8) {
+	if config.GetConfig().Manual {
+		return nil, 4
+	}
+
+	if ttl < MinTtl || ttl > MaxTtl {
+		return nil, 3
+	}
+
+	if speed != nil {
+		profile = temperatures.AddFixedSpeedProfile(*speed)
+	} else if temperatures.GetTemperatureProfile(profile) == nil {
+		return nil, 2
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	now := time.Now()
+	lease := &Lease{
+		Id:      newLeaseId(),
+		Profile: profile,
+		Speed:   speed,
+		Ttl:     ttl,
+		Created: now,
+		Renewed: now,
+		Expires: now.Add(time.Duration(ttl) * time.Second),
+		Targets: make([]Target, 0),
+	}
+
+	for _, target := range targets {
+		previous, ok := takeTarget(target.DeviceId, target.ChannelId)
+		if !ok {
+			previous = getChannelProfile(target.DeviceId, target.ChannelId)
+		}
+
+		if len(previous) == 0 {
+			logger.Log(logger.Fields{"serial": target.DeviceId, "channelId": target.ChannelId}).Warn("Unable to find speed profile for override target")
+			continue
+		}
+
+		lease.Targets = append(lease.Targets, Target{
+			DeviceId:        target.DeviceId,
+			ChannelId:       target.ChannelId,
+			PreviousProfile: previous,
+		})
+	}
+
+	if len(lease.Targets) == 0 {
+		save()
+		return nil, 0
+	}
+
+	// Previous profiles are saved before devices save the override, so they can be restored after a crash
+	leases[lease.Id] = lease
+	save()
+
+	applied := make([]Target, 0, len(lease.Targets))
+	for _, target := range lease.Targets {
+		results := devices.CallDeviceMethod(target.DeviceId, "UpdateSpeedProfile", target.ChannelId, profile)
+		if len(results) == 0 || results[0].Uint() != 1 {
+			logger.Log(logger.Fields{"serial": target.DeviceId, "channelId": target.ChannelId, "profile": profile}).Warn("Unable to apply speed override")
+
+			// Target taken over from another lease still runs its override and has to be reverted
+			if getChannelProfile(target.DeviceId, target.ChannelId) == target.PreviousProfile {
+				continue
+			}
+		}
+		applied = append(applied, target)
+	}
+	lease.Targets = applied
+
+	if len(lease.Targets) == 0 {
+		delete(leases, lease.Id)
+		save()
+		return nil, 0
+	}
+
+	save()
+	value := *lease
+	return &value, 1
+}
+
+// RenewLease will extend lease expiration. When ttl is 0, original lease ttl is used
+func RenewLease(id string, ttl int) (*Lease, uint8) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	lease, ok := leases[id]
+	if !ok || lease.Expired {
+		return nil, 0
+	}
+
+	if ttl != 0 {
+		if ttl < MinTtl || ttl > MaxTtl {
+			return nil, 3
+		}
+		lease.Ttl = ttl
+	}
+
+	lease.Renewed = time.Now()
+	lease.Expires = lease.Renewed.Add(time.Duration(lease.Ttl) * time.Second)
+	save()
+	value := *lease
+	return &value, 1
+}
+
+// ReleaseLease will revert all lease targets to saved profiles
+func ReleaseLease(id string) uint8 {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	lease, ok := leases[id]
+	if !ok {
+		return 0
+	}
+
+	lease.Expired = true
+	revert(lease)
+	save()
+	return 1
+}
+
+// expire will revert all expired leases. Targets of disconnected devices are retried on next run
+func expire() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	changed := false
+	now := time.Now()
+	for _, lease := range leases {
+		if !lease.Expired && now.Before(lease.Expires) {
+			continue
+		}
+
+		if !lease.Expired {
+			logger.Log(logger.Fields{"lease": lease.Id, "profile": lease.Profile}).Info("Speed override lease expired")
+			lease.Expired = true
+			changed = true
+		}
+
+		pending := len(lease.Targets)
+		revert(lease)
+		if pending != len(lease.Targets) || len(lease.Targets) == 0 {
+			changed = true
+		}
+	}
+
+	if changed {
+		save()
+	}
+}
+
+// revert will restore previous speed profile on every lease target and remove the lease when done
+func revert(lease *Lease) {
+	pending := make([]Target, 0)
+	for _, target := range lease.Targets {
+		if devices.GetDevice(target.DeviceId) == nil {
+			pending = append(pending, target)
+			continue
+		}
+
+		results := devices.CallDeviceMethod(target.DeviceId, "UpdateSpeedProfile", target.ChannelId, target.PreviousProfile)
+		if len(results) == 0 || results[0].Uint() != 1 {
+			logger.Log(logger.Fields{"serial": target.DeviceId, "channelId": target.ChannelId, "profile": target.PreviousProfile}).Warn("Unable to revert speed override")
+		}
+	}
+
+	lease.Targets = pending
+	if len(lease.Targets) == 0 {
+		delete(leases, lease.Id)
+	}
+}
+
+// takeTarget will remove target from any active lease and return its saved profile
+func takeTarget(deviceId string, channelId int) (string, bool) {
+	for id, lease := range leases {
+		if lease.Expired {
+			continue
+		}
+
+		for i, target := range lease.Targets {
+			if target.DeviceId == deviceId && target.ChannelId == channelId {
+				lease.Targets = append(lease.Targets[:i], lease.Targets[i+1:]...)
+				if len(lease.Targets) == 0 {
+					delete(leases, id)
+				}
+				return target.PreviousProfile, true
+			}
+		}
+	}
+	return "", false
+}
+
+// getChannelProfile will return current speed profile of a device channel
+func getChannelProfile(deviceId string, channelId int) string {
+	instance := reflect.Indirect(reflect.ValueOf(devices.GetDevice(deviceId)))
+	if instance.Kind() != reflect.Struct {
+		return ""
+	}
+
+	channels := instance.FieldByName("Devices")
+	if !channels.IsValid() || channels.Kind() != reflect.Map || channels.Type().Key().Kind() != reflect.Int {
+		return ""
+	}
+
+	channel := channels.MapIndex(reflect.ValueOf(channelId).Convert(channels.Type().Key()))
+	if !channel.IsValid() {
+		return ""
+	}
+
+	profile := reflect.Indirect(channel)
+	if profile.Kind() != reflect.Struct {
+		return ""
+	}
+
+	value := profile.FieldByName("Profile")
+	if !value.IsValid() || value.Kind() != reflect.String {
+		return ""
+	}
+	return value.String()
+}
+
+// newLeaseId will generate random lease id
+func newLeaseId() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// save will save active leases, so they can be reverted after restart
+func save() {
+	if err := common.SaveJsonData(location, leases); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to save speed overrides")
+	}
+}
@@ -0,0 +1,255 @@
+package reload
+
+// Package: reload
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/keyboards"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/macro"
+	"OpenLinkHub/src/rgb"
+	"OpenLinkHub/src/temperatures"
+	"bytes"
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Reload targets
+const (
+	TargetConfig       = "config"
+	TargetTemperatures = "temperatures"
+	TargetRgb          = "rgb"
+	TargetMacros       = "macros"
+	TargetKeyboards    = "keyboards"
+)
+
+// Report is outcome of a reload
+type Report struct {
+	Applied  []string `json:"applied"`  // Settings and profiles applied to running daemon
+	Restart  []string `json:"restart"`  // Changed settings which take effect after restart
+	Problems []string `json:"problems"` // Validation problems, affected files are not applied
+}
+
+// watch is a directory watched for changes. Empty file matches any .json file in directory
+type watch struct {
+	target string
+	dir    string
+	file   string
+}
+
+var (
+	mutex    sync.Mutex
+	running  sync.Mutex // Serializes reloads from API and watcher
+	inotify  *os.File
+	watches  = map[int]watch{}
+	pending  = map[string]bool{}
+	timer    *time.Timer
+	debounce = time.Second
+	targets  = []string{TargetConfig, TargetTemperatures, TargetRgb, TargetMacros, TargetKeyboards}
+)
+
+// Init will start watching config.json and profile folders when configWatcher is enabled
+func Init() {
+	if !config.GetConfig().ConfigWatcher {
+		return
+	}
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err}).Warn("Unable to initialize config watcher")
+		return
+	}
+
+	location, _ := config.GetLocation()
+	database := config.GetDatabasePath()
+	list := []watch{
+		{target: TargetConfig, dir: filepath.Dir(location), file: filepath.Base(location)},
+		{target: TargetRgb, dir: database, file: "rgb.json"},
+		{target: TargetTemperatures, dir: database + "/temperatures"},
+		{target: TargetMacros, dir: database + "/macros"},
+		{target: TargetKeyboards, dir: database + "/keyboard"},
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	mask := uint32(unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_DELETE)
+	for _, w := range list {
+		wd, e := unix.InotifyAddWatch(fd, w.dir, mask)
+		if e != nil {
+			logger.Log(logger.Fields{"error": e, "location": w.dir}).Warn("Unable to watch folder for changes")
+			continue
+		}
+		watches[wd] = w
+	}
+
+	inotify = os.NewFile(uintptr(fd), "inotify")
+	go read(inotify)
+	logger.Log(logger.Fields{"config": location, "database": database}).Info("Watching config and profiles for changes")
+}
+
+// Stop will stop watching for changes
+func Stop() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if timer != nil {
+		timer.Stop()
+		timer = nil
+	}
+	if inotify != nil {
+		_ = inotify.Close()
+		inotify = nil
+	}
+	watches = map[int]watch{}
+}
+
+// Reload will validate and apply given targets, or all targets when none are given
+func Reload(names ...string) Report {
+	running.Lock()
+	defer running.Unlock()
+
+	if len(names) == 0 {
+		names = targets
+	}
+
+	report := Report{
+		Applied:  make([]string, 0),
+		Restart:  make([]string, 0),
+		Problems: make([]string, 0),
+	}
+
+	// Config goes first, temperature settings depend on it
+	if slices.Contains(names, TargetConfig) {
+		applied, restart, problems := config.Reload()
+		report.Applied = append(report.Applied, applied...)
+		report.Restart = append(report.Restart, restart...)
+		report.Problems = append(report.Problems, problems...)
+		if slices.Contains(applied, "logLevel") {
+			logger.SetLevel(config.GetConfig().LogLevel)
+		}
+	}
+
+	if slices.Contains(names, TargetTemperatures) || slices.Contains(names, TargetConfig) {
+		removed, problems := temperatures.Reload()
+		if len(problems) > 0 {
+			report.Problems = append(report.Problems, problems...)
+		} else if slices.Contains(names, TargetTemperatures) {
+			report.Applied = append(report.Applied, "temperatureProfiles")
+		}
+
+		// Devices using removed profile fall back to default profile
+		for _, name := range removed {
+			devices.ResetSpeedProfiles(name)
+		}
+	}
+
+	if slices.Contains(names, TargetRgb) {
+		if err := rgb.Reload(); err != nil {
+			report.Problems = append(report.Problems, err.Error())
+		} else {
+			report.Applied = append(report.Applied, "rgbProfiles")
+		}
+	}
+
+	if slices.Contains(names, TargetMacros) {
+		if problems := macro.Reload(); len(problems) > 0 {
+			report.Problems = append(report.Problems, problems...)
+		} else {
+			report.Applied = append(report.Applied, "macros")
+		}
+	}
+
+	if slices.Contains(names, TargetKeyboards) {
+		if problems := keyboards.Reload(); len(problems) > 0 {
+			report.Problems = append(report.Problems, problems...)
+		} else {
+			report.Applied = append(report.Applied, "keyboardLayouts")
+		}
+	}
+
+	fields := logger.Fields{"targets": names, "applied": report.Applied}
+	if len(report.Restart) > 0 {
+		fields["restart"] = report.Restart
+	}
+	if len(report.Problems) > 0 {
+		fields["problems"] = report.Problems
+		logger.Log(fields).Warn("Configuration reloaded with problems")
+	} else {
+		logger.Log(fields).Info("Configuration reloaded")
+	}
+	return report
+}
+
+// read will read inotify events and schedule reload of changed targets
+func read(file *os.File) {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				logger.Log(logger.Fields{"error": err}).Warn("Config watcher stopped")
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			start := offset + unix.SizeofInotifyEvent
+			end := start + int(event.Len)
+			if end > n {
+				break
+			}
+			name := string(bytes.TrimRight(buf[start:end], "\x00"))
+			offset = end
+			changed(int(event.Wd), name)
+		}
+	}
+}
+
+// changed will schedule reload when a watched file changes. Changes are debounced, editors write files in several steps
+func changed(wd int, name string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	w, ok := watches[wd]
+	if !ok || len(name) == 0 {
+		return
+	}
+	if len(w.file) > 0 && name != w.file {
+		return
+	}
+	if len(w.file) == 0 && filepath.Ext(name) != ".json" {
+		return
+	}
+
+	pending[w.target] = true
+	if timer != nil {
+		timer.Stop()
+	}
+	timer = time.AfterFunc(debounce, func() {
+		mutex.Lock()
+		names := make([]string, 0, len(pending))
+		for _, target := range targets {
+			if pending[target] {
+				names = append(names, target)
+			}
+		}
+		pending = map[string]bool{}
+		timer = nil
+		mutex.Unlock()
+
+		if len(names) > 0 {
+			Reload(names...)
+		}
+	})
+}
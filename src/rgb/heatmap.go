@@ -0,0 +1,14 @@
+package rgb
+
+// HeatmapColor will return a color for given heat intensity, ranging from 0.0 (cold) to 1.0 (hot)
+func (r *ActiveRGB) HeatmapColor(intensity float64) *Color {
+	intensity = clampFloat01(intensity)
+	if r.RGBMiddleColor == nil || (*r.RGBMiddleColor == Color{}) {
+		return interpolateColor(r.RGBStartColor, r.RGBEndColor, intensity, r.RGBBrightness)
+	}
+
+	if intensity <= 0.5 {
+		return interpolateColor(r.RGBStartColor, r.RGBMiddleColor, intensity*2, r.RGBBrightness)
+	}
+	return interpolateColor(r.RGBMiddleColor, r.RGBEndColor, (intensity-0.5)*2, r.RGBBrightness)
+}
@@ -2,11 +2,14 @@ package rgb
 
 import (
 	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
 	"encoding/json"
+	"fmt"
 	"math"
 	"math/rand"
 	"os"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -87,6 +90,7 @@ type ActiveRGB struct {
 
 var (
 	rgb        RGB
+	mutex      sync.RWMutex // Guards rgb, which is replaced as a whole and never changed in place
 	profileOff = Profile{
 		Speed:       0,
 		Brightness:  0,
@@ -100,27 +104,62 @@ var (
 
 // GetRGB will return RGB
 func GetRGB() RGB {
+	mutex.RLock()
+	defer mutex.RUnlock()
 	return rgb
 }
 
 // Init will initialize RGB configuration
 func Init() {
-	pwd, _ := os.Getwd()
-	cfg := pwd + "/database/rgb.json"
+	cfg := config.GetDatabasePath() + "/rgb.json"
 	f, err := os.Open(cfg)
 	if err != nil {
 		panic(err.Error())
 	}
-	if err = json.NewDecoder(f).Decode(&rgb); err != nil {
+	value := RGB{}
+	if err = json.NewDecoder(f).Decode(&value); err != nil {
 		panic(err.Error())
 	}
 
 	// Off profile to disable RGB
-	rgb.Profiles["off"] = profileOff
+	value.Profiles["off"] = profileOff
+
+	mutex.Lock()
+	rgb = value
+	mutex.Unlock()
+}
+
+// Reload will re-read rgb.json. Running profiles are kept when file is invalid
+func Reload() error {
+	cfg := config.GetDatabasePath() + "/rgb.json"
+	f, err := os.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	value := RGB{}
+	if err = json.NewDecoder(f).Decode(&value); err != nil {
+		return fmt.Errorf("%s: %w", cfg, err)
+	}
+	if len(value.Profiles) == 0 {
+		return fmt.Errorf("%s: no profiles defined", cfg)
+	}
+
+	value.Profiles["off"] = profileOff
+
+	mutex.Lock()
+	rgb = value
+	mutex.Unlock()
+	return nil
 }
 
 // GetRgbProfile will return Profile struct
 func GetRgbProfile(profile string) *Profile {
+	mutex.RLock()
+	defer mutex.RUnlock()
 	if val, ok := rgb.Profiles[profile]; ok {
 		return &val
 	}
@@ -129,6 +168,8 @@ func GetRgbProfile(profile string) *Profile {
 
 // GetRgbProfiles will return all RGB profiles
 func GetRgbProfiles() map[string]Profile {
+	mutex.RLock()
+	defer mutex.RUnlock()
 	return rgb.Profiles
 }
 
@@ -0,0 +1,326 @@
+package scenes
+
+// Package: scenes
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/cluster"
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/ledstream"
+	"OpenLinkHub/src/logger"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+type DeviceState struct {
+	Serial      string `json:"serial"`
+	Product     string `json:"product"`
+	UserProfile string `json:"userProfile"`
+	RGBCluster  *bool  `json:"rgbCluster,omitempty"`
+}
+
+type ClusterState struct {
+	Serial     string   `json:"serial"`
+	Name       string   `json:"name"`
+	RGBProfile string   `json:"rgbProfile"`
+	Brightness uint8    `json:"brightness"`
+	Members    []string `json:"members"`
+}
+
+type Scene struct {
+	Name     string                  `json:"name"`
+	Created  string                  `json:"created"`
+	Updated  string                  `json:"updated"`
+	Devices  map[string]DeviceState  `json:"devices"`
+	Clusters map[string]ClusterState `json:"clusters"`
+}
+
+type Result struct {
+	Serial  string `json:"serial"`
+	Product string `json:"product"`
+	Status  uint8  `json:"status"`
+	Result  string `json:"result"`
+}
+
+const (
+	ResultCaptured    = "captured"
+	ResultApplied     = "applied"
+	ResultMissing     = "missing"
+	ResultUnsupported = "unsupported"
+	ResultFailed      = "failed"
+)
+
+var (
+	location        = ""
+	scenes          = map[string]*Scene{}
+	mutex           sync.Mutex
+	profilePrefix   = "scene"
+	timestampLayout = "2006-01-02 15:04:05"
+)
+
+// Init will load all available scenes
+func Init() {
+	location = config.GetDatabasePath() + "/scenes/"
+
+	files, err := os.ReadDir(location)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to read content of a folder")
+		return
+	}
+
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue // Exclude folders if any
+		}
+
+		// Define a full path of filename
+		sceneLocation := location + fi.Name()
+
+		// Check if filename has .json extension
+		if !common.IsValidExtension(sceneLocation, ".json") {
+			continue
+		}
+
+		file, err := os.Open(sceneLocation)
+		if err != nil {
+			logger.Log(logger.Fields{"error": err, "location": sceneLocation}).Error("Unable to read scene")
+			continue
+		}
+
+		var value Scene
+		if err = json.NewDecoder(file).Decode(&value); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": sceneLocation}).Error("Unable to decode scene")
+			_ = file.Close()
+			continue
+		}
+		_ = file.Close()
+		scenes[value.Name] = &value
+	}
+}
+
+// GetScenes will return all scenes
+func GetScenes() []Scene {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make([]Scene, 0, len(scenes))
+	for _, scene := range scenes {
+		result = append(result, *scene)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// GetScene will return scene by name
+func GetScene(name string) *Scene {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if scene, ok := scenes[name]; ok {
+		value := *scene
+		return &value
+	}
+	return nil
+}
+
+// Capture will snapshot state of every connected device and RGB cluster into a named scene.
+// Existing scene with the same name is overwritten
+func Capture(name string) ([]Result, uint8) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	now := time.Now().Format(timestampLayout)
+	scene := &Scene{
+		Name:     name,
+		Created:  now,
+		Updated:  now,
+		Devices:  make(map[string]DeviceState),
+		Clusters: make(map[string]ClusterState),
+	}
+	if existing, ok := scenes[name]; ok {
+		scene.Created = existing.Created
+	}
+
+	for _, info := range cluster.GetClusters() {
+		scene.Clusters[info.Serial] = ClusterState{
+			Serial:     info.Serial,
+			Name:       info.Name,
+			RGBProfile: info.RGBProfile,
+			Brightness: info.Brightness,
+			Members:    info.Members,
+		}
+	}
+
+	results := make([]Result, 0)
+	for _, device := range getDevices() {
+		result := Result{Serial: device.Serial, Product: device.Product, Status: 0, Result: ResultFailed}
+
+		state := DeviceState{
+			Serial:      device.Serial,
+			Product:     device.Product,
+			UserProfile: profilePrefix + name,
+			RGBCluster:  getRgbCluster(device.Instance),
+		}
+
+		values := devices.CallDeviceMethod(device.Serial, "SaveUserProfile", state.UserProfile)
+		if len(values) == 0 {
+			result.Result = ResultUnsupported
+			results = append(results, result)
+			continue
+		}
+
+		if values[0].Uint() == 1 {
+			// OpenRGB integration enabled only for a LED stream is not part of the device state
+			if ledstream.IsStreaming(device.Serial) {
+				devices.UpdateUserProfile(device.Serial, state.UserProfile, ledstream.DisableIntegration)
+			}
+			scene.Devices[device.Serial] = state
+			result.Status = 1
+			result.Result = ResultCaptured
+		}
+		results = append(results, result)
+	}
+
+	if len(scene.Devices) == 0 {
+		return results, 2
+	}
+
+	sceneLocation := location + name + ".json"
+	if err := common.SaveJsonData(sceneLocation, scene); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": sceneLocation}).Error("Unable to save scene")
+		return results, 0
+	}
+	scenes[name] = scene
+	return results, 1
+}
+
+// Apply will apply a scene to every device and RGB cluster. Devices not connected anymore are skipped
+func Apply(name string) ([]Result, uint8) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	scene, ok := scenes[name]
+	if !ok {
+		return nil, 0
+	}
+
+	// Cluster membership goes first, so devices join the right cluster
+	for serial, state := range scene.Clusters {
+		c := cluster.GetCluster(serial)
+		if c == nil {
+			continue
+		}
+		cluster.UpdateCluster(serial, "", state.Members)
+		if len(state.RGBProfile) > 0 {
+			c.UpdateRgbProfile(0, state.RGBProfile)
+		}
+		c.ChangeDeviceBrightnessValue(state.Brightness)
+	}
+
+	results := make([]Result, 0, len(scene.Devices))
+	for serial, state := range scene.Devices {
+		result := Result{Serial: serial, Product: state.Product, Status: 0, Result: ResultFailed}
+		if devices.GetDevice(serial) == nil {
+			result.Result = ResultMissing
+			results = append(results, result)
+			continue
+		}
+
+		values := devices.CallDeviceMethod(serial, "ChangeDeviceProfile", state.UserProfile)
+		if len(values) == 0 {
+			result.Result = ResultUnsupported
+			results = append(results, result)
+			continue
+		}
+
+		if values[0].Uint() == 1 {
+			if state.RGBCluster != nil {
+				devices.CallDeviceMethod(serial, "ProcessSetRgbCluster", *state.RGBCluster)
+			}
+			result.Status = 1
+			result.Result = ResultApplied
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Serial < results[j].Serial
+	})
+	return results, 1
+}
+
+// Delete will delete a scene and its device user profiles
+func Delete(name string) uint8 {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	scene, ok := scenes[name]
+	if !ok {
+		return 0
+	}
+
+	for serial, state := range scene.Devices {
+		if devices.GetDevice(serial) == nil {
+			continue
+		}
+		devices.CallDeviceMethod(serial, "DeleteDeviceProfile", state.UserProfile)
+	}
+
+	sceneLocation := location + name + ".json"
+	if common.FileExists(sceneLocation) {
+		if err := os.Remove(sceneLocation); err != nil {
+			logger.Log(logger.Fields{"error": err, "location": sceneLocation}).Error("Unable to delete scene")
+			return 0
+		}
+	}
+	delete(scenes, name)
+	return 1
+}
+
+// getDevices will return sorted list of connected devices, excluding RGB clusters
+func getDevices() []*common.Device {
+	list := make([]*common.Device, 0)
+	for _, device := range devices.GetDevices() {
+		if device.ProductType == common.ProductTypeCluster {
+			continue
+		}
+		list = append(list, device)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Serial < list[j].Serial
+	})
+	return list
+}
+
+// getField will return struct field of device instance by name
+func getField(instance interface{}, name string) reflect.Value {
+	value := reflect.Indirect(reflect.ValueOf(instance))
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return value.FieldByName(name)
+}
+
+// getRgbCluster will return RGB cluster state of a device, if device supports it
+func getRgbCluster(instance interface{}) *bool {
+	field := getField(instance, "DeviceProfile")
+	if !field.IsValid() || (field.Kind() == reflect.Ptr && field.IsNil()) {
+		return nil
+	}
+
+	value := getField(field.Interface(), "RGBCluster")
+	if !value.IsValid() || value.Kind() != reflect.Bool {
+		return nil
+	}
+	enabled := value.Bool()
+	return &enabled
+}
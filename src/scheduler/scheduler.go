@@ -9,6 +9,7 @@ import (
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/devices"
 	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/webhooks"
 	"encoding/json"
 	"os"
 	"sync"
@@ -17,16 +18,16 @@ import (
 
 type Scheduler struct {
 	LightsOut  bool
-	RGBControl bool   `json:"rgbControl"`
-	RGBOff     string `json:"rgbOff"`
-	RGBOn      string `json:"rgbOn"`
-	LCDControl bool   `json:"lcdControl"`
+	RGBControl bool     `json:"rgbControl"`
+	RGBOff     string   `json:"rgbOff"`
+	RGBOn      string   `json:"rgbOn"`
+	LCDControl bool     `json:"lcdControl"`
+	Clusters   []string `json:"clusters"`
 }
 
 var (
 	location    = ""
 	scheduler   Scheduler
-	upgrade     = map[string]any{"lcdControl": false}
 	layout      = "15:04"
 	mu          sync.Mutex
 	timer       *time.Ticker
@@ -43,7 +44,7 @@ type Schedule struct {
 
 // Init will initialize a new config object
 func Init() {
-	location = config.GetConfig().ConfigPath + "/database/scheduler.json"
+	location = config.GetDatabasePath() + "/scheduler.json"
 	upgradeFile()
 	file, err := os.Open(location)
 
@@ -83,8 +84,8 @@ func SaveSchedulerSettings(data any) uint8 {
 	return 1
 }
 
-// UpdateRgbSettings will update RGB scheduler settings
-func UpdateRgbSettings(enabled bool, start, end string, lcdControl bool) uint8 {
+// UpdateRgbSettings will update RGB scheduler settings. When clusters are defined, only those RGB clusters are scheduled
+func UpdateRgbSettings(enabled bool, start, end string, lcdControl bool, clusters []string) uint8 {
 	rgbOff, err := time.Parse(layout, start)
 	if err != nil {
 		logger.Log(logger.Fields{"error": err}).Error("Failed to process rgb scheduler start time")
@@ -102,6 +103,7 @@ func UpdateRgbSettings(enabled bool, start, end string, lcdControl bool) uint8 {
 	scheduler.RGBOn = rgbOn.Format(layout)
 	scheduler.RGBControl = enabled
 	scheduler.LCDControl = lcdControl
+	scheduler.Clusters = clusters
 	current := scheduler
 	mu.Unlock()
 
@@ -122,6 +124,22 @@ func GetScheduler() Scheduler {
 	return scheduler
 }
 
+// scheduleBrightness will change brightness of all devices, or only of given RGB clusters
+func scheduleBrightness(mode uint8, clusters []string) {
+	switch mode {
+	case 0:
+		webhooks.Publish(webhooks.EventLightsOut, "", "", map[string]interface{}{"clusters": clusters})
+	case 1:
+		webhooks.Publish(webhooks.EventLightsOn, "", "", map[string]interface{}{"clusters": clusters})
+	}
+
+	if len(clusters) > 0 {
+		devices.ScheduleClusterBrightness(mode, clusters)
+		return
+	}
+	devices.ScheduleDeviceBrightness(mode)
+}
+
 // stopTasks will stop tasks
 func stopTasks() {
 	mu.Lock()
@@ -168,10 +186,11 @@ func startTasks() {
 				if !scheduler.LightsOut {
 					scheduler.LightsOut = true
 					lcdControl := scheduler.LCDControl
+					clusters := scheduler.Clusters
 					current := scheduler
 					mu.Unlock()
 
-					devices.ScheduleDeviceBrightness(0)
+					scheduleBrightness(0, clusters)
 					if lcdControl {
 						devices.ScheduleDeviceLcdBrightness(0)
 					}
@@ -189,10 +208,11 @@ func startTasks() {
 				if scheduler.LightsOut {
 					scheduler.LightsOut = false
 					lcdControl := scheduler.LCDControl
+					clusters := scheduler.Clusters
 					current := scheduler
 					mu.Unlock()
 
-					devices.ScheduleDeviceBrightness(1)
+					scheduleBrightness(1, clusters)
 					if lcdControl {
 						devices.ScheduleDeviceLcdBrightness(1)
 					}
@@ -223,10 +243,11 @@ func startTasks() {
 			if !scheduler.LightsOut {
 				scheduler.LightsOut = true
 				lcdControl := scheduler.LCDControl
+				clusters := scheduler.Clusters
 				current := scheduler
 				mu.Unlock()
 
-				devices.ScheduleDeviceBrightness(0)
+				scheduleBrightness(0, clusters)
 				if lcdControl {
 					devices.ScheduleDeviceLcdBrightness(0)
 				}
@@ -239,10 +260,11 @@ func startTasks() {
 			if scheduler.LightsOut {
 				scheduler.LightsOut = false
 				lcdControl := scheduler.LCDControl
+				clusters := scheduler.Clusters
 				current := scheduler
 				mu.Unlock()
 
-				devices.ScheduleDeviceBrightness(1)
+				scheduleBrightness(1, clusters)
 				if lcdControl {
 					devices.ScheduleDeviceLcdBrightness(1)
 				}
@@ -267,7 +289,7 @@ func startTasks() {
 	}(localTimer, localStop)
 }
 
-// upgradeFile will perform json file upgrade or create initial file
+// upgradeFile will create initial file. Existing file is upgraded by migration package
 func upgradeFile() {
 	if !common.FileExists(location) {
 		logger.Log(logger.Fields{"file": location}).Info("Scheduler file is missing, creating initial one.")
@@ -283,42 +305,5 @@ func upgradeFile() {
 		} else {
 			logger.Log(logger.Fields{"file": location}).Warn("Unable to create scheduler file.")
 		}
-	} else {
-		// File exists, check for possible upgrade
-		logger.Log(logger.Fields{"file": location}).Info("Scheduler file is found, checking for any upgrade...")
-
-		save := false
-		var data map[string]interface{}
-		file, err := os.Open(location)
-		if err != nil {
-			logger.Log(logger.Fields{"error": err, "file": location}).Error("Unable to open file.")
-			panic(err.Error())
-		}
-		defer func() {
-			if err := file.Close(); err != nil {
-				logger.Log(logger.Fields{"error": err, "file": location}).Error("Failed to close file.")
-			}
-		}()
-
-		if err = json.NewDecoder(file).Decode(&data); err != nil {
-			logger.Log(logger.Fields{"error": err, "file": location}).Error("Unable to decode file.")
-			panic(err.Error())
-		}
-
-		// Loop thru upgrade value
-		for key, value := range upgrade {
-			if _, ok := data[key]; !ok {
-				logger.Log(logger.Fields{"key": key, "value": value}).Info("Upgrading fields...")
-				data[key] = value
-				save = true
-			}
-		}
-
-		// Save on change
-		if save {
-			SaveSchedulerSettings(data)
-		} else {
-			logger.Log(logger.Fields{"file": location}).Info("Nothing to upgrade.")
-		}
 	}
 }
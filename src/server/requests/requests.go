@@ -5,20 +5,26 @@ package requests
 // License: GPL-3.0 or later
 
 import (
+	"OpenLinkHub/src/alerts"
 	"OpenLinkHub/src/audio"
+	"OpenLinkHub/src/calibration"
+	"OpenLinkHub/src/cluster"
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
 	"OpenLinkHub/src/dashboard"
 	"OpenLinkHub/src/devices"
 	"OpenLinkHub/src/devices/lcd"
 	"OpenLinkHub/src/display"
+	"OpenLinkHub/src/heatmap"
 	"OpenLinkHub/src/inputmanager"
 	"OpenLinkHub/src/keyboards"
 	"OpenLinkHub/src/language"
 	"OpenLinkHub/src/led"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/macro"
+	"OpenLinkHub/src/overrides"
 	"OpenLinkHub/src/rgb"
+	"OpenLinkHub/src/scenes"
 	"OpenLinkHub/src/scheduler"
 	"OpenLinkHub/src/temperatures"
 	"encoding/json"
@@ -190,6 +196,26 @@ type Payload struct {
 	MousePositionAbsolute         bool                  `json:"mousePositionAbsolute"`
 	MacroRepeat                   int                   `json:"macroRepeat"`
 	MacroRepeatDelay              int                   `json:"macroRepeatDelay"`
+	ClusterId                     string                `json:"clusterId"`
+	ClusterName                   string                `json:"clusterName"`
+	ClusterMembers                []string              `json:"clusterMembers"`
+	Clusters                      []string              `json:"clusters"`
+	SceneName                     string                `json:"sceneName"`
+	Targets                       []overrides.Target    `json:"targets"`
+	FixedSpeed                    *uint8                `json:"fixedSpeed"`
+	Ttl                           int                   `json:"ttl"`
+	LeaseId                       string                `json:"leaseId"`
+	PidMode                       bool                  `json:"pidMode"`
+	Setpoint                      float32               `json:"setpoint"`
+	PidEnabled                    bool                  `json:"pidEnabled"`
+	Kp                            float64               `json:"kp"`
+	Ki                            float64               `json:"ki"`
+	Kd                            float64               `json:"kd"`
+	MinDuty                       uint8                 `json:"minDuty"`
+	MaxDuty                       uint8                 `json:"maxDuty"`
+	RpmMode                       bool                  `json:"rpmMode"`
+	AlertRule                     alerts.Rule           `json:"alertRule"`
+	AlertId                       string                `json:"alertId"`
 	Status                        int
 	Code                          int
 	Message                       string
@@ -332,6 +358,48 @@ func ProcessUpdateTemperatureProfileGraph(r *http.Request) *Payload {
 	return &Payload{Message: language.GetValue("txtSpeedProfileNotUpdated"), Code: http.StatusOK, Status: 0}
 }
 
+// ProcessUpdateTemperatureProfilePid will process PID controller settings update of a temperature profile
+func ProcessUpdateTemperatureProfilePid(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.Profile) {
+		return &Payload{
+			Message: language.GetValue("txtProfileInvalidName"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	settings := temperatures.PidSettings{
+		Enabled:  req.PidEnabled,
+		Setpoint: req.Setpoint,
+		Kp:       req.Kp,
+		Ki:       req.Ki,
+		Kd:       req.Kd,
+		MinDuty:  req.MinDuty,
+		MaxDuty:  req.MaxDuty,
+	}
+
+	switch temperatures.UpdateTemperatureProfilePid(req.Profile, settings) {
+	case 1:
+		return &Payload{Message: language.GetValue("txtSpeedProfileUpdated"), Code: http.StatusOK, Status: 1}
+	case 2:
+		return &Payload{Message: language.GetValue("txtInvalidPidSettings"), Code: http.StatusOK, Status: 0}
+	case 3:
+		return &Payload{Message: language.GetValue("txtPidNotAllowedOnProfile"), Code: http.StatusOK, Status: 0}
+	}
+	return &Payload{Message: language.GetValue("txtNonExistingSpeedProfile"), Code: http.StatusOK, Status: 0}
+}
+
 // ProcessNewTemperatureProfile will process the creation of temperature profile
 func ProcessNewTemperatureProfile(r *http.Request) *Payload {
 	req := &Payload{}
@@ -486,6 +554,33 @@ func ProcessNewTemperatureProfile(r *http.Request) *Payload {
 		}
 	}
 
+	if req.PidMode {
+		if static || linear {
+			return &Payload{
+				Message: language.GetValue("txtStaticOrLinear"),
+				Code:    http.StatusOK,
+				Status:  0,
+			}
+		}
+
+		if !temperatures.ValidatePidSettings(temperatures.PidSettings{Setpoint: req.Setpoint, MaxDuty: 100}) {
+			return &Payload{
+				Message: language.GetValue("txtInvalidPidSettings"),
+				Code:    http.StatusOK,
+				Status:  0,
+			}
+		}
+
+		// PID output is always a duty
+		if req.RpmMode {
+			return &Payload{
+				Message: language.GetValue("txtRpmNotAllowedWithPid"),
+				Code:    http.StatusOK,
+				Status:  0,
+			}
+		}
+	}
+
 	newTemperatureProfile := &temperatures.NewTemperatureProfile{
 		Profile:            profile,
 		DeviceId:           deviceId,
@@ -497,6 +592,9 @@ func ProcessNewTemperatureProfile(r *http.Request) *Payload {
 		HwmonDevice:        hwmonId,
 		TemperatureInputId: temperatureInputId,
 		GpuIndex:           gpuIndex,
+		Pid:                req.PidMode,
+		Setpoint:           req.Setpoint,
+		Rpm:                req.RpmMode,
 	}
 
 	if temperatures.AddTemperatureProfile(newTemperatureProfile) {
@@ -3423,8 +3521,14 @@ func ProcessChangeRgbScheduler(r *http.Request) *Payload {
 		}
 	}
 
+	for _, serial := range req.Clusters {
+		if !common.AlphanumericRegex.MatchString(serial) || cluster.GetCluster(serial) == nil {
+			return &Payload{Message: language.GetValue("txtNonExistingRgbCluster"), Code: http.StatusOK, Status: 0}
+		}
+	}
+
 	// Run it
-	status := scheduler.UpdateRgbSettings(req.RgbControl, req.RgbOff, req.RgbOn, req.LcdControl)
+	status := scheduler.UpdateRgbSettings(req.RgbControl, req.RgbOff, req.RgbOn, req.LcdControl, req.Clusters)
 	switch status {
 	case 1:
 		return &Payload{Message: language.GetValue("txtRgbSchedulerUpdated"), Code: http.StatusOK, Status: 1}
@@ -4728,6 +4832,16 @@ func ProcessSetRgbCluster(r *http.Request) *Payload {
 
 	enabled := req.Mode == 1
 
+	if enabled && len(req.ClusterId) > 0 {
+		if !common.AlphanumericRegex.MatchString(req.ClusterId) {
+			return &Payload{Message: language.GetValue("txtNonExistingRgbCluster"), Code: http.StatusOK, Status: 0}
+		}
+
+		if cluster.AddMember(req.ClusterId, req.DeviceId) != 1 {
+			return &Payload{Message: language.GetValue("txtNonExistingRgbCluster"), Code: http.StatusOK, Status: 0}
+		}
+	}
+
 	results := devices.CallDeviceMethod(
 		req.DeviceId,
 		"ProcessSetRgbCluster",
@@ -4746,6 +4860,428 @@ func ProcessSetRgbCluster(r *http.Request) *Payload {
 	return &Payload{Message: language.GetValue("txtRgbClusterError"), Code: http.StatusOK, Status: 0}
 }
 
+// ProcessNewRgbCluster will process a PUT request from a client for a new named RGB cluster
+func ProcessNewRgbCluster(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if len(req.ClusterName) < 1 || len(req.ClusterName) > 32 {
+		return &Payload{Message: language.GetValue("txtInvalidRgbClusterName"), Code: http.StatusOK, Status: 0}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.ClusterName) {
+		return &Payload{Message: language.GetValue("txtInvalidRgbClusterName"), Code: http.StatusOK, Status: 0}
+	}
+
+	serial, status := devices.AddRgbCluster(req.ClusterName)
+	switch status {
+	case 1:
+		return &Payload{Message: language.GetValue("txtRgbClusterCreated"), Code: http.StatusOK, Status: 1, Data: serial}
+	case 2:
+		return &Payload{Message: language.GetValue("txtRgbClusterExists"), Code: http.StatusOK, Status: 0}
+	}
+	return &Payload{Message: language.GetValue("txtRgbClusterError"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessUpdateRgbCluster will process a POST request from a client for RGB cluster name and member update
+func ProcessUpdateRgbCluster(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.ClusterId) || cluster.GetCluster(req.ClusterId) == nil {
+		return &Payload{Message: language.GetValue("txtNonExistingRgbCluster"), Code: http.StatusOK, Status: 0}
+	}
+
+	if len(req.ClusterName) > 0 {
+		if len(req.ClusterName) > 32 || !common.AlphanumericRegex.MatchString(req.ClusterName) {
+			return &Payload{Message: language.GetValue("txtInvalidRgbClusterName"), Code: http.StatusOK, Status: 0}
+		}
+	}
+
+	for _, member := range req.ClusterMembers {
+		if !common.AlphanumericRegex.MatchString(member) {
+			return &Payload{Message: language.GetValue("txtNonExistingDevice"), Code: http.StatusOK, Status: 0}
+		}
+	}
+
+	if req.ClusterMembers == nil {
+		req.ClusterMembers = make([]string, 0)
+	}
+
+	switch cluster.UpdateCluster(req.ClusterId, req.ClusterName, req.ClusterMembers) {
+	case 1:
+		return &Payload{Message: language.GetValue("txtRgbClusterUpdated"), Code: http.StatusOK, Status: 1}
+	case 2:
+		return &Payload{Message: language.GetValue("txtRgbClusterExists"), Code: http.StatusOK, Status: 0}
+	}
+	return &Payload{Message: language.GetValue("txtRgbClusterError"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessDeleteRgbCluster will process a DELETE request from a client for named RGB cluster removal
+func ProcessDeleteRgbCluster(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.ClusterId) {
+		return &Payload{Message: language.GetValue("txtNonExistingRgbCluster"), Code: http.StatusOK, Status: 0}
+	}
+
+	switch devices.DeleteRgbCluster(req.ClusterId) {
+	case 1:
+		return &Payload{Message: language.GetValue("txtRgbClusterDeleted"), Code: http.StatusOK, Status: 1}
+	case 2:
+		return &Payload{Message: language.GetValue("txtUnableToDeleteDefaultRgbCluster"), Code: http.StatusOK, Status: 0}
+	}
+	return &Payload{Message: language.GetValue("txtNonExistingRgbCluster"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessSaveScene will process a PUT request from a client for scene capture
+func ProcessSaveScene(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if len(req.SceneName) < 1 || len(req.SceneName) > 32 {
+		return &Payload{Message: language.GetValue("txtInvalidSceneName"), Code: http.StatusOK, Status: 0}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.SceneName) {
+		return &Payload{Message: language.GetValue("txtInvalidSceneName"), Code: http.StatusOK, Status: 0}
+	}
+
+	results, status := scenes.Capture(req.SceneName)
+	switch status {
+	case 1:
+		return &Payload{Message: language.GetValue("txtSceneSaved"), Code: http.StatusOK, Status: 1, Data: results}
+	case 2:
+		return &Payload{Message: language.GetValue("txtNoSceneDevices"), Code: http.StatusOK, Status: 0, Data: results}
+	}
+	return &Payload{Message: language.GetValue("txtUnableToSaveScene"), Code: http.StatusOK, Status: 0, Data: results}
+}
+
+// ProcessApplyScene will process a POST request from a client for scene apply
+func ProcessApplyScene(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.SceneName) {
+		return &Payload{Message: language.GetValue("txtNonExistingScene"), Code: http.StatusOK, Status: 0}
+	}
+
+	results, status := scenes.Apply(req.SceneName)
+	if status == 0 {
+		return &Payload{Message: language.GetValue("txtNonExistingScene"), Code: http.StatusOK, Status: 0}
+	}
+
+	for _, result := range results {
+		if result.Status != 1 {
+			return &Payload{Message: language.GetValue("txtScenePartiallyApplied"), Code: http.StatusOK, Status: 1, Data: results}
+		}
+	}
+	return &Payload{Message: language.GetValue("txtSceneApplied"), Code: http.StatusOK, Status: 1, Data: results}
+}
+
+// ProcessDeleteScene will process a DELETE request from a client for scene removal
+func ProcessDeleteScene(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.SceneName) {
+		return &Payload{Message: language.GetValue("txtNonExistingScene"), Code: http.StatusOK, Status: 0}
+	}
+
+	if scenes.Delete(req.SceneName) == 1 {
+		return &Payload{Message: language.GetValue("txtSceneDeleted"), Code: http.StatusOK, Status: 1}
+	}
+	return &Payload{Message: language.GetValue("txtNonExistingScene"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessNewSpeedOverride will process a PUT request from a client for temporary speed override lease
+func ProcessNewSpeedOverride(r *http.Request) *Payload {
+	req := &Payload{}
+	if config.GetConfig().Manual {
+		return &Payload{Message: language.GetValue("txtManualFlag"), Code: http.StatusOK, Status: 0}
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if len(req.Targets) == 0 {
+		return &Payload{Message: language.GetValue("txtNonExistingDevice"), Code: http.StatusOK, Status: 0}
+	}
+
+	for _, target := range req.Targets {
+		if !common.AlphanumericRegex.MatchString(target.DeviceId) || devices.GetDevice(target.DeviceId) == nil {
+			return &Payload{Message: language.GetValue("txtNonExistingDevice"), Code: http.StatusOK, Status: 0}
+		}
+
+		if target.ChannelId < 0 {
+			return &Payload{Message: language.GetValue("txtNonExistingChannelId"), Code: http.StatusOK, Status: 0}
+		}
+	}
+
+	// Fixed speed when fixedSpeed is given, 0 included, otherwise temperature profile
+	if req.FixedSpeed != nil {
+		if *req.FixedSpeed > 100 {
+			return &Payload{Message: language.GetValue("txtInvalidOverrideSpeed"), Code: http.StatusOK, Status: 0}
+		}
+	} else {
+		if len(req.Profile) < 1 || !common.AlphanumericRegex.MatchString(req.Profile) {
+			return &Payload{Message: language.GetValue("txtNonExistingSpeedProfile"), Code: http.StatusOK, Status: 0}
+		}
+	}
+
+	lease, status := overrides.NewLease(req.Targets, req.FixedSpeed, req.Profile, req.Ttl)
+	switch status {
+	case 1:
+		return &Payload{Message: language.GetValue("txtSpeedOverrideCreated"), Code: http.StatusOK, Status: 1, Data: lease}
+	case 2:
+		return &Payload{Message: language.GetValue("txtNonExistingSpeedProfile"), Code: http.StatusOK, Status: 0}
+	case 3:
+		return &Payload{Message: language.GetValue("txtInvalidOverrideTtl"), Code: http.StatusOK, Status: 0}
+	case 4:
+		return &Payload{Message: language.GetValue("txtManualFlag"), Code: http.StatusOK, Status: 0}
+	}
+	return &Payload{Message: language.GetValue("txtUnableToCreateSpeedOverride"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessRenewSpeedOverride will process a POST request from a client for speed override lease heartbeat
+func ProcessRenewSpeedOverride(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.LeaseId) {
+		return &Payload{Message: language.GetValue("txtNonExistingSpeedOverride"), Code: http.StatusOK, Status: 0}
+	}
+
+	lease, status := overrides.RenewLease(req.LeaseId, req.Ttl)
+	switch status {
+	case 1:
+		return &Payload{Message: language.GetValue("txtSpeedOverrideRenewed"), Code: http.StatusOK, Status: 1, Data: lease}
+	case 3:
+		return &Payload{Message: language.GetValue("txtInvalidOverrideTtl"), Code: http.StatusOK, Status: 0}
+	}
+	return &Payload{Message: language.GetValue("txtNonExistingSpeedOverride"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessDeleteSpeedOverride will process a DELETE request from a client for speed override lease release
+func ProcessDeleteSpeedOverride(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.LeaseId) {
+		return &Payload{Message: language.GetValue("txtNonExistingSpeedOverride"), Code: http.StatusOK, Status: 0}
+	}
+
+	if overrides.ReleaseLease(req.LeaseId) == 1 {
+		return &Payload{Message: language.GetValue("txtSpeedOverrideReleased"), Code: http.StatusOK, Status: 1}
+	}
+	return &Payload{Message: language.GetValue("txtNonExistingSpeedOverride"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessStartCalibration will process a PUT request from a client for fan calibration sweep
+func ProcessStartCalibration(r *http.Request) *Payload {
+	req := &Payload{}
+	if config.GetConfig().Manual {
+		return &Payload{Message: language.GetValue("txtManualFlag"), Code: http.StatusOK, Status: 0}
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.DeviceId) {
+		return &Payload{Message: language.GetValue("txtNonExistingDevice"), Code: http.StatusOK, Status: 0}
+	}
+
+	channelIds := req.ChannelIds
+	if len(channelIds) == 0 {
+		channelIds = []int{req.ChannelId}
+	}
+
+	for _, channelId := range channelIds {
+		if channelId < 0 {
+			return &Payload{Message: language.GetValue("txtNonExistingChannelId"), Code: http.StatusOK, Status: 0}
+		}
+	}
+
+	switch calibration.Start(req.DeviceId, channelIds) {
+	case 1:
+		return &Payload{Message: language.GetValue("txtCalibrationStarted"), Code: http.StatusOK, Status: 1}
+	case 2:
+		return &Payload{Message: language.GetValue("txtCalibrationRunning"), Code: http.StatusOK, Status: 0}
+	case 3:
+		return &Payload{Message: language.GetValue("txtCalibrationInvalidChannel"), Code: http.StatusOK, Status: 0}
+	case 4:
+		return &Payload{Message: language.GetValue("txtManualFlag"), Code: http.StatusOK, Status: 0}
+	}
+	return &Payload{Message: language.GetValue("txtNonExistingDevice"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessCancelCalibration will process a DELETE request from a client for fan calibration sweep cancel
+func ProcessCancelCalibration(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.DeviceId) {
+		return &Payload{Message: language.GetValue("txtNonExistingDevice"), Code: http.StatusOK, Status: 0}
+	}
+
+	if calibration.Cancel(req.DeviceId, req.ChannelId) == 1 {
+		return &Payload{Message: language.GetValue("txtCalibrationCancelled"), Code: http.StatusOK, Status: 1}
+	}
+	return &Payload{Message: language.GetValue("txtCalibrationNotRunning"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessSaveAlertRule will process a PUT or POST request from a client for alert rule create or update
+func ProcessSaveAlertRule(r *http.Request, create bool) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	rule := req.AlertRule
+	if create {
+		rule.Id = ""
+	} else if !common.AlphanumericRegex.MatchString(rule.Id) {
+		return &Payload{Message: language.GetValue("txtNonExistingAlertRule"), Code: http.StatusOK, Status: 0}
+	}
+
+	if len(rule.Name) < 1 {
+		return &Payload{Message: language.GetValue("txtInvalidAlertName"), Code: http.StatusOK, Status: 0}
+	}
+
+	if len(rule.Condition.DeviceId) > 0 && !common.AlphanumericRegex.MatchString(rule.Condition.DeviceId) {
+		return &Payload{Message: language.GetValue("txtNonExistingDevice"), Code: http.StatusOK, Status: 0}
+	}
+
+	result, status := alerts.SaveRule(rule)
+	switch status {
+	case 1:
+		return &Payload{Message: language.GetValue("txtAlertRuleSaved"), Code: http.StatusOK, Status: 1, Data: result}
+	case 2:
+		return &Payload{Message: language.GetValue("txtInvalidAlertCondition"), Code: http.StatusOK, Status: 0}
+	case 3:
+		return &Payload{Message: language.GetValue("txtInvalidAlertAction"), Code: http.StatusOK, Status: 0}
+	case 4:
+		return &Payload{Message: language.GetValue("txtAlertCommandsDisabled"), Code: http.StatusOK, Status: 0}
+	}
+	return &Payload{Message: language.GetValue("txtNonExistingAlertRule"), Code: http.StatusOK, Status: 0}
+}
+
+// ProcessDeleteAlertRule will process a DELETE request from a client for alert rule removal
+func ProcessDeleteAlertRule(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.AlertId) {
+		return &Payload{Message: language.GetValue("txtNonExistingAlertRule"), Code: http.StatusOK, Status: 0}
+	}
+
+	if alerts.DeleteRule(req.AlertId) == 1 {
+		return &Payload{Message: language.GetValue("txtAlertRuleDeleted"), Code: http.StatusOK, Status: 1}
+	}
+	return &Payload{Message: language.GetValue("txtNonExistingAlertRule"), Code: http.StatusOK, Status: 0}
+}
+
 // ProcessSetKeyboardLiveSync will process setting data for keyboard live RGB sync
 func ProcessSetKeyboardLiveSync(r *http.Request) *Payload {
 	req := &Payload{}
@@ -4779,6 +5315,33 @@ func ProcessSetKeyboardLiveSync(r *http.Request) *Payload {
 	return &Payload{Message: language.GetValue("txtUnableToSaveKeyboardProfile"), Code: http.StatusOK, Status: 0}
 }
 
+// ProcessResetKeyHeatmap will process reset of key usage statistics
+func ProcessResetKeyHeatmap(r *http.Request) *Payload {
+	req := &Payload{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(map[string]interface{}{"error": err}).Error("Unable to decode JSON")
+		return &Payload{
+			Message: language.GetValue("txtUnableToValidateRequest"),
+			Code:    http.StatusOK,
+			Status:  0,
+		}
+	}
+
+	if len(req.DeviceId) < 1 {
+		return &Payload{Message: language.GetValue("txtNonExistingDevice"), Code: http.StatusOK, Status: 0}
+	}
+
+	if !common.AlphanumericRegex.MatchString(req.DeviceId) {
+		return &Payload{Message: language.GetValue("txtNonExistingDevice"), Code: http.StatusOK, Status: 0}
+	}
+
+	if heatmap.Reset(req.DeviceId) == 1 {
+		return &Payload{Message: language.GetValue("txtKeyHeatmapReset"), Code: http.StatusOK, Status: 1}
+	}
+	return &Payload{Message: language.GetValue("txtNoKeyHeatmapData"), Code: http.StatusOK, Status: 0}
+}
+
 // ProcessSetKeyboardControlDialColors will process setting keyboard control dial colors
 func ProcessSetKeyboardControlDialColors(r *http.Request) *Payload {
 	req := &Payload{}
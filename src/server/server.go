@@ -5,23 +5,37 @@ package server
 // License: GPL-3.0 or later
 
 import (
+	"OpenLinkHub/src/alerts"
 	"OpenLinkHub/src/audio"
 	"OpenLinkHub/src/backup"
+	"OpenLinkHub/src/calibration"
+	"OpenLinkHub/src/cluster"
 	"OpenLinkHub/src/common"
 	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/coolant"
 	"OpenLinkHub/src/dashboard"
 	"OpenLinkHub/src/devices"
 	"OpenLinkHub/src/devices/lcd"
 	"OpenLinkHub/src/display"
+	"OpenLinkHub/src/fanhealth"
+	"OpenLinkHub/src/health"
+	"OpenLinkHub/src/heatmap"
+	"OpenLinkHub/src/history"
 	"OpenLinkHub/src/inputmanager"
 	"OpenLinkHub/src/language"
+	"OpenLinkHub/src/ledstream"
 	"OpenLinkHub/src/logger"
 	"OpenLinkHub/src/macro"
 	"OpenLinkHub/src/media"
 	"OpenLinkHub/src/metrics"
+	"OpenLinkHub/src/migration"
+	"OpenLinkHub/src/overrides"
+	"OpenLinkHub/src/reload"
 	"OpenLinkHub/src/rgb"
+	"OpenLinkHub/src/scenes"
 	"OpenLinkHub/src/scheduler"
 	"OpenLinkHub/src/server/requests"
+	"OpenLinkHub/src/server/v2"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/systeminfo"
 	"OpenLinkHub/src/systray"
@@ -29,7 +43,9 @@ import (
 	"OpenLinkHub/src/templates"
 	"OpenLinkHub/src/version"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -45,6 +61,7 @@ type Response struct {
 	Device    interface{} `json:"device,omitempty"`
 	Devices   interface{} `json:"devices,omitempty"`
 	Dashboard interface{} `json:"dashboard,omitempty"`
+	Health    interface{} `json:"health,omitempty"`
 	Data      interface{} `json:"data,omitempty"` // For dataTables
 }
 
@@ -215,12 +232,14 @@ func getDevices(w http.ResponseWriter, r *http.Request) {
 		resp := &Response{
 			Code:    http.StatusOK,
 			Devices: devices.GetDevicesEx(),
+			Health:  fanhealth.GetHealth(),
 		}
 		resp.Send(w)
 	} else {
 		resp := &Response{
 			Code:   http.StatusOK,
 			Device: devices.GetDevice(deviceId),
+			Health: fanhealth.GetDeviceHealth(deviceId),
 		}
 		resp.Send(w)
 	}
@@ -387,6 +406,49 @@ func getTemperatureGraph(w http.ResponseWriter, r *http.Request) {
 	resp.Send(w)
 }
 
+// getKeyHeatmap returns key usage statistics on /api/keyboard/heatmap/
+func getKeyHeatmap(w http.ResponseWriter, r *http.Request) {
+	resp := &Response{}
+	deviceId, valid := getVar("/api/keyboard/heatmap/", r)
+	if !valid {
+		resp = &Response{
+			Code:   http.StatusOK,
+			Status: 1,
+			Data:   heatmap.GetHeatmaps(),
+		}
+	} else {
+		days := 0
+		if value := r.URL.Query().Get("days"); len(value) > 0 {
+			val, err := strconv.Atoi(value)
+			if err != nil || val < 0 {
+				resp = &Response{
+					Code:    http.StatusOK,
+					Status:  0,
+					Message: language.GetValue("txtInvalidHeatmapDays"),
+				}
+				resp.Send(w)
+				return
+			}
+			days = val
+		}
+
+		if counts := heatmap.GetKeyCounts(deviceId, days); counts != nil {
+			resp = &Response{
+				Code:   http.StatusOK,
+				Status: 1,
+				Data:   counts,
+			}
+		} else {
+			resp = &Response{
+				Code:    http.StatusOK,
+				Status:  0,
+				Message: language.GetValue("txtNoKeyHeatmapData"),
+			}
+		}
+	}
+	resp.Send(w)
+}
+
 // getColor returns response on /color
 func getColor(w http.ResponseWriter, r *http.Request) {
 	resp := &Response{}
@@ -948,6 +1010,17 @@ func updateTemperatureProfileGraph(w http.ResponseWriter, r *http.Request) {
 	resp.Send(w)
 }
 
+// updateTemperatureProfilePid handles PID controller settings update
+func updateTemperatureProfilePid(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessUpdateTemperatureProfilePid(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+	}
+	resp.Send(w)
+}
+
 // setDeviceSpeed handles device speed changes
 func setDeviceSpeed(w http.ResponseWriter, r *http.Request) {
 	request := requests.ProcessChangeSpeed(r)
@@ -1267,6 +1340,424 @@ func setRgbCluster(w http.ResponseWriter, r *http.Request) {
 	resp.Send(w)
 }
 
+// getRgbClusters returns RGB cluster overview on /api/cluster/
+func getRgbClusters(w http.ResponseWriter, r *http.Request) {
+	resp := &Response{}
+	clusterId, valid := getVar("/api/cluster/", r)
+	if !valid {
+		resp = &Response{
+			Code:   http.StatusOK,
+			Status: 1,
+			Data:   cluster.GetClusters(),
+		}
+	} else {
+		resp = &Response{
+			Code:    http.StatusOK,
+			Status:  0,
+			Message: language.GetValue("txtNonExistingRgbCluster"),
+		}
+		for _, value := range cluster.GetClusters() {
+			if value.Serial == clusterId {
+				resp = &Response{
+					Code:   http.StatusOK,
+					Status: 1,
+					Data:   value,
+				}
+				break
+			}
+		}
+	}
+	resp.Send(w)
+}
+
+// newRgbCluster handles creation of named RGB cluster
+func newRgbCluster(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessNewRgbCluster(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+		Data:    request.Data,
+	}
+	resp.Send(w)
+}
+
+// updateRgbCluster handles RGB cluster name and member update
+func updateRgbCluster(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessUpdateRgbCluster(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+	}
+	resp.Send(w)
+}
+
+// deleteRgbCluster handles deletion of named RGB cluster
+func deleteRgbCluster(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessDeleteRgbCluster(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+	}
+	resp.Send(w)
+}
+
+// getScenes returns scene list or a single scene on /api/scenes/
+func getScenes(w http.ResponseWriter, r *http.Request) {
+	resp := &Response{}
+	sceneName, valid := getVar("/api/scenes/", r)
+	if !valid {
+		resp = &Response{
+			Code:   http.StatusOK,
+			Status: 1,
+			Data:   scenes.GetScenes(),
+		}
+	} else {
+		if scene := scenes.GetScene(sceneName); scene != nil {
+			resp = &Response{
+				Code:   http.StatusOK,
+				Status: 1,
+				Data:   scene,
+			}
+		} else {
+			resp = &Response{
+				Code:    http.StatusOK,
+				Status:  0,
+				Message: language.GetValue("txtNonExistingScene"),
+			}
+		}
+	}
+	resp.Send(w)
+}
+
+// saveScene handles scene capture
+func saveScene(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessSaveScene(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+		Data:    request.Data,
+	}
+	resp.Send(w)
+}
+
+// applyScene handles scene apply
+func applyScene(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessApplyScene(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+		Data:    request.Data,
+	}
+	resp.Send(w)
+}
+
+// deleteScene handles scene deletion
+func deleteScene(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessDeleteScene(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+	}
+	resp.Send(w)
+}
+
+// getSpeedOverrides returns active speed override leases
+func getSpeedOverrides(w http.ResponseWriter, _ *http.Request) {
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Data:   overrides.GetLeases(),
+	}
+	resp.Send(w)
+}
+
+// getHistory returns recorded series, or points of a series selected by deviceId, channelId and metric
+func getHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	deviceId := query.Get("deviceId")
+	if len(deviceId) == 0 {
+		resp := &Response{
+			Code:   http.StatusOK,
+			Status: 1,
+			Data:   history.GetSeries(),
+		}
+		resp.Send(w)
+		return
+	}
+
+	channelId := query.Get("channelId")
+	metric := query.Get("metric")
+	if !common.AlphanumericDashSemiColon.MatchString(deviceId) ||
+		!common.AlphanumericDashSemiColon.MatchString(channelId) ||
+		!common.AlphanumericDashSemiColon.MatchString(metric) {
+		resp := &Response{
+			Code:    http.StatusOK,
+			Status:  0,
+			Message: language.GetValue("txtInvalidHistoryQuery"),
+		}
+		resp.Send(w)
+		return
+	}
+
+	values := map[string]int64{"from": 0, "to": 0, "resolution": 0}
+	for name := range values {
+		if value := query.Get(name); len(value) > 0 {
+			val, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || val < 0 {
+				resp := &Response{
+					Code:    http.StatusOK,
+					Status:  0,
+					Message: language.GetValue("txtInvalidHistoryQuery"),
+				}
+				resp.Send(w)
+				return
+			}
+			values[name] = val
+		}
+	}
+
+	result := history.Query(deviceId, channelId, metric, values["from"], values["to"], values["resolution"])
+	if result == nil {
+		resp := &Response{
+			Code:    http.StatusOK,
+			Status:  0,
+			Message: language.GetValue("txtNoHistoryData"),
+		}
+		resp.Send(w)
+		return
+	}
+
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Data:   result,
+	}
+	resp.Send(w)
+}
+
+// getAlertRules returns all alert rules
+func getAlertRules(w http.ResponseWriter, _ *http.Request) {
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Data:   alerts.GetRules(),
+	}
+	resp.Send(w)
+}
+
+// getAlertHistory returns triggered and resolved alerts
+func getAlertHistory(w http.ResponseWriter, _ *http.Request) {
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Data:   alerts.GetEvents(),
+	}
+	resp.Send(w)
+}
+
+// newAlertRule handles alert rule creation
+func newAlertRule(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessSaveAlertRule(r, true)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+		Data:    request.Data,
+	}
+	resp.Send(w)
+}
+
+// updateAlertRule handles alert rule update
+func updateAlertRule(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessSaveAlertRule(r, false)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+		Data:    request.Data,
+	}
+	resp.Send(w)
+}
+
+// deleteAlertRule handles alert rule removal
+func deleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessDeleteAlertRule(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+	}
+	resp.Send(w)
+}
+
+// deleteAlertHistory handles alert history removal
+func deleteAlertHistory(w http.ResponseWriter, _ *http.Request) {
+	alerts.ClearEvents()
+	resp := &Response{
+		Code:    http.StatusOK,
+		Status:  1,
+		Message: language.GetValue("txtAlertHistoryCleared"),
+	}
+	resp.Send(w)
+}
+
+// getCoolantProtection returns critical coolant thresholds, device states and recent protection events
+func getCoolantProtection(w http.ResponseWriter, _ *http.Request) {
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Data:   coolant.GetOverview(),
+	}
+	resp.Send(w)
+}
+
+// getChannelHealth returns fan and pump health of all devices with recent failure events
+func getChannelHealth(w http.ResponseWriter, _ *http.Request) {
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Health: fanhealth.GetHealth(),
+		Data:   fanhealth.GetEvents(),
+	}
+	resp.Send(w)
+}
+
+// getHealth returns daemon health with per-device diagnostics. Responds with 503 when a critical device is missing
+func getHealth(w http.ResponseWriter, _ *http.Request) {
+	status := health.GetHealth()
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Data:   status,
+	}
+	if !status.Ready {
+		resp.Code = http.StatusServiceUnavailable
+		resp.Status = 0
+	}
+	resp.Send(w)
+}
+
+// getEffectiveConfig returns running configuration with origin of each value
+func getEffectiveConfig(w http.ResponseWriter, _ *http.Request) {
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Data:   config.GetEffective(),
+	}
+	resp.Send(w)
+}
+
+// reloadConfig validates and applies config.json and profile files without restart
+func reloadConfig(w http.ResponseWriter, _ *http.Request) {
+	report := reload.Reload()
+	resp := &Response{
+		Code:    http.StatusOK,
+		Status:  1,
+		Message: language.GetValue("txtConfigReloaded"),
+		Data:    report,
+	}
+	if len(report.Problems) > 0 {
+		resp.Code = http.StatusUnprocessableEntity
+		resp.Status = 0
+		resp.Message = language.GetValue("txtConfigReloadProblems")
+	}
+	resp.Send(w)
+}
+
+// getMigrations returns schema versions, migration history, startup summary and pending migrations
+func getMigrations(w http.ResponseWriter, _ *http.Request) {
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Data:   migration.GetStatus(),
+	}
+	resp.Send(w)
+}
+
+// getCalibrations returns fan calibration runs with progress and results
+func getCalibrations(w http.ResponseWriter, _ *http.Request) {
+	resp := &Response{
+		Code:   http.StatusOK,
+		Status: 1,
+		Data:   calibration.GetRuns(),
+	}
+	resp.Send(w)
+}
+
+// startCalibration handles start of fan calibration sweep
+func startCalibration(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessStartCalibration(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+	}
+	resp.Send(w)
+}
+
+// cancelCalibration handles cancel of fan calibration sweep
+func cancelCalibration(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessCancelCalibration(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+	}
+	resp.Send(w)
+}
+
+// newSpeedOverride handles creation of speed override lease
+func newSpeedOverride(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessNewSpeedOverride(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+		Data:    request.Data,
+	}
+	resp.Send(w)
+}
+
+// renewSpeedOverride handles speed override lease heartbeat
+func renewSpeedOverride(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessRenewSpeedOverride(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+		Data:    request.Data,
+	}
+	resp.Send(w)
+}
+
+// deleteSpeedOverride handles speed override lease release
+func deleteSpeedOverride(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessDeleteSpeedOverride(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+	}
+	resp.Send(w)
+}
+
+// resetKeyHeatmap will reset key usage statistics
+func resetKeyHeatmap(w http.ResponseWriter, r *http.Request) {
+	request := requests.ProcessResetKeyHeatmap(r)
+	resp := &Response{
+		Code:    request.Code,
+		Status:  request.Status,
+		Message: request.Message,
+	}
+	resp.Send(w)
+}
+
 // setKeyboardLiveSync saves keyboard live RGB sync state
 func setKeyboardLiveSync(w http.ResponseWriter, r *http.Request) {
 	request := requests.ProcessSetKeyboardLiveSync(r)
@@ -2514,7 +3005,7 @@ func handleFunc(mux *http.ServeMux, path, method string, handler func(w http.Res
 // setRoutes will set up all routes
 func setRoutes() http.Handler {
 	r := http.NewServeMux()
-	fs := http.FileServer(http.Dir("./static"))
+	fs := http.FileServer(http.Dir(config.GetConfig().ConfigPath + "/static"))
 	r.Handle("/static/", http.StripPrefix("/static/", fs))
 
 	// GET
@@ -2550,8 +3041,23 @@ func setRoutes() http.Handler {
 	handleFunc(r, "/api/keyboard/getFlashTap/", http.MethodGet, getKeyboardFlashTap)
 	handleFunc(r, "/api/systray", http.MethodGet, getSystrayData)
 	handleFunc(r, "/api/keyboard/dial/getColors/", http.MethodGet, getControlDialColors)
+	handleFunc(r, "/api/keyboard/heatmap/", http.MethodGet, getKeyHeatmap)
+	handleFunc(r, "/api/cluster/", http.MethodGet, getRgbClusters)
+	handleFunc(r, "/api/scenes/", http.MethodGet, getScenes)
+	handleFunc(r, "/api/speed/overrides", http.MethodGet, getSpeedOverrides)
+	handleFunc(r, "/api/coolant", http.MethodGet, getCoolantProtection)
+	handleFunc(r, "/api/channels/health", http.MethodGet, getChannelHealth)
+	handleFunc(r, "/api/health", http.MethodGet, getHealth)
+	handleFunc(r, "/api/config/effective", http.MethodGet, getEffectiveConfig)
+	handleFunc(r, "/api/reload", http.MethodPost, reloadConfig)
+	handleFunc(r, "/api/migrations", http.MethodGet, getMigrations)
+	handleFunc(r, "/api/calibration", http.MethodGet, getCalibrations)
+	handleFunc(r, "/api/history", http.MethodGet, getHistory)
+	handleFunc(r, "/api/alerts", http.MethodGet, getAlertRules)
+	handleFunc(r, "/api/alerts/history", http.MethodGet, getAlertHistory)
 	handleFunc(r, "/api/getSupportedDevices", http.MethodGet, getSupportedDevices)
 	handleFunc(r, "/api/backup", http.MethodGet, backup.PerformBackup)
+	handleFunc(r, "/api/diagnostics/bundle", http.MethodGet, backup.PerformDiagnostics)
 	handleFunc(r, "/api/position/", http.MethodGet, getPositionData)
 	handleFunc(r, "/api/headset/getEqualizers/", http.MethodGet, getEqualizers)
 	handleFunc(r, "/api/language/", http.MethodGet, getLanguageData)
@@ -2573,11 +3079,17 @@ func setRoutes() http.Handler {
 	handleFunc(r, "/api/color/getOverride", http.MethodPost, getRgbOverride)
 	handleFunc(r, "/api/color/setOverride", http.MethodPost, setRgbOverride)
 	handleFunc(r, "/api/color/setTemperatureProbe", http.MethodPost, setTemperatureProbe)
+	handleFunc(r, "/api/color/stream", http.MethodGet, ledstream.Handle)
 	handleFunc(r, "/api/color/getLedData", http.MethodPost, getLedData)
 	handleFunc(r, "/api/color/setLedData", http.MethodPost, setLedData)
 	handleFunc(r, "/api/color/setOpenRgbIntegration", http.MethodPost, setOpenRgbIntegration)
 	handleFunc(r, "/api/color/setCluster", http.MethodPost, setRgbCluster)
+	handleFunc(r, "/api/cluster/update", http.MethodPost, updateRgbCluster)
+	handleFunc(r, "/api/scenes/apply", http.MethodPost, applyScene)
+	handleFunc(r, "/api/speed/overrides/renew", http.MethodPost, renewSpeedOverride)
+	handleFunc(r, "/api/alerts/update", http.MethodPost, updateAlertRule)
 	handleFunc(r, "/api/keyboard/liveSync", http.MethodPost, setKeyboardLiveSync)
+	handleFunc(r, "/api/keyboard/heatmap/reset", http.MethodPost, resetKeyHeatmap)
 	handleFunc(r, "/api/color/hardware", http.MethodPost, setDeviceHardwareColor)
 	handleFunc(r, "/api/color/gradient/add", http.MethodPost, newDeviceGradientColor)
 	handleFunc(r, "/api/color/gradient/delete", http.MethodPost, deleteDeviceGradientColor)
@@ -2664,20 +3176,35 @@ func setRoutes() http.Handler {
 	// PUT
 	handleFunc(r, "/api/temperatures/update", http.MethodPut, updateTemperatureProfile)
 	handleFunc(r, "/api/temperatures/updateGraph", http.MethodPut, updateTemperatureProfileGraph)
+	handleFunc(r, "/api/temperatures/updatePid", http.MethodPut, updateTemperatureProfilePid)
 	handleFunc(r, "/api/lcd/modes", http.MethodPut, updateLcdProfile)
 	handleFunc(r, "/api/userProfile", http.MethodPut, saveUserProfile)
 	handleFunc(r, "/api/keyboard/profile/new", http.MethodPut, saveDeviceProfile)
 	handleFunc(r, "/api/macro/new", http.MethodPut, newMacroProfile)
 	handleFunc(r, "/api/color/change", http.MethodPut, updateRgbProfile)
+	handleFunc(r, "/api/cluster/new", http.MethodPut, newRgbCluster)
+	handleFunc(r, "/api/scenes/new", http.MethodPut, saveScene)
+	handleFunc(r, "/api/speed/overrides/new", http.MethodPut, newSpeedOverride)
+	handleFunc(r, "/api/calibration/start", http.MethodPut, startCalibration)
+	handleFunc(r, "/api/alerts/new", http.MethodPut, newAlertRule)
 
 	// DELETE
 	handleFunc(r, "/api/keyboard/profile/delete", http.MethodDelete, deleteKeyboardProfile)
 	handleFunc(r, "/api/macro/value", http.MethodDelete, deleteMacroValue)
+	handleFunc(r, "/api/cluster/delete", http.MethodDelete, deleteRgbCluster)
+	handleFunc(r, "/api/scenes/delete", http.MethodDelete, deleteScene)
+	handleFunc(r, "/api/speed/overrides/delete", http.MethodDelete, deleteSpeedOverride)
+	handleFunc(r, "/api/calibration/cancel", http.MethodDelete, cancelCalibration)
+	handleFunc(r, "/api/alerts/delete", http.MethodDelete, deleteAlertRule)
+	handleFunc(r, "/api/alerts/history/clear", http.MethodDelete, deleteAlertHistory)
 	handleFunc(r, "/api/temperatures/delete", http.MethodDelete, deleteTemperatureProfile)
 	handleFunc(r, "/api/macro/profile", http.MethodDelete, deleteMacroProfile)
 	handleFunc(r, "/api/userProfile/delete", http.MethodDelete, deleteUserProfile)
 	handleFunc(r, "/api/dashboard/devices/delete", http.MethodDelete, removeDashboardDevice)
 
+	// v2
+	v2.Register(r)
+
 	// Prometheus metrics
 	if config.GetConfig().Metrics {
 		handleFunc(r, "/api/metrics", http.MethodGet, getDeviceMetrics)
@@ -2717,15 +3244,36 @@ func Init() {
 		},
 	}
 
-	if config.GetConfig().ListenPort > 0 {
-		templates.Init()
+	cfg := config.GetConfig()
+	if cfg.ListenPort < 1 && len(cfg.SocketPath) == 0 {
+		logger.Log(logger.Fields{}).Info("REST server is disabled")
+		return
+	}
+
+	templates.Init()
+	handler := setRoutes()
+	errs := make(chan error, 2)
+
+	if len(cfg.SocketPath) > 0 {
+		listener, err := listenUnix()
+		if err != nil {
+			logger.Log(logger.Fields{"error": err, "path": cfg.SocketPath}).Fatal("Unable to create REST socket")
+		}
+		socketListener = listener
+		fmt.Println(fmt.Sprintf("[Server] Running REST and WebUI on unix socket %s", cfg.SocketPath))
+		go func() {
+			errs <- (&http.Server{Handler: handler}).Serve(listener)
+		}()
+	}
+
+	if cfg.ListenPort > 0 {
 		server = &http.Server{
 			Addr: fmt.Sprintf(
 				"%s:%v",
-				config.GetConfig().ListenAddress,
-				config.GetConfig().ListenPort,
+				cfg.ListenAddress,
+				cfg.ListenPort,
 			),
-			Handler: setRoutes(),
+			Handler: handler,
 		}
 
 		fmt.Println(
@@ -2734,11 +3282,16 @@ func Init() {
 				server.Addr,
 			),
 		)
-		err := server.ListenAndServe()
-		if err != nil {
-			logger.Log(logger.Fields{"error": err}).Fatal("Unable to start REST server")
+		go func() {
+			errs <- server.ListenAndServe()
+		}()
+	}
+
+	// Socket listener is closed on shutdown, keep blocking until process exits
+	for err := range errs {
+		if errors.Is(err, net.ErrClosed) {
+			continue
 		}
-	} else {
-		logger.Log(logger.Fields{}).Info("REST server is disabled")
+		logger.Log(logger.Fields{"error": err}).Fatal("Unable to start REST server")
 	}
 }
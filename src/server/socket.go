@@ -0,0 +1,101 @@
+package server
+
+// Package: server
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/config"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+const defaultSocketMode = 0660
+
+var socketListener *net.UnixListener
+
+// listenUnix will create Unix socket listener and apply configured owner, group and mode.
+// Stale socket file of a previous run is replaced, socket of a running instance is not
+func listenUnix() (*net.UnixListener, error) {
+	cfg := config.GetConfig()
+	path := cfg.SocketPath
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = setSocketPermissions(path, cfg.SocketOwner, cfg.SocketGroup, cfg.SocketMode); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// setSocketPermissions will change socket owner, group and mode. Owner and group can be a name or numeric id
+func setSocketPermissions(path, owner, group, mode string) error {
+	uid, gid := -1, -1
+	if len(owner) > 0 {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			if u, err = user.LookupId(owner); err != nil {
+				return fmt.Errorf("unknown socket owner %s", owner)
+			}
+		}
+		uid, _ = strconv.Atoi(u.Uid)
+	}
+	if len(group) > 0 {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return fmt.Errorf("unknown socket group %s", group)
+			}
+		}
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	perm := os.FileMode(defaultSocketMode)
+	if len(mode) > 0 {
+		value, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || value > 0777 {
+			return errors.New("socketMode must be octal permission, e.g. 0660")
+		}
+		perm = os.FileMode(value)
+	}
+	return os.Chmod(path, perm)
+}
+
+// Stop will close Unix socket listener and remove socket file
+func Stop() {
+	if socketListener != nil {
+		_ = socketListener.Close()
+		socketListener = nil
+	}
+}
@@ -0,0 +1,330 @@
+package v2
+
+// Package: v2
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/language"
+	"OpenLinkHub/src/logger"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Batch actions
+const (
+	ActionRgbProfile   = "rgbProfile"
+	ActionBrightness   = "brightness"
+	ActionSpeedProfile = "speedProfile"
+	ActionLabel        = "label"
+	ActionUserProfile  = "userProfile"
+)
+
+const (
+	maxOperations      = 256
+	defaultConcurrency = 4
+	maxConcurrency     = 16
+)
+
+// Operation is a single batch action. ChannelId is required for speedProfile and label, rgbProfile without it
+// applies to all channels
+type Operation struct {
+	Action    string `json:"action"`
+	Serial    string `json:"serial"`
+	ChannelId *int   `json:"channelId,omitempty"`
+	Profile   string `json:"profile,omitempty"`
+	Value     *uint8 `json:"value,omitempty"`
+	Label     string `json:"label,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// BatchRequest runs operations with bounded concurrency across devices. Operations of the same device run in order.
+// In atomic mode, all operations are rolled back when any of them fails
+type BatchRequest struct {
+	Atomic      bool        `json:"atomic"`
+	Concurrency int         `json:"concurrency"`
+	Operations  []Operation `json:"operations"`
+}
+
+// OperationResult holds outcome of a single operation. Status is HTTP status the matching single endpoint would return
+type OperationResult struct {
+	Index      int    `json:"index"`
+	Action     string `json:"action"`
+	Serial     string `json:"serial"`
+	Status     int    `json:"status"`
+	Message    string `json:"message"`
+	RolledBack bool   `json:"rolledBack"`
+}
+
+type BatchResponse struct {
+	Succeeded  int               `json:"succeeded"`
+	Failed     int               `json:"failed"`
+	RolledBack bool              `json:"rolledBack"`
+	Results    []OperationResult `json:"results"`
+}
+
+// restoreFunc will put back state captured before an operation
+type restoreFunc func() error
+
+// runBatch will run a batch of device operations
+func runBatch(r *http.Request) (int, interface{}) {
+	req := BatchRequest{}
+	if err := decode(r, &req); err != nil {
+		return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > maxOperations {
+		return fail(http.StatusBadRequest, fmt.Sprintf("operations: between 1 and %d operations are required", maxOperations))
+	}
+
+	concurrency := req.Concurrency
+	if concurrency < 1 {
+		concurrency = defaultConcurrency
+	}
+	if concurrency > maxConcurrency {
+		concurrency = maxConcurrency
+	}
+
+	results := make([]OperationResult, len(req.Operations))
+	for i, op := range req.Operations {
+		results[i] = OperationResult{Index: i, Action: op.Action, Serial: op.Serial}
+	}
+
+	// Atomic batch captures current state of every operation first, nothing is applied if that is not possible
+	restore := make([]restoreFunc, len(req.Operations))
+	if req.Atomic {
+		valid := true
+		for i, op := range req.Operations {
+			fn, err := snapshot(op)
+			if err != nil {
+				results[i].Status, results[i].Message = err.status, err.message
+				valid = false
+				continue
+			}
+			restore[i] = fn
+		}
+		if !valid {
+			return http.StatusOK, summarize(results, false)
+		}
+	}
+
+	// Group operations by device, devices run in parallel
+	groups := make(map[string][]int)
+	order := make([]string, 0)
+	for i, op := range req.Operations {
+		if _, ok := groups[op.Serial]; !ok {
+			order = append(order, op.Serial)
+		}
+		groups[op.Serial] = append(groups[op.Serial], i)
+	}
+
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for _, serial := range order {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(indexes []int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			for _, i := range indexes {
+				if req.Atomic && failed.Load() {
+					continue
+				}
+				results[i].Status, results[i].Message = result(apply(req.Operations[i]))
+				if results[i].Status >= 300 {
+					failed.Store(true)
+				}
+			}
+		}(groups[serial])
+	}
+	wg.Wait()
+
+	if !req.Atomic || !failed.Load() {
+		return http.StatusOK, summarize(results, false)
+	}
+
+	// Roll back applied operations in reverse order
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i].Status < 200 || results[i].Status >= 300 {
+			continue
+		}
+		if err := restore[i](); err != nil {
+			logger.Log(logger.Fields{"error": err, "serial": results[i].Serial, "action": results[i].Action}).Error("Unable to roll back batch operation")
+			continue
+		}
+		results[i].RolledBack = true
+	}
+	return http.StatusOK, summarize(results, true)
+}
+
+// apply will run a single operation via the matching single endpoint logic
+func apply(op Operation) (int, interface{}) {
+	channelId := -1
+	if op.ChannelId != nil {
+		channelId = *op.ChannelId
+	}
+
+	switch op.Action {
+	case ActionRgbProfile:
+		return applyRgb(op.Serial, channelId, op.Profile)
+	case ActionBrightness:
+		if op.Value == nil {
+			return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+		}
+		return applyBrightness(op.Serial, *op.Value)
+	case ActionSpeedProfile:
+		if op.ChannelId == nil {
+			return fail(http.StatusBadRequest, language.GetValue("txtNonExistingChannelId"))
+		}
+		return applySpeed(op.Serial, channelId, op.Profile)
+	case ActionLabel:
+		if op.ChannelId == nil {
+			return fail(http.StatusBadRequest, language.GetValue("txtNonExistingChannelId"))
+		}
+		return applyLabel(op.Serial, channelId, op.Label)
+	case ActionUserProfile:
+		return applyUserProfile(op.Serial, op.Name)
+	}
+	return fail(http.StatusBadRequest, fmt.Sprintf("unknown action %q", op.Action))
+}
+
+// result will convert handler response to status and message. Operations not started have status 0
+func result(code int, body interface{}) (int, string) {
+	switch value := body.(type) {
+	case *Error:
+		return code, value.Message
+	case Result:
+		return code, value.Message
+	}
+	return code, ""
+}
+
+// summarize will count results and mark operations skipped in atomic mode
+func summarize(results []OperationResult, rolledBack bool) BatchResponse {
+	response := BatchResponse{RolledBack: rolledBack, Results: results}
+	for i := range results {
+		switch {
+		case results[i].Status == 0:
+			results[i].Status = http.StatusFailedDependency
+			results[i].Message = "not applied, another operation in atomic batch failed"
+			response.Failed++
+		case results[i].Status >= 300 || results[i].RolledBack:
+			response.Failed++
+		default:
+			response.Succeeded++
+		}
+	}
+	return response
+}
+
+// snapshotError is returned when previous state of an operation can not be captured
+type snapshotError struct {
+	status  int
+	message string
+}
+
+// snapshot will capture state changed by operation and return function restoring it
+func snapshot(op Operation) (restoreFunc, *snapshotError) {
+	if !validSerial(op.Serial) {
+		return nil, &snapshotError{http.StatusNotFound, language.GetValue("txtNonExistingDevice")}
+	}
+	unavailable := &snapshotError{http.StatusUnprocessableEntity, op.Action + ": current state of device is not available, operation can not be rolled back"}
+	channels := getChannels(op.Serial)
+
+	switch op.Action {
+	case ActionRgbProfile:
+		previous := make(map[int]string)
+		for id, channel := range channels {
+			if (op.ChannelId == nil || *op.ChannelId == -1 || *op.ChannelId == id) && len(channel.RgbProfile) > 0 {
+				previous[id] = channel.RgbProfile
+			}
+		}
+		if len(previous) == 0 {
+			return nil, unavailable
+		}
+		return func() error {
+			for id, profile := range previous {
+				if err := call(op.Serial, "UpdateRgbProfile", id, profile); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	case ActionSpeedProfile, ActionLabel:
+		if op.ChannelId == nil {
+			return nil, &snapshotError{http.StatusBadRequest, language.GetValue("txtNonExistingChannelId")}
+		}
+		channel, ok := channels[*op.ChannelId]
+		if !ok {
+			return nil, &snapshotError{http.StatusNotFound, language.GetValue("txtNonExistingChannelId")}
+		}
+		if op.Action == ActionLabel {
+			return func() error {
+				return call(op.Serial, "UpdateDeviceLabel", channel.Id, channel.Label)
+			}, nil
+		}
+		if len(channel.SpeedProfile) == 0 {
+			return nil, unavailable
+		}
+		return func() error {
+			return call(op.Serial, "UpdateSpeedProfile", channel.Id, channel.SpeedProfile)
+		}, nil
+	case ActionBrightness:
+		value := deviceProfileField(op.Serial, "BrightnessSlider")
+		if !value.IsValid() || value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Uint8 {
+			return nil, unavailable
+		}
+		previous := uint8(value.Elem().Uint())
+		return func() error {
+			return call(op.Serial, "ChangeDeviceBrightnessValue", previous)
+		}, nil
+	case ActionUserProfile:
+		previous := devices.GetActiveProfile(op.Serial)
+		if len(previous) == 0 {
+			return nil, unavailable
+		}
+		return func() error {
+			return call(op.Serial, "ChangeDeviceProfile", previous)
+		}, nil
+	}
+	return nil, &snapshotError{http.StatusBadRequest, fmt.Sprintf("unknown action %q", op.Action)}
+}
+
+// call will call device method and return error unless it returns status 1
+func call(serial, method string, args ...interface{}) error {
+	kinds := make([]reflect.Kind, len(args))
+	for i, arg := range args {
+		kinds[i] = reflect.TypeOf(arg).Kind()
+	}
+	if !devices.HasMethod(serial, method, kinds...) {
+		return fmt.Errorf("%s is not supported", method)
+	}
+	if devices.GetMethodStatus(devices.CallDeviceMethod(serial, method, args...)) != 1 {
+		return errors.New(method + " failed")
+	}
+	return nil
+}
+
+// deviceProfileField will return field of device DeviceProfile
+func deviceProfileField(serial, name string) reflect.Value {
+	instance := reflect.Indirect(reflect.ValueOf(devices.GetDevice(serial)))
+	if instance.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	profile := instance.FieldByName("DeviceProfile")
+	if !profile.IsValid() {
+		return reflect.Value{}
+	}
+	profile = reflect.Indirect(profile)
+	if profile.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return profile.FieldByName(name)
+}
@@ -0,0 +1,605 @@
+package v2
+
+// Package: v2
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/alerts"
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/devices"
+	"OpenLinkHub/src/health"
+	"OpenLinkHub/src/language"
+	"OpenLinkHub/src/systeminfo"
+	"OpenLinkHub/src/temperatures"
+	"OpenLinkHub/src/webhooks"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+type Device struct {
+	Serial      string `json:"serial"`
+	Product     string `json:"product"`
+	Firmware    string `json:"firmware"`
+	ProductId   uint16 `json:"productId"`
+	ProductType uint16 `json:"productType"`
+	DeviceType  uint32 `json:"deviceType"`
+	Hidden      bool   `json:"hidden"`
+}
+
+type Channel struct {
+	Id           int     `json:"id"`
+	Name         string  `json:"name"`
+	Label        string  `json:"label"`
+	Temperature  float64 `json:"temperature"`
+	Rpm          int64   `json:"rpm"`
+	SpeedProfile string  `json:"speedProfile"`
+	RgbProfile   string  `json:"rgbProfile"`
+	HasSpeed     bool    `json:"hasSpeed"`
+	HasTemps     bool    `json:"hasTemps"`
+	Pump         bool    `json:"pump"`
+}
+
+type ProfileRequest struct {
+	Profile string `json:"profile"`
+}
+
+type BrightnessRequest struct {
+	Value uint8 `json:"value"`
+}
+
+type UserProfileRequest struct {
+	Name string `json:"name"`
+}
+
+type GpuSensor struct {
+	Index       int     `json:"index"`
+	Model       string  `json:"model"`
+	Temperature float32 `json:"temperature"`
+}
+
+type StorageSensor struct {
+	Key         string  `json:"key"`
+	Model       string  `json:"model"`
+	Temperature float32 `json:"temperature"`
+}
+
+type Sensors struct {
+	Cpu     float32         `json:"cpu"`
+	Gpus    []GpuSensor     `json:"gpus"`
+	Storage []StorageSensor `json:"storage"`
+}
+
+// routes returns all v2 endpoints
+func routes() []route {
+	return []route{
+		{method: http.MethodGet, path: "/devices", tag: "devices", summary: "List devices", response: []Device{}, status: http.StatusOK, handler: listDevices},
+		{method: http.MethodGet, path: "/devices/{serial}", tag: "devices", summary: "Get device state as reported by its driver", response: map[string]interface{}{}, status: http.StatusOK, handler: getDevice},
+		{method: http.MethodGet, path: "/devices/{serial}/channels", tag: "devices", summary: "List device channels", response: []Channel{}, status: http.StatusOK, handler: listChannels},
+		{method: http.MethodGet, path: "/devices/{serial}/channels/{channelId}", tag: "devices", summary: "Get device channel", response: Channel{}, status: http.StatusOK, handler: getChannel},
+		{method: http.MethodPut, path: "/devices/{serial}/channels/{channelId}/speed", tag: "devices", summary: "Set speed profile of a channel", request: ProfileRequest{}, response: Result{}, status: http.StatusOK, handler: setChannelSpeed},
+		{method: http.MethodPut, path: "/devices/{serial}/channels/{channelId}/rgb", tag: "devices", summary: "Set RGB profile of a channel", request: ProfileRequest{}, response: Result{}, status: http.StatusOK, handler: setChannelRgb},
+		{method: http.MethodPut, path: "/devices/{serial}/rgb", tag: "devices", summary: "Set RGB profile of all device channels", request: ProfileRequest{}, response: Result{}, status: http.StatusOK, handler: setDeviceRgb},
+		{method: http.MethodPut, path: "/devices/{serial}/brightness", tag: "devices", summary: "Set device brightness (0 - 100)", request: BrightnessRequest{}, response: Result{}, status: http.StatusOK, handler: setDeviceBrightness},
+		{method: http.MethodPut, path: "/devices/{serial}/profile", tag: "devices", summary: "Switch device user profile", request: UserProfileRequest{}, response: Result{}, status: http.StatusOK, handler: setDeviceProfile},
+		{method: http.MethodPost, path: "/batch", tag: "devices", summary: "Run RGB profile, brightness, speed profile, label and user profile changes on many devices, optionally all-or-nothing", request: BatchRequest{}, response: BatchResponse{}, status: http.StatusOK, handler: runBatch},
+		{method: http.MethodGet, path: "/sensors", tag: "sensors", summary: "Get CPU, GPU and storage temperatures", response: Sensors{}, status: http.StatusOK, handler: getSensors},
+		{method: http.MethodGet, path: "/temperatures/profiles", tag: "temperatures", summary: "List speed profiles", response: map[string]temperatures.TemperatureProfileData{}, status: http.StatusOK, handler: listSpeedProfiles},
+		{method: http.MethodGet, path: "/temperatures/profiles/{profile}", tag: "temperatures", summary: "Get speed profile", response: temperatures.TemperatureProfileData{}, status: http.StatusOK, handler: getSpeedProfile},
+		{method: http.MethodDelete, path: "/temperatures/profiles/{profile}", tag: "temperatures", summary: "Delete speed profile", status: http.StatusNoContent, handler: deleteSpeedProfile},
+		{method: http.MethodGet, path: "/alerts/rules", tag: "alerts", summary: "List alert rules", response: []alerts.Rule{}, status: http.StatusOK, handler: listAlertRules},
+		{method: http.MethodPost, path: "/alerts/rules", tag: "alerts", summary: "Create alert rule", request: alerts.Rule{}, response: alerts.Rule{}, status: http.StatusCreated, handler: createAlertRule},
+		{method: http.MethodPut, path: "/alerts/rules/{ruleId}", tag: "alerts", summary: "Update alert rule", request: alerts.Rule{}, response: alerts.Rule{}, status: http.StatusOK, handler: updateAlertRule},
+		{method: http.MethodDelete, path: "/alerts/rules/{ruleId}", tag: "alerts", summary: "Delete alert rule", status: http.StatusNoContent, handler: deleteAlertRule},
+		{method: http.MethodGet, path: "/alerts/events", tag: "alerts", summary: "List recent alert events", response: []alerts.Event{}, status: http.StatusOK, handler: listAlertEvents},
+		{method: http.MethodGet, path: "/webhooks", tag: "webhooks", summary: "List webhooks", response: []webhooks.Hook{}, status: http.StatusOK, handler: listWebhooks},
+		{method: http.MethodPost, path: "/webhooks", tag: "webhooks", summary: "Create webhook. Secret is generated when empty", request: webhooks.Hook{}, response: webhooks.Hook{}, status: http.StatusCreated, handler: createWebhook},
+		{method: http.MethodPut, path: "/webhooks/{hookId}", tag: "webhooks", summary: "Update webhook. Empty secret keeps the current one", request: webhooks.Hook{}, response: webhooks.Hook{}, status: http.StatusOK, handler: updateWebhook},
+		{method: http.MethodDelete, path: "/webhooks/{hookId}", tag: "webhooks", summary: "Delete webhook", status: http.StatusNoContent, handler: deleteWebhook},
+		{method: http.MethodPost, path: "/webhooks/{hookId}/test", tag: "webhooks", summary: "Send a webhook.test event", response: Result{}, status: http.StatusAccepted, handler: testWebhook},
+		{method: http.MethodGet, path: "/webhooks/deliveries", tag: "webhooks", summary: "List recent webhook deliveries, newest first. Filter with ?hookId=", response: []webhooks.Delivery{}, status: http.StatusOK, handler: listWebhookDeliveries},
+		{method: http.MethodGet, path: "/webhooks/events", tag: "webhooks", summary: "List event types webhooks can subscribe to", response: []string{}, status: http.StatusOK, handler: listWebhookEvents},
+		{method: http.MethodGet, path: "/health", tag: "system", summary: "Get daemon health. Responds with 503 while a critical device is missing", response: health.Health{}, status: http.StatusOK, handler: getHealth},
+	}
+}
+
+// listDevices will return all devices
+func listDevices(_ *http.Request) (int, interface{}) {
+	list := make([]Device, 0)
+	for _, device := range devices.GetDevicesEx() {
+		list = append(list, Device{
+			Serial:      device.Serial,
+			Product:     device.Product,
+			Firmware:    device.Firmware,
+			ProductId:   device.ProductId,
+			ProductType: device.ProductType,
+			DeviceType:  device.DeviceType,
+			Hidden:      device.Hidden,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Serial < list[j].Serial
+	})
+	return http.StatusOK, list
+}
+
+// getDevice will return device state
+func getDevice(r *http.Request) (int, interface{}) {
+	serial, ok := getSerial(r)
+	if !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+	return http.StatusOK, devices.GetDevice(serial)
+}
+
+// listChannels will return channels of a device
+func listChannels(r *http.Request) (int, interface{}) {
+	serial, ok := getSerial(r)
+	if !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+
+	channels := getChannels(serial)
+	list := make([]Channel, 0, len(channels))
+	for _, channel := range channels {
+		list = append(list, channel)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+	return http.StatusOK, list
+}
+
+// getChannel will return a single device channel
+func getChannel(r *http.Request) (int, interface{}) {
+	serial, channelId, ok := getSerialChannel(r)
+	if !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+
+	channel, ok := getChannels(serial)[channelId]
+	if !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingChannelId"))
+	}
+	return http.StatusOK, channel
+}
+
+// setChannelSpeed will change speed profile of a channel
+func setChannelSpeed(r *http.Request) (int, interface{}) {
+	serial, channelId, ok := getSerialChannel(r)
+	if !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+
+	req := ProfileRequest{}
+	if err := decode(r, &req); err != nil {
+		return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+	}
+	return applySpeed(serial, channelId, req.Profile)
+}
+
+// applySpeed will change speed profile of a channel
+func applySpeed(serial string, channelId int, profile string) (int, interface{}) {
+	if config.GetConfig().Manual {
+		return fail(http.StatusConflict, language.GetValue("txtManualFlag"))
+	}
+	if !validSerial(serial) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+	if _, ok := getChannels(serial)[channelId]; !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingChannelId"))
+	}
+	if !common.AlphanumericRegex.MatchString(profile) || temperatures.GetTemperatureProfile(profile) == nil {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingSpeedProfile"))
+	}
+	if !devices.HasMethod(serial, "UpdateSpeedProfile", reflect.Int, reflect.String) {
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToApplySpeedProfile"))
+	}
+
+	results := devices.CallDeviceMethod(serial, "UpdateSpeedProfile", channelId, profile)
+	switch devices.GetMethodStatus(results) {
+	case 1:
+		return http.StatusOK, Result{Message: language.GetValue("txtDeviceSpeedProfileUpdated")}
+	case 0:
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingSpeedProfileSelected"))
+	case 2:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtSpeedProfileNoPump"))
+	case 3:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtDeviceProfileMismatch"))
+	case 4:
+		return fail(http.StatusNotFound, language.GetValue("txtSpeedProfileNonExistingDevice"))
+	case 5:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtSpeedProfileNoTemperatureData"))
+	case 6:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtSpeedProfileNoPSU"))
+	}
+	return fail(http.StatusInternalServerError, language.GetValue("txtUnableToApplySpeedProfile"))
+}
+
+// setChannelRgb will change RGB profile of a channel
+func setChannelRgb(r *http.Request) (int, interface{}) {
+	serial, channelId, ok := getSerialChannel(r)
+	if !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+	return setRgb(r, serial, channelId)
+}
+
+// setDeviceRgb will change RGB profile of all device channels
+func setDeviceRgb(r *http.Request) (int, interface{}) {
+	serial, ok := getSerial(r)
+	if !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+	return setRgb(r, serial, -1)
+}
+
+// setRgb will decode RGB profile request and apply it to a channel, or all channels when channelId is -1
+func setRgb(r *http.Request, serial string, channelId int) (int, interface{}) {
+	req := ProfileRequest{}
+	if err := decode(r, &req); err != nil {
+		return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+	}
+	return applyRgb(serial, channelId, req.Profile)
+}
+
+// applyRgb will change RGB profile of a channel, or all channels when channelId is -1
+func applyRgb(serial string, channelId int, profile string) (int, interface{}) {
+	if !validSerial(serial) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+	if _, ok := getChannels(serial)[channelId]; !ok && channelId != -1 {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingChannelId"))
+	}
+	if !common.AlphanumericDashRegex.MatchString(profile) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingRgbProfile"))
+	}
+	if !devices.HasMethod(serial, "UpdateRgbProfile", reflect.Int, reflect.String) {
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToChangeRgbProfile"))
+	}
+
+	results := devices.CallDeviceMethod(serial, "UpdateRgbProfile", channelId, profile)
+	switch devices.GetMethodStatus(results) {
+	case 1:
+		return http.StatusOK, Result{Message: language.GetValue("txtDeviceRgbProfileChanged")}
+	case 2:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToChangeRgbProfileNoPump"))
+	case 3:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToChangeRgbProfileNoKeyboard"))
+	case 4:
+		return fail(http.StatusConflict, language.GetValue("txtUnableToChangeRgbProfileOpenRgb"))
+	case 5:
+		return fail(http.StatusConflict, language.GetValue("txtUnableToChangeRgbProfileCluster"))
+	}
+	return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToChangeRgbProfile"))
+}
+
+// setDeviceBrightness will change device brightness
+func setDeviceBrightness(r *http.Request) (int, interface{}) {
+	serial, ok := getSerial(r)
+	if !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+
+	req := BrightnessRequest{}
+	if err := decode(r, &req); err != nil {
+		return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+	}
+	return applyBrightness(serial, req.Value)
+}
+
+// applyBrightness will change device brightness
+func applyBrightness(serial string, value uint8) (int, interface{}) {
+	if !validSerial(serial) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+	if !devices.HasMethod(serial, "ChangeDeviceBrightnessValue", reflect.Uint8) {
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToChangeBrightness"))
+	}
+
+	switch devices.GetMethodStatus(devices.CallDeviceMethod(serial, "ChangeDeviceBrightnessValue", value)) {
+	case 1:
+		return http.StatusOK, Result{Message: language.GetValue("txtBrightnessChanged")}
+	case 2:
+		return fail(http.StatusBadRequest, language.GetValue("txtBrightnessTooHigh"))
+	}
+	return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToChangeBrightness"))
+}
+
+// setDeviceProfile will switch device user profile
+func setDeviceProfile(r *http.Request) (int, interface{}) {
+	serial, ok := getSerial(r)
+	if !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+
+	req := UserProfileRequest{}
+	if err := decode(r, &req); err != nil {
+		return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+	}
+	return applyUserProfile(serial, req.Name)
+}
+
+// applyUserProfile will switch device user profile
+func applyUserProfile(serial, name string) (int, interface{}) {
+	if !validSerial(serial) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+	if !common.AlphanumericRegex.MatchString(name) {
+		return fail(http.StatusBadRequest, language.GetValue("txtProfileOnlyLettersNumbers"))
+	}
+	if !devices.HasMethod(serial, "ChangeDeviceProfile", reflect.String) {
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToChangeUserProfile"))
+	}
+
+	if devices.GetMethodStatus(devices.CallDeviceMethod(serial, "ChangeDeviceProfile", name)) == 1 {
+		return http.StatusOK, Result{Message: language.GetValue("txtUserProfileChanged")}
+	}
+	return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToChangeUserProfile"))
+}
+
+// applyLabel will change label of a device channel
+func applyLabel(serial string, channelId int, label string) (int, interface{}) {
+	if !validSerial(serial) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingDevice"))
+	}
+	if _, ok := getChannels(serial)[channelId]; !ok {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingChannelId"))
+	}
+	if len(label) < 1 {
+		return fail(http.StatusBadRequest, language.GetValue("txtInvalidLabel"))
+	}
+	if !common.AlphanumericDisplayName.MatchString(label) {
+		return fail(http.StatusBadRequest, language.GetValue("txtInvalidLabelCharacters"))
+	}
+	if !devices.HasMethod(serial, "UpdateDeviceLabel", reflect.Int, reflect.String) {
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToApplyLabel"))
+	}
+
+	if devices.GetMethodStatus(devices.CallDeviceMethod(serial, "UpdateDeviceLabel", channelId, label)) == 1 {
+		return http.StatusOK, Result{Message: language.GetValue("txtDeviceLabelApplied")}
+	}
+	return fail(http.StatusUnprocessableEntity, language.GetValue("txtUnableToApplyLabel"))
+}
+
+// getSensors will return CPU, GPU and storage temperatures
+func getSensors(_ *http.Request) (int, interface{}) {
+	sensors := Sensors{
+		Cpu:     temperatures.GetCpuTemperature(),
+		Gpus:    make([]GpuSensor, 0),
+		Storage: make([]StorageSensor, 0),
+	}
+
+	for index, gpu := range systeminfo.GetInfo().GPU {
+		sensors.Gpus = append(sensors.Gpus, GpuSensor{
+			Index:       index,
+			Model:       gpu.Model,
+			Temperature: temperatures.GetGpuTemperatureIndex(index),
+		})
+	}
+	sort.Slice(sensors.Gpus, func(i, j int) bool {
+		return sensors.Gpus[i].Index < sensors.Gpus[j].Index
+	})
+
+	for _, storage := range temperatures.GetStorageTemperatures() {
+		sensors.Storage = append(sensors.Storage, StorageSensor{
+			Key:         storage.Key,
+			Model:       storage.Model,
+			Temperature: storage.Temperature,
+		})
+	}
+	return http.StatusOK, sensors
+}
+
+// listSpeedProfiles will return all user visible speed profiles
+func listSpeedProfiles(_ *http.Request) (int, interface{}) {
+	profiles := make(map[string]temperatures.TemperatureProfileData)
+	for name, profile := range temperatures.GetTemperatureProfiles() {
+		if !profile.Hidden {
+			profiles[name] = profile
+		}
+	}
+	return http.StatusOK, profiles
+}
+
+// getSpeedProfile will return a single speed profile
+func getSpeedProfile(r *http.Request) (int, interface{}) {
+	name := r.PathValue("profile")
+	if !common.AlphanumericRegex.MatchString(name) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingSpeedProfile"))
+	}
+
+	profile := temperatures.GetTemperatureProfile(name)
+	if profile == nil || profile.Hidden {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingSpeedProfile"))
+	}
+	return http.StatusOK, profile
+}
+
+// deleteSpeedProfile will delete speed profile and reset channels using it
+func deleteSpeedProfile(r *http.Request) (int, interface{}) {
+	name := r.PathValue("profile")
+	if !common.AlphanumericRegex.MatchString(name) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingSpeedProfile"))
+	}
+
+	profile := temperatures.GetTemperatureProfile(name)
+	if profile == nil || profile.Hidden {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingSpeedProfile"))
+	}
+
+	temperatures.DeleteTemperatureProfile(name)
+	devices.ResetSpeedProfiles(name)
+	return http.StatusNoContent, nil
+}
+
+// listAlertRules will return all alert rules
+func listAlertRules(_ *http.Request) (int, interface{}) {
+	return http.StatusOK, alerts.GetRules()
+}
+
+// listAlertEvents will return recent alert events
+func listAlertEvents(_ *http.Request) (int, interface{}) {
+	return http.StatusOK, alerts.GetEvents()
+}
+
+// createAlertRule will create a new alert rule
+func createAlertRule(r *http.Request) (int, interface{}) {
+	rule := alerts.Rule{}
+	if err := decode(r, &rule); err != nil {
+		return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+	}
+	rule.Id = ""
+	return saveAlertRule(rule, http.StatusCreated)
+}
+
+// updateAlertRule will update existing alert rule
+func updateAlertRule(r *http.Request) (int, interface{}) {
+	ruleId := r.PathValue("ruleId")
+	if !common.AlphanumericRegex.MatchString(ruleId) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingAlertRule"))
+	}
+
+	rule := alerts.Rule{}
+	if err := decode(r, &rule); err != nil {
+		return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+	}
+	rule.Id = ruleId
+	return saveAlertRule(rule, http.StatusOK)
+}
+
+// saveAlertRule will validate and store alert rule
+func saveAlertRule(rule alerts.Rule, success int) (int, interface{}) {
+	if len(rule.Name) < 1 {
+		return fail(http.StatusBadRequest, language.GetValue("txtInvalidAlertName"))
+	}
+	if len(rule.Condition.DeviceId) > 0 && !common.AlphanumericRegex.MatchString(rule.Condition.DeviceId) {
+		return fail(http.StatusBadRequest, language.GetValue("txtNonExistingDevice"))
+	}
+
+	result, code := alerts.SaveRule(rule)
+	switch code {
+	case 1:
+		return success, result
+	case 2:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtInvalidAlertCondition"))
+	case 3:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtInvalidAlertAction"))
+	case 4:
+		return fail(http.StatusForbidden, language.GetValue("txtAlertCommandsDisabled"))
+	}
+	return fail(http.StatusNotFound, language.GetValue("txtNonExistingAlertRule"))
+}
+
+// deleteAlertRule will delete alert rule
+func deleteAlertRule(r *http.Request) (int, interface{}) {
+	ruleId := r.PathValue("ruleId")
+	if !common.AlphanumericRegex.MatchString(ruleId) || alerts.DeleteRule(ruleId) != 1 {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingAlertRule"))
+	}
+	return http.StatusNoContent, nil
+}
+
+// getHealth will return daemon health
+func getHealth(_ *http.Request) (int, interface{}) {
+	result := health.GetHealth()
+	if !result.Ready {
+		return http.StatusServiceUnavailable, result
+	}
+	return http.StatusOK, result
+}
+
+// getSerial will return device serial from path if device exists
+func getSerial(r *http.Request) (string, bool) {
+	serial := r.PathValue("serial")
+	if !validSerial(serial) {
+		return "", false
+	}
+	return serial, true
+}
+
+// validSerial will return true if serial is well-formed and device exists
+func validSerial(serial string) bool {
+	return common.AlphanumericDashSemiColon.MatchString(serial) && devices.GetDevice(serial) != nil
+}
+
+// getSerialChannel will return device serial and channel id from path
+func getSerialChannel(r *http.Request) (string, int, bool) {
+	serial, ok := getSerial(r)
+	if !ok {
+		return "", 0, false
+	}
+
+	channelId, err := strconv.Atoi(r.PathValue("channelId"))
+	if err != nil || channelId < 0 {
+		return "", 0, false
+	}
+	return serial, channelId, true
+}
+
+// getChannels will return device channels read from driver Devices map
+func getChannels(serial string) map[int]Channel {
+	channels := make(map[int]Channel)
+	instance := reflect.Indirect(reflect.ValueOf(devices.GetDevice(serial)))
+	if instance.Kind() != reflect.Struct {
+		return channels
+	}
+
+	list := instance.FieldByName("Devices")
+	if !list.IsValid() || list.Kind() != reflect.Map || list.Type().Key().Kind() != reflect.Int {
+		return channels
+	}
+
+	for _, key := range list.MapKeys() {
+		value := reflect.Indirect(list.MapIndex(key))
+		if value.Kind() != reflect.Struct {
+			continue
+		}
+		id := int(key.Int())
+		channels[id] = Channel{
+			Id:           id,
+			Name:         stringField(value, "Name"),
+			Label:        stringField(value, "Label"),
+			Temperature:  floatField(value, "Temperature"),
+			Rpm:          intField(value, "Rpm"),
+			SpeedProfile: stringField(value, "Profile"),
+			RgbProfile:   stringField(value, "RGB"),
+			HasSpeed:     boolField(value, "HasSpeed"),
+			HasTemps:     boolField(value, "HasTemps"),
+			Pump:         boolField(value, "ContainsPump"),
+		}
+	}
+	return channels
+}
+
+func stringField(value reflect.Value, name string) string {
+	field := value.FieldByName(name)
+	if field.IsValid() && field.Kind() == reflect.String {
+		return field.String()
+	}
+	return ""
+}
+
+func floatField(value reflect.Value, name string) float64 {
+	field := value.FieldByName(name)
+	if field.IsValid() && field.CanFloat() {
+		return field.Float()
+	}
+	return 0
+}
+
+func intField(value reflect.Value, name string) int64 {
+	field := value.FieldByName(name)
+	if field.IsValid() && field.CanInt() {
+		return field.Int()
+	}
+	return 0
+}
+
+func boolField(value reflect.Value, name string) bool {
+	field := value.FieldByName(name)
+	return field.IsValid() && field.Kind() == reflect.Bool && field.Bool()
+}
@@ -0,0 +1,204 @@
+package v2
+
+// Package: v2
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/version"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// schema is a subset of OpenAPI 3.0 schema object
+type schema map[string]interface{}
+
+var (
+	openApiOnce     sync.Once
+	openApiDocument schema
+	pathParamRegex  = regexp.MustCompile(`\{([a-zA-Z]+)}`)
+	timeType        = reflect.TypeOf(time.Time{})
+)
+
+// getOpenApi will return OpenAPI document describing all v2 routes
+func getOpenApi(w http.ResponseWriter, _ *http.Request) {
+	openApiOnce.Do(func() {
+		openApiDocument = buildOpenApi(routes())
+	})
+	writeJson(w, http.StatusOK, openApiDocument)
+}
+
+// buildOpenApi will generate OpenAPI document from route definitions and Go types of request and response bodies
+func buildOpenApi(list []route) schema {
+	components := schema{}
+	schemaOf(reflect.TypeOf(Error{}), components)
+
+	paths := schema{}
+	for _, rt := range list {
+		operation := schema{
+			"tags":        []string{rt.tag},
+			"summary":     rt.summary,
+			"operationId": strings.ToLower(rt.method) + operationName(rt.path),
+		}
+
+		parameters := make([]schema, 0)
+		for _, match := range pathParamRegex.FindAllStringSubmatch(rt.path, -1) {
+			paramType := "string"
+			if match[1] == "channelId" {
+				paramType = "integer"
+			}
+			parameters = append(parameters, schema{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   schema{"type": paramType},
+			})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+
+		if rt.request != nil {
+			operation["requestBody"] = schema{
+				"required": true,
+				"content": schema{
+					contentType: schema{"schema": schemaOf(reflect.TypeOf(rt.request), components)},
+				},
+			}
+		}
+
+		responses := schema{}
+		success := schema{"description": http.StatusText(rt.status)}
+		if rt.response != nil {
+			success["content"] = schema{
+				contentType: schema{"schema": schemaOf(reflect.TypeOf(rt.response), components)},
+			}
+		}
+		responses[strconv.Itoa(rt.status)] = success
+		responses["default"] = schema{
+			"description": "Error",
+			"content": schema{
+				contentType: schema{"schema": schema{"$ref": "#/components/schemas/Error"}},
+			},
+		}
+		operation["responses"] = responses
+
+		path := Prefix + rt.path
+		item, ok := paths[path].(schema)
+		if !ok {
+			item = schema{}
+			paths[path] = item
+		}
+		item[strings.ToLower(rt.method)] = operation
+	}
+
+	return schema{
+		"openapi": "3.0.3",
+		"info": schema{
+			"title":       "OpenLinkHub API",
+			"version":     version.Version,
+			"description": "Resource oriented REST API. Errors are returned with matching HTTP status and Error body. v1 API under /api/ stays available.",
+		},
+		"paths":      paths,
+		"components": schema{"schemas": components},
+	}
+}
+
+// schemaOf will return schema of Go type. Named structs are added to components and referenced
+func schemaOf(t reflect.Type, components schema) schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return schema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return schema{"type": "string", "format": "byte"}
+		}
+		return schema{"type": "array", "items": schemaOf(t.Elem(), components)}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": schemaOf(t.Elem(), components)}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return structSchema(t, components)
+		}
+		name := schemaName(t)
+		if _, ok := components[name]; !ok {
+			components[name] = schema{} // Placeholder for recursive types
+			components[name] = structSchema(t, components)
+		}
+		return schema{"$ref": "#/components/schemas/" + name}
+	}
+	return schema{}
+}
+
+// structSchema will return object schema of struct fields, following encoding/json rules
+func structSchema(t reflect.Type, components schema) schema {
+	properties := schema{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if tagName := strings.Split(tag, ",")[0]; len(tagName) > 0 {
+			name = tagName
+		}
+
+		if field.Anonymous && len(tag) == 0 && field.Type.Kind() == reflect.Struct {
+			embedded := structSchema(field.Type, components)
+			for key, value := range embedded["properties"].(schema) {
+				properties[key] = value
+			}
+			continue
+		}
+		properties[name] = schemaOf(field.Type, components)
+	}
+	return schema{"type": "object", "properties": properties}
+}
+
+// schemaName will return component name of a struct. Types outside this package are prefixed with package name
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	if pkg == "v2" {
+		return t.Name()
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+}
+
+// operationName will convert route path to camel case operation name
+func operationName(path string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(path, "/") {
+		part = strings.Trim(part, "{}")
+		if len(part) == 0 {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
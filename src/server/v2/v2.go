@@ -0,0 +1,101 @@
+package v2
+
+// Package: v2
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/logger"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Error is returned with every non-2xx response
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Result is returned by actions which do not return a resource
+type Result struct {
+	Message string `json:"message"`
+}
+
+// handler returns HTTP status and response body. Body of type *Error is sent as error response
+type handler func(r *http.Request) (int, interface{})
+
+// route describes a single endpoint. Request and response hold zero values of body types and are used for OpenAPI document
+type route struct {
+	method   string
+	path     string
+	tag      string
+	summary  string
+	request  interface{}
+	response interface{}
+	status   int
+	handler  handler
+}
+
+const (
+	Prefix      = "/api/v2"
+	maxBodySize = 1 << 20 // 1 MB
+	contentType = "application/json"
+)
+
+// Register will add all v2 routes to the mux
+func Register(mux *http.ServeMux) {
+	for _, rt := range routes() {
+		mux.HandleFunc(rt.method+" "+Prefix+rt.path, serve(rt.handler))
+	}
+	mux.HandleFunc("GET "+Prefix+"/openapi.json", getOpenApi)
+}
+
+// serve will wrap handler with JSON encoding of response
+func serve(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, body := h(r)
+		writeJson(w, status, body)
+	}
+}
+
+// writeJson will send body as JSON with given status. Empty body is sent for 204
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if status == http.StatusNoContent || body == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err}).Error("Unable to encode v2 response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if _, err = w.Write(data); err != nil {
+		logger.Log(logger.Fields{"error": err}).Warn("Unable to write v2 response")
+	}
+}
+
+// fail will return error response
+func fail(status int, message string) (int, interface{}) {
+	return status, &Error{Code: status, Message: message}
+}
+
+// decode will decode JSON request body into v. Unknown fields are rejected
+func decode(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after JSON body")
+	}
+	return nil
+}
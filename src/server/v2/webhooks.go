@@ -0,0 +1,96 @@
+package v2
+
+// Package: v2
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/language"
+	"OpenLinkHub/src/webhooks"
+	"net/http"
+)
+
+// listWebhooks will return all webhooks, without secrets
+func listWebhooks(_ *http.Request) (int, interface{}) {
+	return http.StatusOK, webhooks.GetHooks()
+}
+
+// listWebhookEvents will return event types webhooks can subscribe to
+func listWebhookEvents(_ *http.Request) (int, interface{}) {
+	return http.StatusOK, webhooks.GetEventTypes()
+}
+
+// listWebhookDeliveries will return webhook delivery log, optionally filtered by hookId query parameter
+func listWebhookDeliveries(r *http.Request) (int, interface{}) {
+	hookId := r.URL.Query().Get("hookId")
+	if len(hookId) > 0 && !common.AlphanumericRegex.MatchString(hookId) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingWebhook"))
+	}
+	return http.StatusOK, webhooks.GetDeliveries(hookId)
+}
+
+// createWebhook will create a new webhook
+func createWebhook(r *http.Request) (int, interface{}) {
+	hook := webhooks.Hook{}
+	if err := decode(r, &hook); err != nil {
+		return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+	}
+	hook.Id = ""
+	return saveWebhook(hook, http.StatusCreated)
+}
+
+// updateWebhook will update existing webhook
+func updateWebhook(r *http.Request) (int, interface{}) {
+	hookId := r.PathValue("hookId")
+	if !common.AlphanumericRegex.MatchString(hookId) {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingWebhook"))
+	}
+
+	hook := webhooks.Hook{}
+	if err := decode(r, &hook); err != nil {
+		return fail(http.StatusBadRequest, language.GetValue("txtUnableToValidateRequest"))
+	}
+	hook.Id = hookId
+	return saveWebhook(hook, http.StatusOK)
+}
+
+// saveWebhook will validate and store webhook
+func saveWebhook(hook webhooks.Hook, success int) (int, interface{}) {
+	for _, deviceId := range hook.DeviceIds {
+		if !common.AlphanumericRegex.MatchString(deviceId) {
+			return fail(http.StatusBadRequest, language.GetValue("txtNonExistingDevice"))
+		}
+	}
+
+	result, code := webhooks.SaveHook(hook)
+	switch code {
+	case 1:
+		return success, result
+	case 2:
+		return fail(http.StatusBadRequest, language.GetValue("txtInvalidWebhookName"))
+	case 3:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtInvalidWebhookUrl"))
+	case 4:
+		return fail(http.StatusUnprocessableEntity, language.GetValue("txtInvalidWebhookEvent"))
+	}
+	return fail(http.StatusNotFound, language.GetValue("txtNonExistingWebhook"))
+}
+
+// deleteWebhook will delete webhook
+func deleteWebhook(r *http.Request) (int, interface{}) {
+	hookId := r.PathValue("hookId")
+	if !common.AlphanumericRegex.MatchString(hookId) || webhooks.DeleteHook(hookId) != 1 {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingWebhook"))
+	}
+	return http.StatusNoContent, nil
+}
+
+// testWebhook will queue a webhook.test event for webhook. Outcome is visible in delivery log
+func testWebhook(r *http.Request) (int, interface{}) {
+	hookId := r.PathValue("hookId")
+	if !common.AlphanumericRegex.MatchString(hookId) || webhooks.TestHook(hookId) != 1 {
+		return fail(http.StatusNotFound, language.GetValue("txtNonExistingWebhook"))
+	}
+	return http.StatusAccepted, Result{Message: language.GetValue("txtWebhookTestQueued")}
+}
@@ -4,7 +4,10 @@ package stats
 // Author: Nikola Jurkovic
 // License: GPL-3.0 or later
 
-import "sync"
+import (
+	"OpenLinkHub/src/webhooks"
+	"sync"
+)
 
 type Device struct {
 	Device            string
@@ -33,6 +36,7 @@ var (
 	statsMutex        sync.RWMutex
 	batteryStats      = map[string]BatteryStats{}
 	batteryStatsMutex sync.RWMutex
+	batteryLowLevel   = uint16(15)
 )
 
 func Init() {
@@ -45,6 +49,11 @@ func UpdateBatteryStats(serial, device string, level uint16, deviceType uint8) {
 	batteryStatsMutex.Lock()
 	defer batteryStatsMutex.Unlock()
 
+	// Notify only when level drops below threshold, not on every update
+	if previous, ok := batteryStats[serial]; level > 0 && level <= batteryLowLevel && (!ok || previous.Level > batteryLowLevel) {
+		webhooks.Publish(webhooks.EventBatteryLow, serial, device, map[string]interface{}{"level": level})
+	}
+
 	if data, ok := batteryStats[serial]; ok {
 		data.Level = level
 		data.DeviceType = deviceType
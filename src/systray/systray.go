@@ -5,15 +5,17 @@ package systray
 // License: GPL-3.0 or later
 
 import (
+	"OpenLinkHub/src/cluster"
 	"OpenLinkHub/src/dashboard"
 	"OpenLinkHub/src/stats"
 	"OpenLinkHub/src/temperatures"
 )
 
 type Systray struct {
-	CpuTemp string      `json:"cpu_temp"`
-	GpuTemp string      `json:"gpu_temp"`
-	Battery interface{} `json:"battery"`
+	CpuTemp  string         `json:"cpu_temp"`
+	GpuTemp  string         `json:"gpu_temp"`
+	Battery  interface{}    `json:"battery"`
+	Clusters []cluster.Info `json:"clusters"`
 }
 
 // Get will return base stats used in /api/systray call from external systray application
@@ -21,9 +23,10 @@ func Get() interface{} {
 	cpuTempRaw, gpuTempRaw := temperatures.GetCpuTemperature(), temperatures.GetGpuTemperature()
 	dash := dashboard.GetDashboard()
 	systray := Systray{
-		CpuTemp: dash.TemperatureToString(cpuTempRaw),
-		GpuTemp: dash.TemperatureToString(gpuTempRaw),
-		Battery: stats.GetBatteryStats(),
+		CpuTemp:  dash.TemperatureToString(cpuTempRaw),
+		GpuTemp:  dash.TemperatureToString(gpuTempRaw),
+		Battery:  stats.GetBatteryStats(),
+		Clusters: cluster.GetClusters(),
 	}
 	return systray
 }
@@ -0,0 +1,132 @@
+package temperatures
+
+// Package: temperatures
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+type CalibrationPoint struct {
+	Duty uint8 `json:"duty"`
+	Rpm  int16 `json:"rpm"`
+}
+
+type FanCalibration struct {
+	Points    []CalibrationPoint `json:"points"`
+	StartDuty uint8              `json:"startDuty"`
+	StopDuty  uint8              `json:"stopDuty"`
+	MaxRpm    int16              `json:"maxRpm"`
+	Created   time.Time          `json:"created"`
+}
+
+var (
+	rpmScale = 20 // RPM per duty percent used when new RPM profile is created from default values
+)
+
+// AddCalibrationProfile will register hidden, non-persistent profile with fixed fan duty used during calibration.
+// Zero RPM is enabled, so duty below fan failsafe is applied as well
+func AddCalibrationProfile(duty uint8) string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if duty > 100 {
+		duty = 100
+	}
+
+	name := CalibrationProfileName(duty)
+	if _, ok := temperatures.Profiles[name]; ok {
+		return name
+	}
+
+	temperatures.Profiles[name] = TemperatureProfileData{
+		Sensor: SensorTypeCPU,
+		Profiles: []TemperatureProfile{
+			{Id: 1, Min: 0, Max: 200, Mode: 0, Fans: uint16(duty), Pump: 70},
+		},
+		Points: map[uint8][]Point{
+			0: {{X: 0, Y: 70}, {X: 200, Y: 70}},
+			1: {{X: 0, Y: float32(duty)}, {X: 200, Y: float32(duty)}},
+		},
+		ZeroRpm: true,
+		Hidden:  true,
+	}
+	return name
+}
+
+// CalibrationProfileName will return name of calibration profile for given duty
+func CalibrationProfileName(duty uint8) string {
+	return fmt.Sprintf("calibration%d", duty)
+}
+
+// RpmToDuty will convert target fan RPM to duty by interpolating channel calibration table.
+// Uncalibrated channels use profile duty, the same scale RPM profiles are created with, and duty never goes below
+// calibrated start duty. Targets outside of calibrated range are clamped to the range
+func RpmToDuty(calibration *FanCalibration, rpm int) int {
+	if rpm <= 0 {
+		return 0
+	}
+
+	if calibration == nil || len(calibration.Points) == 0 {
+		return min(rpm/rpmScale, 100)
+	}
+
+	if rpm >= int(calibration.MaxRpm) {
+		return 100
+	}
+
+	points := slices.Clone(calibration.Points)
+	slices.SortFunc(points, func(a, b CalibrationPoint) int {
+		return int(a.Duty) - int(b.Duty)
+	})
+
+	// Targets below the curve run at the lowest calibrated duty, targets above it at full speed
+	lowest, highest := points[0], points[len(points)-1]
+	duty := 100.0
+	switch {
+	case rpm <= int(lowest.Rpm):
+		duty = float64(lowest.Duty)
+	case rpm >= int(highest.Rpm):
+		duty = 100
+	default:
+		for i := 1; i < len(points); i++ {
+			lo, hi := points[i-1], points[i]
+			if rpm >= int(lo.Rpm) && rpm <= int(hi.Rpm) && hi.Rpm > lo.Rpm {
+				ratio := float64(rpm-int(lo.Rpm)) / float64(hi.Rpm-lo.Rpm)
+				duty = float64(lo.Duty) + ratio*float64(hi.Duty-lo.Duty)
+				break
+			}
+		}
+	}
+
+	result := int(math.Round(duty))
+	if result < int(calibration.StartDuty) {
+		result = int(calibration.StartDuty)
+	}
+	return result
+}
+
+// rpmProfile will convert fan values of a profile from percent to RPM
+func rpmProfile(pf TemperatureProfileData) TemperatureProfileData {
+	pf.Rpm = true
+	pf.Profiles = slices.Clone(pf.Profiles)
+	for i := range pf.Profiles {
+		pf.Profiles[i].Fans = pf.Profiles[i].Fans * uint16(rpmScale)
+	}
+
+	if pf.Points != nil {
+		points := make(map[uint8][]Point, len(pf.Points))
+		for key, value := range pf.Points {
+			points[key] = slices.Clone(value)
+		}
+		for i := range points[1] {
+			points[1][i].Y = points[1][i].Y * float32(rpmScale)
+		}
+		pf.Points = points
+	}
+	return pf
+}
@@ -0,0 +1,51 @@
+package temperatures
+
+import "testing"
+
+func TestRpmToDuty(t *testing.T) {
+	calibration := &FanCalibration{
+		Points: []CalibrationPoint{
+			{Duty: 0, Rpm: 450},
+			{Duty: 50, Rpm: 1000},
+			{Duty: 100, Rpm: 2000},
+		},
+		StartDuty: 0,
+		MaxRpm:    2000,
+	}
+	stopping := &FanCalibration{
+		Points: []CalibrationPoint{
+			{Duty: 0, Rpm: 0},
+			{Duty: 20, Rpm: 0},
+			{Duty: 30, Rpm: 600},
+			{Duty: 100, Rpm: 1800},
+		},
+		StartDuty: 30,
+		StopDuty:  20,
+		MaxRpm:    1800,
+	}
+
+	tests := []struct {
+		name        string
+		calibration *FanCalibration
+		rpm         int
+		duty        int
+	}{
+		{"zero target", calibration, 0, 0},
+		{"below range", calibration, 400, 0},
+		{"at lowest point", calibration, 450, 0},
+		{"in range", calibration, 500, 5},
+		{"in range upper segment", calibration, 1500, 75},
+		{"above range", calibration, 2500, 100},
+		{"below start duty", stopping, 100, 30},
+		{"in range with start duty", stopping, 1200, 65},
+		{"above range with start duty", stopping, 5000, 100},
+		{"uncalibrated", nil, 1200, 60},
+		{"uncalibrated above scale", nil, 5000, 100},
+	}
+
+	for _, test := range tests {
+		if duty := RpmToDuty(test.calibration, test.rpm); duty != test.duty {
+			t.Errorf("%s: RpmToDuty(%d) = %d, want %d", test.name, test.rpm, duty, test.duty)
+		}
+	}
+}
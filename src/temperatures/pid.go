@@ -0,0 +1,152 @@
+package temperatures
+
+// Package: temperatures
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/logger"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+type PidSettings struct {
+	Enabled  bool    `json:"enabled"`
+	Setpoint float32 `json:"setpoint"`
+	Kp       float64 `json:"kp"`
+	Ki       float64 `json:"ki"`
+	Kd       float64 `json:"kd"`
+	MinDuty  uint8   `json:"minDuty"`
+	MaxDuty  uint8   `json:"maxDuty"`
+}
+
+type pidState struct {
+	Profile   string
+	Integral  float64
+	LastError float64
+	LastTime  time.Time
+}
+
+var (
+	pidStates     = map[string]*pidState{}
+	pidMutex      sync.Mutex
+	pidMinPump    = 50
+	pidMinFans    = 20
+	pidMaxDelta   = 10.0 // seconds, longer gaps restart derivative and integral step
+	defaultPid    = PidSettings{Enabled: true, Setpoint: 35, Kp: 5, Ki: 0.2, Kd: 1, MinDuty: 30, MaxDuty: 100}
+	pidSetpointLo = float32(10)
+	pidSetpointHi = float32(100)
+	pidLocked     = []string{"Quiet", "Normal", "Performance"}
+)
+
+// ValidatePidSettings will validate PID controller settings
+func ValidatePidSettings(settings PidSettings) bool {
+	if settings.Setpoint < pidSetpointLo || settings.Setpoint > pidSetpointHi {
+		return false
+	}
+
+	if settings.Kp < 0 || settings.Ki < 0 || settings.Kd < 0 {
+		return false
+	}
+
+	if settings.MaxDuty > 100 || settings.MinDuty > settings.MaxDuty {
+		return false
+	}
+	return true
+}
+
+// UpdateTemperatureProfilePid will update PID controller settings of a temperature profile
+func UpdateTemperatureProfilePid(profile string, settings PidSettings) uint8 {
+	pf := GetTemperatureProfile(profile)
+	if pf == nil {
+		return 0
+	}
+
+	// Default profiles are recreated on every start, and hidden ones are internal
+	if pf.Hidden || slices.Contains(pidLocked, profile) {
+		return 3
+	}
+
+	if !ValidatePidSettings(settings) {
+		return 2
+	}
+
+	pf.Pid = settings
+	if err := saveProfileToDisk(profile, *pf); err != nil {
+		logger.Log(logger.Fields{"error": err, "caller": "UpdateTemperatureProfilePid()"}).Error("Unable to save profile to disk")
+		return 0
+	}
+	ResetPid(profile)
+	return 1
+}
+
+// ResetPid will reset controller state of every channel using given profile
+func ResetPid(profile string) {
+	pidMutex.Lock()
+	defer pidMutex.Unlock()
+
+	for key, state := range pidStates {
+		if state.Profile == profile {
+			delete(pidStates, key)
+		}
+	}
+}
+
+// GetPidSpeed will calculate fan or pump duty for a device channel from temperature error.
+// Integration stops while output is saturated (anti-windup). With zero RPM enabled, fans stop when output reaches minimum duty and temperature is below setpoint
+func GetPidSpeed(serial string, channelId int, profile string, pf *TemperatureProfileData, temp float32, pump bool) int {
+	pidMutex.Lock()
+	defer pidMutex.Unlock()
+
+	settings := pf.Pid
+	minDuty := float64(settings.MinDuty)
+	maxDuty := float64(settings.MaxDuty)
+	if pump {
+		minDuty = math.Max(minDuty, float64(pidMinPump))
+	} else if !pf.ZeroRpm {
+		minDuty = math.Max(minDuty, float64(pidMinFans))
+	}
+	if maxDuty < minDuty {
+		maxDuty = minDuty
+	}
+
+	key := fmt.Sprintf("%s-%d", serial, channelId)
+	now := time.Now()
+	state, ok := pidStates[key]
+	if !ok || state.Profile != profile {
+		state = &pidState{Profile: profile, LastTime: now}
+		pidStates[key] = state
+	}
+
+	// Positive error means coolant is above setpoint, more cooling is needed
+	e := float64(temp - settings.Setpoint)
+	dt := now.Sub(state.LastTime).Seconds()
+
+	derivative := 0.0
+	if dt > 0 && dt <= pidMaxDelta {
+		derivative = (e - state.LastError) / dt
+	} else {
+		dt = 0
+	}
+
+	integral := state.Integral + e*dt
+	output := minDuty + settings.Kp*e + settings.Ki*integral + settings.Kd*derivative
+
+	// Anti-windup: keep integral only when it does not push further into saturation
+	if (output > maxDuty && e > 0) || (output < minDuty && e < 0) {
+		integral = state.Integral
+		output = minDuty + settings.Kp*e + settings.Ki*integral + settings.Kd*derivative
+	}
+
+	state.Integral = integral
+	state.LastError = e
+	state.LastTime = now
+
+	if output <= minDuty && pf.ZeroRpm && !pump && e < 0 {
+		return 0
+	}
+	return int(math.Round(math.Max(minDuty, math.Min(maxDuty, output))))
+}
@@ -15,6 +15,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -75,6 +76,8 @@ type TemperatureProfileData struct {
 	Linear             bool                 `json:"linear"`
 	GPUIndex           uint8                `json:"gpuIndex"`
 	SensorString       string               `json:"sensorString"`
+	Pid                PidSettings          `json:"pid"`
+	Rpm                bool                 `json:"rpm"`
 	Hidden             bool
 }
 
@@ -109,12 +112,14 @@ type NewTemperatureProfile struct {
 	HwmonDevice        string
 	TemperatureInputId string
 	GpuIndex           uint8
+	Pid                bool
+	Setpoint           float32
+	Rpm                bool
 }
 
 var (
 	i2cPrefix         = "i2c"
 	temperatureOffset = 0
-	pwd               = ""
 	location          = ""
 	profiles          = map[string]TemperatureProfileData{}
 	memoryTemperature = map[int]MemoryTemperatures{}
@@ -122,6 +127,7 @@ var (
 	temperatures      *Temperatures
 	cpuPackages       = []string{"k10temp", "zenpower", "coretemp"}
 	defaultTempFile   = "temp1_input"
+	defaultProfiles   = []string{"Quiet", "Normal", "Performance", "aioCriticalTemperature"}
 	sensorList        = map[uint8]string{
 		SensorTypeCPU:                "CPU",
 		SensorTypeGPU:                "GPU",
@@ -242,7 +248,7 @@ var (
 	aioCriticalTemperature = TemperatureProfileData{
 		Sensor: 2,
 		Profiles: []TemperatureProfile{
-			{Id: 1, Min: 0, Max: 65, Mode: 0, Fans: 100, Pump: 100},
+			{Id: 1, Min: 0, Max: 100, Mode: 0, Fans: 100, Pump: 100},
 		},
 		Hidden: true,
 	}
@@ -285,8 +291,7 @@ var (
 
 // Init will initialize temperature data
 func Init() {
-	pwd = config.GetConfig().ConfigPath
-	location = pwd + "/database/temperatures/"
+	location = config.GetDatabasePath() + "/temperatures/"
 
 	// Load any custom profile user created
 	LoadUserProfiles(profiles)
@@ -298,7 +303,7 @@ func Init() {
 	profiles["aioCriticalTemperature"] = aioCriticalTemperature
 
 	// Upgrade existing profiles to graph data
-	upgradeGraphProfiles()
+	upgradeGraphProfiles(profiles)
 
 	// Setup
 	temperatures = &Temperatures{
@@ -316,7 +321,7 @@ func Init() {
 }
 
 // upgradeGraphProfiles will perform initial graph calculation
-func upgradeGraphProfiles() {
+func upgradeGraphProfiles(profiles map[string]TemperatureProfileData) {
 	for name, profile := range profiles {
 		if profile.Points == nil {
 			pump := make([]Point, 0)
@@ -429,6 +434,10 @@ func AddTemperatureProfile(newTemperatureProfile *NewTemperatureProfile) bool {
 				pf.Points = data
 			}
 
+			if newTemperatureProfile.Rpm {
+				pf = rpmProfile(pf)
+			}
+
 			err := saveProfileToDisk(newTemperatureProfile.Profile, pf)
 			if err != nil {
 				return false
@@ -520,6 +529,10 @@ func AddTemperatureProfile(newTemperatureProfile *NewTemperatureProfile) bool {
 		pf.ZeroRpm = newTemperatureProfile.ZeroRpm
 		pf.Linear = newTemperatureProfile.Linear
 		pf.GPUIndex = newTemperatureProfile.GpuIndex
+		if newTemperatureProfile.Pid {
+			pf.Pid = defaultPid
+			pf.Pid.Setpoint = newTemperatureProfile.Setpoint
+		}
 
 		if pf.Points == nil {
 			pump := make([]Point, 0)
@@ -546,6 +559,10 @@ func AddTemperatureProfile(newTemperatureProfile *NewTemperatureProfile) bool {
 			pf.Points = data
 		}
 
+		if newTemperatureProfile.Rpm {
+			pf = rpmProfile(pf)
+		}
+
 		err := saveProfileToDisk(newTemperatureProfile.Profile, pf)
 		if err != nil {
 			return false
@@ -625,6 +642,36 @@ func DeleteTemperatureProfile(profile string) {
 	}
 }
 
+// AddFixedSpeedProfile will register hidden, non-persistent profile with fixed fan and pump speed and return its name.
+// Fans can go below 20% and stop, pumps are limited by devices
+func AddFixedSpeedProfile(speed uint8) string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if speed > 100 {
+		speed = 100
+	}
+
+	name := fmt.Sprintf("override%d", speed)
+	if _, ok := temperatures.Profiles[name]; ok {
+		return name
+	}
+
+	temperatures.Profiles[name] = TemperatureProfileData{
+		Sensor: SensorTypeCPU,
+		Profiles: []TemperatureProfile{
+			{Id: 1, Min: 0, Max: 200, Mode: 0, Fans: uint16(speed), Pump: uint16(speed)},
+		},
+		Points: map[uint8][]Point{
+			0: {{X: 0, Y: float32(speed)}, {X: 200, Y: float32(speed)}},
+			1: {{X: 0, Y: float32(speed)}, {X: 200, Y: float32(speed)}},
+		},
+		ZeroRpm: speed < 20,
+		Hidden:  true,
+	}
+	return name
+}
+
 // GetTemperatureProfile will return structs.TemperatureProfile for given profile name
 func GetTemperatureProfile(profile string) *TemperatureProfileData {
 	mutex.Lock()
@@ -710,26 +757,95 @@ func LoadUserProfiles(profiles map[string]TemperatureProfileData) {
 		}
 
 		profileName := strings.Split(fi.Name(), ".")[0]
-		file, fe := os.Open(profileLocation)
+		profile, fe := loadUserProfile(profileLocation)
 		if fe != nil {
 			logger.Log(logger.Fields{"error": fe, "location": profileLocation, "caller": "LoadUserProfiles()"}).Fatal("Unable to read temperature profile")
 		}
+		profiles[profileName] = profile
+	}
+}
 
-		// Decode and create profile
-		var profile TemperatureProfileData
-		reader := json.NewDecoder(file)
-		if fe = reader.Decode(&profile); fe != nil {
-			logger.Log(logger.Fields{"error": fe, "location": profileLocation, "caller": "LoadUserProfiles()"}).Fatal("Unable to read temperature profile")
+// loadUserProfile will decode user profile and resolve its hwmon path
+func loadUserProfile(profileLocation string) (TemperatureProfileData, error) {
+	var profile TemperatureProfileData
+	file, err := os.Open(profileLocation)
+	if err != nil {
+		return profile, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	// Decode and create profile
+	if err = json.NewDecoder(file).Decode(&profile); err != nil {
+		return profile, err
+	}
+
+	// Recalculate hwmon dynamic path
+	if len(profile.HwmonDevice) > 0 && len(profile.TemperatureInputId) > 0 {
+		path := getHwMonDirectoryByDeviceName(profile.HwmonDevice)
+		deviceId := fmt.Sprintf("%s/%s", path, profile.TemperatureInputId)
+		profile.Device = deviceId
+	}
+	return profile, nil
+}
+
+// Reload will re-read user profiles from disk and apply temperatureOffset and cpuTempFile from config.
+// Returns names of removed profiles and problems found. Profiles are not changed when any of them is invalid
+func Reload() ([]string, []string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	temperatureOffset = config.GetConfig().TemperatureOffset
+	defaultTempFile = "temp1_input"
+	if len(config.GetConfig().CpuTempFile) > 0 {
+		defaultTempFile = config.GetConfig().CpuTempFile
+	}
+
+	files, err := os.ReadDir(location)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	loaded := make(map[string]TemperatureProfileData)
+	problems := make([]string, 0)
+	for _, fi := range files {
+		profileLocation := location + fi.Name()
+		if fi.IsDir() || !common.IsValidExtension(profileLocation, ".json") {
+			continue
 		}
 
-		// Recalculate hwmon dynamic path
-		if len(profile.HwmonDevice) > 0 && len(profile.TemperatureInputId) > 0 {
-			path := getHwMonDirectoryByDeviceName(profile.HwmonDevice)
-			deviceId := fmt.Sprintf("%s/%s", path, profile.TemperatureInputId)
-			profile.Device = deviceId
+		profile, fe := loadUserProfile(profileLocation)
+		if fe != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", profileLocation, fe.Error()))
+			continue
 		}
-		profiles[profileName] = profile
+		if len(profile.Profiles) == 0 && len(profile.Points) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: profile has no speed points", profileLocation))
+			continue
+		}
+		loaded[strings.Split(fi.Name(), ".")[0]] = profile
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	upgradeGraphProfiles(loaded)
+
+	removed := make([]string, 0)
+	for name, profile := range temperatures.Profiles {
+		if _, ok := loaded[name]; ok || profile.Hidden || slices.Contains(defaultProfiles, name) {
+			continue
+		}
+		delete(temperatures.Profiles, name)
+		removed = append(removed, name)
+	}
+	for name, profile := range loaded {
+		if slices.Contains(defaultProfiles, name) {
+			continue // Built-in profiles are not overridden
+		}
+		temperatures.Profiles[name] = profile
 	}
+	return removed, nil
 }
 
 // saveProfileToDisk will save profile to the disk
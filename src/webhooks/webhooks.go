@@ -0,0 +1,503 @@
+package webhooks
+
+// Package: webhooks
+// Author: Nikola Jurkovic
+// License: GPL-3.0 or later
+
+import (
+	"OpenLinkHub/src/common"
+	"OpenLinkHub/src/config"
+	"OpenLinkHub/src/logger"
+	"OpenLinkHub/src/version"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Event types
+const (
+	EventDeviceConnected    = "device.connected"
+	EventDeviceDisconnected = "device.disconnected"
+	EventBatteryLow         = "battery.low"
+	EventProfileSwitched    = "profile.switched"
+	EventCoolantCritical    = "coolant.critical"
+	EventCoolantRecovered   = "coolant.recovered"
+	EventLightsOut          = "scheduler.lightsOut"
+	EventLightsOn           = "scheduler.lightsOn"
+	EventTest               = "webhook.test"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-OpenLinkHub-Event"
+	HeaderDelivery  = "X-OpenLinkHub-Delivery"
+	HeaderTimestamp = "X-OpenLinkHub-Timestamp"
+	HeaderSignature = "X-OpenLinkHub-Signature"
+)
+
+type Hook struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"` // HMAC key. Generated when empty, only returned on create and update
+	Events    []string  `json:"events"`           // Empty matches all events
+	DeviceIds []string  `json:"deviceIds"`        // Empty matches all devices
+	Enabled   bool      `json:"enabled"`
+	Created   time.Time `json:"created"`
+}
+
+type Event struct {
+	Id     string                 `json:"id"`
+	Type   string                 `json:"type"`
+	Serial string                 `json:"serial,omitempty"`
+	Device string                 `json:"device,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+	Time   time.Time              `json:"time"`
+}
+
+type Delivery struct {
+	HookId    string    `json:"hookId"`
+	EventId   string    `json:"eventId"`
+	EventType string    `json:"eventType"`
+	Attempt   int       `json:"attempt"`
+	Status    int       `json:"status"` // HTTP status of the endpoint, 0 when it was not reached
+	Error     string    `json:"error,omitempty"`
+	Delivered bool      `json:"delivered"`
+	Retry     bool      `json:"retry"` // Delivery will be attempted again
+	Duration  int64     `json:"duration"`
+	Time      time.Time `json:"time"`
+}
+
+type Webhooks struct {
+	Hooks      map[string]*Hook `json:"hooks"`
+	Deliveries []Delivery       `json:"deliveries"`
+}
+
+// job is a pending delivery of an event to a hook
+type job struct {
+	hook    Hook
+	event   Event
+	body    []byte
+	attempt int
+}
+
+var (
+	location      = ""
+	mutex         sync.Mutex
+	webhooks      = Webhooks{Hooks: map[string]*Hook{}, Deliveries: make([]Delivery, 0)}
+	queue         = make(chan *job, 256)
+	once          sync.Once
+	workers       = 2
+	maxDeliveries = 500
+	maxAttempts   = 6
+	retryDelay    = 2 * time.Second // Doubled on every attempt
+	timeout       = 10 * time.Second
+	saveDelay     = 5 * time.Second // Delivery log is written at most once per delay
+	savePending   = false
+	eventTypes    = []string{
+		EventDeviceConnected,
+		EventDeviceDisconnected,
+		EventBatteryLow,
+		EventProfileSwitched,
+		EventCoolantCritical,
+		EventCoolantRecovered,
+		EventLightsOut,
+		EventLightsOn,
+		EventTest,
+	}
+	client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:       nil,
+			DialContext: (&net.Dialer{Timeout: timeout, Control: localOnly}).DialContext,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// Init will load webhooks and start delivery workers
+func Init() {
+	location = config.GetDatabasePath() + "/webhooks.json"
+	if common.FileExists(location) {
+		file, err := os.Open(location)
+		if err != nil {
+			logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to load webhooks")
+		} else {
+			mutex.Lock()
+			if err = json.NewDecoder(file).Decode(&webhooks); err != nil {
+				logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to decode webhooks")
+				webhooks = Webhooks{}
+			}
+			mutex.Unlock()
+			_ = file.Close()
+		}
+	}
+
+	mutex.Lock()
+	if webhooks.Hooks == nil {
+		webhooks.Hooks = map[string]*Hook{}
+	}
+	if webhooks.Deliveries == nil {
+		webhooks.Deliveries = make([]Delivery, 0)
+	}
+	mutex.Unlock()
+
+	once.Do(func() {
+		for i := 0; i < workers; i++ {
+			go func() {
+				for j := range queue {
+					deliver(j)
+				}
+			}()
+		}
+	})
+}
+
+// GetEventTypes will return all supported event types
+func GetEventTypes() []string {
+	return slices.Clone(eventTypes)
+}
+
+// GetHooks will return all webhooks without secrets
+func GetHooks() []Hook {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make([]Hook, 0, len(webhooks.Hooks))
+	for _, hook := range webhooks.Hooks {
+		value := *hook
+		value.Secret = ""
+		result = append(result, value)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+	return result
+}
+
+// GetDeliveries will return delivery log, newest first. Empty hookId returns deliveries of all hooks
+func GetDeliveries(hookId string) []Delivery {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	result := make([]Delivery, 0)
+	for i := len(webhooks.Deliveries) - 1; i >= 0; i-- {
+		if len(hookId) == 0 || webhooks.Deliveries[i].HookId == hookId {
+			result = append(result, webhooks.Deliveries[i])
+		}
+	}
+	return result
+}
+
+// SaveHook will create a new webhook when id is empty, or update an existing one. Existing secret is kept when empty.
+// Returns 1 on success, 0 on unknown webhook, 2 on invalid name, 3 on invalid URL and 4 on invalid event filter
+func SaveHook(hook Hook) (*Hook, uint8) {
+	if len(hook.Name) < 1 {
+		return nil, 2
+	}
+	if !isLocalUrl(hook.Url) {
+		return nil, 3
+	}
+	for _, event := range hook.Events {
+		if !slices.Contains(eventTypes, event) {
+			return nil, 4
+		}
+	}
+	if hook.Events == nil {
+		hook.Events = make([]string, 0)
+	}
+	if hook.DeviceIds == nil {
+		hook.DeviceIds = make([]string, 0)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(hook.Id) == 0 {
+		hook.Id = newId()
+		hook.Created = time.Now()
+	} else {
+		existing, ok := webhooks.Hooks[hook.Id]
+		if !ok {
+			return nil, 0
+		}
+		hook.Created = existing.Created
+		if len(hook.Secret) == 0 {
+			hook.Secret = existing.Secret
+		}
+	}
+	if len(hook.Secret) == 0 {
+		hook.Secret = newId() + newId()
+	}
+
+	webhooks.Hooks[hook.Id] = &hook
+	save()
+
+	result := hook
+	return &result, 1
+}
+
+// DeleteHook will remove webhook
+func DeleteHook(hookId string) uint8 {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, ok := webhooks.Hooks[hookId]; !ok {
+		return 0
+	}
+	delete(webhooks.Hooks, hookId)
+	save()
+	return 1
+}
+
+// TestHook will send a test event to a webhook, regardless of its filter and state
+func TestHook(hookId string) uint8 {
+	mutex.Lock()
+	hook, ok := webhooks.Hooks[hookId]
+	if !ok {
+		mutex.Unlock()
+		return 0
+	}
+	value := *hook
+	mutex.Unlock()
+
+	event := newEvent(EventTest, "", "", map[string]interface{}{"name": value.Name})
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0
+	}
+	enqueue(&job{hook: value, event: event, body: body, attempt: 1})
+	return 1
+}
+
+// Publish will send event to all enabled webhooks matching its type and device. Never blocks
+func Publish(eventType, serial, device string, data map[string]interface{}) {
+	mutex.Lock()
+	matching := make([]Hook, 0)
+	for _, hook := range webhooks.Hooks {
+		if !hook.Enabled {
+			continue
+		}
+		if len(hook.Events) > 0 && !slices.Contains(hook.Events, eventType) {
+			continue
+		}
+		if len(hook.DeviceIds) > 0 && !slices.Contains(hook.DeviceIds, serial) {
+			continue
+		}
+		matching = append(matching, *hook)
+	}
+	mutex.Unlock()
+
+	if len(matching) == 0 {
+		return
+	}
+
+	event := newEvent(eventType, serial, device, data)
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "event": eventType}).Error("Unable to encode webhook event")
+		return
+	}
+	for _, hook := range matching {
+		enqueue(&job{hook: hook, event: event, body: body, attempt: 1})
+	}
+}
+
+// PublishProfileSwitched will publish profile.switched event of a device. Drivers call it on every successful profile
+// change, including profiles cycled with device buttons
+func PublishProfileSwitched(serial, device, profile string) {
+	Publish(EventProfileSwitched, serial, device, map[string]interface{}{"profile": profile})
+}
+
+// enqueue will add job to delivery queue, or drop it when queue is full
+func enqueue(j *job) {
+	select {
+	case queue <- j:
+	default:
+		record(j, 0, 0, errors.New("delivery queue is full"), false)
+		logger.Log(logger.Fields{"hook": j.hook.Name, "event": j.event.Type}).Warn("Webhook delivery queue is full, event dropped")
+	}
+}
+
+// deliver will POST event to webhook and schedule a retry with exponential backoff on failure
+func deliver(j *job) {
+	start := time.Now()
+	status, err := send(j)
+	retry := err != nil && j.attempt < maxAttempts && (status == 0 || status == http.StatusTooManyRequests || status >= 500)
+	record(j, status, time.Since(start), err, retry)
+
+	if err != nil {
+		logger.Log(logger.Fields{"error": err, "hook": j.hook.Name, "event": j.event.Type, "attempt": j.attempt}).Warn("Webhook delivery failed")
+	}
+	if retry {
+		delay := retryDelay << (j.attempt - 1)
+		next := &job{hook: j.hook, event: j.event, body: j.body, attempt: j.attempt + 1}
+		time.AfterFunc(delay, func() {
+			mutex.Lock()
+			_, exists := webhooks.Hooks[next.hook.Id]
+			mutex.Unlock()
+			if exists {
+				enqueue(next)
+			}
+		})
+	}
+}
+
+// send will perform signed HTTP request and return response status
+func send(j *job) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.hook.Url, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "OpenLinkHub/"+version.Version)
+	req.Header.Set(HeaderEvent, j.event.Type)
+	req.Header.Set(HeaderDelivery, j.event.Id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(j.hook.Secret, timestamp, j.body))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook returned status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// Sign will return hex HMAC-SHA256 of timestamp and body, joined with a dot
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// record will add delivery attempt to delivery log and keep only last maxDeliveries
+func record(j *job, status int, duration time.Duration, err error, retry bool) {
+	delivery := Delivery{
+		HookId:    j.hook.Id,
+		EventId:   j.event.Id,
+		EventType: j.event.Type,
+		Attempt:   j.attempt,
+		Status:    status,
+		Delivered: err == nil,
+		Retry:     retry,
+		Duration:  duration.Milliseconds(),
+		Time:      time.Now(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	webhooks.Deliveries = append(webhooks.Deliveries, delivery)
+	if len(webhooks.Deliveries) > maxDeliveries {
+		webhooks.Deliveries = webhooks.Deliveries[len(webhooks.Deliveries)-maxDeliveries:]
+	}
+
+	// Retries and bursts of events are written together
+	if !savePending {
+		savePending = true
+		time.AfterFunc(saveDelay, Flush)
+	}
+}
+
+// Flush will write delivery log recorded since last save
+func Flush() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if savePending {
+		savePending = false
+		save()
+	}
+}
+
+// isLocalUrl will return true if URL is http(s) and its host is a name or a local network address
+func isLocalUrl(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Hostname()) == 0 {
+		return false
+	}
+
+	ip := net.ParseIP(u.Hostname())
+	return ip == nil || isLocalIp(ip)
+}
+
+// isLocalIp will return true for loopback, private and link-local addresses
+func isLocalIp(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()
+}
+
+// localOnly will refuse connections to addresses outside the local network. Host names are checked after resolving
+func localOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isLocalIp(ip) {
+		return fmt.Errorf("%s is not a local network address", host)
+	}
+	return nil
+}
+
+// newEvent will create event with a random id
+func newEvent(eventType, serial, device string, data map[string]interface{}) Event {
+	return Event{
+		Id:     newId(),
+		Type:   eventType,
+		Serial: serial,
+		Device: device,
+		Data:   data,
+		Time:   time.Now(),
+	}
+}
+
+// newId will generate random id
+func newId() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// save will save webhooks and delivery log
+func save() {
+	if len(location) == 0 {
+		return
+	}
+	savePending = false
+	if err := common.SaveJsonData(location, webhooks); err != nil {
+		logger.Log(logger.Fields{"error": err, "location": location}).Error("Unable to save webhooks")
+	}
+}
@@ -113,6 +113,10 @@ $(document).ready(function () {
         window.location.href = "/api/backup";
     });
 
+    $("#btnSupportBundle").on("click", function() {
+        window.location.href = "/api/diagnostics/bundle";
+    });
+
     $('.saveRgbControl').on('click', function () {
         const rgbControl = $("#rgbControl").is(':checked');
         const rgbOff = $("#rgbOff").val();